@@ -0,0 +1,60 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/mshort55/prayertexter/internal/adminapi"
+	"github.com/mshort55/prayertexter/internal/config"
+	"github.com/mshort55/prayertexter/internal/db"
+	"github.com/mshort55/prayertexter/internal/encryption"
+	"github.com/mshort55/prayertexter/internal/messaging"
+	"github.com/mshort55/prayertexter/internal/privacy"
+)
+
+// MUST BE SET by go build -ldflags "-X main.version=999"
+// like 0.6.14-0-g26fe727 or 0.6.14-2-g9118702-dirty
+
+//lint:ignore U1000 - var used in Makefile
+var version string // do not remove or modify
+
+// adminapi serves the admin api that api gateway routes under /admin. See
+// internal/adminapi/openapi.yaml for the routes.
+func main() {
+	ddbClnt, err := db.GetDdbClient()
+	if err != nil {
+		slog.Error("startup: failed to get dynamodb client", "error", err.Error())
+		os.Exit(1)
+	}
+
+	smsClnt, err := messaging.GetSmsClient()
+	if err != nil {
+		slog.Error("startup: failed to get sms client", "error", err.Error())
+		os.Exit(1)
+	}
+
+	cfg, err := config.Load(ddbClnt)
+	if err != nil {
+		slog.Error("startup: failed to load config", "error", err.Error())
+		os.Exit(1)
+	}
+
+	cfg.Cipher, err = encryption.NewCipher(cfg.EncryptionKeyID, cfg.EncryptionKey, cfg.EncryptNames)
+	if err != nil {
+		slog.Error("startup: failed to set up encryption", "error", err.Error())
+		os.Exit(1)
+	}
+
+	if err := privacy.Validate(cfg.Privacy, cfg.PhoneHashKey); err != nil {
+		slog.Error("startup: invalid privacy level", "error", err.Error())
+		os.Exit(1)
+	}
+	privacy.SetDefault(cfg.Privacy, cfg.PhoneHashKey)
+
+	if len(cfg.AdminAPIKeys) == 0 && cfg.AdminJWTSecret == "" {
+		slog.Warn("startup: neither adminApiKeys nor adminJwtSecret are set, every request will be rejected")
+	}
+
+	lambda.Start(adminapi.New(cfg, ddbClnt, smsClnt).Handle)
+}
@@ -2,9 +2,17 @@ package main
 
 import (
 	"context"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/mshort55/prayertexter/internal/config"
+	"github.com/mshort55/prayertexter/internal/db"
+	"github.com/mshort55/prayertexter/internal/object"
+	"github.com/mshort55/prayertexter/internal/privacy"
 )
 
 // MUST BE SET by go build -ldflags "-X main.version=999"
@@ -13,12 +21,38 @@ import (
 //lint:ignore U1000 - var used in Makefile
 var version string // do not remove or modify
 
-func handler(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+func handler(ctx context.Context, req events.APIGatewayProxyRequest, cfg config.Config,
+	ddbClnt db.DDBConnecter) (events.APIGatewayProxyResponse, error) {
+	// announcements are non-essential sends, so they are suspended once the monthly budget is used up
+	overBudget, err := object.IsOverBudget(ddbClnt, cfg.MonthlySegmentBudget, time.Now())
+	if err != nil {
+		slog.Error("announcer handler: failed to check monthly budget", "error", err.Error())
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	} else if overBudget {
+		slog.Warn("monthly sms budget exceeded, announcements are suspended", "budget", cfg.MonthlySegmentBudget)
+		return events.APIGatewayProxyResponse{StatusCode: 200, Body: "Suspended"}, nil
+	}
+
 	// place holder for future code
 
 	return events.APIGatewayProxyResponse{StatusCode: 200, Body: "Success"}, nil
 }
 
 func main() {
-	lambda.Start(handler)
+	ddbClnt, err := db.GetDdbClient()
+	if err != nil {
+		slog.Error("startup: failed to get dynamodb client", "error", err.Error())
+		os.Exit(1)
+	}
+
+	cfg, err := config.Load(ddbClnt)
+	if err != nil {
+		slog.Error("startup: failed to load config", "error", err.Error())
+		os.Exit(1)
+	}
+	privacy.SetDefault(cfg.Privacy, cfg.PhoneHashKey)
+
+	lambda.Start(func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return handler(ctx, req, cfg, ddbClnt)
+	})
 }
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/mshort55/prayertexter/internal/config"
+	"github.com/mshort55/prayertexter/internal/dashboard"
+	"github.com/mshort55/prayertexter/internal/db"
+	"github.com/mshort55/prayertexter/internal/encryption"
+	"github.com/mshort55/prayertexter/internal/privacy"
+)
+
+// MUST BE SET by go build -ldflags "-X main.version=999"
+// like 0.6.14-0-g26fe727 or 0.6.14-2-g9118702-dirty
+
+//lint:ignore U1000 - var used in Makefile
+var version string // do not remove or modify
+
+// dashboard serves the leader dashboard. Without -addr it runs as a lambda behind api gateway,
+// with -addr it serves over plain HTTP, for example with -addr localhost:8080 -endpoint
+// http://localhost:8000 against dynamodb local.
+func main() {
+	addr := flag.String("addr", "", "address to serve HTTP on instead of running as a lambda")
+	endpoint := flag.String("endpoint", "", "dynamodb endpoint, for example http://localhost:8000 for dynamodb local")
+	flag.Parse()
+
+	ddbClnt, err := db.GetDdbClientForEndpoint(*endpoint)
+	if err != nil {
+		slog.Error("startup: failed to get dynamodb client", "error", err.Error())
+		os.Exit(1)
+	}
+
+	cfg, err := config.Load(ddbClnt)
+	if err != nil {
+		slog.Error("startup: failed to load config", "error", err.Error())
+		os.Exit(1)
+	}
+
+	cfg.Cipher, err = encryption.NewCipher(cfg.EncryptionKeyID, cfg.EncryptionKey, cfg.EncryptNames)
+	if err != nil {
+		slog.Error("startup: failed to set up encryption", "error", err.Error())
+		os.Exit(1)
+	}
+	privacy.SetDefault(cfg.Privacy, cfg.PhoneHashKey)
+
+	if len(cfg.DashboardUsers) == 0 {
+		slog.Warn("startup: dashboardUsers is not set, every request will be rejected")
+	}
+
+	dash, err := dashboard.New(cfg, ddbClnt)
+	if err != nil {
+		slog.Error("startup: failed to load dashboard", "error", err.Error())
+		os.Exit(1)
+	}
+
+	if *addr == "" {
+		lambda.Start(dash.HandleLambda)
+		return
+	}
+
+	srv := &http.Server{Addr: *addr, Handler: dash, ReadHeaderTimeout: 10 * time.Second}
+	slog.Info("serving dashboard", "addr", *addr)
+	if err := srv.ListenAndServe(); err != nil {
+		slog.Error("dashboard server failed", "error", err.Error())
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/mshort55/prayertexter/internal/config"
+	"github.com/mshort55/prayertexter/internal/db"
+	"github.com/mshort55/prayertexter/internal/encryption"
+	"github.com/mshort55/prayertexter/internal/prayertexter"
+	"github.com/mshort55/prayertexter/internal/privacy"
+)
+
+// memberdata prints everything that is stored about a phone number as JSON. With -erase, the data
+// is removed as well, and the printed JSON is what was removed.
+func main() {
+	phone := flag.String("phone", "", "phone number of the member, for example +11234567890")
+	erase := flag.Bool("erase", false, "remove all data stored about the phone number")
+	flag.Parse()
+
+	if *phone == "" {
+		slog.Error("-phone is required")
+		os.Exit(1)
+	}
+
+	ddbClnt, err := db.GetDdbClient()
+	if err != nil {
+		slog.Error("failed to get dynamodb client", "error", err.Error())
+		os.Exit(1)
+	}
+
+	cfg, err := config.Load(ddbClnt)
+	if err != nil {
+		slog.Error("failed to load config", "error", err.Error())
+		os.Exit(1)
+	}
+
+	cfg.Cipher, err = encryption.NewCipher(cfg.EncryptionKeyID, cfg.EncryptionKey, cfg.EncryptNames)
+	if err != nil {
+		slog.Error("failed to set up encryption", "error", err.Error())
+		os.Exit(1)
+	}
+	privacy.SetDefault(cfg.Privacy, cfg.PhoneHashKey)
+
+	var data prayertexter.MemberData
+	if *erase {
+		data, err = prayertexter.EraseMemberData(*phone, cfg, ddbClnt)
+	} else {
+		data, err = prayertexter.ExportMemberData(*phone, cfg, ddbClnt)
+	}
+	if err != nil {
+		slog.Error("failed to process member data", "error", err.Error())
+		os.Exit(1)
+	}
+
+	out, err := json.MarshalIndent(data, "", "    ")
+	if err != nil {
+		slog.Error("failed to marshal member data", "error", err.Error())
+		os.Exit(1)
+	}
+
+	fmt.Println(string(out))
+}
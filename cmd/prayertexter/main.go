@@ -2,15 +2,17 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"log/slog"
-	"net/http"
+	"os"
 
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/mshort55/prayertexter/internal/config"
 	"github.com/mshort55/prayertexter/internal/db"
+	"github.com/mshort55/prayertexter/internal/encryption"
 	"github.com/mshort55/prayertexter/internal/messaging"
 	"github.com/mshort55/prayertexter/internal/prayertexter"
+	"github.com/mshort55/prayertexter/internal/privacy"
 )
 
 // MUST BE SET by go build -ldflags "-X main.version=999"
@@ -19,33 +21,39 @@ import (
 //lint:ignore U1000 - var used in Makefile
 var version string // do not remove or modify
 
-func handler(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	msg := messaging.TextMessage{}
-
-	if err := json.Unmarshal([]byte(req.Body), &msg); err != nil {
-		slog.Error("lambda handler: failed to unmarshal api gateway request", "error", err.Error())
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
-	}
-
+func main() {
 	ddbClnt, err := db.GetDdbClient()
 	if err != nil {
-		slog.Error("lambda handler: failed to get dynamodb client", "error", err.Error())
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+		slog.Error("startup: failed to get dynamodb client", "error", err.Error())
+		os.Exit(1)
 	}
 
 	smsClnt, err := messaging.GetSmsClient()
 	if err != nil {
-		slog.Error("lambda handler: failed to get sms client", "error", err.Error())
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+		slog.Error("startup: failed to get sms client", "error", err.Error())
+		os.Exit(1)
 	}
 
-	if err := prayertexter.MainFlow(msg, ddbClnt, smsClnt); err != nil {
-		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	cfg, err := config.Load(ddbClnt)
+	if err != nil {
+		slog.Error("startup: failed to load config", "error", err.Error())
+		os.Exit(1)
 	}
 
-	return events.APIGatewayProxyResponse{StatusCode: 200, Body: "Success"}, nil
-}
+	cfg.Cipher, err = encryption.NewCipher(cfg.EncryptionKeyID, cfg.EncryptionKey, cfg.EncryptNames)
+	if err != nil {
+		slog.Error("startup: failed to set up encryption", "error", err.Error())
+		os.Exit(1)
+	}
 
-func main() {
-	lambda.Start(handler)
+	if err := prayertexter.ValidateConfig(cfg); err != nil {
+		slog.Error("startup: invalid config", "error", err.Error())
+		os.Exit(1)
+	}
+	// every log line from here on has phone numbers and message bodies redacted
+	privacy.SetDefault(cfg.Privacy, cfg.PhoneHashKey)
+
+	lambda.Start(func(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return prayertexter.HandleWebhook(ctx, req, cfg, ddbClnt, smsClnt)
+	})
 }
@@ -0,0 +1,388 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mshort55/prayertexter/internal/db"
+	"github.com/mshort55/prayertexter/internal/messaging"
+	"github.com/mshort55/prayertexter/internal/object"
+	"github.com/mshort55/prayertexter/internal/prayertexter"
+)
+
+func memberList(adm admin, args []string) error {
+	fs := flag.NewFlagSet("member list", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	mems, err := db.ScanDdbObjects[object.Member](adm.ddbClnt, object.MemberTable, db.Condition{})
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PHONE\tNAME\tTENANT\tSTATUS\tINTERCESSOR\tPRAYERS\tLIMIT")
+	for _, mem := range mems {
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\t%v\t%v\n", mem.Phone, mem.Name, mem.TenantPhone, mem.SetupStatus,
+			mem.Intercessor, mem.PrayerCount, mem.WeeklyPrayerLimit)
+	}
+
+	return tw.Flush()
+}
+
+func memberShow(adm admin, args []string) error {
+	fs := flag.NewFlagSet("member show", flag.ContinueOnError)
+	phone := fs.String("phone", "", "phone number of the member")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	mem, err := getMember(adm, *phone)
+	if err != nil {
+		return err
+	}
+
+	return printJSON(mem)
+}
+
+// memberEdit changes the fields of a member that are given as flags. Changing -intercessor also
+// adds the member to or removes them from the intercessor phone list of their tenant.
+func memberEdit(adm admin, args []string) error {
+	fs := flag.NewFlagSet("member edit", flag.ContinueOnError)
+	phone := fs.String("phone", "", "phone number of the member")
+	name := fs.String("name", "", "name")
+	language := fs.String("language", "", "language, for example en or es")
+	intercessor := fs.Bool("intercessor", false, "whether the member is an intercessor")
+	limit := fs.Int("limit", 0, "weekly prayer limit")
+	count := fs.Int("count", 0, "number of prayers received this week")
+	if err := fs.Parse(args); err != nil {
+		return err
+	} else if *phone == "" {
+		return errors.New("-phone is required")
+	}
+
+	edit := prayertexter.MemberEdit{}
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "name":
+			edit.Name = name
+		case "language":
+			edit.Language = language
+		case "intercessor":
+			edit.Intercessor = intercessor
+		case "limit":
+			edit.WeeklyPrayerLimit = limit
+		case "count":
+			edit.PrayerCount = count
+		}
+	})
+
+	mem, err := prayertexter.EditMember(*phone, edit, adm.ddbClnt)
+	if err != nil {
+		return err
+	}
+
+	return printJSON(mem)
+}
+
+func prayerList(adm admin, args []string) error {
+	fs := flag.NewFlagSet("prayer list", flag.ContinueOnError)
+	queue := fs.Bool("queue", false, "list queued prayers instead of active prayers")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	pryrs, err := object.GetPrayers(adm.ddbClnt, adm.cfg.Cipher, *queue)
+	if err != nil {
+		return err
+	}
+
+	// queued prayers are keyed on a random ID instead of an intercessor phone
+	key := "INTERCESSOR"
+	if *queue {
+		key = "ID"
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "%v\tREQUESTOR\tTENANT\tREQUEST\n", key)
+	for _, pryr := range pryrs {
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%q\n", pryr.IntercessorPhone, pryr.Requestor.Phone, pryr.Requestor.TenantPhone,
+			pryr.Request)
+	}
+
+	return tw.Flush()
+}
+
+func prayerAssign(adm admin, args []string) error {
+	fs := flag.NewFlagSet("prayer assign", flag.ContinueOnError)
+	id := fs.String("id", "", "ID of the queued prayer (see prayer list -queue)")
+	phone := fs.String("phone", "", "phone number of the intercessor")
+	if err := fs.Parse(args); err != nil {
+		return err
+	} else if *id == "" || *phone == "" {
+		return errors.New("-id and -phone are required")
+	}
+
+	smsClnt, err := messaging.GetSmsClient()
+	if err != nil {
+		return err
+	}
+
+	pryr, err := prayertexter.AssignPrayer(*id, *phone, adm.cfg, adm.ddbClnt, smsClnt)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("prayer of %v sent to %v\n", pryr.Requestor.Phone, pryr.IntercessorPhone)
+	return nil
+}
+
+func prayerRequeue(adm admin, args []string) error {
+	fs := flag.NewFlagSet("prayer requeue", flag.ContinueOnError)
+	phone := fs.String("phone", "", "phone number of the intercessor")
+	if err := fs.Parse(args); err != nil {
+		return err
+	} else if *phone == "" {
+		return errors.New("-phone is required")
+	}
+
+	pryr, err := prayertexter.RequeuePrayer(*phone, adm.cfg, adm.ddbClnt)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("prayer of %v moved back to the queue\n", pryr.Requestor.Phone)
+	return nil
+}
+
+func pendingList(adm admin, args []string) error {
+	fs := flag.NewFlagSet("pending list", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	pryrs, err := object.GetPendingPrayers(adm.ddbClnt, adm.cfg.Cipher)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tRECEIVED\tREQUESTOR\tTENANT\tREQUEST")
+	for _, pryr := range pryrs {
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%q\n", pryr.ID, pryr.TimeReceived, pryr.Requestor.Phone,
+			pryr.Requestor.TenantPhone, pryr.Request)
+	}
+
+	return tw.Flush()
+}
+
+func pendingApprove(adm admin, args []string) error {
+	return pendingModerate(adm, "pending approve", true, args)
+}
+
+func pendingReject(adm admin, args []string) error {
+	return pendingModerate(adm, "pending reject", false, args)
+}
+
+// pendingModerate approves or rejects a pending prayer request on behalf of the tenant of its
+// requestor, like a moderator of that tenant would by text message.
+func pendingModerate(adm admin, name string, approve bool, args []string) error {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	id := fs.String("id", "", "ID of the pending prayer request (see pending list)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	} else if *id == "" {
+		return errors.New("-id is required")
+	}
+
+	pending := object.PendingPrayer{ID: *id}
+	if err := pending.Get(adm.ddbClnt, adm.cfg.Cipher); err != nil {
+		return err
+	}
+
+	smsClnt, err := messaging.GetSmsClient()
+	if err != nil {
+		return err
+	}
+
+	pryr, err := prayertexter.ModeratePrayer(*id, approve, "ptadmin", pending.Requestor.TenantPhone, adm.cfg,
+		adm.ddbClnt, smsClnt)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("prayer request %v of %v %v\n", pryr.ID, pryr.Requestor.Phone, strings.ToLower(pryr.Status))
+	return nil
+}
+
+func stateList(adm admin, args []string) error {
+	fs := flag.NewFlagSet("state list", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	st := object.StateTracker{}
+	if err := st.Get(adm.ddbClnt); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSTARTED\tSTAGE\tSTATUS\tPHONE\tERROR")
+	for _, state := range st.States {
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\t%v\n", state.ID, state.TimeStart, state.Stage, state.Status,
+			state.Message.Phone, state.Error)
+	}
+
+	return tw.Flush()
+}
+
+func stateClear(adm admin, args []string) error {
+	fs := flag.NewFlagSet("state clear", flag.ContinueOnError)
+	id := fs.String("id", "", "ID of the entry to remove, all entries are removed without it")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *id != "" {
+		state := object.State{ID: *id}
+		return state.Update(adm.ddbClnt, true)
+	}
+
+	st := object.StateTracker{}
+	return st.Update(adm.ddbClnt, func(st *object.StateTracker) { st.States = nil })
+}
+
+func phonesShow(adm admin, args []string) error {
+	fs := flag.NewFlagSet("phones show", flag.ContinueOnError)
+	tenant := fs.String("tenant", "", "phone number of the tenant, the default tenant without it")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	phones := object.TenantIntercessorPhones(*tenant)
+	if err := phones.Get(adm.ddbClnt); err != nil {
+		return err
+	}
+
+	return printJSON(phones)
+}
+
+func phonesRebuild(adm admin, args []string) error {
+	fs := flag.NewFlagSet("phones rebuild", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	rebuilt, err := object.RebuildIntercessorPhones(adm.ddbClnt)
+	if err != nil {
+		return err
+	}
+
+	for _, phones := range rebuilt {
+		fmt.Printf("%v: %v intercessors\n", phones.Key, len(phones.Phones))
+	}
+
+	return nil
+}
+
+// migrateRun upgrades stored objects to their current schema version, page by page. An interrupted
+// run resumes where it stopped when it is run again.
+func migrateRun(adm admin, args []string) error {
+	fs := flag.NewFlagSet("migrate run", flag.ContinueOnError)
+	name := fs.String("object", "", "kind of object to migrate, all kinds without it (see migrate status)")
+	restart := fs.Bool("restart", false, "start over instead of resuming an interrupted migration")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	targets, err := migrationTargets(*name)
+	if err != nil {
+		return err
+	}
+
+	for _, target := range targets {
+		err := object.MigrateObjects(adm.ddbClnt, target, *restart, func(prog object.MigrationProgress) {
+			fmt.Printf("%v: %v scanned, %v migrated, %v skipped\n", target.Name, prog.Scanned, prog.Migrated,
+				prog.Skipped)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func migrateStatus(adm admin, args []string) error {
+	fs := flag.NewFlagSet("migrate status", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "OBJECT\tTABLE\tSTATUS\tSCANNED\tMIGRATED\tSKIPPED")
+	for _, target := range object.MigrationTargets() {
+		prog := object.MigrationProgress{Key: object.MigrationProgressKeyPrefix + target.Name}
+		if err := prog.Get(adm.ddbClnt); err != nil {
+			return err
+		}
+
+		status := "not run"
+		switch {
+		case prog.Done:
+			status = "done"
+		case prog.LastKey != "":
+			status = "interrupted"
+		}
+		fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\t%v\n", target.Name, target.Table, status, prog.Scanned, prog.Migrated,
+			prog.Skipped)
+	}
+
+	return tw.Flush()
+}
+
+func migrationTargets(name string) ([]object.MigrationTarget, error) {
+	targets := object.MigrationTargets()
+	if name == "" {
+		return targets, nil
+	}
+
+	for _, target := range targets {
+		if target.Name == name {
+			return []object.MigrationTarget{target}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown object %q, see migrate status", name)
+}
+
+func getMember(adm admin, phone string) (object.Member, error) {
+	if phone == "" {
+		return object.Member{}, errors.New("-phone is required")
+	}
+
+	mem := object.Member{Phone: phone}
+	if err := mem.Get(adm.ddbClnt); err != nil {
+		return mem, err
+	} else if mem.SetupStatus == "" {
+		return mem, fmt.Errorf("member %v does not exist", phone)
+	}
+
+	return mem, nil
+}
+
+func printJSON(v any) error {
+	out, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(out))
+	return nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/mshort55/prayertexter/internal/config"
+	"github.com/mshort55/prayertexter/internal/db"
+	"github.com/mshort55/prayertexter/internal/encryption"
+	"github.com/mshort55/prayertexter/internal/privacy"
+)
+
+// ptadmin operates prayertexter from the command line against dynamodb in aws, or against dynamodb
+// local with -endpoint http://localhost:8000. Run it without arguments to list the commands.
+func main() {
+	endpoint := flag.String("endpoint", "", "dynamodb endpoint, for example http://localhost:8000 for dynamodb local")
+	flag.Usage = usage
+	flag.Parse()
+
+	commands := map[string]func(admin, []string) error{
+		"member list":     memberList,
+		"member show":     memberShow,
+		"member edit":     memberEdit,
+		"prayer list":     prayerList,
+		"prayer assign":   prayerAssign,
+		"prayer requeue":  prayerRequeue,
+		"pending list":    pendingList,
+		"pending approve": pendingApprove,
+		"pending reject":  pendingReject,
+		"state list":      stateList,
+		"state clear":     stateClear,
+		"phones show":     phonesShow,
+		"phones rebuild":  phonesRebuild,
+		"migrate run":     migrateRun,
+		"migrate status":  migrateStatus,
+	}
+
+	if flag.NArg() < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	name := strings.Join(flag.Args()[:2], " ")
+	command, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+		usage()
+		os.Exit(2)
+	}
+
+	adm, err := newAdmin(*endpoint)
+	if err != nil {
+		slog.Error("failed to set up", "error", err.Error())
+		os.Exit(1)
+	}
+
+	if err := command(adm, flag.Args()[2:]); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			slog.Error(name+" failed", "error", err.Error())
+		}
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprint(os.Stderr, `usage: ptadmin [-endpoint url] <command> [flags]
+
+commands:
+  member list                       list all members
+  member show -phone P              show a member
+  member edit -phone P [flags]      change a member, run with -h for the flags
+  prayer list [-queue]              list active (or queued) prayers
+  prayer assign -id ID -phone P     send the queued prayer ID to intercessor P
+  prayer requeue -phone P           move the active prayer of intercessor P back to the queue
+  pending list                      list prayer requests that are held for moderation
+  pending approve -id ID            approve the held prayer request ID and send it out
+  pending reject -id ID             reject the held prayer request ID
+  state list                        list StateTracker entries
+  state clear [-id ID]              remove StateTracker entry ID, or all entries
+  phones show [-tenant P]           show the intercessor phone list of a tenant
+  phones rebuild                    rebuild all intercessor phone lists from the members
+  migrate run [-object O] [-restart]
+                                    upgrade stored objects to their current schema version
+  migrate status                    show the kinds of objects and their last migration
+`)
+}
+
+// admin holds what every command needs.
+type admin struct {
+	cfg     config.Config
+	ddbClnt db.DDBConnecter
+}
+
+func newAdmin(endpoint string) (admin, error) {
+	ddbClnt, err := db.GetDdbClientForEndpoint(endpoint)
+	if err != nil {
+		return admin{}, err
+	}
+
+	cfg, err := config.Load(ddbClnt)
+	if err != nil {
+		return admin{}, err
+	}
+
+	cfg.Cipher, err = encryption.NewCipher(cfg.EncryptionKeyID, cfg.EncryptionKey, cfg.EncryptNames)
+	if err != nil {
+		return admin{}, err
+	}
+	privacy.SetDefault(cfg.Privacy, cfg.PhoneHashKey)
+
+	return admin{cfg: cfg, ddbClnt: ddbClnt}, nil
+}
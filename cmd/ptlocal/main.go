@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/mshort55/prayertexter/internal/adminapi"
+	"github.com/mshort55/prayertexter/internal/config"
+	"github.com/mshort55/prayertexter/internal/dashboard"
+	"github.com/mshort55/prayertexter/internal/db"
+	"github.com/mshort55/prayertexter/internal/encryption"
+	"github.com/mshort55/prayertexter/internal/messaging"
+	"github.com/mshort55/prayertexter/internal/object"
+	"github.com/mshort55/prayertexter/internal/prayertexter"
+	"github.com/mshort55/prayertexter/internal/privacy"
+)
+
+// server runs every flow of prayertexter in a single process, with all tables kept in memory and
+// text messages logged instead of sent.
+type server struct {
+	data    string
+	ddbClnt *db.MemoryDB
+	mu      sync.Mutex
+	smsClnt *messaging.LogSender
+}
+
+// ptlocal serves the sms webhook at /, the admin api at /admin, the dashboard at /dashboard and
+// the sent text messages at /messages over plain HTTP, without any aws services. With -data, the
+// tables are loaded from and saved to a JSON file, so that they survive restarts.
+func main() {
+	addr := flag.String("addr", "localhost:8080", "address to serve HTTP on")
+	data := flag.String("data", "", "JSON file to load the tables from and save them to after every request")
+	flag.Parse()
+
+	srv := &server{
+		data:    *data,
+		ddbClnt: db.NewMemoryDB(object.TableKeys()),
+		smsClnt: &messaging.LogSender{Logger: slog.New(slog.NewTextHandler(os.Stdout, nil))},
+	}
+
+	if err := srv.load(); err != nil {
+		slog.Error("startup: failed to load data", "error", err.Error())
+		os.Exit(1)
+	}
+
+	// the config file is read from PRAYERTEXTER_CONFIG_FILE, the Config item can be put into the
+	// General table of the data file
+	cfg, err := config.Load(srv.ddbClnt)
+	if err != nil {
+		slog.Error("startup: failed to load config", "error", err.Error())
+		os.Exit(1)
+	}
+
+	// kms is not available locally, so only encryptionKey is used
+	cfg.Cipher, err = encryption.NewCipher("", cfg.EncryptionKey, cfg.EncryptNames)
+	if err != nil {
+		slog.Error("startup: failed to set up encryption", "error", err.Error())
+		os.Exit(1)
+	}
+
+	if err := prayertexter.ValidateConfig(cfg); err != nil {
+		slog.Error("startup: invalid config", "error", err.Error())
+		os.Exit(1)
+	}
+	// sent text messages are still printed in full by the LogSender, which has its own logger
+	privacy.SetDefault(cfg.Privacy, cfg.PhoneHashKey)
+
+	dash, err := dashboard.New(cfg, srv.ddbClnt)
+	if err != nil {
+		slog.Error("startup: failed to load dashboard", "error", err.Error())
+		os.Exit(1)
+	}
+	api := adminapi.New(cfg, srv.ddbClnt, srv.smsClnt)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /{$}", srv.lambda(func(ctx context.Context, req events.APIGatewayProxyRequest) (
+		events.APIGatewayProxyResponse, error) {
+		return prayertexter.HandleWebhook(ctx, req, cfg, srv.ddbClnt, srv.smsClnt)
+	}))
+	mux.HandleFunc(adminapi.PathPrefix+"/", srv.lambda(api.Handle))
+	mux.Handle("/dashboard", srv.persist(dash))
+	mux.HandleFunc("GET /messages", srv.messages)
+
+	httpSrv := &http.Server{Addr: *addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	slog.Info("serving prayertexter locally", "addr", *addr)
+	if err := httpSrv.ListenAndServe(); err != nil {
+		slog.Error("local server failed", "error", err.Error())
+		os.Exit(1)
+	}
+}
+
+// lambda serves an api gateway lambda handler over HTTP.
+func (s *server) lambda(handle func(context.Context, events.APIGatewayProxyRequest) (
+	events.APIGatewayProxyResponse, error)) http.HandlerFunc {
+	return s.persist(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		req := events.APIGatewayProxyRequest{
+			Body:                  string(body),
+			Headers:               map[string]string{},
+			HTTPMethod:            r.Method,
+			Path:                  r.URL.Path,
+			QueryStringParameters: map[string]string{},
+		}
+		for key := range r.Header {
+			req.Headers[key] = r.Header.Get(key)
+		}
+		for key := range r.URL.Query() {
+			req.QueryStringParameters[key] = r.URL.Query().Get(key)
+		}
+
+		resp, err := handle(r.Context(), req)
+		if err != nil {
+			slog.Error("request failed", "path", r.URL.Path, "error", err.Error())
+		}
+
+		for key, value := range resp.Headers {
+			w.Header().Set(key, value)
+		}
+		if resp.StatusCode == 0 {
+			resp.StatusCode = http.StatusOK
+		}
+		w.WriteHeader(resp.StatusCode)
+		_, _ = io.WriteString(w, resp.Body)
+	}))
+}
+
+// persist serves requests one at a time, like a lambda with a concurrency of 1, and saves the
+// tables after every request.
+func (s *server) persist(next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		next.ServeHTTP(w, r)
+
+		if err := s.save(); err != nil {
+			slog.Error("failed to save data", "error", err.Error())
+		}
+	}
+}
+
+// messages returns the text messages that were sent as JSON, to all phones or to the phone query
+// parameter.
+func (s *server) messages(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+	if err := enc.Encode(s.smsClnt.Sent(r.URL.Query().Get("phone"))); err != nil {
+		slog.Error("failed to write messages", "error", err.Error())
+	}
+}
+
+func (s *server) load() error {
+	if s.data == "" {
+		return nil
+	}
+
+	file, err := os.Open(s.data)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return s.ddbClnt.Load(file)
+}
+
+// save writes to a temporary file first, so that a failed save does not lose the previous data.
+func (s *server) save() error {
+	if s.data == "" {
+		return nil
+	}
+
+	tmp := s.data + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	if err := s.ddbClnt.Save(file); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, s.data)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/mshort55/prayertexter/internal/config"
+	"github.com/mshort55/prayertexter/internal/db"
+	"github.com/mshort55/prayertexter/internal/object"
+	"github.com/mshort55/prayertexter/internal/simulator"
+)
+
+// ptsim simulates conversations between virtual phones and prayertexter without any aws services.
+// Without arguments it starts an interactive shell, with run it plays YAML scenarios.
+func main() {
+	// flows log every failure, which the shell and the scenarios already report
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	if len(os.Args) > 1 && os.Args[1] == "run" {
+		os.Exit(run(os.Args[2:]))
+	} else if len(os.Args) > 1 {
+		fmt.Fprint(os.Stderr, `usage: ptsim              start an interactive shell
+       ptsim run FILE...   play YAML scenarios
+`)
+		os.Exit(2)
+	}
+
+	// the config file is read from PRAYERTEXTER_CONFIG_FILE
+	cfg, err := config.Load(db.NewMemoryDB(object.TableKeys()))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to load config:", err)
+		os.Exit(1)
+	}
+
+	sim, err := simulator.New(cfg, db.NewMemoryDB(object.TableKeys()))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to start simulator:", err)
+		os.Exit(1)
+	}
+
+	shell(sim, os.Stdin, os.Stdout)
+}
+
+// run plays every scenario file and returns the exit code.
+func run(files []string) int {
+	failed := 0
+	for _, file := range files {
+		if err := runFile(file); err != nil {
+			fmt.Printf("FAIL %v: %v\n", file, err)
+			failed++
+		} else {
+			fmt.Printf("ok   %v\n", file)
+		}
+	}
+
+	if failed > 0 || len(files) == 0 {
+		return 1
+	}
+
+	return 0
+}
+
+func runFile(file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sc, err := simulator.LoadScenario(f)
+	if err != nil {
+		return err
+	}
+
+	_, err = simulator.Run(sc, db.NewMemoryDB(object.TableKeys()))
+	return err
+}
+
+const shellHelp = `commands:
+  PHONE TEXT          text TEXT from PHONE (a phone number or a name)
+  name NAME PHONE     name a phone number
+  inbox PHONE         show every text message PHONE received
+  help                show this help
+  quit                leave the shell
+`
+
+// shell reads commands from in until it ends or quit, and prints the text messages that every
+// command sent.
+func shell(sim *simulator.Simulator, in io.Reader, out io.Writer) {
+	fmt.Fprint(out, shellHelp)
+	scanner := bufio.NewScanner(in)
+
+	for fmt.Fprint(out, "> "); scanner.Scan(); fmt.Fprint(out, "> ") {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch {
+		case fields[0] == "quit" || fields[0] == "exit":
+			return
+		case fields[0] == "help":
+			fmt.Fprint(out, shellHelp)
+		case fields[0] == "name" && len(fields) == 3:
+			sim.Alias(fields[1], fields[2])
+		case fields[0] == "inbox" && len(fields) == 2:
+			inbox, err := sim.Inbox(fields[1])
+			if err != nil {
+				fmt.Fprintln(out, err)
+			}
+			for _, msg := range inbox {
+				fmt.Fprintf(out, "%v %v\n", msg.Timestamp, indent(msg.Body))
+			}
+		case len(fields) >= 2:
+			text := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(scanner.Text()), fields[0]))
+			if err := sim.Send(fields[0], text); err != nil {
+				fmt.Fprintln(out, err)
+			}
+			for _, msg := range sim.Unread() {
+				fmt.Fprintf(out, "-> %v: %v\n", sim.Name(msg.Phone), indent(msg.Body))
+			}
+			sim.MarkRead()
+		default:
+			fmt.Fprint(out, shellHelp)
+		}
+	}
+}
+
+// indent lines up multi line text messages under their first line.
+func indent(body string) string {
+	return strings.ReplaceAll(body, "\n", "\n    ")
+}
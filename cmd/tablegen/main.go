@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/mshort55/prayertexter/internal/object"
+	"github.com/mshort55/prayertexter/internal/tablegen"
+)
+
+// tablegen writes the localdev table files and the table resources of template.yaml from
+// object.Tables. With -check, it only reports files that are out of date and exits 1 if there are
+// any.
+func main() {
+	dir := flag.String("dir", ".", "root directory of the repository")
+	check := flag.Bool("check", false, "report out of date files instead of writing them")
+	flag.Parse()
+
+	if err := run(*dir, *check); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(dir string, check bool) error {
+	files, err := tablegen.Generate(dir, object.Tables())
+	if err != nil {
+		return err
+	}
+	stale, err := tablegen.StaleFiles(dir, files)
+	if err != nil {
+		return err
+	}
+
+	var outdated []string
+	for path, data := range files {
+		current, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if !bytes.Equal(current, data) {
+			outdated = append(outdated, path)
+		}
+	}
+	slices.Sort(outdated)
+
+	if check {
+		for _, path := range outdated {
+			fmt.Printf("out of date: %v\n", path)
+		}
+		for _, path := range stale {
+			fmt.Printf("stale: %v\n", path)
+		}
+		if len(outdated) > 0 || len(stale) > 0 {
+			return fmt.Errorf("table files are out of date, run go run ./cmd/tablegen")
+		}
+
+		return nil
+	}
+
+	for _, path := range outdated {
+		if err := os.WriteFile(path, files[path], 0o644); err != nil {
+			return err
+		}
+		fmt.Printf("wrote %v\n", path)
+	}
+	for _, path := range stale {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		fmt.Printf("removed %v\n", path)
+	}
+
+	return nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"cmp"
+	"flag"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/mshort55/prayertexter/internal/config"
+	"github.com/mshort55/prayertexter/internal/db"
+	"github.com/mshort55/prayertexter/internal/object"
+	"github.com/mshort55/prayertexter/internal/privacy"
+)
+
+// usagereport prints sent text message counters and estimated costs for a range of days. Usage is
+// only counted when the trackUsage config setting is enabled. It also prints the number of completed
+// prayers per month.
+func main() {
+	now := time.Now().UTC()
+	from := flag.String("from", now.Format("2006-01")+"-01", "first day of the report (YYYY-MM-DD)")
+	to := flag.String("to", now.Format("2006-01-02"), "last day of the report (YYYY-MM-DD)")
+	members := flag.Int("members", 20, "number of members with the highest usage to show")
+	flag.Parse()
+
+	fromDay, err := time.Parse("2006-01-02", *from)
+	if err != nil {
+		slog.Error("invalid -from date", "error", err.Error())
+		os.Exit(1)
+	}
+
+	toDay, err := time.Parse("2006-01-02", *to)
+	if err != nil {
+		slog.Error("invalid -to date", "error", err.Error())
+		os.Exit(1)
+	}
+
+	ddbClnt, err := db.GetDdbClient()
+	if err != nil {
+		slog.Error("failed to get dynamodb client", "error", err.Error())
+		os.Exit(1)
+	}
+
+	cfg, err := config.Load(ddbClnt)
+	if err != nil {
+		slog.Error("failed to load config", "error", err.Error())
+		os.Exit(1)
+	}
+	privacy.SetDefault(cfg.Privacy, cfg.PhoneHashKey)
+
+	report, err := object.GetUsageReport(ddbClnt, fromDay, toDay)
+	if err != nil {
+		slog.Error("failed to get usage report", "error", err.Error())
+		os.Exit(1)
+	}
+
+	fmt.Printf("usage from %v to %v\n\n", *from, *to)
+	printCounts("total", map[string]object.UsageCount{"all": report.Total}, 0, cfg.SegmentPrice)
+	printCounts("stage", report.Stages, 0, cfg.SegmentPrice)
+	printCounts("country", report.Countries, 0, cfg.SegmentPrice)
+	printCounts("member", report.Members, *members, cfg.SegmentPrice)
+
+	if err := printPrayerStats(ddbClnt, fromDay, toDay); err != nil {
+		slog.Error("failed to get prayer stats", "error", err.Error())
+		os.Exit(1)
+	}
+
+	overBudget, err := object.IsOverBudget(ddbClnt, cfg.MonthlySegmentBudget, now)
+	if err != nil {
+		slog.Error("failed to check monthly budget", "error", err.Error())
+		os.Exit(1)
+	}
+
+	if cfg.MonthlySegmentBudget > 0 {
+		fmt.Printf("monthly budget: %v segments, exceeded: %v\n", cfg.MonthlySegmentBudget, overBudget)
+	}
+}
+
+// printPrayerStats prints the number of completed prayers for every month between from and to.
+func printPrayerStats(ddbClnt db.DDBConnecter, from, to time.Time) error {
+	fmt.Printf("%-20v %10v\n", "month", "prayers")
+	month := time.Date(from.Year(), from.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for ; !month.After(to); month = month.AddDate(0, 1, 0) {
+		stats := object.PrayerStats{Key: object.PrayerStatsKey(month)}
+		if err := stats.Get(ddbClnt); err != nil {
+			return err
+		}
+		fmt.Printf("%-20v %10v\n", month.Format("2006-01"), stats.Completed)
+	}
+	fmt.Println()
+
+	return nil
+}
+
+// printCounts prints counts sorted by the number of segments. limit is the maximum number of
+// rows to print, 0 means all rows.
+func printCounts(title string, counts map[string]object.UsageCount, limit int, price float64) {
+	keys := slices.SortedFunc(maps.Keys(counts), func(a, b string) int {
+		return cmp.Or(cmp.Compare(counts[b].Segments, counts[a].Segments), cmp.Compare(a, b))
+	})
+	if limit > 0 && len(keys) > limit {
+		keys = keys[:limit]
+	}
+
+	fmt.Printf("%-20v %10v %10v %10v %10v\n", title, "messages", "segments", "ucs-2", "cost")
+	for _, key := range keys {
+		count := counts[key]
+		fmt.Printf("%-20v %10v %10v %10v %10.2f\n", key, count.Messages, count.Segments, count.UCS2Messages,
+			float64(count.Segments)*price)
+	}
+	fmt.Println()
+}
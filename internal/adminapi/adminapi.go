@@ -0,0 +1,546 @@
+package adminapi
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/mshort55/prayertexter/internal/config"
+	"github.com/mshort55/prayertexter/internal/db"
+	"github.com/mshort55/prayertexter/internal/messaging"
+	"github.com/mshort55/prayertexter/internal/object"
+	"github.com/mshort55/prayertexter/internal/prayertexter"
+)
+
+// PathPrefix is where api gateway routes the admin api, next to the sms webhook at /.
+const PathPrefix = "/admin"
+
+const usageDateFmt = "2006-01-02"
+
+// maxUsageDays is the longest range of days of a usage request, since every day is a ddb read.
+const maxUsageDays = 366
+
+// apiModerator is saved as the moderator of prayer requests that are approved or rejected over the
+// admin api.
+const apiModerator = "admin api"
+
+// the spec is served at /admin/openapi.yaml and documents every route
+//
+//go:embed openapi.yaml
+var spec []byte
+
+// errBadRequest is returned by handlers when the request can not be processed as sent.
+var errBadRequest = errors.New("bad request")
+
+// API serves the admin api, which lets admins manage members, prayers, held prayer requests and the
+// prayer queue and read stats over HTTPS. Every request needs either one of the AdminAPIKeys or a
+// bearer token that is signed with the AdminJWTSecret.
+type API struct {
+	cfg     config.Config
+	ddbClnt db.DDBConnecter
+	smsClnt messaging.TextSender
+}
+
+// request is what handlers get from an api gateway request.
+type request struct {
+	body   string
+	params map[string]string
+	query  map[string]string
+}
+
+type route struct {
+	method  string
+	pattern string
+	handle  func(api API, req request) (int, any, error)
+}
+
+func New(cfg config.Config, ddbClnt db.DDBConnecter, smsClnt messaging.TextSender) API {
+	return API{cfg: cfg, ddbClnt: ddbClnt, smsClnt: smsClnt}
+}
+
+// routes returns every route of the admin api. Patterns are relative to PathPrefix and {name}
+// segments are path parameters. Routes are matched in order, so fixed segments come first.
+func routes() []route {
+	return []route{
+		{http.MethodGet, "/members", listMembers},
+		{http.MethodPost, "/members", addMember},
+		{http.MethodGet, "/members/{phone}", getMember},
+		{http.MethodPatch, "/members/{phone}", editMember},
+		{http.MethodDelete, "/members/{phone}", eraseMember},
+		{http.MethodGet, "/members/{phone}/data", exportMember},
+		{http.MethodGet, "/pending-prayers", listPendingPrayers},
+		{http.MethodPost, "/pending-prayers/{id}/approve", approvePendingPrayer},
+		{http.MethodPost, "/pending-prayers/{id}/reject", rejectPendingPrayer},
+		{http.MethodGet, "/prayers", listPrayers},
+		{http.MethodGet, "/prayers/{phone}", getPrayer},
+		{http.MethodDelete, "/prayers/{phone}", deletePrayer},
+		{http.MethodPost, "/prayers/{phone}/requeue", requeuePrayer},
+		{http.MethodGet, "/queue", listQueue},
+		{http.MethodPost, "/queue/retry", retryQueue},
+		{http.MethodGet, "/queue/{id}", getQueued},
+		{http.MethodDelete, "/queue/{id}", deleteQueued},
+		{http.MethodPost, "/queue/{id}/assign", assignQueued},
+		{http.MethodGet, "/stats", getStats},
+		{http.MethodGet, "/stats/stuck", getStuck},
+		{http.MethodGet, "/stats/usage", getUsage},
+	}
+}
+
+// Handle authorizes and routes a single api gateway request. Errors are returned to the caller as
+// JSON error responses, so the returned error is always nil.
+func (a API) Handle(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	path := strings.TrimSuffix(strings.TrimPrefix(req.Path, PathPrefix), "/")
+
+	if req.HTTPMethod == http.MethodGet && path == "/openapi.yaml" {
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusOK,
+			Headers:    map[string]string{"Content-Type": "application/yaml"},
+			Body:       string(spec),
+		}, nil
+	}
+
+	// paths hold member phones, so only the route pattern is logged
+	rt, params, status := match(req.HTTPMethod, path)
+	pattern := rt.pattern
+	if status != http.StatusOK {
+		pattern = "unknown"
+	}
+
+	if err := authorize(req.Headers, a.cfg, time.Now()); err != nil {
+		slog.WarnContext(ctx, "admin api: unauthorized request", "route", pattern, "error", err.Error())
+		return respond(http.StatusUnauthorized, errorBody(err)), nil
+	}
+
+	if status != http.StatusOK {
+		return respond(status, errorBody(errors.New(http.StatusText(status)))), nil
+	}
+
+	status, body, err := rt.handle(a, request{body: req.Body, params: params, query: req.QueryStringParameters})
+	if err != nil {
+		status = errorStatus(err)
+		if status == http.StatusInternalServerError {
+			slog.ErrorContext(ctx, "admin api: request failed", "method", req.HTTPMethod, "route", pattern,
+				"error", err.Error())
+			err = errors.New(http.StatusText(status))
+		}
+		body = errorBody(err)
+	}
+
+	return respond(status, body), nil
+}
+
+// match finds the route of method and path. Status is http.StatusNotFound if no route has the path
+// and http.StatusMethodNotAllowed if no route of the path has the method.
+func match(method, path string) (route, map[string]string, int) {
+	status := http.StatusNotFound
+	segments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+
+	for _, rt := range routes() {
+		params, ok := matchPattern(rt.pattern, segments)
+		if !ok {
+			continue
+		} else if rt.method != method {
+			status = http.StatusMethodNotAllowed
+			continue
+		}
+
+		return rt, params, http.StatusOK
+	}
+
+	return route{}, nil, status
+}
+
+func matchPattern(pattern string, segments []string) (map[string]string, bool) {
+	parts := strings.Split(strings.TrimPrefix(pattern, "/"), "/")
+	if len(parts) != len(segments) {
+		return nil, false
+	}
+
+	params := map[string]string{}
+	for i, part := range parts {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			// phone numbers may arrive with their + escaped
+			value, err := url.PathUnescape(segments[i])
+			if err != nil || value == "" {
+				return nil, false
+			}
+			params[strings.Trim(part, "{}")] = value
+		} else if part != segments[i] {
+			return nil, false
+		}
+	}
+
+	return params, true
+}
+
+// errorStatus maps errors of the prayertexter package to http status codes.
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, errBadRequest):
+		return http.StatusBadRequest
+	case errors.Is(err, prayertexter.ErrNoMember), errors.Is(err, prayertexter.ErrNoActivePrayer),
+		errors.Is(err, prayertexter.ErrNoQueuedPrayer), errors.Is(err, prayertexter.ErrNoPendingPrayer):
+		return http.StatusNotFound
+	case errors.Is(err, prayertexter.ErrMemberExists), errors.Is(err, prayertexter.ErrIntercessorUnavailable):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func errorBody(err error) map[string]string {
+	return map[string]string{"error": err.Error()}
+}
+
+func respond(status int, body any) events.APIGatewayProxyResponse {
+	out, err := json.Marshal(body)
+	if err != nil {
+		slog.Error("admin api: failed to marshal response", "error", err.Error())
+		status, out = http.StatusInternalServerError, []byte(`{"error":"Internal Server Error"}`)
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: status,
+		Headers:    map[string]string{"Content-Type": "application/json"},
+		Body:       string(out),
+	}
+}
+
+// decode unmarshals the JSON body of req into v. Fields that v does not have are rejected, so
+// that a body can not set more than the handler allows.
+func decode(req request, v any) error {
+	dec := json.NewDecoder(strings.NewReader(req.body))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("%w: %w", errBadRequest, err)
+	}
+
+	return nil
+}
+
+func listMembers(api API, _ request) (int, any, error) {
+	mems, err := db.ScanDdbObjects[object.Member](api.ddbClnt, object.MemberTable, db.Condition{})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusOK, nonNil(mems), nil
+}
+
+// newMember is the body of an add member request. These are the only fields that admins set,
+// the rest of the member is set by AddMember.
+type newMember struct {
+	Intercessor       bool
+	Language          string
+	Name              string
+	Phone             string
+	TenantPhone       string
+	WeeklyPrayerLimit int
+}
+
+func addMember(api API, req request) (int, any, error) {
+	body := newMember{}
+	if err := decode(req, &body); err != nil {
+		return 0, nil, err
+	} else if body.Phone == "" {
+		return 0, nil, fmt.Errorf("%w: phone is required", errBadRequest)
+	}
+
+	phone, err := messaging.NormalizePhone(body.Phone)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%w: %w", errBadRequest, err)
+	}
+
+	tenant, err := tenantPhone(api, body.TenantPhone)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	mem := object.Member{
+		Intercessor:       body.Intercessor,
+		Language:          body.Language,
+		Name:              body.Name,
+		Phone:             phone,
+		TenantPhone:       tenant,
+		WeeklyPrayerLimit: body.WeeklyPrayerLimit,
+	}
+	mem, err = prayertexter.AddMember(mem, api.ddbClnt)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusCreated, mem, nil
+}
+
+// tenantPhone returns phone as the TenantPhone of a member, which is empty for the default
+// PrayerTexter number. Phones of tenants that do not exist are rejected, since their members would
+// never get any prayer requests.
+func tenantPhone(api API, phone string) (string, error) {
+	if phone == "" {
+		return "", nil
+	}
+
+	phone, err := messaging.NormalizePhone(phone)
+	if err != nil {
+		return "", fmt.Errorf("%w: tenant %w", errBadRequest, err)
+	} else if phone == messaging.PrayerTexterPhone {
+		return "", nil
+	}
+
+	tnt := object.Tenant{Phone: phone}
+	if err := tnt.Get(api.ddbClnt); err != nil {
+		return "", err
+	} else if tnt.Key == "" {
+		return "", fmt.Errorf("%w: no tenant configured for phone %v", errBadRequest, phone)
+	}
+
+	return phone, nil
+}
+
+func getMember(api API, req request) (int, any, error) {
+	mem := object.Member{Phone: req.params["phone"]}
+	if err := mem.Get(api.ddbClnt); err != nil {
+		return 0, nil, err
+	} else if mem.SetupStatus == "" {
+		return 0, nil, prayertexter.ErrNoMember
+	}
+
+	return http.StatusOK, mem, nil
+}
+
+func editMember(api API, req request) (int, any, error) {
+	edit := prayertexter.MemberEdit{}
+	if err := decode(req, &edit); err != nil {
+		return 0, nil, err
+	}
+
+	mem, err := prayertexter.EditMember(req.params["phone"], edit, api.ddbClnt)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusOK, mem, nil
+}
+
+func eraseMember(api API, req request) (int, any, error) {
+	data, err := prayertexter.EraseMemberData(req.params["phone"], api.cfg, api.ddbClnt)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusOK, data, nil
+}
+
+func exportMember(api API, req request) (int, any, error) {
+	data, err := prayertexter.ExportMemberData(req.params["phone"], api.cfg, api.ddbClnt)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusOK, data, nil
+}
+
+func listPendingPrayers(api API, _ request) (int, any, error) {
+	pryrs, err := object.GetPendingPrayers(api.ddbClnt, api.cfg.Cipher)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusOK, nonNil(pryrs), nil
+}
+
+func approvePendingPrayer(api API, req request) (int, any, error) {
+	return moderatePendingPrayer(api, req.params["id"], true)
+}
+
+func rejectPendingPrayer(api API, req request) (int, any, error) {
+	return moderatePendingPrayer(api, req.params["id"], false)
+}
+
+// moderatePendingPrayer approves or rejects a pending prayer request. Admins are not scoped to a
+// tenant, so they moderate on behalf of the tenant of the requestor.
+func moderatePendingPrayer(api API, id string, approve bool) (int, any, error) {
+	pending := object.PendingPrayer{ID: id}
+	if err := pending.Get(api.ddbClnt, api.cfg.Cipher); err != nil {
+		return 0, nil, err
+	}
+
+	pryr, err := prayertexter.ModeratePrayer(id, approve, apiModerator, pending.Requestor.TenantPhone, api.cfg,
+		api.ddbClnt, api.smsClnt)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusOK, pryr, nil
+}
+
+func listPrayers(api API, _ request) (int, any, error) {
+	pryrs, err := object.GetPrayers(api.ddbClnt, api.cfg.Cipher, false)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusOK, nonNil(pryrs), nil
+}
+
+func getPrayer(api API, req request) (int, any, error) {
+	return prayerResponse(api, req.params["phone"], false)
+}
+
+func deletePrayer(api API, req request) (int, any, error) {
+	pryr := object.Prayer{IntercessorPhone: req.params["phone"]}
+	if err := pryr.Get(api.ddbClnt, api.cfg.Cipher, false); err != nil {
+		return 0, nil, err
+	} else if pryr.Request == "" {
+		return 0, nil, prayertexter.ErrNoActivePrayer
+	}
+
+	if err := pryr.Delete(api.ddbClnt, false); err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusOK, pryr, nil
+}
+
+func requeuePrayer(api API, req request) (int, any, error) {
+	pryr, err := prayertexter.RequeuePrayer(req.params["phone"], api.cfg, api.ddbClnt)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusOK, pryr, nil
+}
+
+func listQueue(api API, _ request) (int, any, error) {
+	pryrs, err := object.GetPrayers(api.ddbClnt, api.cfg.Cipher, true)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusOK, nonNil(pryrs), nil
+}
+
+func retryQueue(api API, _ request) (int, any, error) {
+	sent, queued, err := prayertexter.RetryQueue(api.cfg, api.ddbClnt, api.smsClnt)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusOK, map[string]int{"Queued": queued, "Sent": sent}, nil
+}
+
+func getQueued(api API, req request) (int, any, error) {
+	return prayerResponse(api, req.params["id"], true)
+}
+
+func deleteQueued(api API, req request) (int, any, error) {
+	pryr := object.Prayer{IntercessorPhone: req.params["id"]}
+	if err := pryr.Get(api.ddbClnt, api.cfg.Cipher, true); err != nil {
+		return 0, nil, err
+	} else if pryr.Request == "" {
+		return 0, nil, prayertexter.ErrNoQueuedPrayer
+	}
+
+	if err := pryr.Delete(api.ddbClnt, true); err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusOK, pryr, nil
+}
+
+func assignQueued(api API, req request) (int, any, error) {
+	body := struct {
+		IntercessorPhone string
+	}{}
+	if err := decode(req, &body); err != nil {
+		return 0, nil, err
+	} else if body.IntercessorPhone == "" {
+		return 0, nil, fmt.Errorf("%w: intercessor phone is required", errBadRequest)
+	}
+
+	pryr, err := prayertexter.AssignPrayer(req.params["id"], body.IntercessorPhone, api.cfg, api.ddbClnt,
+		api.smsClnt)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusOK, pryr, nil
+}
+
+func getStats(api API, _ request) (int, any, error) {
+	stats, err := prayertexter.GetStats(api.ddbClnt, time.Now())
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusOK, stats, nil
+}
+
+func getStuck(api API, _ request) (int, any, error) {
+	stuck, err := prayertexter.StuckStates(api.ddbClnt, time.Now())
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusOK, nonNil(stuck), nil
+}
+
+// getUsage returns the usage from the from to the to query parameter (YYYY-MM-DD), by default from
+// the first day of the current month until today.
+func getUsage(api API, req request) (int, any, error) {
+	now := time.Now().UTC()
+	from, to := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC), now
+
+	for name, day := range map[string]*time.Time{"from": &from, "to": &to} {
+		if value := req.query[name]; value != "" {
+			parsed, err := time.Parse(usageDateFmt, value)
+			if err != nil {
+				return 0, nil, fmt.Errorf("%w: %w", errBadRequest, err)
+			}
+			*day = parsed
+		}
+	}
+
+	if to.Before(from) {
+		return 0, nil, fmt.Errorf("%w: from is after to", errBadRequest)
+	} else if days := int(to.Sub(from).Hours()/24) + 1; days > maxUsageDays {
+		return 0, nil, fmt.Errorf("%w: range of %v days is longer than %v days", errBadRequest, days, maxUsageDays)
+	}
+
+	usg, err := object.GetUsageReport(api.ddbClnt, from, to)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return http.StatusOK, usg, nil
+}
+
+func prayerResponse(api API, key string, queue bool) (int, any, error) {
+	pryr := object.Prayer{IntercessorPhone: key}
+	if err := pryr.Get(api.ddbClnt, api.cfg.Cipher, queue); err != nil {
+		return 0, nil, err
+	} else if pryr.Request == "" && queue {
+		return 0, nil, prayertexter.ErrNoQueuedPrayer
+	} else if pryr.Request == "" {
+		return 0, nil, prayertexter.ErrNoActivePrayer
+	}
+
+	return http.StatusOK, pryr, nil
+}
+
+// nonNil makes empty lists marshal to [] instead of null.
+func nonNil[T any](items []T) []T {
+	if items == nil {
+		return []T{}
+	}
+
+	return items
+}
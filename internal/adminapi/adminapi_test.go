@@ -0,0 +1,449 @@
+package adminapi_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/mshort55/prayertexter/internal/adminapi"
+	"github.com/mshort55/prayertexter/internal/config"
+	"github.com/mshort55/prayertexter/internal/db"
+	"github.com/mshort55/prayertexter/internal/encryption"
+	"github.com/mshort55/prayertexter/internal/messaging"
+	"github.com/mshort55/prayertexter/internal/mock"
+	"github.com/mshort55/prayertexter/internal/object"
+)
+
+const (
+	testKey    = "test-key"
+	testSecret = "test-secret"
+)
+
+func handle(t *testing.T, ddbMock *mock.DDBConnecter, method, path, body string,
+	headers map[string]string) events.APIGatewayProxyResponse {
+	t.Helper()
+
+	cfg := config.Config{AdminAPIKeys: []string{testKey}, AdminJWTSecret: testSecret}
+	api := adminapi.New(cfg, ddbMock, &mock.TextSender{})
+	req := events.APIGatewayProxyRequest{HTTPMethod: method, Path: path, Body: body, Headers: headers}
+
+	resp, err := api.Handle(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	return resp
+}
+
+func TestHandle(t *testing.T) {
+	keyHeaders := map[string]string{"x-api-key": testKey}
+	expired := map[string]string{"Authorization": "Bearer " + adminapi.NewToken(testSecret, time.Now().Add(-time.Minute))}
+	valid := map[string]string{"Authorization": "Bearer " + adminapi.NewToken(testSecret, time.Now().Add(time.Minute))}
+
+	mem := object.Member{Name: "John Doe", Phone: "+11234567890", SetupStatus: "completed"}
+	memItem, err := attributevalue.MarshalMap(mem)
+	if err != nil {
+		t.Fatalf("failed to marshal Member: %v", err)
+	}
+
+	tests := []struct {
+		description    string
+		method         string
+		path           string
+		body           string
+		headers        map[string]string
+		getItemOutputs []*dynamodb.GetItemOutput
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			description:    "No credentials",
+			method:         http.MethodGet,
+			path:           "/admin/stats",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			description:    "Wrong api key",
+			method:         http.MethodGet,
+			path:           "/admin/stats",
+			headers:        map[string]string{"X-Api-Key": "wrong"},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			description:    "Expired token",
+			method:         http.MethodGet,
+			path:           "/admin/stats",
+			headers:        expired,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			description:    "Stats with api key",
+			method:         http.MethodGet,
+			path:           "/admin/stats",
+			headers:        keyHeaders,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"Members":0`,
+		},
+		{
+			description:    "Stats with token",
+			method:         http.MethodGet,
+			path:           "/admin/stats/",
+			headers:        valid,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			description:    "Get member with escaped phone",
+			method:         http.MethodGet,
+			path:           "/admin/members/%2B11234567890",
+			headers:        keyHeaders,
+			getItemOutputs: []*dynamodb.GetItemOutput{{Item: memItem}},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"Name":"John Doe"`,
+		},
+		{
+			description:    "Get member that does not exist",
+			method:         http.MethodGet,
+			path:           "/admin/members/+11234567890",
+			headers:        keyHeaders,
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   "no member",
+		},
+		{
+			description:    "Add member that already exists",
+			method:         http.MethodPost,
+			path:           "/admin/members",
+			body:           `{"Phone": "+11234567890"}`,
+			headers:        keyHeaders,
+			getItemOutputs: []*dynamodb.GetItemOutput{{Item: memItem}},
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			description:    "Add member without phone",
+			method:         http.MethodPost,
+			path:           "/admin/members",
+			body:           `{"Name": "John Doe"}`,
+			headers:        keyHeaders,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			description:    "Add member with a field that admins do not set",
+			method:         http.MethodPost,
+			path:           "/admin/members",
+			body:           `{"Phone": "+11234567890", "PrayerCount": 100}`,
+			headers:        keyHeaders,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "unknown field",
+		},
+		{
+			description:    "Add member with invalid phone",
+			method:         http.MethodPost,
+			path:           "/admin/members",
+			body:           `{"Phone": "1234"}`,
+			headers:        keyHeaders,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "E.164",
+		},
+		{
+			description:    "Add member of a tenant that does not exist",
+			method:         http.MethodPost,
+			path:           "/admin/members",
+			body:           `{"Phone": "+11234567890", "TenantPhone": "+15550009999"}`,
+			headers:        keyHeaders,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "no tenant",
+		},
+		{
+			description:    "Assign with malformed body",
+			method:         http.MethodPost,
+			path:           "/admin/queue/1a2b/assign",
+			body:           `{`,
+			headers:        keyHeaders,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			description:    "Empty queue is an empty list",
+			method:         http.MethodGet,
+			path:           "/admin/queue",
+			headers:        keyHeaders,
+			expectedStatus: http.StatusOK,
+			expectedBody:   "[]",
+		},
+		{
+			description:    "No pending prayers is an empty list",
+			method:         http.MethodGet,
+			path:           "/admin/pending-prayers",
+			headers:        keyHeaders,
+			expectedStatus: http.StatusOK,
+			expectedBody:   "[]",
+		},
+		{
+			description:    "Approve pending prayer that does not exist",
+			method:         http.MethodPost,
+			path:           "/admin/pending-prayers/1a2b3c4d/approve",
+			headers:        keyHeaders,
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   "no pending prayer",
+		},
+		{
+			description:    "Unknown path",
+			method:         http.MethodGet,
+			path:           "/admin/unknown",
+			headers:        keyHeaders,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			description:    "Unknown method",
+			method:         http.MethodPut,
+			path:           "/admin/stats",
+			headers:        keyHeaders,
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			description:    "Spec does not need credentials",
+			method:         http.MethodGet,
+			path:           "/admin/openapi.yaml",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "openapi: 3.0.3",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			ddbMock := &mock.DDBConnecter{}
+			for _, output := range test.getItemOutputs {
+				ddbMock.GetItemResults = append(ddbMock.GetItemResults, struct {
+					Output *dynamodb.GetItemOutput
+					Error  error
+				}{Output: output, Error: nil})
+			}
+
+			resp := handle(t, ddbMock, test.method, test.path, test.body, test.headers)
+			if resp.StatusCode != test.expectedStatus {
+				t.Errorf("expected status %v, got %v (%v)", test.expectedStatus, resp.StatusCode, resp.Body)
+			}
+
+			if !strings.Contains(resp.Body, test.expectedBody) {
+				t.Errorf("expected body to contain %v, got %v", test.expectedBody, resp.Body)
+			}
+		})
+	}
+}
+
+func TestModeratePendingPrayer(t *testing.T) {
+	cfg := config.Config{AdminAPIKeys: []string{testKey}}
+	ddbClnt := db.NewMemoryDB(object.TableKeys())
+	txtMock := &mock.TextSender{}
+	api := adminapi.New(cfg, ddbClnt, txtMock)
+
+	tnt := object.Tenant{Name: "Grace Church", Phone: "+15550009999"}
+	if err := tnt.Put(ddbClnt); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	requestor := object.Member{Name: "John Doe", Phone: "+11234567890", TenantPhone: tnt.Phone}
+	for _, id := range []string{"1a2b3c4d", "5e6f7a8b"} {
+		pryr := object.PendingPrayer{Request: "I need prayer for...", Requestor: requestor}
+		if err := pryr.Create(ddbClnt, encryption.Cipher{}, id); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+
+	moderate := func(method, path string) events.APIGatewayProxyResponse {
+		req := events.APIGatewayProxyRequest{HTTPMethod: method, Path: path,
+			Headers: map[string]string{"X-Api-Key": testKey}}
+		resp, err := api.Handle(context.Background(), req)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		return resp
+	}
+
+	// admins moderate the prayer requests of every tenant
+	resp := moderate(http.MethodPost, "/admin/pending-prayers/1a2b3c4d/reject")
+	if resp.StatusCode != http.StatusOK || !strings.Contains(resp.Body, `"Status":"REJECTED"`) {
+		t.Fatalf("expected rejected prayer request, got %v %v", resp.StatusCode, resp.Body)
+	}
+
+	pryr := object.PendingPrayer{ID: "1a2b3c4d"}
+	if err := pryr.Get(ddbClnt, encryption.Cipher{}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if pryr.Status != object.PrayerRejected || pryr.ModeratedBy != "admin api" {
+		t.Errorf("expected decision by the admin api to be saved, got %v", pryr)
+	}
+	if txtMock.SendTextCalls != 1 || txtMock.SendTextInputs[0].DestinationPhoneNumber == nil ||
+		*txtMock.SendTextInputs[0].DestinationPhoneNumber != requestor.Phone {
+		t.Errorf("expected the requestor to be told, got %v texts", txtMock.SendTextCalls)
+	}
+
+	resp = moderate(http.MethodPost, "/admin/pending-prayers/1a2b3c4d/approve")
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected already decided prayer request to be not found, got %v", resp.StatusCode)
+	}
+
+	resp = moderate(http.MethodGet, "/admin/pending-prayers")
+	if resp.StatusCode != http.StatusOK || strings.Contains(resp.Body, "1a2b3c4d") ||
+		!strings.Contains(resp.Body, "5e6f7a8b") {
+		t.Errorf("expected only the pending prayer request to be listed, got %v", resp.Body)
+	}
+}
+
+func TestAddMember(t *testing.T) {
+	ddbClnt := db.NewMemoryDB(object.TableKeys())
+	api := adminapi.New(config.Config{AdminAPIKeys: []string{testKey}}, ddbClnt, &mock.TextSender{})
+
+	tnt := object.Tenant{Name: "Grace Church", Phone: "+15550009999"}
+	if err := tnt.Put(ddbClnt); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	for body, expected := range map[string]object.Member{
+		`{"Name": "John Doe", "Phone": "+1 (123) 456-7890", "TenantPhone": "+15550009999"}`: {
+			Name: "John Doe", Phone: "+11234567890", TenantPhone: tnt.Phone},
+		`{"Name": "Jane Doe", "Phone": "+11111111111", "TenantPhone": "` + messaging.PrayerTexterPhone + `"}`: {
+			Name: "Jane Doe", Phone: "+11111111111"},
+	} {
+		req := events.APIGatewayProxyRequest{HTTPMethod: http.MethodPost, Path: "/admin/members", Body: body,
+			Headers: map[string]string{"X-Api-Key": testKey}}
+		resp, err := api.Handle(context.Background(), req)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		} else if resp.StatusCode != http.StatusCreated {
+			t.Fatalf("expected status %v, got %v (%v)", http.StatusCreated, resp.StatusCode, resp.Body)
+		}
+
+		mem := object.Member{Phone: expected.Phone}
+		if err := mem.Get(ddbClnt); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if mem.Name != expected.Name || mem.TenantPhone != expected.TenantPhone || mem.SetupStatus != "completed" {
+			t.Errorf("expected added member %v, got %v", expected, mem)
+		}
+	}
+}
+
+func TestHandleLogsRoute(t *testing.T) {
+	var buf bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+
+	ddbMock := &mock.DDBConnecter{}
+	ddbMock.GetItemResults = []struct {
+		Output *dynamodb.GetItemOutput
+		Error  error
+	}{
+		{Output: nil, Error: errors.New("get item failure")},
+	}
+
+	handle(t, ddbMock, http.MethodGet, "/admin/members/+11234567890", "", nil)
+	resp := handle(t, ddbMock, http.MethodGet, "/admin/members/+11234567890", "",
+		map[string]string{"x-api-key": testKey})
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("expected status %v, got %v", http.StatusInternalServerError, resp.StatusCode)
+	}
+
+	logs := buf.String()
+	if strings.Contains(logs, "+11234567890") {
+		t.Errorf("expected no phones in logs, got %v", logs)
+	}
+	if strings.Count(logs, "route=/members/{phone}") != 2 {
+		t.Errorf("expected both log lines to have the route pattern, got %v", logs)
+	}
+}
+
+func TestGetUsageRange(t *testing.T) {
+	api := adminapi.New(config.Config{AdminAPIKeys: []string{testKey}}, db.NewMemoryDB(object.TableKeys()),
+		&mock.TextSender{})
+
+	tests := []struct {
+		description    string
+		query          map[string]string
+		expectedStatus int
+	}{
+		{
+			description:    "Default range of the current month",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			description:    "Range of a whole leap year",
+			query:          map[string]string{"from": "2028-01-01", "to": "2028-12-31"},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			description:    "From after to",
+			query:          map[string]string{"from": "2026-10-18", "to": "2026-10-01"},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			description:    "Range longer than 366 days",
+			query:          map[string]string{"from": "2016-01-01", "to": "2026-10-01"},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			description:    "Invalid date",
+			query:          map[string]string{"from": "yesterday"},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			req := events.APIGatewayProxyRequest{HTTPMethod: http.MethodGet, Path: "/admin/stats/usage",
+				Headers: map[string]string{"x-api-key": testKey}, QueryStringParameters: test.query}
+			resp, err := api.Handle(context.Background(), req)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			if resp.StatusCode != test.expectedStatus {
+				t.Errorf("expected status %v, got %v (%v)", test.expectedStatus, resp.StatusCode, resp.Body)
+			}
+		})
+	}
+}
+
+// TestSpecRoutes makes sure that every operation in openapi.yaml is routed.
+func TestSpecRoutes(t *testing.T) {
+	spec := handle(t, &mock.DDBConnecter{}, http.MethodGet, "/admin/openapi.yaml", "", nil).Body
+	methods := map[string]string{"get": http.MethodGet, "post": http.MethodPost, "patch": http.MethodPatch,
+		"delete": http.MethodDelete}
+
+	inPaths, path, operations := false, "", 0
+	for _, line := range strings.Split(spec, "\n") {
+		switch {
+		case line == "paths:":
+			inPaths = true
+		case inPaths && line != "" && !strings.HasPrefix(line, " "):
+			inPaths = false
+		case inPaths && strings.HasPrefix(line, "  /"):
+			path = strings.TrimSuffix(strings.TrimSpace(line), ":")
+			path = strings.NewReplacer("{phone}", "+11234567890", "{id}", "1a2b").Replace(path)
+		case inPaths && methods[strings.TrimSuffix(strings.TrimSpace(line), ":")] != "" &&
+			strings.HasPrefix(line, "    ") && !strings.HasPrefix(line, "     "):
+			method := methods[strings.TrimSuffix(strings.TrimSpace(line), ":")]
+			resp := handle(t, &mock.DDBConnecter{}, method, adminapi.PathPrefix+path, "{}",
+				map[string]string{"X-Api-Key": testKey})
+
+			body := map[string]any{}
+			_ = json.Unmarshal([]byte(resp.Body), &body)
+			if body["error"] == http.StatusText(http.StatusNotFound) ||
+				body["error"] == http.StatusText(http.StatusMethodNotAllowed) {
+				t.Errorf("expected %v %v to be routed, got %v", method, path, resp.StatusCode)
+			}
+			operations++
+		}
+	}
+
+	if operations == 0 {
+		t.Errorf("expected operations in openapi.yaml, found none")
+	}
+}
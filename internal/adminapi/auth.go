@@ -0,0 +1,122 @@
+package adminapi
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mshort55/prayertexter/internal/config"
+)
+
+const (
+	apiKeyHeader        = "X-Api-Key"
+	authorizationHeader = "Authorization"
+	bearerPrefix        = "Bearer "
+)
+
+var (
+	// ErrUnauthorized is returned when a request has neither a valid api key nor a valid token.
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrInvalidToken is returned when a bearer token is malformed, not signed with the
+	// AdminJWTSecret or expired.
+	ErrInvalidToken = errors.New("invalid token")
+)
+
+// authorize checks that headers carry one of the AdminAPIKeys, or a bearer token signed with the
+// AdminJWTSecret that is valid at now. Without keys and secret, every request is rejected.
+func authorize(headers map[string]string, cfg config.Config, now time.Time) error {
+	if key := header(headers, apiKeyHeader); key != "" {
+		for _, valid := range cfg.AdminAPIKeys {
+			if valid != "" && subtle.ConstantTimeCompare([]byte(key), []byte(valid)) == 1 {
+				return nil
+			}
+		}
+
+		return ErrUnauthorized
+	}
+
+	auth := header(headers, authorizationHeader)
+	if strings.HasPrefix(auth, bearerPrefix) && cfg.AdminJWTSecret != "" {
+		return VerifyToken(strings.TrimPrefix(auth, bearerPrefix), cfg.AdminJWTSecret, now)
+	}
+
+	return ErrUnauthorized
+}
+
+// header returns the value of header name. Api gateway passes headers the way clients sent them,
+// so names are compared case insensitive.
+func header(headers map[string]string, name string) string {
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+
+	return ""
+}
+
+// VerifyToken checks that token is a JWT that is signed with HS256 and secret, and that it is valid
+// at now. Tokens have to expire, so the exp claim is required.
+func VerifyToken(token, secret string, now time.Time) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("VerifyToken: %w", ErrInvalidToken)
+	}
+
+	header := struct {
+		Alg string `json:"alg"`
+	}{}
+	if err := decodeSegment(parts[0], &header); err != nil || header.Alg != "HS256" {
+		return fmt.Errorf("VerifyToken: unsupported header: %w", ErrInvalidToken)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(signature, sign(parts[0]+"."+parts[1], secret)) {
+		return fmt.Errorf("VerifyToken: bad signature: %w", ErrInvalidToken)
+	}
+
+	claims := struct {
+		Exp int64 `json:"exp"`
+		Nbf int64 `json:"nbf"`
+	}{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return fmt.Errorf("VerifyToken: bad claims: %w", ErrInvalidToken)
+	} else if claims.Exp == 0 || now.Unix() >= claims.Exp {
+		return fmt.Errorf("VerifyToken: expired: %w", ErrInvalidToken)
+	} else if claims.Nbf != 0 && now.Unix() < claims.Nbf {
+		return fmt.Errorf("VerifyToken: not valid yet: %w", ErrInvalidToken)
+	}
+
+	return nil
+}
+
+// NewToken returns a JWT signed with HS256 and secret that expires at expires, for example for
+// scripts and the dashboard to call the admin api with.
+func NewToken(secret string, expires time.Time) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	claims := base64.RawURLEncoding.EncodeToString(fmt.Appendf(nil, `{"exp":%d}`, expires.Unix()))
+	signature := base64.RawURLEncoding.EncodeToString(sign(header+"."+claims, secret))
+
+	return header + "." + claims + "." + signature
+}
+
+func sign(data, secret string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
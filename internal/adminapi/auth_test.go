@@ -0,0 +1,58 @@
+package adminapi_test
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mshort55/prayertexter/internal/adminapi"
+)
+
+func TestVerifyToken(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	valid := adminapi.NewToken(testSecret, now.Add(time.Hour))
+	parts := strings.Split(valid, ".")
+	encode := base64.RawURLEncoding.EncodeToString
+
+	tests := []struct {
+		description string
+		token       string
+		secret      string
+		expectedErr bool
+	}{
+		{description: "Valid token", token: valid, secret: testSecret},
+		{description: "Wrong secret", token: valid, secret: "other", expectedErr: true},
+		{
+			description: "Expired token",
+			token:       adminapi.NewToken(testSecret, now.Add(-time.Second)),
+			secret:      testSecret,
+			expectedErr: true,
+		},
+		{
+			description: "Unsigned token",
+			token:       encode([]byte(`{"alg":"none"}`)) + "." + parts[1] + ".",
+			secret:      testSecret,
+			expectedErr: true,
+		},
+		{
+			description: "Changed claims",
+			token:       parts[0] + "." + encode([]byte(`{"exp":9999999999}`)) + "." + parts[2],
+			secret:      testSecret,
+			expectedErr: true,
+		},
+		{description: "Malformed token", token: "abc", secret: testSecret, expectedErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			err := adminapi.VerifyToken(test.token, test.secret, now)
+			if test.expectedErr && !errors.Is(err, adminapi.ErrInvalidToken) {
+				t.Errorf("expected ErrInvalidToken, got %v", err)
+			} else if !test.expectedErr && err != nil {
+				t.Errorf("unexpected error %v", err)
+			}
+		})
+	}
+}
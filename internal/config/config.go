@@ -0,0 +1,285 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+
+	"github.com/mshort55/prayertexter/internal/db"
+	"github.com/mshort55/prayertexter/internal/encryption"
+)
+
+// Config holds per deployment settings. Settings are loaded once at startup from an optional JSON
+// config file and an optional Config item in the General table, with the ddb item taking priority.
+// The zero value is a valid Config that uses all defaults.
+type Config struct {
+	// AdminPrefix starts the text messages of Admins that are admin commands. "" means
+	// DefaultAdminPrefix
+	AdminPrefix string `json:"adminPrefix"`
+	// AdminAPIKeys are the keys that authorize requests to the admin api, sent as the X-Api-Key
+	// header. No keys and no AdminJWTSecret means the admin api rejects every request
+	AdminAPIKeys []string `json:"adminApiKeys"`
+	// AdminJWTSecret is the secret that HS256 signed bearer tokens to the admin api are verified with
+	AdminJWTSecret string `json:"adminJwtSecret"`
+	// Admins are the phones that can run admin commands by text message
+	Admins []string `json:"admins"`
+	// Cipher encrypts prayer requests at rest. It is not loaded but set at startup from
+	// EncryptionKeyID or EncryptionKey. The zero value does not encrypt
+	Cipher encryption.Cipher `json:"-" dynamodbav:"-"`
+	// CommandRateLimit limits how many messages other than prayer requests a phone may send
+	CommandRateLimit RateLimit `json:"commandRateLimit"`
+	// ContentFilters are the filters that every prayer request goes through, in order. nil means
+	// the default profanity filter, an empty list means no filters
+	ContentFilters []ContentFilter `json:"contentFilters"`
+	// DashboardUsers are the user names and passwords that can sign in to the dashboard with basic
+	// auth. No users means the dashboard rejects every request
+	DashboardUsers map[string]string `json:"dashboardUsers"`
+	// EncryptNames encrypts member names in Prayers in addition to prayer requests
+	EncryptNames bool `json:"encryptNames"`
+	// EncryptionKey is a base64 encoded 256 bit key that encrypts prayer requests. It is meant
+	// for sam local testing only, EncryptionKeyID takes priority
+	EncryptionKey string `json:"encryptionKey"`
+	// EncryptionKeyID is the ID or ARN of the kms key that encrypts prayer requests
+	EncryptionKeyID string `json:"encryptionKeyId"`
+	// MaxPrayerSegments is the maximum number of sms segments that a prayer request may take up
+	// when it gets sent to an intercessor. Longer prayer requests are rejected. 0 means
+	// DefaultMaxPrayerSegments
+	MaxPrayerSegments int `json:"maxPrayerSegments"`
+	// ModeratePrayers holds prayer requests until a moderator approves them
+	ModeratePrayers bool `json:"moderatePrayers"`
+	// Moderators are the phones that get asked to approve held prayer requests and that can
+	// approve, reject and block by text message
+	Moderators []string `json:"moderators"`
+	// MonthlySegmentBudget is the number of sms segments that may be sent each month before
+	// non-essential sends (such as announcements) are suspended. Replies to members are always
+	// sent. 0 means no budget. This needs TrackUsage, a budget without it is a config error
+	MonthlySegmentBudget int `json:"monthlySegmentBudget"`
+	// PhoneHashKey is the secret key that phone numbers in archived prayers and states are hashed
+	// with. Without it, archived prayers do not identify members, so they can not be exported or
+	// erased for a member
+	PhoneHashKey string `json:"phoneHashKey"`
+	// PrayerRetentionDays is how long completed prayers are archived with their prayer request
+	// text. Counts of completed prayers are kept forever. 0 means DefaultPrayerRetentionDays
+	PrayerRetentionDays int `json:"prayerRetentionDays"`
+	// Privacy controls how much of phone numbers and message bodies is written to logs and to the
+	// StateTracker. "" means the default mask level. The strict level needs PhoneHashKey
+	Privacy string `json:"privacy"`
+	// PrayerRateLimit limits how many prayer requests a member may send
+	PrayerRateLimit RateLimit `json:"prayerRateLimit"`
+	// RateLimitBlockMinutes is how long a phone is blocked for once it has sent
+	// RateLimitBlockThreshold rate limited messages in a row. 0 means phones are never blocked
+	RateLimitBlockMinutes   int `json:"rateLimitBlockMinutes"`
+	RateLimitBlockThreshold int `json:"rateLimitBlockThreshold"`
+	// SegmentPrice is the price of a single sms segment. It is only used to estimate costs in
+	// usage reports
+	SegmentPrice float64 `json:"segmentPrice"`
+	// TemplateVars overrides variables used by message templates, for example SupportEmail
+	TemplateVars map[string]string `json:"templateVars"`
+	// Templates overrides message templates by template name
+	Templates map[string]string `json:"templates"`
+	// TrackUsage enables daily and monthly counters of all sent text messages
+	TrackUsage bool `json:"trackUsage"`
+}
+
+// ContentFilter configures a single filter of the prayer request content filter pipeline. Type is
+// the kind of content to look for and Action is what happens to prayer requests that contain it.
+// Allow and Deny remove words from or add words to the default word list of a words filter.
+type ContentFilter struct {
+	Action string   `json:"action"`
+	Allow  []string `json:"allow"`
+	Deny   []string `json:"deny"`
+	Type   string   `json:"type"`
+}
+
+// RateLimit is a token bucket. A phone can send Capacity messages at once and gets to send one
+// more message every RefillSeconds. The zero value means no limit.
+type RateLimit struct {
+	Capacity      int `json:"capacity"`
+	RefillSeconds int `json:"refillSeconds"`
+}
+
+const (
+	ConfigAttribute = "Key"
+	ConfigKey       = "Config"
+	ConfigTable     = "General"
+	// ConfigFileEnv is the environmental variable that holds the path of the JSON config file
+	ConfigFileEnv              = "PRAYERTEXTER_CONFIG_FILE"
+	DefaultAdminPrefix         = "#admin"
+	DefaultMaxPrayerSegments   = 10
+	DefaultPrayerRetentionDays = 90
+)
+
+// Schema is the schema version of the Config item, see db.Versioned.
+func (c *Config) Schema() db.Schema { return db.Schema{Version: 1} }
+
+func Load(ddbClnt db.DDBConnecter) (Config, error) {
+	cfg := Config{}
+
+	if file := os.Getenv(ConfigFileEnv); file != "" {
+		fileCfg, err := loadFile(file)
+		if err != nil {
+			return cfg, fmt.Errorf("config load: %w", err)
+		}
+		cfg.merge(fileCfg)
+	}
+
+	ddbCfg, err := db.GetDdbObject[Config](ddbClnt, ConfigAttribute, ConfigKey, ConfigTable)
+	if err != nil {
+		return cfg, fmt.Errorf("config load: %w", err)
+	}
+	cfg.merge(*ddbCfg)
+
+	return cfg, nil
+}
+
+func loadFile(file string) (Config, error) {
+	cfg := Config{}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return cfg, fmt.Errorf("loadFile: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("loadFile failed unmarshal: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// GetAdminPrefix returns AdminPrefix, or DefaultAdminPrefix if it is not set.
+func (c Config) GetAdminPrefix() string {
+	if c.AdminPrefix != "" {
+		return c.AdminPrefix
+	}
+
+	return DefaultAdminPrefix
+}
+
+// GetMaxPrayerSegments returns MaxPrayerSegments, or DefaultMaxPrayerSegments if it is not set.
+func (c Config) GetMaxPrayerSegments() int {
+	if c.MaxPrayerSegments > 0 {
+		return c.MaxPrayerSegments
+	}
+
+	return DefaultMaxPrayerSegments
+}
+
+func (c Config) GetPrayerRetentionDays() int {
+	if c.PrayerRetentionDays > 0 {
+		return c.PrayerRetentionDays
+	}
+
+	return DefaultPrayerRetentionDays
+}
+
+// IsEnabled returns true if r limits messages.
+func (r RateLimit) IsEnabled() bool {
+	return r.Capacity > 0 && r.RefillSeconds > 0
+}
+
+// merge copies every setting that is set in other into c.
+func (c *Config) merge(other Config) {
+	if other.AdminPrefix != "" {
+		c.AdminPrefix = other.AdminPrefix
+	}
+
+	if other.AdminAPIKeys != nil {
+		c.AdminAPIKeys = other.AdminAPIKeys
+	}
+
+	if other.AdminJWTSecret != "" {
+		c.AdminJWTSecret = other.AdminJWTSecret
+	}
+
+	if other.Admins != nil {
+		c.Admins = other.Admins
+	}
+
+	if other.CommandRateLimit != (RateLimit{}) {
+		c.CommandRateLimit = other.CommandRateLimit
+	}
+
+	if other.PrayerRateLimit != (RateLimit{}) {
+		c.PrayerRateLimit = other.PrayerRateLimit
+	}
+
+	if other.PhoneHashKey != "" {
+		c.PhoneHashKey = other.PhoneHashKey
+	}
+
+	if other.PrayerRetentionDays != 0 {
+		c.PrayerRetentionDays = other.PrayerRetentionDays
+	}
+
+	if other.Privacy != "" {
+		c.Privacy = other.Privacy
+	}
+
+	if other.RateLimitBlockMinutes != 0 {
+		c.RateLimitBlockMinutes = other.RateLimitBlockMinutes
+	}
+
+	if other.RateLimitBlockThreshold != 0 {
+		c.RateLimitBlockThreshold = other.RateLimitBlockThreshold
+	}
+
+	if other.ContentFilters != nil {
+		c.ContentFilters = other.ContentFilters
+	}
+
+	if other.DashboardUsers != nil {
+		c.DashboardUsers = other.DashboardUsers
+	}
+
+	if other.EncryptNames {
+		c.EncryptNames = true
+	}
+
+	if other.EncryptionKey != "" {
+		c.EncryptionKey = other.EncryptionKey
+	}
+
+	if other.EncryptionKeyID != "" {
+		c.EncryptionKeyID = other.EncryptionKeyID
+	}
+
+	if other.MaxPrayerSegments != 0 {
+		c.MaxPrayerSegments = other.MaxPrayerSegments
+	}
+
+	if other.ModeratePrayers {
+		c.ModeratePrayers = true
+	}
+
+	if other.Moderators != nil {
+		c.Moderators = other.Moderators
+	}
+
+	if other.MonthlySegmentBudget != 0 {
+		c.MonthlySegmentBudget = other.MonthlySegmentBudget
+	}
+
+	if other.SegmentPrice != 0 {
+		c.SegmentPrice = other.SegmentPrice
+	}
+
+	if other.TrackUsage {
+		c.TrackUsage = true
+	}
+
+	if other.TemplateVars != nil {
+		if c.TemplateVars == nil {
+			c.TemplateVars = map[string]string{}
+		}
+		maps.Copy(c.TemplateVars, other.TemplateVars)
+	}
+
+	if other.Templates != nil {
+		if c.Templates == nil {
+			c.Templates = map[string]string{}
+		}
+		maps.Copy(c.Templates, other.Templates)
+	}
+}
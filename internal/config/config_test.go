@@ -0,0 +1,75 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/mshort55/prayertexter/internal/config"
+	"github.com/mshort55/prayertexter/internal/mock"
+)
+
+func TestLoad(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.json")
+	data := `{
+		"contentFilters": [{"type": "words", "action": "reject", "deny": ["darn"]}],
+		"maxPrayerSegments": 5,
+		"prayerRateLimit": {"capacity": 3, "refillSeconds": 3600},
+		"templates": {"help": "file help", "post": "file post"},
+		"templateVars": {"SupportEmail": "file@example.com"}
+	}`
+	if err := os.WriteFile(file, []byte(data), 0o600); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	t.Setenv(config.ConfigFileEnv, file)
+
+	ddbMock := &mock.DDBConnecter{}
+	ddbMock.GetItemResults = []struct {
+		Output *dynamodb.GetItemOutput
+		Error  error
+	}{
+		{
+			Output: &dynamodb.GetItemOutput{
+				Item: map[string]types.AttributeValue{
+					"Key": &types.AttributeValueMemberS{Value: config.ConfigKey},
+					"Templates": &types.AttributeValueMemberM{
+						Value: map[string]types.AttributeValue{
+							"help": &types.AttributeValueMemberS{Value: "ddb help"},
+						},
+					},
+				},
+			},
+			Error: nil,
+		},
+	}
+
+	cfg, err := config.Load(ddbMock)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// ddb settings take priority over file settings
+	expected := config.Config{
+		ContentFilters:    []config.ContentFilter{{Action: "reject", Deny: []string{"darn"}, Type: "words"}},
+		MaxPrayerSegments: 5,
+		PrayerRateLimit:   config.RateLimit{Capacity: 3, RefillSeconds: 3600},
+		TemplateVars:      map[string]string{"SupportEmail": "file@example.com"},
+		Templates:         map[string]string{"help": "ddb help", "post": "file post"},
+	}
+	if !reflect.DeepEqual(cfg, expected) {
+		t.Errorf("expected Config %v, got %v", expected, cfg)
+	}
+
+	if (config.Config{}).GetMaxPrayerSegments() != config.DefaultMaxPrayerSegments {
+		t.Errorf("expected default max prayer segments %v, got %v", config.DefaultMaxPrayerSegments,
+			(config.Config{}).GetMaxPrayerSegments())
+	}
+
+	t.Setenv(config.ConfigFileEnv, filepath.Join(t.TempDir(), "missing.json"))
+	if _, err := config.Load(ddbMock); err == nil {
+		t.Errorf("expected error for missing config file, got nil")
+	}
+}
@@ -0,0 +1,194 @@
+package dashboard
+
+import (
+	"cmp"
+	"crypto/subtle"
+	"embed"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/mshort55/prayertexter/internal/config"
+	"github.com/mshort55/prayertexter/internal/db"
+	"github.com/mshort55/prayertexter/internal/object"
+	"github.com/mshort55/prayertexter/internal/prayertexter"
+)
+
+//go:embed templates
+var templates embed.FS
+
+// Dashboard is a read only overview of members, prayers and the prayer queue for leaders. Every
+// request needs basic auth with one of the DashboardUsers.
+type Dashboard struct {
+	cfg     config.Config
+	ddbClnt db.DDBConnecter
+	tmpl    *template.Template
+}
+
+// Overview is everything that the dashboard shows.
+type Overview struct {
+	Generated    time.Time
+	Intercessors []IntercessorLoad
+	Members      []object.Member
+	OldestQueued time.Duration
+	Queue        []QueuedPrayer
+	Stats        prayertexter.Stats
+	Stuck        []object.State
+}
+
+// IntercessorLoad is how many prayers an intercessor received this week compared to their weekly
+// prayer limit.
+type IntercessorLoad struct {
+	ActivePrayer bool
+	Member       object.Member
+}
+
+// QueuedPrayer is a queued Prayer and how long it has been queued. Age is 0 for prayers that were
+// queued before queue times were saved.
+type QueuedPrayer struct {
+	Age    time.Duration
+	Prayer object.Prayer
+}
+
+func New(cfg config.Config, ddbClnt db.DDBConnecter) (Dashboard, error) {
+	tmpl, err := template.New("").Funcs(template.FuncMap{"age": formatAge}).ParseFS(templates, "templates/*")
+	if err != nil {
+		return Dashboard{}, fmt.Errorf("dashboard new: %w", err)
+	}
+
+	return Dashboard{cfg: cfg, ddbClnt: ddbClnt, tmpl: tmpl}, nil
+}
+
+// ServeHTTP renders the overview for GET requests of any path, so the dashboard works the same
+// locally and under the /dashboard path of api gateway.
+func (d Dashboard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !d.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="prayertexter", charset="UTF-8"`)
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	} else if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	overview, err := Load(d.cfg, d.ddbClnt, time.Now())
+	if err != nil {
+		slog.ErrorContext(r.Context(), "dashboard: failed to load overview", "error", err.Error())
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := d.tmpl.ExecuteTemplate(w, "index.html", overview); err != nil {
+		slog.ErrorContext(r.Context(), "dashboard: failed to render overview", "error", err.Error())
+	}
+}
+
+func (d Dashboard) authorized(r *http.Request) bool {
+	user, password, ok := r.BasicAuth()
+	if !ok {
+		return false
+	}
+
+	expected, ok := d.cfg.DashboardUsers[user]
+	return ok && expected != "" && subtle.ConstantTimeCompare([]byte(password), []byte(expected)) == 1
+}
+
+// Load collects the Overview at now. This scans the Members, ActivePrayers and PrayersQueue
+// tables once each.
+func Load(cfg config.Config, ddbClnt db.DDBConnecter, now time.Time) (Overview, error) {
+	overview := Overview{Generated: now}
+
+	mems, err := db.ScanDdbObjects[object.Member](ddbClnt, object.MemberTable, db.Condition{})
+	if err != nil {
+		return overview, fmt.Errorf("dashboard load: %w", err)
+	}
+
+	active, err := object.GetPrayers(ddbClnt, cfg.Cipher, false)
+	if err != nil {
+		return overview, fmt.Errorf("dashboard load: %w", err)
+	}
+
+	queued, err := object.GetPrayers(ddbClnt, cfg.Cipher, true)
+	if err != nil {
+		return overview, fmt.Errorf("dashboard load: %w", err)
+	}
+
+	if overview.Stuck, err = prayertexter.StuckStates(ddbClnt, now); err != nil {
+		return overview, fmt.Errorf("dashboard load: %w", err)
+	}
+
+	completed := object.PrayerStats{Key: object.PrayerStatsKey(now)}
+	if err := completed.Get(ddbClnt); err != nil {
+		return overview, fmt.Errorf("dashboard load: %w", err)
+	}
+
+	praying := map[string]bool{}
+	for _, pryr := range active {
+		praying[pryr.IntercessorPhone] = true
+	}
+
+	slices.SortFunc(mems, func(a, b object.Member) int { return cmp.Compare(a.Name, b.Name) })
+	for _, mem := range mems {
+		if mem.SetupStatus != "completed" {
+			continue
+		}
+		overview.Stats.Members++
+		if mem.Intercessor {
+			overview.Stats.Intercessors++
+			overview.Intercessors = append(overview.Intercessors,
+				IntercessorLoad{ActivePrayer: praying[mem.Phone], Member: mem})
+		}
+	}
+	overview.Members = mems
+
+	// the busiest intercessors come first
+	slices.SortStableFunc(overview.Intercessors, func(a, b IntercessorLoad) int {
+		return cmp.Compare(b.Percent(), a.Percent())
+	})
+
+	for _, pryr := range queued {
+		qp := QueuedPrayer{Prayer: pryr}
+		if queuedTime, err := time.Parse(time.RFC3339, pryr.QueuedTime); err == nil {
+			qp.Age = now.Sub(queuedTime)
+		}
+		overview.Queue = append(overview.Queue, qp)
+		overview.OldestQueued = max(overview.OldestQueued, qp.Age)
+	}
+	slices.SortStableFunc(overview.Queue, func(a, b QueuedPrayer) int { return cmp.Compare(b.Age, a.Age) })
+
+	overview.Stats.ActivePrayers = len(active)
+	overview.Stats.QueuedPrayers = len(queued)
+	overview.Stats.CompletedPrayers = completed.Completed
+
+	return overview, nil
+}
+
+// Percent returns the share of the weekly prayer limit that is used up, capped at 100.
+func (l IntercessorLoad) Percent() int {
+	if l.Member.WeeklyPrayerLimit <= 0 {
+		return 100
+	}
+
+	return min(100, l.Member.PrayerCount*100/l.Member.WeeklyPrayerLimit)
+}
+
+// formatAge formats d in days, hours and minutes, for example "2d 3h" or "45m".
+func formatAge(d time.Duration) string {
+	if d <= 0 {
+		return "unknown"
+	}
+
+	days, hours, minutes := int(d.Hours())/24, int(d.Hours())%24, int(d.Minutes())%60
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	default:
+		return fmt.Sprintf("%dm", minutes)
+	}
+}
@@ -0,0 +1,147 @@
+package dashboard_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/mshort55/prayertexter/internal/config"
+	"github.com/mshort55/prayertexter/internal/dashboard"
+	"github.com/mshort55/prayertexter/internal/mock"
+	"github.com/mshort55/prayertexter/internal/object"
+)
+
+func scanItems(t *testing.T, objs ...any) struct {
+	Output *dynamodb.ScanOutput
+	Error  error
+} {
+	out := &dynamodb.ScanOutput{}
+	for _, obj := range objs {
+		item, err := attributevalue.MarshalMap(obj)
+		if err != nil {
+			t.Fatalf("failed to marshal %v: %v", obj, err)
+		}
+		out.Items = append(out.Items, item)
+	}
+
+	return struct {
+		Output *dynamodb.ScanOutput
+		Error  error
+	}{Output: out, Error: nil}
+}
+
+// newDdbMock returns a mock with members, one active prayer and two queued prayers, one of them
+// queued before queue times were saved.
+func newDdbMock(t *testing.T, now time.Time) *mock.DDBConnecter {
+	busy := object.Member{Intercessor: true, Name: "Busy", Phone: "+11111111111", PrayerCount: 5,
+		SetupStatus: "completed", WeeklyPrayerLimit: 5}
+	idle := object.Member{Intercessor: true, Name: "Idle", Phone: "+12222222222", PrayerCount: 1,
+		SetupStatus: "completed", WeeklyPrayerLimit: 4}
+	requestor := object.Member{Name: "John Doe", Phone: "+11234567890", SetupStatus: "completed"}
+	signingUp := object.Member{Phone: "+13333333333", SetupStatus: "in-progress"}
+
+	ddbMock := &mock.DDBConnecter{}
+	ddbMock.ScanResults = append(ddbMock.ScanResults,
+		scanItems(t, idle, requestor, busy, signingUp),
+		scanItems(t, object.Prayer{IntercessorPhone: busy.Phone, Request: "pray for me", Requestor: requestor}),
+		scanItems(t,
+			object.Prayer{IntercessorPhone: "1a2b", Request: "old request", Requestor: requestor},
+			object.Prayer{IntercessorPhone: "3c4d", QueuedTime: now.Add(-26 * time.Hour).Format(time.RFC3339),
+				Request: "new request", Requestor: requestor},
+		),
+	)
+
+	return ddbMock
+}
+
+func TestLoad(t *testing.T) {
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	overview, err := dashboard.Load(config.Config{}, newDdbMock(t, now), now)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if overview.Stats.Members != 3 || overview.Stats.Intercessors != 2 || overview.Stats.ActivePrayers != 1 ||
+		overview.Stats.QueuedPrayers != 2 {
+		t.Errorf("expected 3 members, 2 intercessors, 1 active and 2 queued prayers, got %+v", overview.Stats)
+	}
+
+	if len(overview.Intercessors) != 2 || overview.Intercessors[0].Member.Name != "Busy" ||
+		!overview.Intercessors[0].ActivePrayer || overview.Intercessors[0].Percent() != 100 ||
+		overview.Intercessors[1].Percent() != 25 {
+		t.Errorf("expected Busy at 100%% with an active prayer before Idle at 25%%, got %+v", overview.Intercessors)
+	}
+
+	if overview.OldestQueued != 26*time.Hour || overview.Queue[0].Prayer.Request != "new request" ||
+		overview.Queue[1].Age != 0 {
+		t.Errorf("expected oldest queued prayer of 26h first and the prayer without queue time last, got %+v",
+			overview.Queue)
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	cfg := config.Config{DashboardUsers: map[string]string{"leader": "secret"}}
+	now := time.Now()
+
+	tests := []struct {
+		description    string
+		user           string
+		password       string
+		method         string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{description: "No credentials", method: http.MethodGet, expectedStatus: http.StatusUnauthorized},
+		{
+			description:    "Wrong password",
+			user:           "leader",
+			password:       "wrong",
+			method:         http.MethodGet,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			description:    "Overview",
+			user:           "leader",
+			password:       "secret",
+			method:         http.MethodGet,
+			expectedStatus: http.StatusOK,
+			expectedBody:   "<td>5 / 5</td>",
+		},
+		{
+			description:    "Read only",
+			user:           "leader",
+			password:       "secret",
+			method:         http.MethodPost,
+			expectedStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			dash, err := dashboard.New(cfg, newDdbMock(t, now))
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			req := httptest.NewRequest(test.method, "/", nil)
+			if test.user != "" {
+				req.SetBasicAuth(test.user, test.password)
+			}
+			rec := httptest.NewRecorder()
+
+			dash.ServeHTTP(rec, req)
+			if rec.Code != test.expectedStatus {
+				t.Errorf("expected status %v, got %v", test.expectedStatus, rec.Code)
+			}
+
+			if !strings.Contains(rec.Body.String(), test.expectedBody) {
+				t.Errorf("expected body to contain %v, got %v", test.expectedBody, rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package dashboard
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// responseBuffer is an http.ResponseWriter that keeps the response for api gateway.
+type responseBuffer struct {
+	body   bytes.Buffer
+	header http.Header
+	status int
+}
+
+func (b *responseBuffer) Header() http.Header {
+	return b.header
+}
+
+func (b *responseBuffer) Write(data []byte) (int, error) {
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+
+	return b.body.Write(data)
+}
+
+func (b *responseBuffer) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+// HandleLambda serves an api gateway request with ServeHTTP.
+func (d Dashboard) HandleLambda(ctx context.Context, req events.APIGatewayProxyRequest) (
+	events.APIGatewayProxyResponse, error) {
+	r, err := http.NewRequestWithContext(ctx, req.HTTPMethod, req.Path, strings.NewReader(req.Body))
+	if err != nil {
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusBadRequest}, fmt.Errorf("HandleLambda: %w", err)
+	}
+	for key, value := range req.Headers {
+		r.Header.Set(key, value)
+	}
+
+	buf := &responseBuffer{header: http.Header{}}
+	d.ServeHTTP(buf, r)
+
+	headers := map[string]string{}
+	for key := range buf.header {
+		headers[key] = buf.header.Get(key)
+	}
+
+	return events.APIGatewayProxyResponse{StatusCode: buf.status, Headers: headers, Body: buf.body.String()}, nil
+}
@@ -0,0 +1,157 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// BatchGetSize and BatchWriteSize are the most items that ddb takes in one BatchGetItem and
+// BatchWriteItem request. Items that ddb returns as unprocessed, for example because of
+// throttling, are sent again up to MaxBatchAttempts times in all, waiting BatchRetryDelay before
+// the first retry and twice as long before every next one.
+const (
+	BatchGetSize     = 100
+	BatchRetryDelay  = 50 * time.Millisecond
+	BatchWriteSize   = 25
+	MaxBatchAttempts = 6
+)
+
+// ErrUnprocessed is returned for items that were still unprocessed after MaxBatchAttempts.
+var ErrUnprocessed = errors.New("items were not processed")
+
+// BatchGetObjects returns the objects of table whose hash key attr is one of keys, in the order of
+// keys. Keys without an object are left out, and duplicate keys are only read once. Keys are read
+// in chunks of BatchGetSize, and every chunk is read even if others fail, in which case the error
+// of every failed chunk is returned together.
+func BatchGetObjects[T any](ddbClnt DDBConnecter, attr, table string, keys []string) ([]T, error) {
+	seen := map[string]bool{}
+	keys = slices.DeleteFunc(slices.Clone(keys), func(key string) bool {
+		dup := seen[key]
+		seen[key] = true
+		return dup
+	})
+
+	found := map[string]map[string]types.AttributeValue{}
+	var errs []error
+	for chunk := range slices.Chunk(keys, BatchGetSize) {
+		requestKeys := make([]map[string]types.AttributeValue, len(chunk))
+		for i, key := range chunk {
+			requestKeys[i] = map[string]types.AttributeValue{attr: &types.AttributeValueMemberS{Value: key}}
+		}
+
+		items, err := batchGet(ddbClnt, table, requestKeys)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		for _, item := range items {
+			if key, ok := item[attr].(*types.AttributeValueMemberS); ok {
+				found[key.Value] = item
+			}
+		}
+	}
+
+	// batch responses do not keep the order of the keys
+	var items []map[string]types.AttributeValue
+	for _, key := range keys {
+		if item, ok := found[key]; ok {
+			items = append(items, item)
+		}
+	}
+
+	objects, err := unmarshalItems[T](items)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("BatchGetObjects: %w", err)
+	}
+
+	return objects, nil
+}
+
+// batchGet reads one chunk of keys and retries the unprocessed ones.
+func batchGet(ddbClnt DDBConnecter, table string,
+	keys []map[string]types.AttributeValue) ([]map[string]types.AttributeValue, error) {
+	var items []map[string]types.AttributeValue
+	request := map[string]types.KeysAndAttributes{table: {Keys: keys}}
+
+	for attempt := range MaxBatchAttempts {
+		if attempt > 0 {
+			time.Sleep(BatchRetryDelay << (attempt - 1))
+		}
+
+		resp, err := ddbClnt.BatchGetItem(context.TODO(), &dynamodb.BatchGetItemInput{RequestItems: request})
+		if err != nil {
+			return items, err
+		}
+		items = append(items, resp.Responses[table]...)
+
+		request = resp.UnprocessedKeys
+		if len(request[table].Keys) == 0 {
+			return items, nil
+		}
+	}
+
+	return items, fmt.Errorf("%d keys: %w", len(request[table].Keys), ErrUnprocessed)
+}
+
+// BatchPutObjects puts objects into table in chunks of BatchWriteSize. The objects must have
+// different keys, and are put without conditions, so a put replaces the item like PutDdbObject
+// does. Every chunk is put even if others fail, in which case the error of every failed chunk is
+// returned together.
+func BatchPutObjects[T any](ddbClnt DDBConnecter, table string, objects []T) error {
+	var errs []error
+
+	for chunk := range slices.Chunk(objects, BatchWriteSize) {
+		requests := make([]types.WriteRequest, 0, len(chunk))
+		for i := range chunk {
+			item, err := marshalObject(&chunk[i])
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed marshal: %w", err))
+				continue
+			}
+			requests = append(requests, types.WriteRequest{PutRequest: &types.PutRequest{Item: item}})
+		}
+
+		if len(requests) > 0 {
+			if err := batchWrite(ddbClnt, table, requests); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("BatchPutObjects: %w", err)
+	}
+
+	return nil
+}
+
+// batchWrite writes one chunk of requests and retries the unprocessed ones.
+func batchWrite(ddbClnt DDBConnecter, table string, requests []types.WriteRequest) error {
+	request := map[string][]types.WriteRequest{table: requests}
+
+	for attempt := range MaxBatchAttempts {
+		if attempt > 0 {
+			time.Sleep(BatchRetryDelay << (attempt - 1))
+		}
+
+		resp, err := ddbClnt.BatchWriteItem(context.TODO(), &dynamodb.BatchWriteItemInput{RequestItems: request})
+		if err != nil {
+			return err
+		}
+
+		request = resp.UnprocessedItems
+		if len(request[table]) == 0 {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%d items: %w", len(request[table]), ErrUnprocessed)
+}
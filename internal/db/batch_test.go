@@ -0,0 +1,150 @@
+package db_test
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/mshort55/prayertexter/internal/db"
+	"github.com/mshort55/prayertexter/internal/mock"
+	"github.com/mshort55/prayertexter/internal/object"
+)
+
+func TestBatchObjects(t *testing.T) {
+	// MemoryDB refuses requests that are larger than ddb allows, so this checks the chunking
+	memDB := db.NewMemoryDB(object.TableKeys())
+
+	var mems []object.Member
+	for i := range 60 {
+		mems = append(mems, object.Member{Name: fmt.Sprint("Member", i), Phone: fmt.Sprintf("+1%010d", i)})
+	}
+	if err := db.BatchPutObjects(memDB, object.MemberTable, mems); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// 150 keys in reverse order, with duplicates and keys of members that do not exist
+	var keys []string
+	for i := 74; i >= 0; i-- {
+		keys = append(keys, fmt.Sprintf("+1%010d", i), fmt.Sprintf("+1%010d", i%30))
+	}
+
+	got, err := db.BatchGetObjects[object.Member](memDB, object.MemberAttribute, object.MemberTable, keys)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// every member once, in the order that its key first appears (phones have the same length)
+	var expected []string
+	seen := map[string]bool{}
+	for _, key := range keys {
+		if key <= mems[len(mems)-1].Phone {
+			if !seen[key] {
+				expected = append(expected, key)
+			}
+			seen[key] = true
+		}
+	}
+	if got := memberPhones(got); len(expected) != len(mems) || !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestBatchPutObjectsUnprocessed(t *testing.T) {
+	mems := []object.Member{{Phone: "+11111111111"}, {Phone: "+12222222222"}}
+	unprocessed := &dynamodb.BatchWriteItemOutput{
+		UnprocessedItems: map[string][]types.WriteRequest{
+			object.MemberTable: {
+				{PutRequest: &types.PutRequest{Item: map[string]types.AttributeValue{
+					"Phone": &types.AttributeValueMemberS{Value: "+12222222222"},
+				}}},
+			},
+		},
+	}
+
+	t.Run("Retried", func(t *testing.T) {
+		ddbMock := &mock.DDBConnecter{}
+		ddbMock.BatchWriteItemResults = []struct {
+			Output *dynamodb.BatchWriteItemOutput
+			Error  error
+		}{
+			{Output: unprocessed},
+			{Output: &dynamodb.BatchWriteItemOutput{}},
+		}
+
+		if err := db.BatchPutObjects(ddbMock, object.MemberTable, mems); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if ddbMock.BatchWriteItemCalls != 2 || len(ddbMock.BatchWriteItemInputs[1].RequestItems[object.MemberTable]) != 1 {
+			t.Errorf("expected the unprocessed item to be sent again, got %v calls", ddbMock.BatchWriteItemCalls)
+		}
+	})
+
+	t.Run("Gave up", func(t *testing.T) {
+		ddbMock := &mock.DDBConnecter{}
+		for range db.MaxBatchAttempts {
+			ddbMock.BatchWriteItemResults = append(ddbMock.BatchWriteItemResults, struct {
+				Output *dynamodb.BatchWriteItemOutput
+				Error  error
+			}{Output: unprocessed})
+		}
+
+		if err := db.BatchPutObjects(ddbMock, object.MemberTable, mems); !errors.Is(err, db.ErrUnprocessed) {
+			t.Errorf("expected ErrUnprocessed, got %v", err)
+		}
+		if ddbMock.BatchWriteItemCalls != db.MaxBatchAttempts {
+			t.Errorf("expected %v attempts, got %v", db.MaxBatchAttempts, ddbMock.BatchWriteItemCalls)
+		}
+	})
+}
+
+func TestBatchObjectsErrors(t *testing.T) {
+	mems := make([]object.Member, db.BatchWriteSize+1)
+	for i := range mems {
+		mems[i].Phone = fmt.Sprintf("+1%010d", i)
+	}
+
+	errFirst, errSecond := errors.New("first chunk failure"), errors.New("second chunk failure")
+	ddbMock := &mock.DDBConnecter{}
+	ddbMock.BatchWriteItemResults = []struct {
+		Output *dynamodb.BatchWriteItemOutput
+		Error  error
+	}{
+		{Error: errFirst},
+		{Error: errSecond},
+	}
+
+	// every chunk is tried and every error is returned
+	err := db.BatchPutObjects(ddbMock, object.MemberTable, mems)
+	if !errors.Is(err, errFirst) || !errors.Is(err, errSecond) {
+		t.Errorf("expected errors of both chunks, got %v", err)
+	}
+	if ddbMock.BatchWriteItemCalls != 2 || len(ddbMock.BatchWriteItemInputs[1].RequestItems[object.MemberTable]) != 1 {
+		t.Errorf("expected chunks of %v and 1 items, got %v calls", db.BatchWriteSize, ddbMock.BatchWriteItemCalls)
+	}
+
+	ddbMock.BatchGetItemResults = []struct {
+		Output *dynamodb.BatchGetItemOutput
+		Error  error
+	}{
+		{Error: errFirst},
+		{Output: &dynamodb.BatchGetItemOutput{
+			Responses: map[string][]map[string]types.AttributeValue{
+				object.MemberTable: {{"Phone": &types.AttributeValueMemberS{Value: "+10000000100"}}},
+			},
+		}},
+	}
+	keys := make([]string, db.BatchGetSize+1)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("+1%010d", i)
+	}
+	if _, err := db.BatchGetObjects[object.Member](ddbMock, object.MemberAttribute, object.MemberTable,
+		keys); !errors.Is(err, errFirst) {
+		t.Errorf("expected error of first chunk, got %v", err)
+	}
+	if ddbMock.BatchGetItemCalls != 2 {
+		t.Errorf("expected 2 chunks to be read, got %v", ddbMock.BatchGetItemCalls)
+	}
+}
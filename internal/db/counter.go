@@ -0,0 +1,171 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Counter is a number attribute that AddDdbCounters adds Value to. Path is the name of the
+// attribute, followed by the keys of the maps it is nested in, for example Stages, HELP, Messages
+// for the attribute Stages.HELP.Messages.
+type Counter struct {
+	Path  []string
+	Value int
+}
+
+// maxCountersPerUpdate keeps update expressions well below the ddb limit of 4KB.
+const maxCountersPerUpdate = 100
+
+// AddDdbCounters adds counters to the item of T with key in table. ddb adds them atomically, so
+// unlike a get and a put, concurrent flows do not lose counts. The item, the counters and the maps
+// they are nested in are created if they do not exist yet. ddb only adds to attributes of maps that
+// exist, so missing maps are created first, with one update per level of nesting, and maps of T
+// must be omitempty, since a nil map is stored as null. Updates that fail after others succeeded
+// leave those counts added.
+func AddDdbCounters[T any](ddbClnt DDBConnecter, attr, key, table string, counters []Counter) error {
+	depth := 0
+	for _, c := range counters {
+		depth = max(depth, len(c.Path))
+	}
+
+	// an update can not set a map and an attribute in it, so every level has its own updates
+	for level := 1; level < depth; level++ {
+		seen := map[string]bool{}
+		var paths [][]string
+		for _, c := range counters {
+			if len(c.Path) <= level {
+				continue
+			}
+			if id := strings.Join(c.Path[:level], "\x00"); !seen[id] {
+				seen[id] = true
+				paths = append(paths, c.Path[:level])
+			}
+		}
+
+		for chunk := range slices.Chunk(paths, maxCountersPerUpdate) {
+			u := newUpdate()
+			empty := u.value(&types.AttributeValueMemberM{Value: map[string]types.AttributeValue{}})
+			var actions []string
+			for _, path := range chunk {
+				name := u.path(path)
+				actions = append(actions, fmt.Sprintf("%s = if_not_exists(%s, %s)", name, name, empty))
+			}
+
+			if err := u.apply(ddbClnt, attr, key, table, "SET "+strings.Join(actions, ", ")); err != nil {
+				return fmt.Errorf("AddDdbCounters: %w", err)
+			}
+		}
+	}
+
+	for chunk := range slices.Chunk(counters, maxCountersPerUpdate) {
+		u := newUpdate()
+		var actions []string
+		for _, c := range chunk {
+			n := &types.AttributeValueMemberN{Value: strconv.Itoa(c.Value)}
+			actions = append(actions, u.path(c.Path)+" "+u.value(n))
+		}
+
+		expr := "ADD " + strings.Join(actions, ", ")
+		if schema, ok := schemaOf[T](); ok {
+			version := &types.AttributeValueMemberN{Value: strconv.Itoa(schema.Version)}
+			name := u.path([]string{SchemaVersionAttribute})
+			expr = fmt.Sprintf("SET %s = if_not_exists(%s, %s) %s", name, name, u.value(version), expr)
+		}
+
+		if err := u.apply(ddbClnt, attr, key, table, expr); err != nil {
+			return fmt.Errorf("AddDdbCounters: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// RemoveDdbAttributes removes the attributes at paths from the item with key in table, see Counter
+// for how paths are written. ddb removes them atomically, so concurrent updates of other
+// attributes of the item are kept. Items that do not exist are not created.
+func RemoveDdbAttributes(ddbClnt DDBConnecter, attr, key, table string, paths [][]string) error {
+	for chunk := range slices.Chunk(paths, maxCountersPerUpdate) {
+		u := newUpdate()
+		var actions []string
+		for _, path := range chunk {
+			actions = append(actions, u.path(path))
+		}
+		u.condition = fmt.Sprintf("attribute_exists(%s)", u.path([]string{attr}))
+
+		err := u.apply(ddbClnt, attr, key, table, "REMOVE "+strings.Join(actions, ", "))
+		var condErr *types.ConditionalCheckFailedException
+		if errors.As(err, &condErr) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("RemoveDdbAttributes: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// update collects the expression attribute names and values of an update expression, and the
+// condition of the update, if any.
+type update struct {
+	condition   string
+	names       map[string]string
+	placeholder map[string]string
+	values      map[string]types.AttributeValue
+}
+
+func newUpdate() *update {
+	return &update{
+		names:       map[string]string{},
+		placeholder: map[string]string{},
+		values:      map[string]types.AttributeValue{},
+	}
+}
+
+// path returns the attribute path of path in the expression.
+func (u *update) path(path []string) string {
+	parts := make([]string, len(path))
+	for i, name := range path {
+		if _, ok := u.placeholder[name]; !ok {
+			u.placeholder[name] = "#n" + strconv.Itoa(len(u.names))
+			u.names[u.placeholder[name]] = name
+		}
+		parts[i] = u.placeholder[name]
+	}
+
+	return strings.Join(parts, ".")
+}
+
+// value returns the placeholder of a new value in the expression.
+func (u *update) value(value types.AttributeValue) string {
+	placeholder := ":v" + strconv.Itoa(len(u.values))
+	u.values[placeholder] = value
+
+	return placeholder
+}
+
+func (u *update) apply(ddbClnt DDBConnecter, attr, key, table, expr string) error {
+	input := &dynamodb.UpdateItemInput{
+		TableName:                &table,
+		Key:                      map[string]types.AttributeValue{attr: &types.AttributeValueMemberS{Value: key}},
+		UpdateExpression:         &expr,
+		ExpressionAttributeNames: u.names,
+	}
+	// ddb rejects empty expression attribute values
+	if len(u.values) > 0 {
+		input.ExpressionAttributeValues = u.values
+	}
+	if u.condition != "" {
+		input.ConditionExpression = &u.condition
+	}
+
+	_, err := ddbClnt.UpdateItem(context.TODO(), input)
+
+	return err
+}
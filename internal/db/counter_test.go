@@ -0,0 +1,137 @@
+package db_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/mshort55/prayertexter/internal/db"
+	"github.com/mshort55/prayertexter/internal/mock"
+)
+
+type stats struct {
+	Key    string
+	Stages map[string]map[string]int `dynamodbav:",omitempty"`
+	Total  int
+	Note   string
+}
+
+func (s *stats) Schema() db.Schema { return db.Schema{Version: 1} }
+
+func TestAddDdbCounters(t *testing.T) {
+	memDB := db.NewMemoryDB(map[string]string{"General": "Key"})
+	if err := db.PutDdbObject(memDB, "General", &stats{Key: "Stats", Note: "kept"}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	counters := []db.Counter{
+		{Path: []string{"Total"}, Value: 2},
+		{Path: []string{"Stages", "HELP", "Messages"}, Value: 1},
+		{Path: []string{"Stages", "HELP", "Segments"}, Value: 3},
+	}
+	for range 2 {
+		if err := db.AddDdbCounters[stats](memDB, "Key", "Stats", "General", counters); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+
+	got, err := db.GetDdbObject[stats](memDB, "Key", "Stats", "General")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	expected := stats{
+		Key:    "Stats",
+		Stages: map[string]map[string]int{"HELP": {"Messages": 2, "Segments": 6}},
+		Total:  4,
+		Note:   "kept",
+	}
+	if !reflect.DeepEqual(*got, expected) {
+		t.Errorf("expected %v, got %v", expected, *got)
+	}
+
+	// counters of items that do not exist create the item
+	if err := db.AddDdbCounters[stats](memDB, "Key", "New", "General", counters[:1]); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	out, err := memDB.GetItem(context.Background(), &dynamodb.GetItemInput{
+		TableName: aws.String("General"),
+		Key:       map[string]types.AttributeValue{"Key": &types.AttributeValueMemberS{Value: "New"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if version, _ := db.ItemSchemaVersion(out.Item); version != 1 || out.Item["Total"] == nil {
+		t.Errorf("expected new item with Total and schema version, got %v", out.Item)
+	}
+}
+
+func TestAddDdbCountersUpdates(t *testing.T) {
+	ddbMock := &mock.DDBConnecter{}
+	counters := []db.Counter{
+		{Path: []string{"Total"}, Value: 1},
+		{Path: []string{"Stages", "HELP", "Messages"}, Value: 1},
+	}
+	if err := db.AddDdbCounters[stats](ddbMock, "Key", "Stats", "General", counters); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// maps are created one level per update before the counters are added
+	expected := []string{
+		"SET #n0 = if_not_exists(#n0, :v0)",
+		"SET #n0.#n1 = if_not_exists(#n0.#n1, :v0)",
+		"SET #n4 = if_not_exists(#n4, :v2) ADD #n0 :v0, #n1.#n2.#n3 :v1",
+	}
+	if ddbMock.UpdateItemCalls != len(expected) {
+		t.Fatalf("expected %v updates, got %v", len(expected), ddbMock.UpdateItemCalls)
+	}
+	for i, input := range ddbMock.UpdateItemInputs {
+		if *input.UpdateExpression != expected[i] {
+			t.Errorf("expected update %q, got %q", expected[i], *input.UpdateExpression)
+		}
+	}
+
+	errUpdate := errors.New("update failure")
+	ddbMock = &mock.DDBConnecter{}
+	ddbMock.UpdateItemResults = []struct {
+		Error error
+	}{
+		{Error: errUpdate},
+	}
+	if err := db.AddDdbCounters[stats](ddbMock, "Key", "Stats", "General", counters); !errors.Is(err, errUpdate) {
+		t.Errorf("expected update failure, got %v", err)
+	}
+}
+
+func TestMemoryDBUpdateItem(t *testing.T) {
+	memDB := db.NewMemoryDB(map[string]string{"General": "Key"})
+
+	_, err := memDB.UpdateItem(context.Background(), &dynamodb.UpdateItemInput{
+		TableName:                 aws.String("General"),
+		Key:                       map[string]types.AttributeValue{"Key": &types.AttributeValueMemberS{Value: "Stats"}},
+		UpdateExpression:          aws.String("ADD #stages.#help :one"),
+		ExpressionAttributeNames:  map[string]string{"#stages": "Stages", "#help": "HELP"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{":one": &types.AttributeValueMemberN{Value: "1"}},
+	})
+	if !errors.Is(err, db.ErrExpression) {
+		t.Errorf("expected ErrExpression for a path through a missing map, got %v", err)
+	}
+
+	_, err = memDB.UpdateItem(context.Background(), &dynamodb.UpdateItemInput{
+		TableName:                aws.String("General"),
+		Key:                      map[string]types.AttributeValue{"Key": &types.AttributeValueMemberS{Value: "Stats"}},
+		UpdateExpression:         aws.String("ADD #total :one"),
+		ConditionExpression:      aws.String("attribute_exists(#total)"),
+		ExpressionAttributeNames: map[string]string{"#total": "Total"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":one": &types.AttributeValueMemberN{Value: "1"},
+		},
+	})
+	var ccfe *types.ConditionalCheckFailedException
+	if !errors.As(err, &ccfe) {
+		t.Errorf("expected ConditionalCheckFailedException, got %v", err)
+	}
+}
@@ -2,7 +2,9 @@ package db
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
@@ -21,25 +23,46 @@ type DDBConnecter interface {
 	DeleteItem(ctx context.Context,
 		input *dynamodb.DeleteItemInput,
 		opts ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error)
+	UpdateItem(ctx context.Context,
+		input *dynamodb.UpdateItemInput,
+		opts ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error)
+	Scan(ctx context.Context,
+		input *dynamodb.ScanInput,
+		opts ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+	Query(ctx context.Context,
+		input *dynamodb.QueryInput,
+		opts ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+	BatchGetItem(ctx context.Context,
+		input *dynamodb.BatchGetItemInput,
+		opts ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error)
+	BatchWriteItem(ctx context.Context,
+		input *dynamodb.BatchWriteItemInput,
+		opts ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error)
 }
 
 func GetDdbClient() (*dynamodb.Client, error) {
+	if utility.IsAwsLocal() {
+		return GetDdbClientForEndpoint("http://dynamodb:8000")
+	}
+
+	return GetDdbClientForEndpoint("")
+}
+
+// GetDdbClientForEndpoint returns a ddb client that connects to endpoint, for example
+// http://localhost:8000 for dynamodb local. An empty endpoint connects to aws.
+func GetDdbClientForEndpoint(endpoint string) (*dynamodb.Client, error) {
 	cfg, err := utility.GetAwsConfig()
 	if err != nil {
 		return nil, fmt.Errorf("GetDdbClient: %w", err)
 	}
 
-	var ddbClnt *dynamodb.Client
-
-	if utility.IsAwsLocal() {
-		ddbClnt = dynamodb.NewFromConfig(cfg, func(o *dynamodb.Options) {
-			o.BaseEndpoint = aws.String("http://dynamodb:8000")
-		})
-	} else {
-		ddbClnt = dynamodb.NewFromConfig(cfg)
+	if endpoint == "" {
+		return dynamodb.NewFromConfig(cfg), nil
 	}
 
-	return ddbClnt, nil
+	return dynamodb.NewFromConfig(cfg, func(o *dynamodb.Options) {
+		o.BaseEndpoint = aws.String(endpoint)
+	}), nil
 }
 
 func getDdbItem(ddbClnt DDBConnecter, attr, key, table string) (*dynamodb.GetItemOutput, error) {
@@ -59,8 +82,13 @@ func GetDdbObject[T any](ddbClnt DDBConnecter, attr, key, table string) (*T, err
 		return nil, fmt.Errorf("getDdbItem: %w", err)
 	}
 
+	item, err := migrateCopy[T](resp.Item)
+	if err != nil {
+		return nil, fmt.Errorf("getDdbObject: %w", err)
+	}
+
 	var object T
-	if err := attributevalue.UnmarshalMap(resp.Item, &object); err != nil {
+	if err := attributevalue.UnmarshalMap(item, &object); err != nil {
 		return nil, fmt.Errorf("getDdbObject failed unmarshal: %w", err)
 	}
 
@@ -77,7 +105,7 @@ func putDdbItem(ddbClnt DDBConnecter, table string, data map[string]types.Attrib
 }
 
 func PutDdbObject[T any](ddbClnt DDBConnecter, table string, object *T) error {
-	item, err := attributevalue.MarshalMap(object)
+	item, err := marshalObject(object)
 	if err != nil {
 		return fmt.Errorf("putDdbObject failed marshal: %w", err)
 	}
@@ -89,6 +117,66 @@ func PutDdbObject[T any](ddbClnt DDBConnecter, table string, object *T) error {
 	return nil
 }
 
+// Condition is a ddb condition expression. Names and Values hold the expression attribute names
+// (#name) and values (:value) used in Expression.
+type Condition struct {
+	Expression string
+	Names      map[string]string
+	Values     map[string]types.AttributeValue
+}
+
+// PutDdbObjectIf puts object only if cond is true for the existing item. It returns false without
+// an error if the item was not put because cond is false.
+func PutDdbObjectIf[T any](ddbClnt DDBConnecter, table string, object *T, cond Condition) (bool, error) {
+	item, err := marshalObject(object)
+	if err != nil {
+		return false, fmt.Errorf("putDdbObjectIf failed marshal: %w", err)
+	}
+
+	_, err = ddbClnt.PutItem(context.TODO(), &dynamodb.PutItemInput{
+		TableName:                 &table,
+		Item:                      item,
+		ConditionExpression:       &cond.Expression,
+		ExpressionAttributeNames:  cond.Names,
+		ExpressionAttributeValues: cond.Values,
+	})
+
+	var condErr *types.ConditionalCheckFailedException
+	if errors.As(err, &condErr) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("putDdbObjectIf: %w", err)
+	}
+
+	return true, nil
+}
+
+// VersionAttribute holds the version of objects that are shared by concurrent flows, such as lists
+// of phones. Every put increments the version, so that an update can tell whether the object
+// changed since it was read. MaxUpdateAttempts is how often such an update is tried.
+const (
+	MaxUpdateAttempts = 10
+	VersionAttribute  = "Version"
+)
+
+// ErrConflict is returned when an object kept changing for MaxUpdateAttempts while it was updated.
+var ErrConflict = errors.New("object changed during update")
+
+// VersionCondition is true if the stored item still has version, the version that an object was
+// read with. Items that do not exist yet or that were stored without a version have version 0.
+func VersionCondition(version int) Condition {
+	expr := "#version = :version"
+	if version == 0 {
+		expr = "attribute_not_exists(#version) OR " + expr
+	}
+
+	return Condition{
+		Expression: expr,
+		Names:      map[string]string{"#version": VersionAttribute},
+		Values:     map[string]types.AttributeValue{":version": &types.AttributeValueMemberN{Value: strconv.Itoa(version)}},
+	}
+}
+
 func DelDdbItem(ddbClnt DDBConnecter, attr, key, table string) error {
 	_, err := ddbClnt.DeleteItem(context.TODO(), &dynamodb.DeleteItemInput{
 		TableName: &table,
@@ -99,3 +187,38 @@ func DelDdbItem(ddbClnt DDBConnecter, attr, key, table string) error {
 
 	return err
 }
+
+// DelDdbItemIf deletes the item with key only if cond is true for it. It returns false without an
+// error if the item was not deleted because cond is false.
+func DelDdbItemIf(ddbClnt DDBConnecter, attr, key, table string, cond Condition) (bool, error) {
+	_, err := ddbClnt.DeleteItem(context.TODO(), &dynamodb.DeleteItemInput{
+		TableName: &table,
+		Key: map[string]types.AttributeValue{
+			attr: &types.AttributeValueMemberS{Value: key},
+		},
+		ConditionExpression:       &cond.Expression,
+		ExpressionAttributeNames:  cond.Names,
+		ExpressionAttributeValues: cond.Values,
+	})
+
+	var condErr *types.ConditionalCheckFailedException
+	if errors.As(err, &condErr) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("delDdbItemIf: %w", err)
+	}
+
+	return true, nil
+}
+
+// ScanDdbObjects returns all objects in table that match filter, reading every page of the scan.
+// A filter with an empty Expression returns all objects. Scans read the whole table, so this is
+// only meant for rare admin operations. See ScanAll for parallel scans.
+func ScanDdbObjects[T any](ddbClnt DDBConnecter, table string, filter Condition) ([]T, error) {
+	objects, err := ScanAll[T](ddbClnt, table, ScanOptions{Filter: filter})
+	if err != nil {
+		return nil, fmt.Errorf("scanDdbObjects: %w", err)
+	}
+
+	return objects, nil
+}
@@ -1,6 +1,7 @@
 package db_test
 
 import (
+	"errors"
 	"reflect"
 	"testing"
 
@@ -268,7 +269,152 @@ func testPutObject[T any](t *testing.T, ddbMock *mock.DDBConnecter, expectedObje
 		t.Errorf("failed to unmarshal lastPutItem: %v", err)
 	}
 
+	// every object is put with its schema version
+	if lastPutMap[db.SchemaVersionAttribute] != float64(1) {
+		t.Errorf("expected schema version 1, got %v", lastPutMap[db.SchemaVersionAttribute])
+	}
+	delete(lastPutMap, db.SchemaVersionAttribute)
+
 	if !reflect.DeepEqual(expectedMap, lastPutMap) {
 		t.Errorf("expected map %v, got %v", expectedMap, lastPutMap)
 	}
 }
+
+func TestPutDdbObjectIf(t *testing.T) {
+	ddbMock := &mock.DDBConnecter{}
+	ddbMock.PutItemResults = []struct {
+		Error error
+	}{
+		{Error: nil},
+		{Error: &types.ConditionalCheckFailedException{}},
+		{Error: errors.New("put item failure")},
+	}
+
+	mem := object.Member{Phone: "+11234567890"}
+	cond := db.Condition{
+		Expression: "attribute_not_exists(#phone)",
+		Names:      map[string]string{"#phone": object.MemberAttribute},
+	}
+
+	if put, err := db.PutDdbObjectIf(ddbMock, object.MemberTable, &mem, cond); err != nil || !put {
+		t.Errorf("expected object to be put, got %v (error %v)", put, err)
+	}
+
+	if *ddbMock.PutItemInputs[0].ConditionExpression != cond.Expression {
+		t.Errorf("expected condition %v, got %v", cond.Expression, *ddbMock.PutItemInputs[0].ConditionExpression)
+	}
+
+	// a failed condition is not an error
+	if put, err := db.PutDdbObjectIf(ddbMock, object.MemberTable, &mem, cond); err != nil || put {
+		t.Errorf("expected object not to be put without error, got %v (error %v)", put, err)
+	}
+
+	if _, err := db.PutDdbObjectIf(ddbMock, object.MemberTable, &mem, cond); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestDelDdbItemIf(t *testing.T) {
+	memDB := db.NewMemoryDB(object.TableKeys())
+	mem := object.Member{Name: "John Doe", Phone: "+11234567890"}
+	if err := db.PutDdbObject(memDB, object.MemberTable, &mem); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	cond := func(name string) db.Condition {
+		return db.Condition{
+			Expression: "#name = :name",
+			Names:      map[string]string{"#name": "Name"},
+			Values:     map[string]types.AttributeValue{":name": &types.AttributeValueMemberS{Value: name}},
+		}
+	}
+
+	// a failed condition is not an error
+	deleted, err := db.DelDdbItemIf(memDB, object.MemberAttribute, mem.Phone, object.MemberTable, cond("Jane Doe"))
+	if err != nil || deleted {
+		t.Errorf("expected item not to be deleted without error, got %v (error %v)", deleted, err)
+	}
+
+	deleted, err = db.DelDdbItemIf(memDB, object.MemberAttribute, mem.Phone, object.MemberTable, cond("John Doe"))
+	if err != nil || !deleted {
+		t.Errorf("expected item to be deleted, got %v (error %v)", deleted, err)
+	}
+
+	got, err := db.GetDdbObject[object.Member](memDB, object.MemberAttribute, mem.Phone, object.MemberTable)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	} else if got.Phone != "" {
+		t.Errorf("expected deleted item, got %v", got)
+	}
+
+	ddbMock := &mock.DDBConnecter{}
+	ddbMock.DeleteItemResults = []struct {
+		Error error
+	}{
+		{Error: errors.New("delete item failure")},
+	}
+	if _, err := db.DelDdbItemIf(ddbMock, object.MemberAttribute, mem.Phone, object.MemberTable,
+		cond("John Doe")); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
+
+func TestScanDdbObjects(t *testing.T) {
+	ddbMock := &mock.DDBConnecter{}
+	ddbMock.ScanResults = []struct {
+		Output *dynamodb.ScanOutput
+		Error  error
+	}{
+		{
+			Output: &dynamodb.ScanOutput{
+				Items: []map[string]types.AttributeValue{
+					{"Phone": &types.AttributeValueMemberS{Value: "+11111111111"}},
+				},
+				LastEvaluatedKey: map[string]types.AttributeValue{
+					"Phone": &types.AttributeValueMemberS{Value: "+11111111111"},
+				},
+			},
+			Error: nil,
+		},
+		{
+			Output: &dynamodb.ScanOutput{
+				Items: []map[string]types.AttributeValue{
+					{"Phone": &types.AttributeValueMemberS{Value: "+12222222222"}},
+				},
+			},
+			Error: nil,
+		},
+		{
+			Output: nil,
+			Error:  errors.New("scan failure"),
+		},
+	}
+
+	filter := db.Condition{
+		Expression: "#intercessor = :intercessor",
+		Names:      map[string]string{"#intercessor": "Intercessor"},
+		Values:     map[string]types.AttributeValue{":intercessor": &types.AttributeValueMemberBOOL{Value: true}},
+	}
+
+	mems, err := db.ScanDdbObjects[object.Member](ddbMock, object.MemberTable, filter)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	expected := []object.Member{{Phone: "+11111111111"}, {Phone: "+12222222222"}}
+	if !reflect.DeepEqual(mems, expected) {
+		t.Errorf("expected %v, got %v", expected, mems)
+	}
+
+	if ddbMock.ScanCalls != 2 || ddbMock.ScanInputs[1].ExclusiveStartKey == nil {
+		t.Errorf("expected second scan to continue after the first page, got %v calls", ddbMock.ScanCalls)
+	}
+
+	if *ddbMock.ScanInputs[0].FilterExpression != filter.Expression {
+		t.Errorf("expected filter %v, got %v", filter.Expression, *ddbMock.ScanInputs[0].FilterExpression)
+	}
+
+	if _, err := db.ScanDdbObjects[object.Member](ddbMock, object.MemberTable, filter); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+}
@@ -0,0 +1,521 @@
+package db
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"math/big"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ErrExpression is returned by MemoryDB when a condition or filter expression is not supported.
+var ErrExpression = errors.New("unsupported expression")
+
+// exprParser evaluates the subset of ddb condition expressions that MemoryDB supports against a
+// single item: comparisons (= <> < <= > >=), AND, OR, NOT, parentheses and the attribute_exists,
+// attribute_not_exists, begins_with and contains functions. Attribute paths may be nested maps
+// (#a.#b) but not list elements.
+type exprParser struct {
+	item   map[string]types.AttributeValue
+	names  map[string]string
+	pos    int
+	tokens []string
+	values map[string]types.AttributeValue
+}
+
+// evalCondition returns whether item matches expr. A nil item is an item that does not exist.
+func evalCondition(expr string, names map[string]string, values map[string]types.AttributeValue,
+	item map[string]types.AttributeValue) (bool, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return false, err
+	}
+
+	p := &exprParser{item: item, names: names, tokens: tokens, values: values}
+	result, err := p.parseOr()
+	if err != nil {
+		return false, err
+	} else if p.pos != len(p.tokens) {
+		return false, fmt.Errorf("%w: unexpected %q in %q", ErrExpression, p.tokens[p.pos], expr)
+	}
+
+	return result, nil
+}
+
+func tokenize(expr string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(expr); {
+		c := rune(expr[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case strings.ContainsRune("(),.", c):
+			tokens = append(tokens, string(c))
+			i++
+		case strings.ContainsRune("=<>", c):
+			op := string(c)
+			if i+1 < len(expr) && (expr[i:i+2] == "<>" || expr[i:i+2] == "<=" || expr[i:i+2] == ">=") {
+				op = expr[i : i+2]
+			}
+			tokens = append(tokens, op)
+			i += len(op)
+		case c == '#' || c == ':' || c == '_' || unicode.IsLetter(c) || unicode.IsDigit(c):
+			end := strings.IndexFunc(expr[i+1:], func(r rune) bool {
+				return r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r)
+			})
+			if end < 0 {
+				end = len(expr) - i - 1
+			}
+			tokens = append(tokens, expr[i:i+1+end])
+			i += 1 + end
+		default:
+			return nil, fmt.Errorf("%w: unexpected %q in %q", ErrExpression, c, expr)
+		}
+	}
+
+	return tokens, nil
+}
+
+func (p *exprParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+
+	return ""
+}
+
+func (p *exprParser) expect(token string) error {
+	if p.peek() != token {
+		return fmt.Errorf("%w: expected %q, got %q", ErrExpression, token, p.peek())
+	}
+	p.pos++
+
+	return nil
+}
+
+func (p *exprParser) parseOr() (bool, error) {
+	result, err := p.parseAnd()
+	for err == nil && strings.EqualFold(p.peek(), "OR") {
+		p.pos++
+		var next bool
+		next, err = p.parseAnd()
+		result = result || next
+	}
+
+	return result, err
+}
+
+func (p *exprParser) parseAnd() (bool, error) {
+	result, err := p.parseNot()
+	for err == nil && strings.EqualFold(p.peek(), "AND") {
+		p.pos++
+		var next bool
+		next, err = p.parseNot()
+		result = result && next
+	}
+
+	return result, err
+}
+
+func (p *exprParser) parseNot() (bool, error) {
+	if strings.EqualFold(p.peek(), "NOT") {
+		p.pos++
+		result, err := p.parseNot()
+		return !result, err
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (bool, error) {
+	if p.peek() == "(" {
+		p.pos++
+		result, err := p.parseOr()
+		if err != nil {
+			return false, err
+		}
+		return result, p.expect(")")
+	}
+
+	switch function := p.peek(); function {
+	case "attribute_exists", "attribute_not_exists", "begins_with", "contains":
+		p.pos++
+		return p.parseFunction(function)
+	}
+
+	left, err := p.parseOperand()
+	if err != nil {
+		return false, err
+	}
+
+	op := p.peek()
+	p.pos++
+	right, err := p.parseOperand()
+	if err != nil {
+		return false, err
+	}
+
+	return compareOp(left, op, right)
+}
+
+func (p *exprParser) parseFunction(function string) (bool, error) {
+	if err := p.expect("("); err != nil {
+		return false, err
+	}
+
+	attr, err := p.parseOperand()
+	if err != nil {
+		return false, err
+	}
+
+	result := false
+	switch function {
+	case "attribute_exists":
+		result = attr != nil
+	case "attribute_not_exists":
+		result = attr == nil
+	case "begins_with", "contains":
+		if err := p.expect(","); err != nil {
+			return false, err
+		}
+		operand, err := p.parseOperand()
+		if err != nil {
+			return false, err
+		}
+		result = matchString(function, attr, operand)
+	}
+
+	return result, p.expect(")")
+}
+
+// parseOperand returns the value of a :value or of an attribute path in the item, which is nil if
+// the attribute does not exist.
+func (p *exprParser) parseOperand() (types.AttributeValue, error) {
+	token := p.peek()
+	p.pos++
+
+	if strings.HasPrefix(token, ":") {
+		value, ok := p.values[token]
+		if !ok {
+			return nil, fmt.Errorf("%w: missing value %v", ErrExpression, token)
+		}
+		return value, nil
+	}
+
+	current := p.item
+	for {
+		name, err := p.attributeName(token)
+		if err != nil {
+			return nil, err
+		}
+
+		value := current[name]
+		if p.peek() != "." {
+			return value, nil
+		}
+		p.pos++
+
+		m, ok := value.(*types.AttributeValueMemberM)
+		if !ok {
+			current = nil
+		} else {
+			current = m.Value
+		}
+		token = p.peek()
+		p.pos++
+	}
+}
+
+func (p *exprParser) attributeName(token string) (string, error) {
+	if token == "" || strings.ContainsAny(token[:1], "(),.=<>:") {
+		return "", fmt.Errorf("%w: expected attribute, got %q", ErrExpression, token)
+	} else if !strings.HasPrefix(token, "#") {
+		return token, nil
+	}
+
+	name, ok := p.names[token]
+	if !ok {
+		return "", fmt.Errorf("%w: missing name %v", ErrExpression, token)
+	}
+
+	return name, nil
+}
+
+func compareOp(left types.AttributeValue, op string, right types.AttributeValue) (bool, error) {
+	cmp, comparable := compareValues(left, right)
+
+	switch op {
+	case "=":
+		return equalValues(left, right), nil
+	case "<>":
+		return !equalValues(left, right), nil
+	case "<":
+		return comparable && cmp < 0, nil
+	case "<=":
+		return comparable && cmp <= 0, nil
+	case ">":
+		return comparable && cmp > 0, nil
+	case ">=":
+		return comparable && cmp >= 0, nil
+	default:
+		return false, fmt.Errorf("%w: unknown operator %q", ErrExpression, op)
+	}
+}
+
+// compareValues compares two scalar values of the same type. Values of different types, missing
+// values and sets, lists and maps are not comparable.
+func compareValues(left, right types.AttributeValue) (int, bool) {
+	switch l := left.(type) {
+	case *types.AttributeValueMemberS:
+		if r, ok := right.(*types.AttributeValueMemberS); ok {
+			return strings.Compare(l.Value, r.Value), true
+		}
+	case *types.AttributeValueMemberN:
+		if r, ok := right.(*types.AttributeValueMemberN); ok {
+			lf, lok := new(big.Float).SetString(l.Value)
+			rf, rok := new(big.Float).SetString(r.Value)
+			if lok && rok {
+				return lf.Cmp(rf), true
+			}
+		}
+	case *types.AttributeValueMemberB:
+		if r, ok := right.(*types.AttributeValueMemberB); ok {
+			return bytes.Compare(l.Value, r.Value), true
+		}
+	case *types.AttributeValueMemberBOOL:
+		if r, ok := right.(*types.AttributeValueMemberBOOL); ok && l.Value == r.Value {
+			return 0, true
+		} else if ok {
+			return 1, true
+		}
+	}
+
+	return 0, false
+}
+
+// equalValues returns true if left and right are the same value. Unlike compareValues, it also
+// compares sets, lists, maps and nulls, like = does in ddb.
+func equalValues(left, right types.AttributeValue) bool {
+	if cmp, comparable := compareValues(left, right); comparable {
+		return cmp == 0
+	}
+
+	switch l := left.(type) {
+	case *types.AttributeValueMemberNULL:
+		_, ok := right.(*types.AttributeValueMemberNULL)
+		return ok
+	case *types.AttributeValueMemberL:
+		r, ok := right.(*types.AttributeValueMemberL)
+		return ok && slices.EqualFunc(l.Value, r.Value, equalValues)
+	case *types.AttributeValueMemberM:
+		r, ok := right.(*types.AttributeValueMemberM)
+		return ok && maps.EqualFunc(l.Value, r.Value, equalValues)
+	case *types.AttributeValueMemberSS:
+		r, ok := right.(*types.AttributeValueMemberSS)
+		return ok && equalSets(l.Value, r.Value)
+	case *types.AttributeValueMemberNS:
+		r, ok := right.(*types.AttributeValueMemberNS)
+		return ok && equalSets(l.Value, r.Value)
+	case *types.AttributeValueMemberBS:
+		r, ok := right.(*types.AttributeValueMemberBS)
+		return ok && equalSets(l.Value, r.Value)
+	}
+
+	return false
+}
+
+// equalSets returns true if left and right have the same elements, in any order.
+func equalSets[E string | []byte](left, right []E) bool {
+	if len(left) != len(right) {
+		return false
+	}
+
+	counts := map[string]int{}
+	for _, e := range left {
+		counts[string(e)]++
+	}
+	for _, e := range right {
+		if counts[string(e)]--; counts[string(e)] < 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchString implements begins_with and contains for strings, and contains for string sets and
+// lists.
+func matchString(function string, attr, operand types.AttributeValue) bool {
+	sub, ok := operand.(*types.AttributeValueMemberS)
+	if !ok {
+		return false
+	}
+
+	switch a := attr.(type) {
+	case *types.AttributeValueMemberS:
+		if function == "begins_with" {
+			return strings.HasPrefix(a.Value, sub.Value)
+		}
+		return strings.Contains(a.Value, sub.Value)
+	case *types.AttributeValueMemberSS:
+		return function == "contains" && slices.Contains(a.Value, sub.Value)
+	case *types.AttributeValueMemberL:
+		for _, v := range a.Value {
+			if s, ok := v.(*types.AttributeValueMemberS); ok && function == "contains" && s.Value == sub.Value {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// applyUpdate applies the SET, ADD and REMOVE clauses of an update expression to item in place.
+// SET supports values, attributes and if_not_exists, ADD supports numbers. Like in ddb, every map
+// on the path of an updated attribute must exist.
+func applyUpdate(expr string, names map[string]string, values map[string]types.AttributeValue,
+	item map[string]types.AttributeValue) error {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return err
+	}
+
+	p := &exprParser{item: item, names: names, tokens: tokens, values: values}
+	for p.pos < len(p.tokens) {
+		clause := strings.ToUpper(p.peek())
+		if clause != "SET" && clause != "ADD" && clause != "REMOVE" {
+			return fmt.Errorf("%w: unknown update clause %q in %q", ErrExpression, p.peek(), expr)
+		}
+		p.pos++
+
+		for {
+			if err := p.parseUpdateAction(clause); err != nil {
+				return err
+			}
+			if p.peek() != "," {
+				break
+			}
+			p.pos++
+		}
+	}
+
+	return nil
+}
+
+func (p *exprParser) parseUpdateAction(clause string) error {
+	parent, name, err := p.parsePath()
+	if err != nil {
+		return err
+	}
+
+	if clause == "REMOVE" {
+		delete(parent, name)
+		return nil
+	}
+
+	if clause == "SET" {
+		if err := p.expect("="); err != nil {
+			return err
+		}
+		value, err := p.parseSetValue()
+		if err != nil {
+			return err
+		}
+		parent[name] = value
+		return nil
+	}
+
+	value, err := p.parseOperand()
+	if err != nil {
+		return err
+	}
+	sum, err := addNumbers(parent[name], value)
+	if err != nil {
+		return err
+	}
+	parent[name] = sum
+
+	return nil
+}
+
+// parsePath returns the map that holds the attribute at an attribute path and its name.
+func (p *exprParser) parsePath() (map[string]types.AttributeValue, string, error) {
+	current := p.item
+	for {
+		name, err := p.attributeName(p.peek())
+		if err != nil {
+			return nil, "", err
+		}
+		p.pos++
+
+		if p.peek() != "." {
+			return current, name, nil
+		}
+		p.pos++
+
+		m, ok := current[name].(*types.AttributeValueMemberM)
+		if !ok {
+			return nil, "", fmt.Errorf("%w: %v is not a map", ErrExpression, name)
+		}
+		current = m.Value
+	}
+}
+
+func (p *exprParser) parseSetValue() (types.AttributeValue, error) {
+	if p.peek() != "if_not_exists" {
+		return p.parseOperand()
+	}
+	p.pos++
+
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	existing, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(","); err != nil {
+		return nil, err
+	}
+	value, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+
+	if existing != nil {
+		return existing, nil
+	}
+
+	return value, nil
+}
+
+// addNumbers adds value to existing, which is nil if the attribute does not exist yet.
+func addNumbers(existing, value types.AttributeValue) (types.AttributeValue, error) {
+	v, ok := value.(*types.AttributeValueMemberN)
+	if !ok {
+		return nil, fmt.Errorf("%w: ADD of %T", ErrExpression, value)
+	} else if existing == nil {
+		return v, nil
+	}
+
+	e, ok := existing.(*types.AttributeValueMemberN)
+	if !ok {
+		return nil, fmt.Errorf("%w: ADD to %T", ErrExpression, existing)
+	}
+
+	ef, eok := new(big.Float).SetString(e.Value)
+	vf, vok := new(big.Float).SetString(v.Value)
+	if !eok || !vok {
+		return nil, fmt.Errorf("%w: ADD of %v to %v", ErrExpression, v.Value, e.Value)
+	}
+
+	return &types.AttributeValueMemberN{Value: ef.Add(ef, vf).Text('f', -1)}, nil
+}
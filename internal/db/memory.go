@@ -0,0 +1,474 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"slices"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// MemoryDB is a DDBConnecter that keeps every table in memory, for running prayertexter locally
+// without dynamodb. It supports the condition and filter expressions that prayertexter uses (see
+// exprParser) and is safe for concurrent use. Tables only need to be known by their hash key
+// attribute, which is given by the keys map of NewMemoryDB.
+type MemoryDB struct {
+	// PageSize is the number of items that Scan and Query read per page if the input has no
+	// Limit, 0 for all items in one page
+	PageSize int
+
+	keys   map[string]string
+	mu     sync.Mutex
+	tables map[string]map[string]map[string]types.AttributeValue
+}
+
+// NewMemoryDB returns an empty MemoryDB. keys maps every table name to the attribute name of its
+// hash key.
+func NewMemoryDB(keys map[string]string) *MemoryDB {
+	return &MemoryDB{keys: keys, tables: map[string]map[string]map[string]types.AttributeValue{}}
+}
+
+func (m *MemoryDB) GetItem(_ context.Context, input *dynamodb.GetItemInput,
+	_ ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key, err := m.itemKey(aws.ToString(input.TableName), input.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	item := m.tables[aws.ToString(input.TableName)][key]
+	return &dynamodb.GetItemOutput{Item: copyItem(item)}, nil
+}
+
+func (m *MemoryDB) PutItem(_ context.Context, input *dynamodb.PutItemInput,
+	_ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	table := aws.ToString(input.TableName)
+	key, err := m.itemKey(table, input.Item)
+	if err != nil {
+		return nil, err
+	}
+
+	if cond := aws.ToString(input.ConditionExpression); cond != "" {
+		ok, err := evalCondition(cond, input.ExpressionAttributeNames, input.ExpressionAttributeValues,
+			m.tables[table][key])
+		if err != nil {
+			return nil, fmt.Errorf("MemoryDB PutItem: %w", err)
+		} else if !ok {
+			return nil, &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")}
+		}
+	}
+
+	if m.tables[table] == nil {
+		m.tables[table] = map[string]map[string]types.AttributeValue{}
+	}
+	m.tables[table][key] = copyItem(input.Item)
+
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (m *MemoryDB) DeleteItem(_ context.Context, input *dynamodb.DeleteItemInput,
+	_ ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	table := aws.ToString(input.TableName)
+	key, err := m.itemKey(table, input.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	if cond := aws.ToString(input.ConditionExpression); cond != "" {
+		ok, err := evalCondition(cond, input.ExpressionAttributeNames, input.ExpressionAttributeValues,
+			m.tables[table][key])
+		if err != nil {
+			return nil, fmt.Errorf("MemoryDB DeleteItem: %w", err)
+		} else if !ok {
+			return nil, &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")}
+		}
+	}
+
+	delete(m.tables[table], key)
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+// UpdateItem applies the update expression to the item, which is created from the key if it does
+// not exist yet. See applyUpdate for the supported update expressions.
+func (m *MemoryDB) UpdateItem(_ context.Context, input *dynamodb.UpdateItemInput,
+	_ ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	table := aws.ToString(input.TableName)
+	key, err := m.itemKey(table, input.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	existing := m.tables[table][key]
+	if cond := aws.ToString(input.ConditionExpression); cond != "" {
+		ok, err := evalCondition(cond, input.ExpressionAttributeNames, input.ExpressionAttributeValues, existing)
+		if err != nil {
+			return nil, fmt.Errorf("MemoryDB UpdateItem: %w", err)
+		} else if !ok {
+			return nil, &types.ConditionalCheckFailedException{Message: aws.String("The conditional request failed")}
+		}
+	}
+
+	item := copyItem(existing)
+	if item == nil {
+		item = copyItem(input.Key)
+	}
+	err = applyUpdate(aws.ToString(input.UpdateExpression), input.ExpressionAttributeNames,
+		input.ExpressionAttributeValues, item)
+	if err != nil {
+		return nil, fmt.Errorf("MemoryDB UpdateItem: %w", err)
+	}
+
+	if m.tables[table] == nil {
+		m.tables[table] = map[string]map[string]types.AttributeValue{}
+	}
+	// the values of the input are copied too
+	m.tables[table][key] = copyItem(item)
+
+	return &dynamodb.UpdateItemOutput{}, nil
+}
+
+// Scan returns the items of the table that match the filter expression, ordered by key. Pages
+// end after Limit items were read, or PageSize items without a Limit. Segments split the items by
+// a hash of their key.
+func (m *MemoryDB) Scan(_ context.Context, input *dynamodb.ScanInput,
+	_ ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	items, last, err := m.read(readInput{
+		filter:   aws.ToString(input.FilterExpression),
+		limit:    aws.ToInt32(input.Limit),
+		names:    input.ExpressionAttributeNames,
+		segment:  aws.ToInt32(input.Segment),
+		segments: aws.ToInt32(input.TotalSegments),
+		start:    input.ExclusiveStartKey,
+		table:    aws.ToString(input.TableName),
+		values:   input.ExpressionAttributeValues,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("MemoryDB Scan: %w", err)
+	}
+
+	return &dynamodb.ScanOutput{Items: items, LastEvaluatedKey: last}, nil
+}
+
+// Query returns the items of the table that match the key condition and filter expressions,
+// paged like Scan. Indexes are not known to MemoryDB, so a query of an index reads the items that
+// match the key condition in the order of the table and ScanIndexForward is ignored.
+func (m *MemoryDB) Query(_ context.Context, input *dynamodb.QueryInput,
+	_ ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	items, last, err := m.read(readInput{
+		filter:  aws.ToString(input.FilterExpression),
+		keyCond: aws.ToString(input.KeyConditionExpression),
+		limit:   aws.ToInt32(input.Limit),
+		names:   input.ExpressionAttributeNames,
+		start:   input.ExclusiveStartKey,
+		table:   aws.ToString(input.TableName),
+		values:  input.ExpressionAttributeValues,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("MemoryDB Query: %w", err)
+	}
+
+	return &dynamodb.QueryOutput{Items: items, LastEvaluatedKey: last}, nil
+}
+
+// BatchGetItem returns the items of every table for the keys, with the same limits as ddb: at most
+// BatchGetSize keys and no duplicate keys. It never leaves keys unprocessed.
+func (m *MemoryDB) BatchGetItem(_ context.Context, input *dynamodb.BatchGetItemInput,
+	_ ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	output := &dynamodb.BatchGetItemOutput{Responses: map[string][]map[string]types.AttributeValue{}}
+	count := 0
+	for table, keys := range input.RequestItems {
+		seen := map[string]bool{}
+		for _, k := range keys.Keys {
+			key, err := m.itemKey(table, k)
+			if err != nil {
+				return nil, fmt.Errorf("MemoryDB BatchGetItem: %w", err)
+			} else if seen[key] {
+				return nil, fmt.Errorf("MemoryDB BatchGetItem: duplicate key %v of table %v", key, table)
+			}
+			seen[key] = true
+			count++
+
+			if item, ok := m.tables[table][key]; ok {
+				output.Responses[table] = append(output.Responses[table], copyItem(item))
+			}
+		}
+	}
+	if count > BatchGetSize {
+		return nil, fmt.Errorf("MemoryDB BatchGetItem: %d keys is more than %d", count, BatchGetSize)
+	}
+
+	return output, nil
+}
+
+// BatchWriteItem puts and deletes items, with the same limits as ddb: at most BatchWriteSize
+// requests and only one request per item. Nothing is written if the input breaks them, and it
+// never leaves items unprocessed.
+func (m *MemoryDB) BatchWriteItem(_ context.Context, input *dynamodb.BatchWriteItemInput,
+	_ ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	type write struct {
+		item  map[string]types.AttributeValue
+		key   string
+		table string
+	}
+	var writes []write
+
+	for table, requests := range input.RequestItems {
+		seen := map[string]bool{}
+		for _, request := range requests {
+			w := write{table: table}
+			var k map[string]types.AttributeValue
+			switch {
+			case request.PutRequest != nil:
+				w.item, k = request.PutRequest.Item, request.PutRequest.Item
+			case request.DeleteRequest != nil:
+				k = request.DeleteRequest.Key
+			default:
+				return nil, fmt.Errorf("MemoryDB BatchWriteItem: empty request for table %v", table)
+			}
+
+			var err error
+			if w.key, err = m.itemKey(table, k); err != nil {
+				return nil, fmt.Errorf("MemoryDB BatchWriteItem: %w", err)
+			} else if seen[w.key] {
+				return nil, fmt.Errorf("MemoryDB BatchWriteItem: duplicate key %v of table %v", w.key, table)
+			}
+			seen[w.key] = true
+			writes = append(writes, w)
+		}
+	}
+	if len(writes) > BatchWriteSize {
+		return nil, fmt.Errorf("MemoryDB BatchWriteItem: %d requests is more than %d", len(writes), BatchWriteSize)
+	}
+
+	for _, w := range writes {
+		if w.item == nil {
+			delete(m.tables[w.table], w.key)
+			continue
+		}
+		if m.tables[w.table] == nil {
+			m.tables[w.table] = map[string]map[string]types.AttributeValue{}
+		}
+		m.tables[w.table][w.key] = copyItem(w.item)
+	}
+
+	return &dynamodb.BatchWriteItemOutput{}, nil
+}
+
+// readInput is what Scan and Query have in common.
+type readInput struct {
+	filter   string
+	keyCond  string
+	limit    int32
+	names    map[string]string
+	segment  int32
+	segments int32
+	start    map[string]types.AttributeValue
+	table    string
+	values   map[string]types.AttributeValue
+}
+
+// read returns a page of the items of in.table and the key to continue after, which is nil after
+// the last page. Items that do not match the key condition do not count as read, like in ddb, but
+// items that do not match the filter do.
+func (m *MemoryDB) read(in readInput) ([]map[string]types.AttributeValue, map[string]types.AttributeValue, error) {
+	attr, ok := m.keys[in.table]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown table %v", in.table)
+	}
+
+	start := ""
+	if len(in.start) > 0 {
+		var err error
+		if start, err = m.itemKey(in.table, in.start); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	keys := make([]string, 0, len(m.tables[in.table]))
+	for key := range m.tables[in.table] {
+		if key > start && (in.segments <= 1 || keySegment(key, in.segments) == in.segment) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+
+	limit := int(in.limit)
+	if limit <= 0 {
+		limit = m.PageSize
+	}
+
+	var items []map[string]types.AttributeValue
+	read := 0
+	for i, key := range keys {
+		item := m.tables[in.table][key]
+		if in.keyCond != "" {
+			ok, err := evalCondition(in.keyCond, in.names, in.values, item)
+			if err != nil {
+				return nil, nil, err
+			} else if !ok {
+				continue
+			}
+		}
+		read++
+
+		match := true
+		if in.filter != "" {
+			var err error
+			if match, err = evalCondition(in.filter, in.names, in.values, item); err != nil {
+				return nil, nil, err
+			}
+		}
+		if match {
+			items = append(items, copyItem(item))
+		}
+
+		if limit > 0 && read == limit && i < len(keys)-1 {
+			return items, map[string]types.AttributeValue{attr: &types.AttributeValueMemberS{Value: key}}, nil
+		}
+	}
+
+	return items, nil, nil
+}
+
+// keySegment returns the scan segment of key out of segments.
+func keySegment(key string, segments int32) int32 {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+
+	return int32(h.Sum32() % uint32(segments))
+}
+
+// Save writes every table to w as JSON, so that it can be read back with Load.
+func (m *MemoryDB) Save(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	data := map[string][]map[string]any{}
+	for table, items := range m.tables {
+		for _, item := range items {
+			var plain map[string]any
+			if err := attributevalue.UnmarshalMap(item, &plain); err != nil {
+				return fmt.Errorf("MemoryDB Save: %w", err)
+			}
+			data[table] = append(data[table], plain)
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+	if err := enc.Encode(data); err != nil {
+		return fmt.Errorf("MemoryDB Save: %w", err)
+	}
+
+	return nil
+}
+
+// Load reads tables that were written by Save and puts their items.
+func (m *MemoryDB) Load(r io.Reader) error {
+	data := map[string][]map[string]any{}
+	if err := json.NewDecoder(r).Decode(&data); err != nil {
+		return fmt.Errorf("MemoryDB Load: %w", err)
+	}
+
+	for table, items := range data {
+		for _, plain := range items {
+			item, err := attributevalue.MarshalMap(plain)
+			if err != nil {
+				return fmt.Errorf("MemoryDB Load: %w", err)
+			}
+
+			input := &dynamodb.PutItemInput{TableName: aws.String(table), Item: item}
+			if _, err := m.PutItem(context.Background(), input); err != nil {
+				return fmt.Errorf("MemoryDB Load: %w", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// itemKey returns the string value of the hash key of table in item.
+func (m *MemoryDB) itemKey(table string, item map[string]types.AttributeValue) (string, error) {
+	attr, ok := m.keys[table]
+	if !ok {
+		return "", fmt.Errorf("MemoryDB: unknown table %v", table)
+	}
+
+	key, ok := item[attr].(*types.AttributeValueMemberS)
+	if !ok {
+		return "", fmt.Errorf("MemoryDB: missing string key %v of table %v", attr, table)
+	}
+
+	return key.Value, nil
+}
+
+// copyItem copies item deeply, so that callers can not change stored items.
+func copyItem(item map[string]types.AttributeValue) map[string]types.AttributeValue {
+	if item == nil {
+		return nil
+	}
+
+	copied := make(map[string]types.AttributeValue, len(item))
+	for name, value := range item {
+		copied[name] = copyValue(value)
+	}
+
+	return copied
+}
+
+func copyValue(value types.AttributeValue) types.AttributeValue {
+	switch v := value.(type) {
+	case *types.AttributeValueMemberM:
+		return &types.AttributeValueMemberM{Value: copyItem(v.Value)}
+	case *types.AttributeValueMemberL:
+		list := make([]types.AttributeValue, len(v.Value))
+		for i, elem := range v.Value {
+			list[i] = copyValue(elem)
+		}
+		return &types.AttributeValueMemberL{Value: list}
+	case *types.AttributeValueMemberS:
+		return &types.AttributeValueMemberS{Value: v.Value}
+	case *types.AttributeValueMemberN:
+		return &types.AttributeValueMemberN{Value: v.Value}
+	case *types.AttributeValueMemberBOOL:
+		return &types.AttributeValueMemberBOOL{Value: v.Value}
+	case *types.AttributeValueMemberB:
+		return &types.AttributeValueMemberB{Value: slices.Clone(v.Value)}
+	case *types.AttributeValueMemberSS:
+		return &types.AttributeValueMemberSS{Value: slices.Clone(v.Value)}
+	case *types.AttributeValueMemberNS:
+		return &types.AttributeValueMemberNS{Value: slices.Clone(v.Value)}
+	default:
+		return value
+	}
+}
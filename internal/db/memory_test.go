@@ -0,0 +1,167 @@
+package db_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/mshort55/prayertexter/internal/db"
+	"github.com/mshort55/prayertexter/internal/object"
+)
+
+func TestMemoryDB(t *testing.T) {
+	memDB := db.NewMemoryDB(object.TableKeys())
+
+	members := []object.Member{
+		{Intercessor: true, Name: "Intercessor1", Phone: "+11111111111", SetupStatus: "completed"},
+		{Name: "John Doe", Phone: "+11234567890", SetupStatus: "completed"},
+		{Phone: "+12222222222", SetupStatus: "in-progress"},
+	}
+	for _, mem := range members {
+		if err := db.PutDdbObject(memDB, object.MemberTable, &mem); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+
+	t.Run("Get", func(t *testing.T) {
+		mem, err := db.GetDdbObject[object.Member](memDB, object.MemberAttribute, "+11234567890", object.MemberTable)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		} else if *mem != members[1] {
+			t.Errorf("expected %v, got %v", members[1], *mem)
+		}
+	})
+
+	t.Run("Unknown table", func(t *testing.T) {
+		_, err := db.GetDdbObject[object.Member](memDB, object.MemberAttribute, "+11234567890", "Unknown")
+		if err == nil {
+			t.Errorf("expected error for unknown table, got nil")
+		}
+	})
+
+	t.Run("Conditional put", func(t *testing.T) {
+		item := map[string]types.AttributeValue{"Phone": &types.AttributeValueMemberS{Value: "+11111111111"}}
+		_, err := memDB.PutItem(context.Background(), &dynamodb.PutItemInput{
+			TableName:                aws.String(object.MemberTable),
+			Item:                     item,
+			ConditionExpression:      aws.String("attribute_not_exists(#p)"),
+			ExpressionAttributeNames: map[string]string{"#p": "Phone"},
+		})
+
+		var ccfe *types.ConditionalCheckFailedException
+		if !errors.As(err, &ccfe) {
+			t.Errorf("expected ConditionalCheckFailedException, got %v", err)
+		}
+	})
+
+	t.Run("Scan with filter", func(t *testing.T) {
+		out, err := memDB.Scan(context.Background(), &dynamodb.ScanInput{
+			TableName: aws.String(object.MemberTable),
+			FilterExpression: aws.String(
+				"(#i = :true OR begins_with(#n, :john)) AND NOT attribute_not_exists(#s)"),
+			ExpressionAttributeNames: map[string]string{"#i": "Intercessor", "#n": "Name", "#s": "SetupStatus"},
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":true": &types.AttributeValueMemberBOOL{Value: true},
+				":john": &types.AttributeValueMemberS{Value: "John"},
+			},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+
+		if len(out.Items) != 2 {
+			t.Errorf("expected 2 items, got %v", len(out.Items))
+		}
+	})
+
+	t.Run("Equality of maps, lists and sets", func(t *testing.T) {
+		item := map[string]types.AttributeValue{
+			"Key": &types.AttributeValueMemberS{Value: "Stats"},
+			"Map": &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+				"List": &types.AttributeValueMemberL{Value: []types.AttributeValue{&types.AttributeValueMemberN{Value: "1"}}},
+			}},
+			"Set": &types.AttributeValueMemberSS{Value: []string{"a", "b"}},
+		}
+		if _, err := memDB.PutItem(context.Background(), &dynamodb.PutItemInput{
+			TableName: aws.String(object.StateTrackerTable),
+			Item:      item,
+		}); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+
+		for _, test := range []struct {
+			value    types.AttributeValue
+			expected int
+		}{
+			{value: &types.AttributeValueMemberSS{Value: []string{"b", "a"}}, expected: 1},
+			{value: &types.AttributeValueMemberSS{Value: []string{"a"}}, expected: 0},
+			{value: item["Map"], expected: 1},
+			{value: &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+				"List": &types.AttributeValueMemberL{Value: []types.AttributeValue{&types.AttributeValueMemberN{Value: "2"}}},
+			}}, expected: 0},
+		} {
+			name := "Set"
+			if _, ok := test.value.(*types.AttributeValueMemberM); ok {
+				name = "Map"
+			}
+			out, err := memDB.Scan(context.Background(), &dynamodb.ScanInput{
+				TableName:                 aws.String(object.StateTrackerTable),
+				FilterExpression:          aws.String("#a = :v"),
+				ExpressionAttributeNames:  map[string]string{"#a": name},
+				ExpressionAttributeValues: map[string]types.AttributeValue{":v": test.value},
+			})
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if len(out.Items) != test.expected {
+				t.Errorf("expected %v items with %v = %v, got %v", test.expected, name, test.value, len(out.Items))
+			}
+		}
+	})
+
+	t.Run("Unsupported expression", func(t *testing.T) {
+		_, err := memDB.Scan(context.Background(), &dynamodb.ScanInput{
+			TableName:        aws.String(object.MemberTable),
+			FilterExpression: aws.String("size(Name) > :n"),
+		})
+		if !errors.Is(err, db.ErrExpression) {
+			t.Errorf("expected ErrExpression, got %v", err)
+		}
+	})
+
+	t.Run("Save and load", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := memDB.Save(&buf); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+
+		loaded := db.NewMemoryDB(object.TableKeys())
+		if err := loaded.Load(&buf); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+
+		mem, err := db.GetDdbObject[object.Member](loaded, object.MemberAttribute, "+11111111111", object.MemberTable)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		} else if *mem != members[0] {
+			t.Errorf("expected %v, got %v", members[0], *mem)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		if err := db.DelDdbItem(memDB, object.MemberAttribute, "+12222222222", object.MemberTable); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+
+		mem, err := db.GetDdbObject[object.Member](memDB, object.MemberAttribute, "+12222222222", object.MemberTable)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		} else if mem.Phone != "" {
+			t.Errorf("expected deleted member, got %v", *mem)
+		}
+	})
+}
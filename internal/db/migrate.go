@@ -0,0 +1,254 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// SchemaVersionAttribute holds the schema version that a Versioned object was stored with. Items
+// that were stored before schema versions existed do not have it and are version 1.
+const SchemaVersionAttribute = "SchemaVersion"
+
+// ErrSchemaVersion is returned for items that were stored by newer code with a schema version
+// that is not known yet. They are not read, because putting them back would lose their changes.
+var ErrSchemaVersion = errors.New("unknown schema version")
+
+// Migration upgrades a stored item from one schema version to the next, in place. It works on the
+// raw item because the item does not match the current struct anymore. Items are copied before
+// they are migrated, but only the top level map, so nested maps and lists must be replaced
+// instead of changed.
+type Migration func(item map[string]types.AttributeValue) error
+
+// Schema is the current schema version of an object and the migrations to it. Migrations[v]
+// upgrades items from version v to v+1, so every version below Version needs one.
+type Schema struct {
+	Migrations map[int]Migration
+	Version    int
+}
+
+// Versioned objects are stored with their schema version and are migrated when they are read. An
+// object whose struct changes in a way that existing items do not unmarshal into, for example a
+// renamed or retyped field, gets a new version and a migration to it.
+type Versioned interface {
+	Schema() Schema
+}
+
+// schemaOf returns the Schema of T, and false if T is not Versioned.
+func schemaOf[T any]() (Schema, bool) {
+	if v, ok := any(new(T)).(Versioned); ok {
+		return v.Schema(), true
+	}
+
+	return Schema{}, false
+}
+
+// ItemSchemaVersion returns the schema version that item was stored with.
+func ItemSchemaVersion(item map[string]types.AttributeValue) (int, error) {
+	attr, ok := item[SchemaVersionAttribute]
+	if !ok {
+		return 1, nil
+	}
+
+	n, ok := attr.(*types.AttributeValueMemberN)
+	if !ok {
+		return 0, fmt.Errorf("ItemSchemaVersion %T: %w", attr, ErrSchemaVersion)
+	}
+	version, err := strconv.Atoi(n.Value)
+	if err != nil {
+		return 0, fmt.Errorf("ItemSchemaVersion: %w", err)
+	}
+
+	return version, nil
+}
+
+// MigrateItem upgrades item to the schema version of T in place and returns whether it changed.
+// Items of objects that are not Versioned and empty items, which do not exist, are left as they
+// are.
+func MigrateItem[T any](item map[string]types.AttributeValue) (bool, error) {
+	schema, ok := schemaOf[T]()
+	if !ok || len(item) == 0 {
+		return false, nil
+	}
+
+	version, err := ItemSchemaVersion(item)
+	if err != nil {
+		return false, err
+	}
+	if version > schema.Version {
+		return false, fmt.Errorf("MigrateItem version %d is newer than %d: %w", version, schema.Version,
+			ErrSchemaVersion)
+	}
+
+	migrated := false
+	for ; version < schema.Version; version++ {
+		migration, ok := schema.Migrations[version]
+		if !ok {
+			return false, fmt.Errorf("MigrateItem no migration from version %d: %w", version, ErrSchemaVersion)
+		}
+		if err := migration(item); err != nil {
+			return false, fmt.Errorf("MigrateItem from version %d: %w", version, err)
+		}
+		migrated = true
+	}
+	item[SchemaVersionAttribute] = &types.AttributeValueMemberN{Value: strconv.Itoa(schema.Version)}
+
+	return migrated, nil
+}
+
+// marshalObject marshals object and adds the schema version of Versioned objects.
+func marshalObject[T any](object *T) (map[string]types.AttributeValue, error) {
+	item, err := attributevalue.MarshalMap(object)
+	if err != nil {
+		return nil, err
+	}
+
+	if schema, ok := schemaOf[T](); ok {
+		item[SchemaVersionAttribute] = &types.AttributeValueMemberN{Value: strconv.Itoa(schema.Version)}
+	}
+
+	return item, nil
+}
+
+// migrateCopy returns a migrated copy of item, so that the item of the response is not changed.
+func migrateCopy[T any](item map[string]types.AttributeValue) (map[string]types.AttributeValue, error) {
+	item = maps.Clone(item)
+	if _, err := MigrateItem[T](item); err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+// MigrationPage is the result of migrating one page of a table. LastKey is where the next page
+// starts, and is empty after the last page.
+type MigrationPage struct {
+	LastKey  map[string]types.AttributeValue
+	Migrated int
+	Scanned  int
+	// Skipped counts items that changed or were deleted while they were migrated. Changed items
+	// were put by current code and are up to date already.
+	Skipped int
+}
+
+// MigrateTable upgrades every item of T in table that matches filter, starting after startKey
+// (from the beginning if it is empty), and puts the items that changed back. Migrated items are
+// only put if the stored item still has the schema version that it was read with, so that writes
+// that happen in the meantime are not lost. page is called after every page, for example to save
+// progress so that an interrupted migration can resume from LastKey, and stops the migration if it
+// returns an error.
+func MigrateTable[T any](ddbClnt DDBConnecter, attr, table string, filter Condition,
+	startKey map[string]types.AttributeValue, page func(MigrationPage) error) error {
+	input := &dynamodb.ScanInput{TableName: &table}
+	if filter.Expression != "" {
+		input.FilterExpression = &filter.Expression
+		input.ExpressionAttributeNames = filter.Names
+		input.ExpressionAttributeValues = filter.Values
+	}
+	if len(startKey) > 0 {
+		input.ExclusiveStartKey = startKey
+	}
+
+	for {
+		resp, err := ddbClnt.Scan(context.TODO(), input)
+		if err != nil {
+			return fmt.Errorf("MigrateTable: %w", err)
+		}
+
+		result := MigrationPage{LastKey: resp.LastEvaluatedKey, Scanned: len(resp.Items)}
+		for _, item := range resp.Items {
+			migrated, put, err := migrateStoredItem[T](ddbClnt, attr, table, item)
+			if err != nil {
+				return fmt.Errorf("MigrateTable: %w", err)
+			}
+			if put {
+				result.Migrated++
+			} else if migrated {
+				result.Skipped++
+			}
+		}
+
+		if err := page(result); err != nil {
+			return fmt.Errorf("MigrateTable: %w", err)
+		}
+		if len(resp.LastEvaluatedKey) == 0 {
+			return nil
+		}
+		input.ExclusiveStartKey = resp.LastEvaluatedKey
+	}
+}
+
+// migrateStoredItem migrates item and puts it back if it changed. It returns whether item needed
+// a migration and whether it was put. Migrated items are only put if the stored item is still the
+// scanned item, see unchangedCondition.
+func migrateStoredItem[T any](ddbClnt DDBConnecter, attr, table string,
+	item map[string]types.AttributeValue) (bool, bool, error) {
+	scanned := copyItem(item)
+
+	migrated, err := MigrateItem[T](item)
+	if err != nil || !migrated {
+		return false, false, err
+	}
+
+	cond := unchangedCondition(attr, scanned)
+	_, err = ddbClnt.PutItem(context.TODO(), &dynamodb.PutItemInput{
+		TableName:                 &table,
+		Item:                      item,
+		ConditionExpression:       &cond.Expression,
+		ExpressionAttributeNames:  cond.Names,
+		ExpressionAttributeValues: cond.Values,
+	})
+
+	var condErr *types.ConditionalCheckFailedException
+	if errors.As(err, &condErr) {
+		return true, false, nil
+	} else if err != nil {
+		return true, false, err
+	}
+
+	return true, true, nil
+}
+
+// unchangedCondition is true if the stored item still is item, the item as it was scanned. Items
+// with a Version are compared by their Version, which every put of them changes. Other items are
+// compared attribute by attribute, since during a rolling deploy they are also put by older code,
+// which may store them with the same schema version or without one. Items that were scanned
+// without a schema version must still not have one.
+func unchangedCondition(attr string, item map[string]types.AttributeValue) Condition {
+	cond := Condition{
+		Names:  map[string]string{"#key": attr},
+		Values: map[string]types.AttributeValue{},
+	}
+	clauses := []string{"attribute_exists(#key)"}
+
+	names := slices.Sorted(maps.Keys(item))
+	if _, ok := item[VersionAttribute]; ok {
+		names = []string{VersionAttribute}
+	}
+	names = slices.DeleteFunc(names, func(name string) bool { return name == attr })
+	for i, name := range names {
+		cond.Names[fmt.Sprintf("#a%d", i)] = name
+		cond.Values[fmt.Sprintf(":a%d", i)] = item[name]
+		clauses = append(clauses, fmt.Sprintf("#a%d = :a%d", i, i))
+	}
+
+	if _, ok := item[SchemaVersionAttribute]; !ok {
+		cond.Names["#schema"] = SchemaVersionAttribute
+		clauses = append(clauses, "attribute_not_exists(#schema)")
+	}
+
+	cond.Expression = strings.Join(clauses, " AND ")
+	if len(cond.Values) == 0 {
+		cond.Values = nil
+	}
+
+	return cond
+}
@@ -0,0 +1,265 @@
+package db_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/mshort55/prayertexter/internal/db"
+	"github.com/mshort55/prayertexter/internal/mock"
+)
+
+// contact is at schema version 3: version 2 renamed Number to Phone, and version 3 changed Count
+// from a string to a number.
+type contact struct {
+	Count int
+	ID    string
+	Phone string
+}
+
+func (c *contact) Schema() db.Schema {
+	return db.Schema{
+		Version: 3,
+		Migrations: map[int]db.Migration{
+			1: func(item map[string]types.AttributeValue) error {
+				item["Phone"] = item["Number"]
+				delete(item, "Number")
+				return nil
+			},
+			2: func(item map[string]types.AttributeValue) error {
+				if s, ok := item["Count"].(*types.AttributeValueMemberS); ok {
+					n, err := strconv.Atoi(s.Value)
+					if err != nil {
+						return err
+					}
+					item["Count"] = &types.AttributeValueMemberN{Value: strconv.Itoa(n)}
+				}
+				return nil
+			},
+		},
+	}
+}
+
+func oldContact(id string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"Count":  &types.AttributeValueMemberS{Value: "7"},
+		"ID":     &types.AttributeValueMemberS{Value: id},
+		"Number": &types.AttributeValueMemberS{Value: "+11234567890"},
+	}
+}
+
+func TestMigrateItem(t *testing.T) {
+	item := oldContact("1")
+	if migrated, err := db.MigrateItem[contact](item); err != nil || !migrated {
+		t.Fatalf("expected item to be migrated, got %v (error %v)", migrated, err)
+	}
+	if version, _ := db.ItemSchemaVersion(item); version != 3 {
+		t.Errorf("expected schema version 3, got %v", version)
+	}
+
+	// up to date items are not migrated again
+	if migrated, err := db.MigrateItem[contact](item); err != nil || migrated {
+		t.Errorf("expected item not to be migrated, got %v (error %v)", migrated, err)
+	}
+
+	newer := oldContact("1")
+	newer[db.SchemaVersionAttribute] = &types.AttributeValueMemberN{Value: "4"}
+	if _, err := db.MigrateItem[contact](newer); !errors.Is(err, db.ErrSchemaVersion) {
+		t.Errorf("expected ErrSchemaVersion for newer item, got %v", err)
+	}
+
+	broken := oldContact("1")
+	broken["Count"] = &types.AttributeValueMemberS{Value: "seven"}
+	if _, err := db.MigrateItem[contact](broken); err == nil {
+		t.Errorf("expected error for failed migration, got nil")
+	}
+}
+
+func TestGetDdbObjectMigrates(t *testing.T) {
+	item := oldContact("1")
+	ddbMock := &mock.DDBConnecter{}
+	ddbMock.GetItemResults = []struct {
+		Output *dynamodb.GetItemOutput
+		Error  error
+	}{
+		{Output: &dynamodb.GetItemOutput{Item: item}},
+	}
+
+	c, err := db.GetDdbObject[contact](ddbMock, "ID", "1", "Contacts")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	expected := contact{Count: 7, ID: "1", Phone: "+11234567890"}
+	if *c != expected {
+		t.Errorf("expected %v, got %v", expected, *c)
+	}
+
+	// the response is not changed
+	if _, ok := item["Number"]; !ok || len(item) != 3 {
+		t.Errorf("expected item of response not to change, got %v", item)
+	}
+}
+
+func TestMigrateTable(t *testing.T) {
+	ddbClnt := db.NewMemoryDB(map[string]string{"Contacts": "ID"})
+	for _, item := range []map[string]types.AttributeValue{oldContact("1"), oldContact("2")} {
+		if _, err := ddbClnt.PutItem(context.Background(), &dynamodb.PutItemInput{
+			TableName: aws.String("Contacts"),
+			Item:      item,
+		}); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+	current := contact{Count: 1, ID: "3", Phone: "+12222222222"}
+	if err := db.PutDdbObject(ddbClnt, "Contacts", &current); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	var pages []db.MigrationPage
+	err := db.MigrateTable[contact](ddbClnt, "ID", "Contacts", db.Condition{}, nil, func(page db.MigrationPage) error {
+		pages = append(pages, page)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if len(pages) != 1 || pages[0].Scanned != 3 || pages[0].Migrated != 2 || pages[0].Skipped != 0 {
+		t.Errorf("expected 1 page with 3 scanned and 2 migrated items, got %+v", pages)
+	}
+
+	out, err := ddbClnt.GetItem(context.Background(), &dynamodb.GetItemInput{
+		TableName: aws.String("Contacts"),
+		Key:       map[string]types.AttributeValue{"ID": &types.AttributeValueMemberS{Value: "1"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if version, _ := db.ItemSchemaVersion(out.Item); version != 3 {
+		t.Errorf("expected stored item at schema version 3, got %v", out.Item)
+	}
+}
+
+func TestMigrateTableResume(t *testing.T) {
+	ddbMock := &mock.DDBConnecter{}
+	ddbMock.ScanResults = []struct {
+		Output *dynamodb.ScanOutput
+		Error  error
+	}{
+		{
+			Output: &dynamodb.ScanOutput{
+				Items:            []map[string]types.AttributeValue{oldContact("2")},
+				LastEvaluatedKey: map[string]types.AttributeValue{"ID": &types.AttributeValueMemberS{Value: "2"}},
+			},
+		},
+		{Output: &dynamodb.ScanOutput{Items: []map[string]types.AttributeValue{oldContact("3")}}},
+	}
+	// item 3 changed after it was scanned
+	ddbMock.PutItemResults = []struct {
+		Error error
+	}{
+		{Error: nil},
+		{Error: &types.ConditionalCheckFailedException{}},
+	}
+
+	startKey := map[string]types.AttributeValue{"ID": &types.AttributeValueMemberS{Value: "1"}}
+	errStop := errors.New("stop")
+	var pages []db.MigrationPage
+	err := db.MigrateTable[contact](ddbMock, "ID", "Contacts", db.Condition{}, startKey,
+		func(page db.MigrationPage) error {
+			pages = append(pages, page)
+			if len(pages) == 2 {
+				return errStop
+			}
+			return nil
+		})
+	if !errors.Is(err, errStop) {
+		t.Errorf("expected error of page to stop the migration, got %v", err)
+	}
+
+	if ddbMock.ScanInputs[0].ExclusiveStartKey["ID"].(*types.AttributeValueMemberS).Value != "1" {
+		t.Errorf("expected scan to start after key 1, got %v", ddbMock.ScanInputs[0].ExclusiveStartKey)
+	}
+	if ddbMock.ScanInputs[1].ExclusiveStartKey["ID"].(*types.AttributeValueMemberS).Value != "2" {
+		t.Errorf("expected second scan to start after key 2, got %v", ddbMock.ScanInputs[1].ExclusiveStartKey)
+	}
+
+	if len(pages) != 2 || pages[0].Migrated != 1 || pages[1].Skipped != 1 || len(pages[1].LastKey) != 0 {
+		t.Errorf("expected a migrated and a skipped item, got %+v", pages)
+	}
+	expectedCond := "attribute_exists(#key) AND #a0 = :a0 AND #a1 = :a1 AND attribute_not_exists(#schema)"
+	if *ddbMock.PutItemInputs[0].ConditionExpression != expectedCond {
+		t.Errorf("expected put only if the item was not changed, got %v", *ddbMock.PutItemInputs[0].ConditionExpression)
+	}
+}
+
+// racingDB puts the items in writes right after every scan, like flows that put items while a
+// migration runs.
+type racingDB struct {
+	*db.MemoryDB
+	writes []map[string]types.AttributeValue
+}
+
+func (r *racingDB) Scan(ctx context.Context, input *dynamodb.ScanInput,
+	opts ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	out, err := r.MemoryDB.Scan(ctx, input, opts...)
+	for _, item := range r.writes {
+		if _, err := r.PutItem(ctx, &dynamodb.PutItemInput{TableName: aws.String("Contacts"), Item: item}); err != nil {
+			return nil, err
+		}
+	}
+
+	return out, err
+}
+
+func TestMigrateTableConcurrentWrite(t *testing.T) {
+	// older code still running during a deploy puts items without a schema version, and code at
+	// the current version puts versioned items
+	changed := oldContact("1")
+	changed["Count"] = &types.AttributeValueMemberS{Value: "8"}
+	versioned := oldContact("2")
+	versioned["Version"] = &types.AttributeValueMemberN{Value: "4"}
+	bumped := oldContact("2")
+	bumped["Version"] = &types.AttributeValueMemberN{Value: "5"}
+
+	ddbClnt := &racingDB{MemoryDB: db.NewMemoryDB(map[string]string{"Contacts": "ID"})}
+	for _, item := range []map[string]types.AttributeValue{oldContact("1"), versioned, oldContact("3")} {
+		if _, err := ddbClnt.PutItem(context.Background(), &dynamodb.PutItemInput{
+			TableName: aws.String("Contacts"),
+			Item:      item,
+		}); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+	ddbClnt.writes = []map[string]types.AttributeValue{changed, bumped}
+
+	var pages []db.MigrationPage
+	err := db.MigrateTable[contact](ddbClnt, "ID", "Contacts", db.Condition{}, nil, func(page db.MigrationPage) error {
+		pages = append(pages, page)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(pages) != 1 || pages[0].Migrated != 1 || pages[0].Skipped != 2 {
+		t.Errorf("expected only the unchanged item to be migrated, got %+v", pages)
+	}
+
+	for id, count := range map[string]string{"1": "8", "2": "7"} {
+		out, err := ddbClnt.GetItem(context.Background(), &dynamodb.GetItemInput{
+			TableName: aws.String("Contacts"),
+			Key:       map[string]types.AttributeValue{"ID": &types.AttributeValueMemberS{Value: id}},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if c, ok := out.Item["Count"].(*types.AttributeValueMemberS); !ok || c.Value != count {
+			t.Errorf("expected concurrent write of item %v to be kept, got %v", id, out.Item)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ScanOptions change how ScanAll reads a table. The zero value reads every item with one scan.
+type ScanOptions struct {
+	// Filter drops items that do not match it after they are read, so they still count towards
+	// the read capacity that the scan uses
+	Filter Condition
+	// Limit is the number of items that each request reads, 0 for as many as fit in a page
+	Limit int32
+	// Segments splits the table into that many segments that are scanned in parallel, 0 or 1 scan
+	// the table in one go
+	Segments int
+}
+
+// QueryOptions change how QueryAll reads a table.
+type QueryOptions struct {
+	// Descending returns items in descending order of their range key instead of ascending
+	Descending bool
+	// Filter drops items that do not match it after they are read
+	Filter Condition
+	// Index is the name of the global secondary index to query, or empty for the table itself
+	Index string
+	// Limit is the number of items that each request reads, 0 for as many as fit in a page
+	Limit int32
+}
+
+// ScanAll returns all objects of table that match opts.Filter, reading every page of every
+// segment. The objects of a parallel scan are returned by segment, and in the order of the table
+// within a segment.
+func ScanAll[T any](ddbClnt DDBConnecter, table string, opts ScanOptions) ([]T, error) {
+	input := &dynamodb.ScanInput{TableName: &table}
+	if opts.Filter.Expression != "" {
+		input.FilterExpression = &opts.Filter.Expression
+		input.ExpressionAttributeNames = opts.Filter.Names
+		input.ExpressionAttributeValues = opts.Filter.Values
+	}
+	if opts.Limit > 0 {
+		input.Limit = aws.Int32(opts.Limit)
+	}
+
+	if opts.Segments <= 1 {
+		objects, err := scanPages[T](ddbClnt, input)
+		if err != nil {
+			return nil, fmt.Errorf("ScanAll: %w", err)
+		}
+
+		return objects, nil
+	}
+
+	segments := make([][]T, opts.Segments)
+	errs := make([]error, opts.Segments)
+	var wg sync.WaitGroup
+	for segment := range opts.Segments {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			segInput := *input
+			segInput.Segment = aws.Int32(int32(segment))
+			segInput.TotalSegments = aws.Int32(int32(opts.Segments))
+			if segments[segment], errs[segment] = scanPages[T](ddbClnt, &segInput); errs[segment] != nil {
+				errs[segment] = fmt.Errorf("segment %d: %w", segment, errs[segment])
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("ScanAll: %w", err)
+	}
+
+	return slices.Concat(segments...), nil
+}
+
+func scanPages[T any](ddbClnt DDBConnecter, input *dynamodb.ScanInput) ([]T, error) {
+	var objects []T
+	for {
+		resp, err := ddbClnt.Scan(context.TODO(), input)
+		if err != nil {
+			return nil, err
+		}
+
+		page, err := unmarshalItems[T](resp.Items)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, page...)
+
+		if len(resp.LastEvaluatedKey) == 0 {
+			return objects, nil
+		}
+		input.ExclusiveStartKey = resp.LastEvaluatedKey
+	}
+}
+
+// QueryAll returns all objects of table (or of the index opts.Index) that match keyCond and
+// opts.Filter, reading every page of the query. keyCond must name the hash key, for example
+// "#phone = :phone". keyCond and opts.Filter share their names and values, so their placeholders
+// must not clash.
+func QueryAll[T any](ddbClnt DDBConnecter, table string, keyCond Condition, opts QueryOptions) ([]T, error) {
+	input := &dynamodb.QueryInput{
+		ExpressionAttributeNames:  maps.Clone(keyCond.Names),
+		ExpressionAttributeValues: maps.Clone(keyCond.Values),
+		KeyConditionExpression:    &keyCond.Expression,
+		ScanIndexForward:          aws.Bool(!opts.Descending),
+		TableName:                 &table,
+	}
+	if opts.Filter.Expression != "" {
+		input.FilterExpression = &opts.Filter.Expression
+		if input.ExpressionAttributeNames == nil {
+			input.ExpressionAttributeNames = map[string]string{}
+		}
+		maps.Copy(input.ExpressionAttributeNames, opts.Filter.Names)
+		if input.ExpressionAttributeValues == nil {
+			input.ExpressionAttributeValues = map[string]types.AttributeValue{}
+		}
+		maps.Copy(input.ExpressionAttributeValues, opts.Filter.Values)
+	}
+	if opts.Index != "" {
+		input.IndexName = &opts.Index
+	}
+	if opts.Limit > 0 {
+		input.Limit = aws.Int32(opts.Limit)
+	}
+
+	var objects []T
+	for {
+		resp, err := ddbClnt.Query(context.TODO(), input)
+		if err != nil {
+			return nil, fmt.Errorf("QueryAll: %w", err)
+		}
+
+		page, err := unmarshalItems[T](resp.Items)
+		if err != nil {
+			return nil, fmt.Errorf("QueryAll: %w", err)
+		}
+		objects = append(objects, page...)
+
+		if len(resp.LastEvaluatedKey) == 0 {
+			return objects, nil
+		}
+		input.ExclusiveStartKey = resp.LastEvaluatedKey
+	}
+}
+
+// unmarshalItems migrates items and unmarshals them.
+func unmarshalItems[T any](items []map[string]types.AttributeValue) ([]T, error) {
+	migrated := make([]map[string]types.AttributeValue, len(items))
+	for i, item := range items {
+		var err error
+		if migrated[i], err = migrateCopy[T](item); err != nil {
+			return nil, err
+		}
+	}
+
+	var objects []T
+	if err := attributevalue.UnmarshalListOfMaps(migrated, &objects); err != nil {
+		return nil, fmt.Errorf("failed unmarshal: %w", err)
+	}
+
+	return objects, nil
+}
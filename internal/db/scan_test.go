@@ -0,0 +1,215 @@
+package db_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/mshort55/prayertexter/internal/db"
+	"github.com/mshort55/prayertexter/internal/mock"
+	"github.com/mshort55/prayertexter/internal/object"
+)
+
+func testMembers(t *testing.T, ddbClnt db.DDBConnecter, phones ...string) {
+	t.Helper()
+
+	for i, phone := range phones {
+		mem := object.Member{Intercessor: i%2 == 0, Phone: phone}
+		if err := mem.Put(ddbClnt); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+}
+
+func memberPhones(mems []object.Member) []string {
+	phones := make([]string, len(mems))
+	for i, mem := range mems {
+		phones[i] = mem.Phone
+	}
+
+	return phones
+}
+
+func TestScanAll(t *testing.T) {
+	memDB := db.NewMemoryDB(object.TableKeys())
+	memDB.PageSize = 2
+	phones := []string{"+11111111111", "+12222222222", "+13333333333", "+14444444444", "+15555555555"}
+	testMembers(t, memDB, phones...)
+
+	t.Run("Pages", func(t *testing.T) {
+		mems, err := db.ScanAll[object.Member](memDB, object.MemberTable, db.ScanOptions{})
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if got := memberPhones(mems); !reflect.DeepEqual(got, phones) {
+			t.Errorf("expected %v, got %v", phones, got)
+		}
+	})
+
+	t.Run("Filter and limit", func(t *testing.T) {
+		filter := db.Condition{
+			Expression: "#intercessor = :intercessor",
+			Names:      map[string]string{"#intercessor": "Intercessor"},
+			Values:     map[string]types.AttributeValue{":intercessor": &types.AttributeValueMemberBOOL{Value: true}},
+		}
+		mems, err := db.ScanAll[object.Member](memDB, object.MemberTable, db.ScanOptions{Filter: filter, Limit: 1})
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+
+		expected := []string{phones[0], phones[2], phones[4]}
+		if got := memberPhones(mems); !reflect.DeepEqual(got, expected) {
+			t.Errorf("expected %v, got %v", expected, got)
+		}
+	})
+
+	t.Run("Segments", func(t *testing.T) {
+		mems, err := db.ScanAll[object.Member](memDB, object.MemberTable, db.ScanOptions{Segments: 3})
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+
+		got := memberPhones(mems)
+		slices.Sort(got)
+		if !reflect.DeepEqual(got, phones) {
+			t.Errorf("expected every member once, got %v", got)
+		}
+	})
+
+	t.Run("Segment error", func(t *testing.T) {
+		ddbMock := &mock.DDBConnecter{}
+		ddbMock.ScanResults = []struct {
+			Output *dynamodb.ScanOutput
+			Error  error
+		}{
+			{Output: &dynamodb.ScanOutput{}},
+			{Error: errors.New("scan failure")},
+		}
+
+		if _, err := db.ScanAll[object.Member](ddbMock, object.MemberTable, db.ScanOptions{Segments: 2}); err == nil {
+			t.Errorf("expected error of failed segment, got nil")
+		}
+
+		segments := []int32{*ddbMock.ScanInputs[0].Segment, *ddbMock.ScanInputs[1].Segment}
+		slices.Sort(segments)
+		if !reflect.DeepEqual(segments, []int32{0, 1}) || *ddbMock.ScanInputs[0].TotalSegments != 2 {
+			t.Errorf("expected segments 0 and 1 of 2, got %v", segments)
+		}
+	})
+}
+
+func TestQueryAll(t *testing.T) {
+	t.Run("Memory", func(t *testing.T) {
+		memDB := db.NewMemoryDB(object.TableKeys())
+		testMembers(t, memDB, "+11111111111", "+12222222222")
+
+		keyCond := db.Condition{
+			Expression: "#phone = :phone",
+			Names:      map[string]string{"#phone": object.MemberAttribute},
+			Values:     map[string]types.AttributeValue{":phone": &types.AttributeValueMemberS{Value: "+12222222222"}},
+		}
+		mems, err := db.QueryAll[object.Member](memDB, object.MemberTable, keyCond, db.QueryOptions{})
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if len(mems) != 1 || mems[0].Phone != "+12222222222" {
+			t.Errorf("expected member +12222222222, got %v", mems)
+		}
+	})
+
+	t.Run("Pages", func(t *testing.T) {
+		ddbMock := &mock.DDBConnecter{}
+		ddbMock.QueryResults = []struct {
+			Output *dynamodb.QueryOutput
+			Error  error
+		}{
+			{
+				Output: &dynamodb.QueryOutput{
+					Items: []map[string]types.AttributeValue{
+						{"Phone": &types.AttributeValueMemberS{Value: "+11111111111"}},
+					},
+					LastEvaluatedKey: map[string]types.AttributeValue{
+						"Phone": &types.AttributeValueMemberS{Value: "+11111111111"},
+					},
+				},
+			},
+			{
+				Output: &dynamodb.QueryOutput{
+					Items: []map[string]types.AttributeValue{
+						{"Phone": &types.AttributeValueMemberS{Value: "+12222222222"}},
+					},
+				},
+			},
+			{Error: errors.New("query failure")},
+		}
+
+		keyCond := db.Condition{
+			Expression: "#tenant = :tenant",
+			Names:      map[string]string{"#tenant": "TenantPhone"},
+			Values:     map[string]types.AttributeValue{":tenant": &types.AttributeValueMemberS{Value: "+19999999999"}},
+		}
+		opts := db.QueryOptions{
+			Descending: true,
+			Filter: db.Condition{
+				Expression: "#intercessor = :intercessor",
+				Names:      map[string]string{"#intercessor": "Intercessor"},
+				Values:     map[string]types.AttributeValue{":intercessor": &types.AttributeValueMemberBOOL{Value: true}},
+			},
+			Index: "ByTenant",
+		}
+
+		mems, err := db.QueryAll[object.Member](ddbMock, object.MemberTable, keyCond, opts)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if got := memberPhones(mems); !reflect.DeepEqual(got, []string{"+11111111111", "+12222222222"}) {
+			t.Errorf("expected members of both pages, got %v", got)
+		}
+
+		input := ddbMock.QueryInputs[0]
+		if aws.ToString(input.IndexName) != "ByTenant" || aws.ToBool(input.ScanIndexForward) ||
+			len(input.ExpressionAttributeNames) != 2 || len(input.ExpressionAttributeValues) != 2 {
+			t.Errorf("expected descending query of ByTenant with key condition and filter, got %+v", input)
+		}
+		if ddbMock.QueryInputs[1].ExclusiveStartKey == nil {
+			t.Errorf("expected second query to continue after the first page")
+		}
+		// the key condition is not changed by adding the filter
+		if len(keyCond.Names) != 1 {
+			t.Errorf("expected key condition names not to change, got %v", keyCond.Names)
+		}
+
+		if _, err := db.QueryAll[object.Member](ddbMock, object.MemberTable, keyCond, opts); err == nil ||
+			!strings.Contains(err.Error(), "query failure") {
+			t.Errorf("expected query failure, got %v", err)
+		}
+	})
+}
+
+func TestMemoryDBScanPages(t *testing.T) {
+	memDB := db.NewMemoryDB(object.TableKeys())
+	testMembers(t, memDB, "+11111111111", "+12222222222", "+13333333333")
+
+	input := &dynamodb.ScanInput{TableName: aws.String(object.MemberTable), Limit: aws.Int32(2)}
+	out, err := memDB.Scan(context.Background(), input)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(out.Items) != 2 || out.LastEvaluatedKey == nil {
+		t.Fatalf("expected first page of 2 items, got %v items and last key %v", len(out.Items), out.LastEvaluatedKey)
+	}
+
+	input.ExclusiveStartKey = out.LastEvaluatedKey
+	if out, err = memDB.Scan(context.Background(), input); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(out.Items) != 1 || out.LastEvaluatedKey != nil {
+		t.Errorf("expected last page of 1 item, got %v items and last key %v", len(out.Items), out.LastEvaluatedKey)
+	}
+}
@@ -0,0 +1,180 @@
+package encryption
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// KeyProvider creates data keys for envelope encryption and unwraps them again. Every encrypted
+// value is encrypted with a data key, and only the wrapped data key is stored next to it.
+type KeyProvider interface {
+	// GenerateDataKey returns a new data key and the same data key wrapped by the provider's key
+	GenerateDataKey(ctx context.Context) ([]byte, []byte, error)
+	// DecryptDataKey unwraps a data key returned by GenerateDataKey
+	DecryptDataKey(ctx context.Context, wrapped []byte) ([]byte, error)
+}
+
+// Cipher encrypts fields of objects at rest. The zero value does not encrypt anything, and any
+// Cipher reads values that were stored before encryption was turned on.
+type Cipher struct {
+	// EncryptNames encrypts member names in addition to prayer requests
+	EncryptNames bool
+	Keys         KeyProvider
+}
+
+// ErrNoKeyProvider is returned when reading encrypted values with a Cipher without a KeyProvider.
+var ErrNoKeyProvider = errors.New("no key provider to decrypt with")
+
+const (
+	// prefix marks encrypted values. The format is prefix, the wrapped data key and the nonce
+	// with the ciphertext, separated by ":" and base64 encoded
+	prefix     = "enc:v1:"
+	dataKeyLen = 32
+	// values that start with marker are reserved for the Cipher. Members choose the text of their
+	// values, so plaintext that starts with it is stored behind escapePrefix and can not be
+	// mistaken for an encrypted value
+	escapePrefix = "enc:plain:"
+	marker       = "enc:"
+)
+
+// IsEnabled returns true if c encrypts values.
+func (c Cipher) IsEnabled() bool {
+	return c.Keys != nil
+}
+
+// Encrypt encrypts the non empty values in place with a single new data key. A Cipher that does
+// not encrypt leaves them as they are, except for escaping plaintext that looks like an encrypted
+// value.
+func (c Cipher) Encrypt(values ...*string) error {
+	if !c.IsEnabled() {
+		for _, value := range values {
+			if strings.HasPrefix(*value, marker) {
+				*value = escapePrefix + *value
+			}
+		}
+
+		return nil
+	}
+
+	key, wrapped, err := c.Keys.GenerateDataKey(context.TODO())
+	if err != nil {
+		return fmt.Errorf("Cipher encrypt: %w", err)
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return fmt.Errorf("Cipher encrypt: %w", err)
+	}
+
+	for _, value := range values {
+		if *value == "" {
+			continue
+		}
+
+		sealed, err := seal(aead, []byte(*value))
+		if err != nil {
+			return fmt.Errorf("Cipher encrypt: %w", err)
+		}
+
+		*value = prefix + base64.StdEncoding.EncodeToString(wrapped) + ":" + base64.StdEncoding.EncodeToString(sealed)
+	}
+
+	return nil
+}
+
+// Decrypt decrypts the encrypted values and unescapes the escaped ones in place. Values that are
+// neither are left as is.
+func (c Cipher) Decrypt(values ...*string) error {
+	// data keys are only unwrapped once, since values encrypted together share a data key
+	keys := map[string][]byte{}
+
+	for _, value := range values {
+		if strings.HasPrefix(*value, escapePrefix) {
+			*value = strings.TrimPrefix(*value, escapePrefix)
+			continue
+		} else if !IsEncrypted(*value) {
+			continue
+		}
+
+		if !c.IsEnabled() {
+			return fmt.Errorf("Cipher decrypt: %w", ErrNoKeyProvider)
+		}
+
+		wrapped, sealed, found := strings.Cut(strings.TrimPrefix(*value, prefix), ":")
+		if !found {
+			return fmt.Errorf("Cipher decrypt: malformed encrypted value")
+		}
+
+		key, ok := keys[wrapped]
+		if !ok {
+			wrappedKey, err := base64.StdEncoding.DecodeString(wrapped)
+			if err != nil {
+				return fmt.Errorf("Cipher decrypt: %w", err)
+			}
+
+			key, err = c.Keys.DecryptDataKey(context.TODO(), wrappedKey)
+			if err != nil {
+				return fmt.Errorf("Cipher decrypt: %w", err)
+			}
+			keys[wrapped] = key
+		}
+
+		sealedBytes, err := base64.StdEncoding.DecodeString(sealed)
+		if err != nil {
+			return fmt.Errorf("Cipher decrypt: %w", err)
+		}
+
+		aead, err := newAEAD(key)
+		if err != nil {
+			return fmt.Errorf("Cipher decrypt: %w", err)
+		}
+
+		plaintext, err := open(aead, sealedBytes)
+		if err != nil {
+			return fmt.Errorf("Cipher decrypt: %w", err)
+		}
+
+		*value = string(plaintext)
+	}
+
+	return nil
+}
+
+// IsEncrypted returns true if value was encrypted by a Cipher.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// seal returns the nonce followed by the ciphertext of plaintext.
+func seal(aead cipher.AEAD, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func open(aead cipher.AEAD, sealed []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext is too short")
+	}
+
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, nil)
+}
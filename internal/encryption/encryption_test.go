@@ -0,0 +1,119 @@
+package encryption_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mshort55/prayertexter/internal/encryption"
+	"github.com/mshort55/prayertexter/internal/mock"
+)
+
+func TestCipher(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32))
+	keys, err := encryption.NewStaticKeyProvider(key)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	cphr := encryption.Cipher{Keys: keys}
+
+	request, name, empty := "please pray for my family", "John Doe", ""
+	if err := cphr.Encrypt(&request, &name, &empty); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if !encryption.IsEncrypted(request) || !encryption.IsEncrypted(name) || strings.Contains(request, "family") {
+		t.Errorf("expected encrypted values, got %q and %q", request, name)
+	}
+
+	if empty != "" {
+		t.Errorf("expected empty value to stay empty, got %q", empty)
+	}
+
+	// values that were stored before encryption was turned on are read as they are
+	plain := "already plaintext"
+	if err := cphr.Decrypt(&request, &name, &plain); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if request != "please pray for my family" || name != "John Doe" || plain != "already plaintext" {
+		t.Errorf("expected decrypted values, got %q, %q and %q", request, name, plain)
+	}
+
+	// the zero Cipher does not encrypt, and cannot read encrypted values
+	if err := (encryption.Cipher{}).Encrypt(&plain); err != nil || plain != "already plaintext" {
+		t.Errorf("expected zero Cipher not to encrypt, got %q (error %v)", plain, err)
+	}
+
+	if err := cphr.Encrypt(&plain); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if err := (encryption.Cipher{}).Decrypt(&plain); !errors.Is(err, encryption.ErrNoKeyProvider) {
+		t.Errorf("expected ErrNoKeyProvider, got %v", err)
+	}
+
+	other, _ := encryption.NewStaticKeyProvider(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{8}, 32)))
+	if err := (encryption.Cipher{Keys: other}).Decrypt(&plain); err == nil {
+		t.Errorf("expected error decrypting with a different key")
+	}
+
+	if _, err := encryption.NewStaticKeyProvider(base64.StdEncoding.EncodeToString([]byte("short"))); err == nil {
+		t.Errorf("expected error for a key that is not 256 bits")
+	}
+}
+
+func TestCipherReservedPrefix(t *testing.T) {
+	keys, err := encryption.NewStaticKeyProvider(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32)))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// members choose their text, so it can look like an encrypted or escaped value
+	for _, text := range []string{"enc:v1:pray for me", "enc:plain:pray for me", "enc:"} {
+		for _, cphr := range []encryption.Cipher{{}, {Keys: keys}} {
+			value := text
+			if err := cphr.Encrypt(&value); err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if cphr.IsEnabled() && !encryption.IsEncrypted(value) {
+				t.Errorf("expected %q to be encrypted, got %q", text, value)
+			}
+
+			if err := cphr.Decrypt(&value); err != nil || value != text {
+				t.Errorf("expected %q to be read back (encryption %v), got %q (error %v)", text, cphr.IsEnabled(),
+					value, err)
+			}
+		}
+	}
+}
+
+func TestKMSKeyProvider(t *testing.T) {
+	kmsMock := &mock.KMSConnecter{DataKey: bytes.Repeat([]byte{1}, 32)}
+	cphr := encryption.Cipher{Keys: encryption.KMSKeyProvider{Client: kmsMock, KeyID: "alias/prayertexter"}}
+
+	request, name := "please pray for my family", "John Doe"
+	if err := cphr.Encrypt(&request, &name); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if err := cphr.Decrypt(&request, &name); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if request != "please pray for my family" || name != "John Doe" {
+		t.Errorf("expected decrypted values, got %q and %q", request, name)
+	}
+
+	// values encrypted together share a single data key
+	if kmsMock.GenerateDataKeyCalls != 1 || kmsMock.DecryptCalls != 1 {
+		t.Errorf("expected 1 GenerateDataKey and 1 Decrypt call, got %v and %v", kmsMock.GenerateDataKeyCalls,
+			kmsMock.DecryptCalls)
+	}
+
+	if keyID := *kmsMock.GenerateDataKeyInputs[0].KeyId; keyID != "alias/prayertexter" {
+		t.Errorf("expected kms key alias/prayertexter, got %v", keyID)
+	}
+}
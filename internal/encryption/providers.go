@@ -0,0 +1,135 @@
+package encryption
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/mshort55/prayertexter/internal/utility"
+)
+
+// KMSConnecter is the part of the kms client that KMSKeyProvider uses. It exists so that kms can
+// be mocked in tests.
+type KMSConnecter interface {
+	Decrypt(ctx context.Context, params *kms.DecryptInput, optFns ...func(*kms.Options)) (*kms.DecryptOutput, error)
+	GenerateDataKey(ctx context.Context, params *kms.GenerateDataKeyInput,
+		optFns ...func(*kms.Options)) (*kms.GenerateDataKeyOutput, error)
+}
+
+// KMSKeyProvider creates data keys with the kms key KeyID. This is used in production, so that the
+// key that protects prayer requests never leaves kms.
+type KMSKeyProvider struct {
+	Client KMSConnecter
+	KeyID  string
+}
+
+// StaticKeyProvider wraps data keys with a fixed 256 bit key. This is meant for tests and sam local
+// testing only.
+type StaticKeyProvider struct {
+	Key []byte
+}
+
+func GetKmsClient() (*kms.Client, error) {
+	cfg, err := utility.GetAwsConfig()
+	if err != nil {
+		return nil, fmt.Errorf("GetKmsClient: %w", err)
+	}
+
+	return kms.NewFromConfig(cfg), nil
+}
+
+// NewCipher returns the Cipher for the given settings. keyID (a kms key ID or ARN) takes priority
+// over staticKey (a base64 encoded 256 bit key). Without either, the Cipher does not encrypt.
+func NewCipher(keyID, staticKey string, encryptNames bool) (Cipher, error) {
+	switch {
+	case keyID != "":
+		kmsClnt, err := GetKmsClient()
+		if err != nil {
+			return Cipher{}, fmt.Errorf("NewCipher: %w", err)
+		}
+
+		return Cipher{EncryptNames: encryptNames, Keys: KMSKeyProvider{Client: kmsClnt, KeyID: keyID}}, nil
+	case staticKey != "":
+		keys, err := NewStaticKeyProvider(staticKey)
+		if err != nil {
+			return Cipher{}, fmt.Errorf("NewCipher: %w", err)
+		}
+
+		return Cipher{EncryptNames: encryptNames, Keys: keys}, nil
+	default:
+		return Cipher{}, nil
+	}
+}
+
+func (k KMSKeyProvider) GenerateDataKey(ctx context.Context) ([]byte, []byte, error) {
+	out, err := k.Client.GenerateDataKey(ctx, &kms.GenerateDataKeyInput{
+		KeyId:   &k.KeyID,
+		KeySpec: types.DataKeySpecAes256,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("KMSKeyProvider generateDataKey: %w", err)
+	}
+
+	return out.Plaintext, out.CiphertextBlob, nil
+}
+
+func (k KMSKeyProvider) DecryptDataKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	out, err := k.Client.Decrypt(ctx, &kms.DecryptInput{
+		CiphertextBlob: wrapped,
+		KeyId:          &k.KeyID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("KMSKeyProvider decryptDataKey: %w", err)
+	}
+
+	return out.Plaintext, nil
+}
+
+// NewStaticKeyProvider returns a StaticKeyProvider for the base64 encoded 256 bit key.
+func NewStaticKeyProvider(key string) (StaticKeyProvider, error) {
+	decoded, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return StaticKeyProvider{}, fmt.Errorf("NewStaticKeyProvider: %w", err)
+	} else if len(decoded) != dataKeyLen {
+		return StaticKeyProvider{}, fmt.Errorf("NewStaticKeyProvider: key must be %v bytes, got %v", dataKeyLen,
+			len(decoded))
+	}
+
+	return StaticKeyProvider{Key: decoded}, nil
+}
+
+func (s StaticKeyProvider) GenerateDataKey(_ context.Context) ([]byte, []byte, error) {
+	key := make([]byte, dataKeyLen)
+	if _, err := rand.Read(key); err != nil {
+		return nil, nil, fmt.Errorf("StaticKeyProvider generateDataKey: %w", err)
+	}
+
+	aead, err := newAEAD(s.Key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("StaticKeyProvider generateDataKey: %w", err)
+	}
+
+	wrapped, err := seal(aead, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("StaticKeyProvider generateDataKey: %w", err)
+	}
+
+	return key, wrapped, nil
+}
+
+func (s StaticKeyProvider) DecryptDataKey(_ context.Context, wrapped []byte) ([]byte, error) {
+	aead, err := newAEAD(s.Key)
+	if err != nil {
+		return nil, fmt.Errorf("StaticKeyProvider decryptDataKey: %w", err)
+	}
+
+	key, err := open(aead, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("StaticKeyProvider decryptDataKey: %w", err)
+	}
+
+	return key, nil
+}
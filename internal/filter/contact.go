@@ -0,0 +1,90 @@
+package filter
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// PatternFilter finds content that matches a regular expression, such as contact information
+// that should not be shared with intercessors.
+type PatternFilter struct {
+	pattern *regexp.Regexp
+	// valid drops matches that the pattern alone cannot rule out. match is the matched text and
+	// before is the text in front of it
+	valid func(match, before string) bool
+}
+
+// minimum and maximum number of digits of a phone number, see E.164
+const (
+	minPhoneDigits = 7
+	maxPhoneDigits = 15
+)
+
+// NewEmailFilter returns a PatternFilter that finds email addresses.
+func NewEmailFilter() PatternFilter {
+	return PatternFilter{pattern: regexp.MustCompile(`(?i)[a-z0-9._%+-]+@[a-z0-9-]+(?:\.[a-z0-9-]+)*\.[a-z]{2,}`)}
+}
+
+// NewPhoneFilter returns a PatternFilter that finds phone numbers in common formats, like
+// +1 (555) 555-5555, 555.555.5555 or 5555555.
+func NewPhoneFilter() PatternFilter {
+	return PatternFilter{
+		pattern: regexp.MustCompile(`\+?\(?\d(?:[\s().-]{0,2}\d)+`),
+		valid: func(match, _ string) bool {
+			digits := 0
+			for _, r := range match {
+				if unicode.IsDigit(r) {
+					digits++
+				}
+			}
+
+			return digits >= minPhoneDigits && digits <= maxPhoneDigits
+		},
+	}
+}
+
+// NewURLFilter returns a PatternFilter that finds links, with or without scheme, like
+// https://example.com/page or example.org. Domains of email addresses are not links.
+func NewURLFilter() PatternFilter {
+	return PatternFilter{
+		pattern: regexp.MustCompile(`(?i)\b(?:https?://\S+|www\.\S+|[a-z0-9-]+(?:\.[a-z0-9-]+)*\.` +
+			`(?:com|org|net|edu|gov|io|co|us|info|biz|me|ly|app|church)\b(?:/\S*)?)`),
+		valid: func(_, before string) bool {
+			return !strings.HasSuffix(before, "@")
+		},
+	}
+}
+
+func (p PatternFilter) Find(text string) []string {
+	var found []string
+	for _, loc := range p.matches(text) {
+		found = append(found, text[loc[0]:loc[1]])
+	}
+
+	return found
+}
+
+func (p PatternFilter) Redact(text string) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range p.matches(text) {
+		b.WriteString(text[last:loc[0]])
+		b.WriteString(Redacted)
+		last = loc[1]
+	}
+	b.WriteString(text[last:])
+
+	return b.String()
+}
+
+func (p PatternFilter) matches(text string) [][]int {
+	var valid [][]int
+	for _, loc := range p.pattern.FindAllStringIndex(text, -1) {
+		if p.valid == nil || p.valid(text[loc[0]:loc[1]], text[:loc[0]]) {
+			valid = append(valid, loc)
+		}
+	}
+
+	return valid
+}
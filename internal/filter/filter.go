@@ -0,0 +1,123 @@
+package filter
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/mshort55/prayertexter/internal/config"
+)
+
+// Filter finds one kind of unwanted content in the text of a prayer request.
+type Filter interface {
+	// Find returns the content found in text, or nil if there is none
+	Find(text string) []string
+	// Redact returns text with all found content replaced
+	Redact(text string) string
+}
+
+// Step is a Filter of a Pipeline together with what happens to prayer requests that it finds
+// content in.
+type Step struct {
+	Action string
+	Filter Filter
+	Type   string
+}
+
+// Pipeline runs a prayer request through a list of Steps in order.
+type Pipeline []Step
+
+// Result is the outcome of running a prayer request through a Pipeline.
+type Result struct {
+	// Action is ActionReject if the prayer request must be rejected, ActionFlag if it must be held
+	// for review and empty otherwise
+	Action string
+	// Found is the content found by the rejecting Step
+	Found []string
+	// Text is the prayer request with content of all redacting Steps replaced
+	Text string
+	// Type is the type of the rejecting Step, or of the first flagging Step
+	Type string
+}
+
+// filter actions
+const (
+	ActionFlag   = "flag"
+	ActionRedact = "redact"
+	ActionReject = "reject"
+)
+
+// filter types
+const (
+	TypeEmail = "email"
+	TypePhone = "phone"
+	TypeURL   = "url"
+	TypeWords = "words"
+)
+
+// Redacted replaces content removed by redacting Steps.
+const Redacted = "***"
+
+// New returns the Pipeline configured by cfgs. nil cfgs means the default Pipeline, which rejects
+// prayer requests with profanity.
+func New(cfgs []config.ContentFilter) (Pipeline, error) {
+	if cfgs == nil {
+		cfgs = []config.ContentFilter{{Action: ActionReject, Type: TypeWords}}
+	}
+
+	pipeline := make(Pipeline, 0, len(cfgs))
+	for _, cfg := range cfgs {
+		if !slices.Contains([]string{ActionFlag, ActionRedact, ActionReject}, cfg.Action) {
+			return nil, fmt.Errorf("filter new: unknown action %q of %v filter", cfg.Action, cfg.Type)
+		}
+
+		step := Step{Action: cfg.Action, Type: cfg.Type}
+		switch cfg.Type {
+		case TypeEmail:
+			step.Filter = NewEmailFilter()
+		case TypePhone:
+			step.Filter = NewPhoneFilter()
+		case TypeURL:
+			step.Filter = NewURLFilter()
+		case TypeWords:
+			step.Filter = NewWordFilter(cfg.Allow, cfg.Deny)
+		default:
+			return nil, fmt.Errorf("filter new: unknown filter type %q", cfg.Type)
+		}
+
+		pipeline = append(pipeline, step)
+	}
+
+	return pipeline, nil
+}
+
+// HasAction returns true if any Step of the Pipeline has action.
+func (p Pipeline) HasAction(action string) bool {
+	return slices.ContainsFunc(p, func(s Step) bool { return s.Action == action })
+}
+
+// Check runs text through every Step of the Pipeline. Redacting Steps change the text that later
+// Steps see, and the first rejecting Step that finds anything stops the Pipeline.
+func (p Pipeline) Check(text string) Result {
+	result := Result{Text: text}
+
+	for _, step := range p {
+		found := step.Filter.Find(result.Text)
+		if len(found) == 0 {
+			continue
+		}
+
+		switch step.Action {
+		case ActionReject:
+			result.Action, result.Found, result.Type = ActionReject, found, step.Type
+			return result
+		case ActionRedact:
+			result.Text = step.Filter.Redact(result.Text)
+		case ActionFlag:
+			if result.Action == "" {
+				result.Action, result.Type = ActionFlag, step.Type
+			}
+		}
+	}
+
+	return result
+}
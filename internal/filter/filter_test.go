@@ -0,0 +1,156 @@
+package filter_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mshort55/prayertexter/internal/config"
+	"github.com/mshort55/prayertexter/internal/filter"
+)
+
+func TestWordFilter(t *testing.T) {
+	words := filter.NewWordFilter(nil, nil)
+	if found := words.Find("test text message, no profanity"); found != nil {
+		t.Errorf("expected no profanity, got %v", found)
+	}
+
+	if found := words.Find("this message contains profanity, sh!t!"); !reflect.DeepEqual(found, []string{"shit"}) {
+		t.Errorf("expected profanity, got %v", found)
+	}
+
+	// jerk is allowed by default
+	if found := words.Find("my boss is a jerk"); found != nil {
+		t.Errorf("expected jerk to be allowed, got %v", found)
+	}
+
+	custom := filter.NewWordFilter([]string{"Crap"}, []string{"Darn"})
+	if found := custom.Find("oh crap"); found != nil {
+		t.Errorf("expected allowed word not to be found, got %v", found)
+	}
+
+	if found := custom.Find("darn it"); !reflect.DeepEqual(found, []string{"darn"}) {
+		t.Errorf("expected denied word to be found, got %v", found)
+	}
+
+	// custom word lists do not change the default word list
+	if found := words.Find("oh crap"); found == nil {
+		t.Errorf("expected default word list to be unchanged")
+	}
+
+	if redacted := words.Redact("oh shit"); redacted != "oh ****" {
+		t.Errorf("expected redacted profanity, got %q", redacted)
+	}
+}
+
+func TestPatternFilters(t *testing.T) {
+	testCases := []struct {
+		description string
+		filter      filter.Filter
+		text        string
+		found       []string
+		redacted    string
+	}{
+		{
+			description: "phone number with country code",
+			filter:      filter.NewPhoneFilter(),
+			text:        "please call me at +1 (555) 555-5555 tonight",
+			found:       []string{"+1 (555) 555-5555"},
+			redacted:    "please call me at *** tonight",
+		},
+		{
+			description: "dotted phone number",
+			filter:      filter.NewPhoneFilter(),
+			text:        "my number is 555.555.5555",
+			found:       []string{"555.555.5555"},
+			redacted:    "my number is ***",
+		},
+		{
+			description: "short numbers are not phone numbers",
+			filter:      filter.NewPhoneFilter(),
+			text:        "surgery on 10/21 at 9:30, room 1204",
+			redacted:    "surgery on 10/21 at 9:30, room 1204",
+		},
+		{
+			description: "email address",
+			filter:      filter.NewEmailFilter(),
+			text:        "email John.Doe@example.com for details",
+			found:       []string{"John.Doe@example.com"},
+			redacted:    "email *** for details",
+		},
+		{
+			description: "links with and without scheme",
+			filter:      filter.NewURLFilter(),
+			text:        "see https://example.com/page?id=1 or gofundme.com/f/help",
+			found:       []string{"https://example.com/page?id=1", "gofundme.com/f/help"},
+			redacted:    "see *** or ***",
+		},
+		{
+			description: "email domains are not links",
+			filter:      filter.NewURLFilter(),
+			text:        "email john@example.com. Thanks",
+			redacted:    "email john@example.com. Thanks",
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.description, func(t *testing.T) {
+			if found := test.filter.Find(test.text); !reflect.DeepEqual(found, test.found) {
+				t.Errorf("expected found %v, got %v", test.found, found)
+			}
+
+			if redacted := test.filter.Redact(test.text); redacted != test.redacted {
+				t.Errorf("expected redacted %q, got %q", test.redacted, redacted)
+			}
+		})
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	if _, err := filter.New([]config.ContentFilter{{Action: "delete", Type: filter.TypeURL}}); err == nil {
+		t.Errorf("expected error for unknown action")
+	}
+
+	if _, err := filter.New([]config.ContentFilter{{Action: filter.ActionReject, Type: "ssn"}}); err == nil {
+		t.Errorf("expected error for unknown type")
+	}
+
+	defaults, err := filter.New(nil)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if result := defaults.Check("pray for sh!t"); result.Action != filter.ActionReject || result.Type != filter.TypeWords {
+		t.Errorf("expected default pipeline to reject profanity, got %v", result)
+	}
+
+	pipeline, err := filter.New([]config.ContentFilter{
+		{Action: filter.ActionRedact, Type: filter.TypeEmail},
+		{Action: filter.ActionRedact, Type: filter.TypePhone},
+		{Action: filter.ActionFlag, Type: filter.TypeURL},
+		{Action: filter.ActionReject, Type: filter.TypeWords},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if !pipeline.HasAction(filter.ActionFlag) || pipeline.HasAction("delete") {
+		t.Errorf("unexpected HasAction result")
+	}
+
+	result := pipeline.Check("pray for my mom, call 555-555-5555 or email mom@example.com")
+	expected := filter.Result{Text: "pray for my mom, call *** or email ***"}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("expected %v, got %v", expected, result)
+	}
+
+	result = pipeline.Check("pray for the fundraiser at example.org")
+	if result.Action != filter.ActionFlag || result.Type != filter.TypeURL {
+		t.Errorf("expected flagged prayer request, got %v", result)
+	}
+
+	// rejecting filters win over earlier flagging filters
+	result = pipeline.Check("example.org, oh crap")
+	if result.Action != filter.ActionReject || !reflect.DeepEqual(result.Found, []string{"crap"}) {
+		t.Errorf("expected rejected prayer request, got %v", result)
+	}
+}
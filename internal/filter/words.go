@@ -0,0 +1,52 @@
+package filter
+
+import (
+	"slices"
+	"strings"
+
+	goaway "github.com/TwiN/go-away"
+)
+
+// WordFilter finds profanity from a word list. Leetspeak, accents and special characters are
+// taken into account, so "sh!t" is found as well.
+type WordFilter struct {
+	detector *goaway.ProfanityDetector
+}
+
+// NewWordFilter returns a WordFilter with the default word list, without the allowed words and
+// with the denied words added. The default word list is copied, so filters with different word
+// lists do not affect each other.
+func NewWordFilter(allow, deny []string) WordFilter {
+	// some words are removed from the default word list because it is too sensitive
+	allowed := lower(append([]string{"jerk"}, allow...))
+	isAllowed := func(word string) bool { return slices.Contains(allowed, word) }
+
+	profanities := slices.DeleteFunc(slices.Concat(goaway.DefaultProfanities, lower(deny)), isAllowed)
+	falseNegatives := slices.DeleteFunc(slices.Clone(goaway.DefaultFalseNegatives), isAllowed)
+	falsePositives := slices.Concat(goaway.DefaultFalsePositives, allowed)
+
+	detector := goaway.NewProfanityDetector().WithCustomDictionary(profanities, falsePositives, falseNegatives)
+
+	return WordFilter{detector: detector}
+}
+
+func (w WordFilter) Find(text string) []string {
+	if word := w.detector.ExtractProfanity(text); word != "" {
+		return []string{word}
+	}
+
+	return nil
+}
+
+func (w WordFilter) Redact(text string) string {
+	return w.detector.Censor(text)
+}
+
+func lower(words []string) []string {
+	lowered := make([]string, 0, len(words))
+	for _, word := range words {
+		lowered = append(lowered, strings.ToLower(strings.TrimSpace(word)))
+	}
+
+	return lowered
+}
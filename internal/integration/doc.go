@@ -0,0 +1,4 @@
+// Package integration holds end to end tests that run against dynamodb local instead of mocks, to
+// catch marshalling, key schema and condition expression mistakes. They are skipped unless
+// PRAYERTEXTER_DDB_ENDPOINT is set.
+package integration
@@ -0,0 +1,96 @@
+package integration_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/mshort55/prayertexter/internal/db"
+	"github.com/mshort55/prayertexter/internal/object"
+	"github.com/mshort55/prayertexter/internal/tablegen"
+)
+
+// endpointEnv opts in to the integration tests, for example with http://localhost:8000 after
+// docker compose -f localdev/compose.yaml up -d.
+const endpointEnv = "PRAYERTEXTER_DDB_ENDPOINT"
+
+// localHosts are the only hosts the tests run against, because every test deletes all tables.
+func localHosts() []string {
+	return []string{"localhost", "127.0.0.1", "::1", "dynamodb"}
+}
+
+// newDdbClient returns a client of dynamodb local with every table deleted and created again
+// empty, or skips the test if endpointEnv is not set.
+func newDdbClient(t *testing.T) *dynamodb.Client {
+	t.Helper()
+
+	endpoint := os.Getenv(endpointEnv)
+	if endpoint == "" {
+		t.Skipf("set %v to run integration tests against dynamodb local", endpointEnv)
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil || !slices.Contains(localHosts(), u.Hostname()) {
+		t.Fatalf("%v must be a dynamodb local endpoint on one of %v, got %v", endpointEnv, localHosts(), endpoint)
+	}
+
+	// dynamodb local accepts any credentials, but the sdk needs some
+	if os.Getenv("AWS_ACCESS_KEY_ID") == "" {
+		t.Setenv("AWS_ACCESS_KEY_ID", "local")
+		t.Setenv("AWS_SECRET_ACCESS_KEY", "local")
+	}
+
+	ddbClnt, err := db.GetDdbClientForEndpoint(endpoint)
+	if err != nil {
+		t.Fatalf("failed to get dynamodb client: %v", err)
+	}
+
+	for _, tbl := range object.Tables() {
+		resetTable(t, ddbClnt, tbl)
+	}
+
+	return ddbClnt
+}
+
+// resetTable deletes tbl if it exists and creates it from its schema.
+func resetTable(t *testing.T, ddbClnt *dynamodb.Client, tbl object.Table) {
+	t.Helper()
+	ctx := context.Background()
+
+	_, err := ddbClnt.DeleteTable(ctx, &dynamodb.DeleteTableInput{TableName: aws.String(tbl.Name)})
+	var notFound *types.ResourceNotFoundException
+	if err != nil && !errors.As(err, &notFound) {
+		t.Fatalf("failed to delete table %v: %v", tbl.Name, err)
+	}
+
+	if _, err := ddbClnt.CreateTable(ctx, tablegen.CreateTableInput(tbl)); err != nil {
+		t.Fatalf("failed to create table %v: %v", tbl.Name, err)
+	}
+
+	waiter := dynamodb.NewTableExistsWaiter(ddbClnt)
+	if err := waiter.Wait(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(tbl.Name)}, time.Minute); err != nil {
+		t.Fatalf("table %v was not created: %v", tbl.Name, err)
+	}
+}
+
+// getItem returns the stored item of table, to check attributes as ddb stores them.
+func getItem(t *testing.T, ddbClnt db.DDBConnecter, table, attr, key string) map[string]types.AttributeValue {
+	t.Helper()
+
+	out, err := ddbClnt.GetItem(context.Background(), &dynamodb.GetItemInput{
+		TableName: aws.String(table),
+		Key:       map[string]types.AttributeValue{attr: &types.AttributeValueMemberS{Value: key}},
+	})
+	if err != nil {
+		t.Fatalf("failed to get %v from %v: %v", key, table, err)
+	}
+
+	return out.Item
+}
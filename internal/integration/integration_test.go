@@ -0,0 +1,216 @@
+package integration_test
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/mshort55/prayertexter/internal/config"
+	"github.com/mshort55/prayertexter/internal/db"
+	"github.com/mshort55/prayertexter/internal/encryption"
+	"github.com/mshort55/prayertexter/internal/messaging"
+	"github.com/mshort55/prayertexter/internal/object"
+	"github.com/mshort55/prayertexter/internal/prayertexter"
+	"github.com/mshort55/prayertexter/internal/simulator"
+)
+
+// TestScenarios runs the simulator scenarios against dynamodb local and checks the items that the
+// prayer lifecycle leaves behind.
+func TestScenarios(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("..", "simulator", "testdata", "*.yaml"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("expected simulator scenarios, got %v (%v)", files, err)
+	}
+
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			ddbClnt := newDdbClient(t)
+
+			f, err := os.Open(file)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			defer f.Close()
+
+			sc, err := simulator.LoadScenario(f)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			if _, err := simulator.Run(sc, ddbClnt); err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			if filepath.Base(file) == "prayer-lifecycle.yaml" {
+				checkPrayerLifecycle(t, ddbClnt)
+			}
+		})
+	}
+}
+
+func checkPrayerLifecycle(t *testing.T, ddbClnt db.DDBConnecter) {
+	t.Helper()
+
+	item := getItem(t, ddbClnt, object.MemberTable, object.MemberAttribute, "+11111111111")
+	if _, ok := item["Intercessor"].(*types.AttributeValueMemberBOOL); !ok {
+		t.Errorf("expected Intercessor stored as BOOL, got %#v", item["Intercessor"])
+	}
+	if _, ok := item["PrayerCount"].(*types.AttributeValueMemberN); !ok {
+		t.Errorf("expected PrayerCount stored as N, got %#v", item["PrayerCount"])
+	}
+
+	phones := object.IntercessorPhones{}
+	if err := phones.Get(ddbClnt); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(phones.Phones) != 3 || phones.Version != 3 {
+		t.Errorf("expected 3 intercessor phones at version 3, got %v at version %v", phones.Phones, phones.Version)
+	}
+
+	archived, err := db.ScanDdbObjects[object.ArchivedPrayer](ddbClnt, object.ArchivedPrayersTable, db.Condition{})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(archived) != 1 {
+		t.Errorf("expected 1 archived prayer, got %v", len(archived))
+	}
+
+	prayers, err := object.GetPrayers(ddbClnt, encryption.Cipher{}, false)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(prayers) != 1 {
+		t.Errorf("expected 1 prayer still active, got %v", len(prayers))
+	}
+
+	sttrackr := object.StateTracker{}
+	if err := sttrackr.Get(ddbClnt); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(sttrackr.States) != 0 {
+		t.Errorf("expected no states left in progress, got %v", sttrackr.States)
+	}
+}
+
+// TestPrayerQueue checks that a prayer request without intercessors is queued under the
+// PrayersQueue key and that its request is stored encrypted.
+func TestPrayerQueue(t *testing.T) {
+	ddbClnt := newDdbClient(t)
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	cphr, err := encryption.NewCipher("", base64.StdEncoding.EncodeToString(key), false)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	sim, err := simulator.New(config.Config{Cipher: cphr}, ddbClnt)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	phone, request := "+11234567890", "please pray for my family"
+	for _, body := range []string{"pray", "Dave", "1", request} {
+		if err := sim.Send(phone, body); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+
+	out, err := ddbClnt.Scan(context.Background(), &dynamodb.ScanInput{TableName: aws.String(object.QueuedPrayersTable)})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(out.Items) != 1 {
+		t.Fatalf("expected 1 queued prayer, got %v", len(out.Items))
+	}
+
+	if id, ok := out.Items[0][object.PrayersAttribute].(*types.AttributeValueMemberS); !ok || id.Value == "" {
+		t.Errorf("expected queued prayer keyed on %v, got %#v", object.PrayersAttribute, out.Items[0])
+	}
+	if strings.Contains(fmtItem(out.Items[0]), request) {
+		t.Errorf("expected request to be stored encrypted, got %#v", out.Items[0])
+	}
+
+	queued, err := object.GetPrayers(ddbClnt, cphr, true)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(queued) != 1 || queued[0].Request != request {
+		t.Errorf("expected queued prayer with request %v, got %v", request, queued)
+	}
+}
+
+// fmtItem returns the string values of item, to look for plaintext in it.
+func fmtItem(item map[string]types.AttributeValue) string {
+	var b strings.Builder
+	for _, v := range item {
+		switch v := v.(type) {
+		case *types.AttributeValueMemberS:
+			b.WriteString(v.Value)
+		case *types.AttributeValueMemberB:
+			b.Write(v.Value)
+		case *types.AttributeValueMemberM:
+			b.WriteString(fmtItem(v.Value))
+		}
+	}
+
+	return b.String()
+}
+
+// TestHandleWebhookDuplicate checks the conditional put that claims inbound messages.
+func TestHandleWebhookDuplicate(t *testing.T) {
+	ddbClnt := newDdbClient(t)
+
+	smsClnt := &messaging.LogSender{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	body := `{"phone-number": "+11234567890", "body": "pray", "message-id": "1"}`
+	req := events.APIGatewayProxyRequest{HTTPMethod: http.MethodPost, Path: "/", Body: body}
+
+	for _, expected := range []string{"Success", "Duplicate"} {
+		resp, err := prayertexter.HandleWebhook(context.Background(), req, config.Config{}, ddbClnt, smsClnt)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if resp.Body != expected {
+			t.Errorf("expected body %v, got %v", expected, resp.Body)
+		}
+	}
+}
+
+// TestVersionCondition checks that a put with a stale version is refused by ddb.
+func TestVersionCondition(t *testing.T) {
+	ddbClnt := newDdbClient(t)
+
+	phones := object.IntercessorPhones{}
+	if err := phones.Update(ddbClnt, func(i *object.IntercessorPhones) { i.AddPhone("+11111111111") }); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	stale := object.IntercessorPhones{Key: object.IntercessorPhonesKey, Phones: []string{"+12222222222"}, Version: 1}
+	updated, err := db.PutDdbObjectIf(ddbClnt, object.IntercessorPhonesTable, &stale, db.VersionCondition(0))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if updated {
+		t.Errorf("expected put with stale version to be refused")
+	}
+
+	if err := phones.Update(ddbClnt, func(i *object.IntercessorPhones) { i.AddPhone("+12222222222") }); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(phones.Phones) != 2 || phones.Version != 2 {
+		t.Errorf("expected 2 phones at version 2, got %v at version %v", phones.Phones, phones.Version)
+	}
+}
@@ -0,0 +1,103 @@
+package messaging
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxPartSegments is the maximum number of sms segments of a single text message. Messages that
+// are longer get split into numbered parts that are sent as separate text messages.
+const MaxPartSegments = 10
+
+// Compose wraps a rendered message body with MsgPre and MsgPost and returns the text messages to
+// send. Most messages fit into a single text message. Longer messages, usually prayer requests,
+// get split at word boundaries into parts that are numbered like "(1/3) ". Every part starts with
+// MsgPre and only the first part ends with MsgPost, so continuation parts do not repeat the footer.
+func (t *Templates) Compose(body string) ([]string, error) {
+	pre, err := t.Render(MsgPre, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Templates compose: %w", err)
+	}
+
+	post, err := t.Render(MsgPost, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Templates compose: %w", err)
+	}
+
+	if msg := pre + body + "\n\n" + post; partFits(msg) {
+		return []string{msg}, nil
+	}
+
+	format := func(index, total int, chunk string) string {
+		part := fmt.Sprintf("%v(%d/%d) %v", pre, index, total, chunk)
+		if index == 1 {
+			part += "\n\n" + post
+		}
+
+		return part
+	}
+
+	// the number prefix gets longer with the number of parts, so the split is done again if there
+	// are more parts than assumed
+	for total := 9; ; total = total*10 + 9 {
+		chunks, err := splitChunks(body, func(index int, chunk string) bool {
+			return partFits(format(index, total, chunk))
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Templates compose: %w", err)
+		}
+
+		if len(chunks) <= total {
+			parts := make([]string, len(chunks))
+			for i, chunk := range chunks {
+				parts[i] = format(i+1, len(chunks), chunk)
+			}
+
+			return parts, nil
+		}
+	}
+}
+
+func partFits(part string) bool {
+	return utf8.RuneCountInString(part) <= MaxMessageLength && Segments(part) <= MaxPartSegments
+}
+
+// splitChunks splits body into chunks that fit. fits reports whether chunk fits as part number
+// index. Chunks end at word boundaries unless a single word is too long to fit into one part.
+func splitChunks(body string, fits func(index int, chunk string) bool) ([]string, error) {
+	// every word keeps the whitespace that follows it so that new lines inside the body are kept
+	words := regexp.MustCompile(`\S+\s*`).FindAllString(body, -1)
+
+	var chunks []string
+	for len(words) > 0 {
+		index := len(chunks) + 1
+		chunk := func(n int) string { return strings.TrimRight(strings.Join(words[:n], ""), " \t\n") }
+
+		// the largest number of words that fit. sort.Search finds the first number that does not
+		// fit
+		n := sort.Search(len(words), func(n int) bool { return !fits(index, chunk(n+1)) })
+		if n > 0 {
+			chunks = append(chunks, chunk(n))
+			words = words[n:]
+			continue
+		}
+
+		// this means that the first word alone is too long, so it gets split at any character
+		runes := []rune(words[0])
+		n = sort.Search(len(runes), func(n int) bool { return !fits(index, string(runes[:n+1])) })
+		if n == 0 {
+			return nil, fmt.Errorf("splitChunks: pre and post messages are too long to fit any text")
+		}
+
+		chunks = append(chunks, string(runes[:n]))
+		words[0] = string(runes[n:])
+		if strings.TrimSpace(words[0]) == "" {
+			words = words[1:]
+		}
+	}
+
+	return chunks, nil
+}
@@ -0,0 +1,99 @@
+package messaging_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mshort55/prayertexter/internal/messaging"
+)
+
+func TestCompose(t *testing.T) {
+	tmpls, err := messaging.LoadTemplates(nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	pre, _ := tmpls.Render(messaging.MsgPre, nil)
+	post, _ := tmpls.Render(messaging.MsgPost, nil)
+
+	parts, err := tmpls.Compose("short message")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	expected, _ := tmpls.Wrap("short message")
+	if len(parts) != 1 || parts[0] != expected {
+		t.Errorf("expected single part %q, got %q", expected, parts)
+	}
+
+	// a long prayer with new lines and words of different lengths
+	var words []string
+	for i := range 700 {
+		words = append(words, fmt.Sprintf("word%d", i))
+		if i%50 == 0 {
+			words = append(words, "\n")
+		}
+	}
+	body := strings.Join(words, " ")
+
+	parts, err = tmpls.Compose(body)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if len(parts) < 2 {
+		t.Fatalf("expected message to be split into parts, got %v", len(parts))
+	}
+
+	var joined []string
+	for i, part := range parts {
+		if segments := messaging.Segments(part); segments > messaging.MaxPartSegments {
+			t.Errorf("expected part %v to be at most %v segments, got %v", i+1, messaging.MaxPartSegments, segments)
+		}
+
+		prefix := fmt.Sprintf("%v(%d/%d) ", pre, i+1, len(parts))
+		if !strings.HasPrefix(part, prefix) {
+			t.Errorf("expected part %v to start with %q, got %q", i+1, prefix, part[:len(prefix)])
+		}
+
+		// only the first part has the footer
+		if hasPost := strings.HasSuffix(part, post); hasPost != (i == 0) {
+			t.Errorf("expected footer on part %v to be %v, got %v", i+1, i == 0, hasPost)
+		}
+
+		chunk := strings.TrimSuffix(strings.TrimPrefix(part, prefix), "\n\n"+post)
+		joined = append(joined, chunk)
+	}
+
+	// parts end at word boundaries, so no words get lost or cut apart
+	if strings.Join(strings.Fields(strings.Join(joined, " ")), " ") != strings.Join(strings.Fields(body), " ") {
+		t.Errorf("expected parts to contain the whole message body")
+	}
+
+	// a single word that does not fit in one part gets split at any character
+	parts, err = tmpls.Compose(strings.Repeat("a", 2000))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if len(parts) != 2 {
+		t.Errorf("expected 2 parts, got %v", len(parts))
+	}
+
+	// ucs-2 parts hold fewer characters than gsm-7 parts
+	ucs2Parts, err := tmpls.Compose(strings.Repeat("🙏 ", 300))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	for _, part := range ucs2Parts {
+		if segments := messaging.Segments(part); segments > messaging.MaxPartSegments {
+			t.Errorf("expected ucs-2 part to be at most %v segments, got %v", messaging.MaxPartSegments, segments)
+		}
+	}
+
+	if len(ucs2Parts) < 2 {
+		t.Errorf("expected ucs-2 message to be split into parts, got %v", len(ucs2Parts))
+	}
+}
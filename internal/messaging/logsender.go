@@ -0,0 +1,62 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/pinpointsmsvoicev2"
+)
+
+// LogSender is a TextSender that logs text messages instead of sending them, for running
+// prayertexter locally. It keeps every message so they can be looked at later, and is safe for
+// concurrent use.
+type LogSender struct {
+	// Logger logs every message. nil means slog.Default()
+	Logger *slog.Logger
+
+	mu   sync.Mutex
+	sent []TextMessage
+}
+
+func (l *LogSender) SendTextMessage(ctx context.Context,
+	params *pinpointsmsvoicev2.SendTextMessageInput,
+	_ ...func(*pinpointsmsvoicev2.Options)) (*pinpointsmsvoicev2.SendTextMessageOutput, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	msg := TextMessage{
+		Body:        aws.ToString(params.MessageBody),
+		ID:          fmt.Sprintf("local-%d", len(l.sent)+1),
+		Phone:       aws.ToString(params.DestinationPhoneNumber),
+		TenantPhone: aws.ToString(params.OriginationIdentity),
+		Timestamp:   time.Now().Format(time.RFC3339),
+	}
+	l.sent = append(l.sent, msg)
+
+	logger := l.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	logger.InfoContext(ctx, "text message", "from", msg.TenantPhone, "to", msg.Phone, "body", msg.Body)
+
+	return &pinpointsmsvoicev2.SendTextMessageOutput{MessageId: aws.String(msg.ID)}, nil
+}
+
+// Sent returns the messages sent to phone, or all messages if phone is empty, oldest first.
+func (l *LogSender) Sent(phone string) []TextMessage {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	sent := []TextMessage{}
+	for _, msg := range l.sent {
+		if phone == "" || msg.Phone == phone {
+			sent = append(sent, msg)
+		}
+	}
+
+	return sent
+}
@@ -0,0 +1,71 @@
+package messaging
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/pinpointsmsvoicev2"
+)
+
+// SentText describes a single text message that was sent out. A message that gets composed into
+// several parts is one SentText per part.
+type SentText struct {
+	Country  string
+	Encoding string
+	Phone    string
+	Segments int
+}
+
+// RecordingSender is a TextSender that records every text message that is successfully sent
+// through the wrapped TextSender. This is used for usage and cost accounting.
+type RecordingSender struct {
+	TextSender
+	Sent []SentText
+}
+
+func (r *RecordingSender) SendTextMessage(ctx context.Context,
+	params *pinpointsmsvoicev2.SendTextMessageInput,
+	optFns ...func(*pinpointsmsvoicev2.Options)) (*pinpointsmsvoicev2.SendTextMessageOutput, error) {
+	output, err := r.TextSender.SendTextMessage(ctx, params, optFns...)
+	if err != nil {
+		return output, err
+	}
+
+	body, phone := aws.ToString(params.MessageBody), aws.ToString(params.DestinationPhoneNumber)
+	r.Sent = append(r.Sent, SentText{
+		Country:  CountryCode(phone),
+		Encoding: Encoding(body),
+		Phone:    phone,
+		Segments: Segments(body),
+	})
+
+	return output, nil
+}
+
+// CountryCode returns the country calling code of an E.164 phone number, for example "+1" for
+// the US and Canada. Unknown or invalid numbers return an empty string.
+func CountryCode(phone string) string {
+	digits, found := strings.CutPrefix(phone, "+")
+	if !found || len(digits) < 4 {
+		return ""
+	}
+
+	// calling codes are prefix free. Only 1 and 7 are single digit codes, and the two digit codes
+	// are listed here. Every other calling code has three digits
+	twoDigitCodes := []string{
+		"20", "27", "30", "31", "32", "33", "34", "36", "39", "40", "41", "43", "44", "45", "46", "47",
+		"48", "49", "51", "52", "53", "54", "55", "56", "57", "58", "60", "61", "62", "63", "64", "65",
+		"66", "81", "82", "84", "86", "90", "91", "92", "93", "94", "95", "98",
+	}
+
+	switch {
+	case digits[0] == '1' || digits[0] == '7':
+		return "+" + digits[:1]
+	case slices.Contains(twoDigitCodes, digits[:2]):
+		return "+" + digits[:2]
+	default:
+		return "+" + digits[:3]
+	}
+}
@@ -0,0 +1,103 @@
+package messaging
+
+import (
+	"strings"
+	"unicode/utf16"
+)
+
+// sms encodings
+const (
+	EncodingGSM7 = "GSM-7"
+	EncodingUCS2 = "UCS-2"
+)
+
+// A single segment text message holds 160 GSM-7 characters or 70 UCS-2 characters. Text messages
+// that need more than one segment lose some room in every segment to the header that is used to
+// put the segments back together on the phone.
+const (
+	gsm7SegmentLength      = 160
+	gsm7MultiSegmentLength = 153
+	ucs2SegmentLength      = 70
+	ucs2MultiSegmentLength = 67
+)
+
+// gsm7Chars is the GSM-7 basic character set. gsm7ExtChars are part of the GSM-7 extension table
+// and take up two characters because they need an escape character.
+const (
+	gsm7Chars = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?" +
+		"¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+	gsm7ExtChars = "\f^{}\\[~]|€"
+)
+
+// Encoding returns the encoding that text gets sent with. Text that only has GSM-7 characters is
+// sent as GSM-7, anything else (for example emojis) makes the whole text message UCS-2.
+func Encoding(text string) string {
+	for _, r := range text {
+		if gsm7Length(r) == 0 {
+			return EncodingUCS2
+		}
+	}
+
+	return EncodingGSM7
+}
+
+// Segments returns the number of sms segments that text is billed and delivered as.
+func Segments(text string) int {
+	encoding := Encoding(text)
+	single, multi := gsm7SegmentLength, gsm7MultiSegmentLength
+	if encoding == EncodingUCS2 {
+		single, multi = ucs2SegmentLength, ucs2MultiSegmentLength
+	}
+
+	length := encodedLength(text, encoding)
+
+	if length <= single {
+		return 1
+	}
+
+	return (length + multi - 1) / multi
+}
+
+// encodedLength returns the number of characters that text takes up in encoding. For GSM-7 this
+// counts extension characters twice and for UCS-2 this counts characters outside of the basic
+// multilingual plane (most emojis) twice.
+func encodedLength(text, encoding string) int {
+	if encoding == EncodingUCS2 {
+		return len(utf16.Encode([]rune(text)))
+	}
+
+	length := 0
+	for _, r := range text {
+		length += gsm7Length(r)
+	}
+
+	return length
+}
+
+// TotalSegments returns the number of sms segments of all parts of a composed text message.
+func TotalSegments(parts []string) int {
+	total := 0
+	for _, part := range parts {
+		total += Segments(part)
+	}
+
+	return total
+}
+
+// gsm7Length returns the number of GSM-7 characters that r takes up, or 0 if r is not a GSM-7
+// character.
+func gsm7Length(r rune) int {
+	switch {
+	case strings.ContainsRune(gsm7ExtChars, r):
+		return 2
+	case r == '`':
+		return 0
+	case r >= ' ' && r <= '~':
+		// all other printable ascii characters are part of the basic character set
+		return 1
+	case strings.ContainsRune(gsm7Chars, r):
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,88 @@
+package messaging_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mshort55/prayertexter/internal/messaging"
+)
+
+func TestSegments(t *testing.T) {
+	testCases := []struct {
+		description string
+		text        string
+		encoding    string
+		segments    int
+	}{
+		{
+			description: "short plain text",
+			text:        "Please pray for my family",
+			encoding:    messaging.EncodingGSM7,
+			segments:    1,
+		},
+		{
+			description: "gsm-7 text that exactly fills one segment",
+			text:        strings.Repeat("a", 160),
+			encoding:    messaging.EncodingGSM7,
+			segments:    1,
+		},
+		{
+			description: "gsm-7 text one character over a single segment",
+			text:        strings.Repeat("a", 161),
+			encoding:    messaging.EncodingGSM7,
+			segments:    2,
+		},
+		{
+			description: "gsm-7 extension characters count twice",
+			text:        strings.Repeat("a", 151) + "{}[]~",
+			encoding:    messaging.EncodingGSM7,
+			segments:    2,
+		},
+		{
+			description: "accented gsm-7 characters",
+			text:        "¡Gracias Señor! ¿Qué?",
+			encoding:    messaging.EncodingGSM7,
+			segments:    1,
+		},
+		{
+			description: "accented characters that are not gsm-7",
+			text:        "¿Dónde está?",
+			encoding:    messaging.EncodingUCS2,
+			segments:    1,
+		},
+		{
+			description: "emoji makes the whole text ucs-2",
+			text:        strings.Repeat("a", 70) + "🙏",
+			encoding:    messaging.EncodingUCS2,
+			segments:    2,
+		},
+		{
+			description: "emojis outside the basic multilingual plane count twice",
+			text:        strings.Repeat("🙏", 35),
+			encoding:    messaging.EncodingUCS2,
+			segments:    1,
+		},
+		{
+			description: "multi segment ucs-2 text",
+			text:        strings.Repeat("é", 200) + "’",
+			encoding:    messaging.EncodingUCS2,
+			segments:    3,
+		},
+	}
+
+	for _, test := range testCases {
+		t.Run(test.description, func(t *testing.T) {
+			if encoding := messaging.Encoding(test.text); encoding != test.encoding {
+				t.Errorf("expected encoding %v, got %v", test.encoding, encoding)
+			}
+
+			if segments := messaging.Segments(test.text); segments != test.segments {
+				t.Errorf("expected %v segments, got %v", test.segments, segments)
+			}
+		})
+	}
+
+	if total := messaging.TotalSegments([]string{"a", strings.Repeat("a", 161)}); total != 3 {
+		t.Errorf("expected 3 total segments, got %v", total)
+	}
+}
@@ -0,0 +1,417 @@
+package messaging
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"unicode/utf8"
+)
+
+// template names
+const (
+	// sign up messages
+	MsgNameRequest               = "name-request"
+	MsgMemberTypeRequest         = "member-type-request"
+	MsgPrayerInstructions        = "prayer-instructions"
+	MsgPrayerNumRequest          = "prayer-num-request"
+	MsgIntercessorInstructions   = "intercessor-instructions"
+	MsgWrongInput                = "wrong-input"
+	MsgSignUpConfirmation        = "sign-up-confirmation"
+	MsgPrayerSignUpComplete      = "prayer-sign-up-complete"
+	MsgIntercessorSignUpComplete = "intercessor-sign-up-complete"
+	MsgRemoveUser                = "remove-user"
+
+	// prayer request messages
+	MsgProfanityFound   = "profanity-found"
+	MsgContactInfoFound = "contact-info-found"
+	MsgPrayerIntro      = "prayer-intro"
+	MsgPrayerQueued     = "prayer-queued"
+	MsgPrayerSentOut    = "prayer-sent-out"
+	MsgPrayerTooLong    = "prayer-too-long"
+
+	// prayer completion messages
+	MsgNoActivePrayer     = "no-active-prayer"
+	MsgPrayerThankYou     = "prayer-thank-you"
+	MsgPrayerConfirmation = "prayer-confirmation"
+
+	// moderation messages
+	MsgModerationRequest  = "moderation-request"
+	MsgModerationApproved = "moderation-approved"
+	MsgModerationRejected = "moderation-rejected"
+	MsgModerationNotFound = "moderation-not-found"
+	MsgPrayerPending      = "prayer-pending"
+	MsgPrayerRejected     = "prayer-rejected"
+	MsgPhoneBlocked       = "phone-blocked"
+	MsgPhoneUnblocked     = "phone-unblocked"
+	MsgInvalidPhone       = "invalid-phone"
+
+	// admin messages
+	MsgAdminBroadcast  = "admin-broadcast"
+	MsgAdminHelp       = "admin-help"
+	MsgAdminOverBudget = "admin-over-budget"
+	MsgAdminQueue      = "admin-queue"
+	MsgAdminRetry      = "admin-retry"
+	MsgAdminStats      = "admin-stats"
+	MsgAdminStuck      = "admin-stuck"
+
+	// other
+	MsgHelp            = "help"
+	MsgLanguageChanged = "language-changed"
+	MsgRateLimited     = "rate-limited"
+	MsgPre             = "pre"
+	MsgPost            = "post"
+)
+
+// keywords are commands that members text in. Each locale has its own words for every keyword
+const (
+	KeywordHelp     = "help"
+	KeywordLanguage = "language"
+	KeywordPray     = "pray"
+	KeywordPrayed   = "prayed"
+	KeywordStop     = "stop"
+
+	// moderator keywords are followed by a prayer request ID or phone
+	KeywordApprove = "approve"
+	KeywordBlock   = "block"
+	KeywordReject  = "reject"
+	KeywordUnblock = "unblock"
+)
+
+const (
+	// MaxMessageLength is the maximum number of characters the sms provider accepts for a single
+	// text message, including MsgPre and MsgPost
+	MaxMessageLength = 1600
+	// DefaultLocale is used for members without a language and for any template that is missing
+	// from a locale
+	DefaultLocale = "en"
+	keywordsFile  = "keywords.json"
+	templatesDir  = "templates"
+	templatesExt  = ".tmpl"
+	varsFile      = "vars.json"
+)
+
+//go:embed templates
+var defaultTemplates embed.FS
+
+// Templates renders all member facing messages. Every message is a text/template that is
+// executed with a map of named variables, for example {{.Name}}. Variables come from deployment
+// wide vars (such as SupportEmail) merged with the per message data passed to Render.
+//
+// Templates are kept per locale (templates/<locale>/*.tmpl). Render uses the locale selected with
+// Locale, which starts out as DefaultLocale.
+type Templates struct {
+	keywords map[string]map[string][]string
+	locale   string
+	sets     map[string]*template.Template
+	vars     map[string]string
+}
+
+// LoadTemplates loads the embedded default templates, keywords and variables of every locale and
+// applies overrides and vars on top of them. overrides maps template names to template text. A
+// plain name such as "help" overrides the DefaultLocale template, a name with a locale prefix
+// such as "es/help" overrides the template of that locale.
+func LoadTemplates(overrides, vars map[string]string) (*Templates, error) {
+	tmpls := &Templates{
+		keywords: map[string]map[string][]string{},
+		locale:   DefaultLocale,
+		sets:     map[string]*template.Template{},
+		vars:     map[string]string{},
+	}
+
+	files, err := fs.Glob(defaultTemplates, path.Join(templatesDir, "*", "*"+templatesExt))
+	if err != nil {
+		return nil, fmt.Errorf("LoadTemplates: %w", err)
+	}
+
+	for _, file := range files {
+		text, err := fs.ReadFile(defaultTemplates, file)
+		if err != nil {
+			return nil, fmt.Errorf("LoadTemplates: %w", err)
+		}
+
+		locale := path.Base(path.Dir(file))
+		if tmpls.sets[locale] == nil {
+			tmpls.sets[locale] = template.New("").Option("missingkey=error")
+		}
+
+		name := strings.TrimSuffix(path.Base(file), templatesExt)
+		if err := tmpls.parse(locale, name, string(text)); err != nil {
+			return nil, fmt.Errorf("LoadTemplates: %w", err)
+		}
+	}
+
+	if tmpls.sets[DefaultLocale] == nil {
+		return nil, fmt.Errorf("LoadTemplates: missing templates for default locale %v", DefaultLocale)
+	}
+
+	for locale := range tmpls.sets {
+		keywordsJSON, err := fs.ReadFile(defaultTemplates, path.Join(templatesDir, locale, keywordsFile))
+		if err != nil {
+			return nil, fmt.Errorf("LoadTemplates: %w", err)
+		}
+
+		keywords := map[string][]string{}
+		if err := json.Unmarshal(keywordsJSON, &keywords); err != nil {
+			return nil, fmt.Errorf("LoadTemplates failed unmarshal: %w", err)
+		}
+		tmpls.keywords[locale] = keywords
+	}
+
+	varsJSON, err := fs.ReadFile(defaultTemplates, path.Join(templatesDir, varsFile))
+	if err != nil {
+		return nil, fmt.Errorf("LoadTemplates: %w", err)
+	}
+
+	if err := json.Unmarshal(varsJSON, &tmpls.vars); err != nil {
+		return nil, fmt.Errorf("LoadTemplates failed unmarshal: %w", err)
+	}
+
+	return tmpls.Override(overrides, vars)
+}
+
+// Override returns a copy of t with templates replaced by overrides and variables replaced by
+// vars. t is not modified. Overrides must use an existing template name and locale.
+func (t *Templates) Override(overrides, vars map[string]string) (*Templates, error) {
+	tmpls := &Templates{
+		keywords: t.keywords,
+		locale:   t.locale,
+		sets:     map[string]*template.Template{},
+		vars:     maps.Clone(t.vars),
+	}
+	maps.Copy(tmpls.vars, vars)
+
+	for locale, set := range t.sets {
+		clone, err := set.Clone()
+		if err != nil {
+			return nil, fmt.Errorf("Templates override: %w", err)
+		}
+		tmpls.sets[locale] = clone
+	}
+
+	for key, text := range overrides {
+		locale, name, found := strings.Cut(key, "/")
+		if !found {
+			locale, name = DefaultLocale, key
+		}
+
+		if tmpls.sets[locale] == nil {
+			return nil, fmt.Errorf("Templates override: unknown locale %v", locale)
+		}
+
+		if tmpls.sets[DefaultLocale].Lookup(name) == nil {
+			return nil, fmt.Errorf("Templates override: unknown template %v", name)
+		}
+
+		if err := tmpls.parse(locale, name, text); err != nil {
+			return nil, fmt.Errorf("Templates override: %w", err)
+		}
+	}
+
+	return tmpls, nil
+}
+
+func (t *Templates) parse(locale, name, text string) error {
+	// template files end with a newline that is not part of the message
+	text = strings.TrimSuffix(text, "\n")
+	if _, err := t.sets[locale].New(name).Parse(text); err != nil {
+		return fmt.Errorf("parse template %v/%v: %w", locale, name, err)
+	}
+
+	return nil
+}
+
+// Locale returns a copy of t that renders messages in locale. Unknown or empty locales use
+// DefaultLocale. Templates are shared with t, so this is cheap to call for every message.
+func (t *Templates) Locale(locale string) *Templates {
+	tmpls := *t
+	tmpls.locale = DefaultLocale
+	if t.sets[locale] != nil {
+		tmpls.locale = locale
+	}
+
+	return &tmpls
+}
+
+// Locales returns the sorted names of all locales.
+func (t *Templates) Locales() []string {
+	return slices.Sorted(maps.Keys(t.sets))
+}
+
+// Names returns the sorted names of all templates.
+func (t *Templates) Names() []string {
+	var names []string
+	for _, tmpl := range t.sets[DefaultLocale].Templates() {
+		if tmpl.Name() != "" {
+			names = append(names, tmpl.Name())
+		}
+	}
+
+	slices.Sort(names)
+
+	return names
+}
+
+// MatchKeyword returns the keyword and locale of body if it is one of the keywords of any locale.
+// Matching ignores case and surrounding whitespace. An empty keyword means no match.
+func (t *Templates) MatchKeyword(body string) (string, string) {
+	body = strings.ToLower(strings.TrimSpace(body))
+
+	for _, locale := range t.Locales() {
+		for keyword, words := range t.keywords[locale] {
+			if slices.Contains(words, body) {
+				return keyword, locale
+			}
+		}
+	}
+
+	return "", ""
+}
+
+// Matches returns whether body could have been rendered from the template name of any locale.
+// This is true when every text outside of actions at the top level of the template, including the
+// templates it invokes, appears in body in order. This also matches messages that combine several
+// templates. Templates without such text never match.
+func (t *Templates) Matches(name, body string) bool {
+	for _, locale := range t.Locales() {
+		texts := staticTexts(t.sets[locale], name, 0)
+		if len(texts) == 0 {
+			continue
+		}
+
+		rest, found := body, true
+		for _, text := range texts {
+			if _, rest, found = strings.Cut(rest, text); !found {
+				break
+			}
+		}
+
+		if found {
+			return true
+		}
+	}
+
+	return false
+}
+
+// staticTexts returns the trimmed texts at the top level of the template name of set, in order.
+// Invoked templates are followed up to a small depth, which also stops recursive templates.
+func staticTexts(set *template.Template, name string, depth int) []string {
+	tmpl := set.Lookup(name)
+	if tmpl == nil || tmpl.Tree == nil || depth > 5 {
+		return nil
+	}
+
+	var texts []string
+	for _, node := range tmpl.Tree.Root.Nodes {
+		switch n := node.(type) {
+		case *parse.TextNode:
+			if text := strings.TrimSpace(string(n.Text)); text != "" {
+				texts = append(texts, text)
+			}
+		case *parse.TemplateNode:
+			texts = append(texts, staticTexts(set, n.Name, depth+1)...)
+		}
+	}
+
+	return texts
+}
+
+// Render executes the template name of the current locale with the deployment variables and
+// data. data takes priority over deployment variables and may be nil. Templates that are missing
+// from the current locale are rendered from DefaultLocale.
+func (t *Templates) Render(name string, data map[string]string) (string, error) {
+	tmpl := t.sets[t.locale].Lookup(name)
+	if tmpl == nil {
+		tmpl = t.sets[DefaultLocale].Lookup(name)
+	}
+	if tmpl == nil {
+		return "", fmt.Errorf("Templates render: unknown template %v", name)
+	}
+
+	vars := maps.Clone(t.vars)
+	maps.Copy(vars, data)
+
+	var body strings.Builder
+	if err := tmpl.Execute(&body, vars); err != nil {
+		return "", fmt.Errorf("Templates render: %w", err)
+	}
+
+	return body.String(), nil
+}
+
+// Wrap adds MsgPre and MsgPost to a rendered message body. This is how a message that fits into a
+// single text message gets sent out, see Compose for longer messages.
+func (t *Templates) Wrap(body string) (string, error) {
+	pre, err := t.Render(MsgPre, nil)
+	if err != nil {
+		return "", err
+	}
+
+	post, err := t.Render(MsgPost, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return pre + body + "\n\n" + post, nil
+}
+
+// Validate renders every template of every locale with sample data and verifies that the wrapped
+// message fits within MaxMessageLength. This should run at startup so that broken overrides are
+// caught before any member receives a message.
+func (t *Templates) Validate() error {
+	for _, locale := range t.Locales() {
+		tmpls := t.Locale(locale)
+		for _, name := range t.Names() {
+			if name == MsgPre || name == MsgPost {
+				continue
+			}
+
+			body, err := tmpls.Render(name, sampleData())
+			if err != nil {
+				return fmt.Errorf("Templates validate %v: %w", locale, err)
+			}
+
+			msg, err := tmpls.Wrap(body)
+			if err != nil {
+				return fmt.Errorf("Templates validate %v: %w", locale, err)
+			}
+
+			if length := utf8.RuneCountInString(msg); length > MaxMessageLength {
+				return fmt.Errorf("Templates validate: template %v/%v renders %v characters, max is %v",
+					locale, name, length, MaxMessageLength)
+			}
+		}
+	}
+
+	return nil
+}
+
+// sampleData returns values for every per message variable that templates may use. The values
+// have realistic lengths. Prayer requests are member content and are not part of the template
+// length, so Request is empty.
+func sampleData() map[string]string {
+	return map[string]string{
+		"Active":       "99999",
+		"Completed":    "99999",
+		"Count":        "99999",
+		"ID":           strings.Repeat("I", 8),
+		"Intercessors": "99999",
+		"Members":      "99999",
+		"Name":         strings.Repeat("N", 40),
+		"Phone":        "+15555555555",
+		"Prefix":       strings.Repeat("#", 10),
+		"Profanity":    strings.Repeat("P", 20),
+		"Queued":       "99999",
+		"Request":      "",
+		"Sent":         "99999",
+		// admin-stuck lists up to 5 states of about 60 characters each
+		"States": strings.Repeat("S", 300),
+	}
+}
@@ -0,0 +1,208 @@
+package messaging_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mshort55/prayertexter/internal/messaging"
+)
+
+func TestLoadTemplates(t *testing.T) {
+	tmpls, err := messaging.LoadTemplates(nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if err := tmpls.Validate(); err != nil {
+		t.Errorf("expected default templates to be valid, got %v", err)
+	}
+
+	body, err := tmpls.Render(messaging.MsgPrayerIntro, map[string]string{"Name": "John Doe", "Request": "please pray"})
+	if err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	expected := "Hello! Please pray for John Doe:\nplease pray"
+	if body != expected {
+		t.Errorf("expected body %q, got %q", expected, body)
+	}
+
+	// composite templates include other templates
+	body, err = tmpls.Render(messaging.MsgPrayerSignUpComplete, nil)
+	if err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	for _, name := range []string{messaging.MsgPrayerInstructions, messaging.MsgSignUpConfirmation} {
+		part, _ := tmpls.Render(name, nil)
+		if !strings.Contains(body, part) {
+			t.Errorf("expected %v to contain %q, got %q", messaging.MsgPrayerSignUpComplete, part, body)
+		}
+	}
+
+	if _, err := tmpls.Render("does-not-exist", nil); err == nil {
+		t.Errorf("expected error for unknown template, got nil")
+	}
+
+	// missing per message variables are an error instead of rendering "<no value>"
+	if _, err := tmpls.Render(messaging.MsgPrayerConfirmation, nil); err == nil {
+		t.Errorf("expected error for missing variable, got nil")
+	}
+}
+
+func TestTemplatesOverride(t *testing.T) {
+	overrides := map[string]string{messaging.MsgHelp: "Call {{.SupportPhone}} for help"}
+	vars := map[string]string{"SupportPhone": "(555) 555-5555"}
+
+	tmpls, err := messaging.LoadTemplates(overrides, vars)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if body, _ := tmpls.Render(messaging.MsgHelp, nil); body != "Call (555) 555-5555 for help" {
+		t.Errorf("expected overridden help message, got %q", body)
+	}
+
+	tenantTmpls, err := tmpls.Override(nil, map[string]string{"SupportPhone": "(777) 777-7777"})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if body, _ := tenantTmpls.Render(messaging.MsgHelp, nil); body != "Call (777) 777-7777 for help" {
+		t.Errorf("expected tenant help message, got %q", body)
+	}
+
+	// the original Templates must not be changed by an Override
+	if body, _ := tmpls.Render(messaging.MsgHelp, nil); body != "Call (555) 555-5555 for help" {
+		t.Errorf("expected original help message to be unchanged, got %q", body)
+	}
+
+	if _, err := tmpls.Override(map[string]string{"hepl": "typo"}, nil); err == nil {
+		t.Errorf("expected error for unknown template override, got nil")
+	}
+
+	if _, err := tmpls.Override(map[string]string{messaging.MsgHelp: "{{.SupportPhone"}, nil); err == nil {
+		t.Errorf("expected error for invalid template override, got nil")
+	}
+}
+
+func TestTemplatesValidate(t *testing.T) {
+	tmpls, err := messaging.LoadTemplates(map[string]string{messaging.MsgHelp: strings.Repeat("a", messaging.MaxMessageLength)}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if err := tmpls.Validate(); err == nil {
+		t.Errorf("expected error for template over max message length, got nil")
+	}
+
+	tmpls, err = messaging.LoadTemplates(map[string]string{messaging.MsgHelp: "Call {{.SupportFax}}"}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if err := tmpls.Validate(); err == nil {
+		t.Errorf("expected error for template with unknown variable, got nil")
+	}
+}
+
+func TestTemplatesLocale(t *testing.T) {
+	overrides := map[string]string{"es/" + messaging.MsgHelp: "Llame al {{.SupportPhone}}"}
+	tmpls, err := messaging.LoadTemplates(overrides, map[string]string{"SupportPhone": "(555) 555-5555"})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// every locale must have a translation of every template
+	data := map[string]string{"Active": "1", "Completed": "2", "Count": "3", "ID": "1a2b3c4d",
+		"Intercessors": "4", "Members": "5", "Name": "John Doe", "Phone": "+11234567890", "Prefix": "#admin",
+		"Profanity": "x", "Queued": "6", "Request": "y", "Sent": "7", "States": "z"}
+	for _, locale := range tmpls.Locales() {
+		for _, name := range tmpls.Names() {
+			en, _ := tmpls.Render(name, data)
+			body, err := tmpls.Locale(locale).Render(name, data)
+			if err != nil {
+				t.Errorf("unexpected error %v", err)
+			}
+			if locale != messaging.DefaultLocale && name != messaging.MsgPre && body == en {
+				t.Errorf("expected %v translation of %v, got default %q", locale, name, body)
+			}
+		}
+	}
+
+	if body, _ := tmpls.Locale("es").Render(messaging.MsgHelp, nil); body != "Llame al (555) 555-5555" {
+		t.Errorf("expected overridden spanish help message, got %q", body)
+	}
+
+	// the default locale is not affected by a locale specific override
+	if body, _ := tmpls.Render(messaging.MsgHelp, nil); strings.Contains(body, "Llame") {
+		t.Errorf("expected english help message, got %q", body)
+	}
+
+	// unknown locales fall back to the default locale
+	expected, _ := tmpls.Render(messaging.MsgPrayerThankYou, nil)
+	if body, _ := tmpls.Locale("xx").Render(messaging.MsgPrayerThankYou, nil); body != expected {
+		t.Errorf("expected default locale message %q, got %q", expected, body)
+	}
+
+	if _, err := tmpls.Override(map[string]string{"xx/" + messaging.MsgHelp: "help"}, nil); err == nil {
+		t.Errorf("expected error for unknown locale override, got nil")
+	}
+}
+
+func TestMatchKeyword(t *testing.T) {
+	tmpls, err := messaging.LoadTemplates(nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	testCases := []struct {
+		body    string
+		keyword string
+		locale  string
+	}{
+		{body: "help", keyword: messaging.KeywordHelp, locale: "en"},
+		{body: " Pray ", keyword: messaging.KeywordPray, locale: "en"},
+		{body: "CANCEL", keyword: messaging.KeywordStop, locale: "en"},
+		{body: "ayuda", keyword: messaging.KeywordHelp, locale: "es"},
+		{body: "Oré", keyword: messaging.KeywordPrayed, locale: "es"},
+		{body: "idioma", keyword: messaging.KeywordLanguage, locale: "es"},
+		{body: "please pray for my family", keyword: "", locale: ""},
+	}
+
+	for _, test := range testCases {
+		keyword, locale := tmpls.MatchKeyword(test.body)
+		if keyword != test.keyword || locale != test.locale {
+			t.Errorf("expected keyword %q and locale %q for %q, got %q and %q", test.keyword, test.locale,
+				test.body, keyword, locale)
+		}
+	}
+}
+
+func TestTemplatesMatches(t *testing.T) {
+	tmpls, err := messaging.LoadTemplates(nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	confirmation, err := tmpls.Locale("es").Render(messaging.MsgPrayerConfirmation, map[string]string{"Name": "Juan"})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	body, err := tmpls.Wrap(confirmation)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if !tmpls.Matches(messaging.MsgPrayerConfirmation, body) {
+		t.Errorf("expected %q to match %v", body, messaging.MsgPrayerConfirmation)
+	}
+
+	if tmpls.Matches(messaging.MsgPrayerThankYou, body) {
+		t.Errorf("expected %q to not match %v", body, messaging.MsgPrayerThankYou)
+	}
+
+	if tmpls.Matches("unknown", body) {
+		t.Errorf("expected %q to not match an unknown template", body)
+	}
+}
@@ -2,10 +2,12 @@ package messaging
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"regexp"
+	"strings"
 
-	goaway "github.com/TwiN/go-away"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/pinpointsmsvoicev2"
 	"github.com/aws/aws-sdk-go-v2/service/pinpointsmsvoicev2/types"
@@ -13,37 +15,26 @@ import (
 )
 
 const (
-	// sign up messages
-	MsgNameRequest             = "Reply your name, or 2 to stay anonymous"
-	MsgMemberTypeRequest       = "Reply 1 to send prayer request, or 2 to be added to the intercessors list (to pray for others). 2 will also allow you to send in prayer requests."
-	MsgPrayerInstructions      = "You are now signed up to send prayer requests! You can send them directly to this number at any time. You will be alerted when someone has prayed for your request."
-	MsgPrayerNumRequest        = "Reply with the number of maximum prayer texts you are willing to receive and pray for each week"
-	MsgIntercessorInstructions = "You are now signed up to receive prayer requests. Please try to pray for the requests ASAP. Once you are done praying, send 'prayed' back to this number for confirmation."
-	MsgWrongInput              = "Wrong input received during sign up process, please try again"
-	MsgSignUpConfirmation      = "You have opted in to PrayerTexter. Msg & data rates may apply."
-	MsgRemoveUser              = "You have been removed from PrayerTexter. To sign back up, text the word pray to this number."
-
-	// prayer request messages
-	MsgProfanityFound = "There was profanity found in your prayer request:\n\nPLACEHOLDER\n\nPlease try the request again without this word or words."
-	MsgPrayerIntro    = "Hello! Please pray for PLACEHOLDER:\n"
-	MsgPrayerQueued   = "We could not find any available intercessors. Your prayer has been added to the queue and will get sent out as soon as someone is available."
-	MsgPrayerSentOut  = "Your prayer request has been sent out!"
-
-	// prayer completion messages
-	MsgNoActivePrayer     = "You have no more active prayers to mark as prayed"
-	MsgPrayerThankYou     = "Thank you for praying!"
-	MsgPrayerConfirmation = "You're prayer request has been prayed for by PLACEHOLDER"
-
-	// other
-	MsgHelp           = "To receive support, please email info@4jesusministries.com or call/text (657) 217-1678. Thank you!"
-	MsgPre            = "PrayerTexter: "
-	MsgPost           = "Reply HELP for help or STOP to cancel."
 	PrayerTexterPhone = "+12762908579"
 )
 
+// ErrInvalidPhone is returned by NormalizePhone for phones that are not E.164 numbers.
+var ErrInvalidPhone = errors.New("phone is not an E.164 number like +11234567890")
+
+// e164 matches a whole E.164 phone number, which is how the sms provider sends member phones.
+var e164 = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+
 type TextMessage struct {
-	Body  string `json:"body"`
+	Body string `json:"body"`
+	// ID is the sms provider's message ID of a received message, if the provider sends one
+	ID    string `json:"message-id" dynamodbav:",omitempty"`
 	Phone string `json:"phone-number"`
+	// TenantPhone is the PrayerTexter number on our side of the conversation. For received
+	// messages it is the number the member texted, and for sent messages it is the origination
+	// number. Empty means PrayerTexterPhone.
+	TenantPhone string `json:"destination-number" dynamodbav:",omitempty"`
+	// Timestamp is the time the sms provider received the message, if the provider sends one
+	Timestamp string `json:"timestamp" dynamodbav:",omitempty"`
 }
 
 type TextSender interface {
@@ -52,6 +43,23 @@ type TextSender interface {
 		optFns ...func(*pinpointsmsvoicev2.Options)) (*pinpointsmsvoicev2.SendTextMessageOutput, error)
 }
 
+// NormalizePhone returns phone in E.164 format, the way members are stored when they sign up.
+// Spaces, dashes, dots and parentheses are removed, so "+1 (123) 456-7890" becomes "+11234567890".
+func NormalizePhone(phone string) (string, error) {
+	normalized := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(" -.()", r) {
+			return -1
+		}
+		return r
+	}, phone)
+
+	if !e164.MatchString(normalized) {
+		return "", fmt.Errorf("%q: %w", phone, ErrInvalidPhone)
+	}
+
+	return normalized, nil
+}
+
 func GetSmsClient() (*pinpointsmsvoicev2.Client, error) {
 	cfg, err := utility.GetAwsConfig()
 	if err != nil {
@@ -63,36 +71,36 @@ func GetSmsClient() (*pinpointsmsvoicev2.Client, error) {
 	return smsClnt, nil
 }
 
-func SendText(smsClnt TextSender, msg TextMessage) error {
-	body := MsgPre + msg.Body + "\n\n" + MsgPost
+// SendText sends msg to msg.Phone. msg.Body is a rendered message that gets composed into one or
+// more text messages with MsgPre and MsgPost from tmpls.
+func SendText(smsClnt TextSender, tmpls *Templates, msg TextMessage) error {
+	parts, err := tmpls.Compose(msg.Body)
+	if err != nil {
+		return err
+	}
 
-	input := &pinpointsmsvoicev2.SendTextMessageInput{
-		DestinationPhoneNumber: aws.String(msg.Phone),
-		MessageBody:            aws.String(body),
-		MessageType:            types.MessageTypeTransactional,
-		OriginationIdentity:    aws.String(PrayerTexterPhone),
+	origination := PrayerTexterPhone
+	if msg.TenantPhone != "" {
+		origination = msg.TenantPhone
 	}
 
-	if _, err := smsClnt.SendTextMessage(context.TODO(), input); err != nil {
-		return err
+	for _, part := range parts {
+		input := &pinpointsmsvoicev2.SendTextMessageInput{
+			DestinationPhoneNumber: aws.String(msg.Phone),
+			MessageBody:            aws.String(part),
+			MessageType:            types.MessageTypeTransactional,
+			OriginationIdentity:    aws.String(origination),
+		}
+
+		if _, err := smsClnt.SendTextMessage(context.TODO(), input); err != nil {
+			return err
+		}
 	}
 
 	// this helps with unit testing and sam local testing so you can view the text message flow from the logs
 	if utility.IsAwsLocal() {
-		slog.Info("sent text message", "phone", msg.Phone, "body", msg.Body)
+		slog.Info("sent text message", "phone", msg.Phone, "body", msg.Body, "parts", len(parts))
 	}
 
 	return nil
 }
-
-func (t TextMessage) CheckProfanity() string {
-	// We need to remove some words from the profanity filter because it is too sensitive
-	removedWords := []string{"jerk"}
-	profanities := &goaway.DefaultProfanities
-
-	for _, word := range removedWords {
-		utility.RemoveItem(profanities, word)
-	}
-
-	return goaway.ExtractProfanity(t.Body)
-}
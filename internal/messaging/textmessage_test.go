@@ -1,6 +1,8 @@
 package messaging_test
 
 import (
+	"errors"
+	"reflect"
 	"testing"
 
 	"github.com/mshort55/prayertexter/internal/messaging"
@@ -14,8 +16,12 @@ func TestSendText(t *testing.T) {
 	}
 
 	txtMock := &mock.TextSender{}
+	tmpls, err := messaging.LoadTemplates(nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
 
-	if err := messaging.SendText(txtMock, msg); err != nil {
+	if err := messaging.SendText(txtMock, tmpls, msg); err != nil {
 		t.Errorf("unexpected error, %v", err)
 	}
 
@@ -24,7 +30,7 @@ func TestSendText(t *testing.T) {
 		Phone: *txtMock.SendTextInputs[0].DestinationPhoneNumber,
 	}
 
-	msg.Body = messaging.MsgPre + msg.Body + "\n\n" + messaging.MsgPost
+	msg.Body = "PrayerTexter: " + msg.Body + "\n\nReply HELP for help or STOP to cancel."
 
 	if receivedText != msg {
 		t.Errorf("expected txt %v, got %v", msg, receivedText)
@@ -33,19 +39,14 @@ func TestSendText(t *testing.T) {
 	if *txtMock.SendTextInputs[0].OriginationIdentity != messaging.PrayerTexterPhone {
 		t.Errorf("expected phone number %v, got %v", messaging.PrayerTexterPhone, *txtMock.SendTextInputs[0].OriginationIdentity)
 	}
-}
 
-func TestCheckProfanity(t *testing.T) {
-	msg := messaging.TextMessage{Body: "test text message, no profanity"}
-	profanity := msg.CheckProfanity()
-	if profanity != "" {
-		t.Errorf("expected no profanity, got %v", profanity)
+	msg.TenantPhone = "+19998887777"
+	if err := messaging.SendText(txtMock, tmpls, msg); err != nil {
+		t.Errorf("unexpected error, %v", err)
 	}
 
-	msg.Body = "this message contains profanity, sh!t!"
-	profanity = msg.CheckProfanity()
-	if profanity == "" {
-		t.Errorf("expected profanity, got none (empty string): %v", profanity)
+	if *txtMock.SendTextInputs[1].OriginationIdentity != msg.TenantPhone {
+		t.Errorf("expected phone number %v, got %v", msg.TenantPhone, *txtMock.SendTextInputs[1].OriginationIdentity)
 	}
 }
 
@@ -63,3 +64,72 @@ func TestCheckProfanity(t *testing.T) {
 // 		t.Errorf("unexpected error, %v", err)
 // 	}
 // }
+
+func TestRecordingSender(t *testing.T) {
+	txtMock := &mock.TextSender{}
+	txtMock.SendTextResults = []struct {
+		Error error
+	}{
+		{Error: nil},
+		{Error: errors.New("second send failure")},
+	}
+
+	rec := &messaging.RecordingSender{TextSender: txtMock}
+	tmpls, err := messaging.LoadTemplates(nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error, %v", err)
+	}
+
+	if err := messaging.SendText(rec, tmpls, messaging.TextMessage{Body: "🙏", Phone: "+11234567890"}); err != nil {
+		t.Errorf("unexpected error, %v", err)
+	}
+
+	if err := messaging.SendText(rec, tmpls, messaging.TextMessage{Body: "hi", Phone: "+11234567890"}); err == nil {
+		t.Errorf("expected error, got nil")
+	}
+
+	// failed sends are not recorded
+	expected := []messaging.SentText{
+		{Country: "+1", Encoding: messaging.EncodingUCS2, Phone: "+11234567890", Segments: 1},
+	}
+	if !reflect.DeepEqual(rec.Sent, expected) {
+		t.Errorf("expected sent texts %v, got %v", expected, rec.Sent)
+	}
+}
+
+func TestCountryCode(t *testing.T) {
+	for phone, expected := range map[string]string{
+		"+11234567890":   "+1",
+		"+525512345678":  "+52",
+		"+447911123456":  "+44",
+		"+5930991234567": "+593",
+		"+79161234567":   "+7",
+		"11234567890":    "",
+		"+1":             "",
+	} {
+		if code := messaging.CountryCode(phone); code != expected {
+			t.Errorf("expected country code %q for %v, got %q", expected, phone, code)
+		}
+	}
+}
+
+func TestNormalizePhone(t *testing.T) {
+	for phone, expected := range map[string]string{
+		"+11234567890":      "+11234567890",
+		"+1 (123) 456-7890": "+11234567890",
+		"+44 7911.123456":   "+447911123456",
+		"11234567890":       "",
+		"+01234567890":      "",
+		"+1123":             "",
+		"+1123456789012345": "",
+		"+1123456789a":      "",
+		"":                  "",
+	} {
+		normalized, err := messaging.NormalizePhone(phone)
+		if expected == "" && !errors.Is(err, messaging.ErrInvalidPhone) {
+			t.Errorf("expected ErrInvalidPhone for %q, got %v", phone, err)
+		} else if normalized != expected {
+			t.Errorf("expected %q for %q, got %q", expected, phone, normalized)
+		}
+	}
+}
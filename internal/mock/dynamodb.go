@@ -2,18 +2,33 @@ package mock
 
 import (
 	"context"
+	"sync"
 
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 )
 
+// DDBConnecter returns its results in the order of the calls. It is safe for concurrent use, but
+// the order of concurrent calls, for example of parallel scan segments, is not known.
 type DDBConnecter struct {
 	GetItemCalls    int
 	PutItemCalls    int
 	DeleteItemCalls int
+	UpdateItemCalls int
+	ScanCalls       int
+	QueryCalls      int
+
+	BatchGetItemCalls   int
+	BatchWriteItemCalls int
 
 	GetItemInputs    []dynamodb.GetItemInput
 	PutItemInputs    []dynamodb.PutItemInput
 	DeleteItemInputs []dynamodb.DeleteItemInput
+	UpdateItemInputs []dynamodb.UpdateItemInput
+	ScanInputs       []dynamodb.ScanInput
+	QueryInputs      []dynamodb.QueryInput
+
+	BatchGetItemInputs   []dynamodb.BatchGetItemInput
+	BatchWriteItemInputs []dynamodb.BatchWriteItemInput
 
 	GetItemResults []struct {
 		Output *dynamodb.GetItemOutput
@@ -25,11 +40,35 @@ type DDBConnecter struct {
 	DeleteItemResults []struct {
 		Error error
 	}
+	UpdateItemResults []struct {
+		Error error
+	}
+	ScanResults []struct {
+		Output *dynamodb.ScanOutput
+		Error  error
+	}
+	QueryResults []struct {
+		Output *dynamodb.QueryOutput
+		Error  error
+	}
+	BatchGetItemResults []struct {
+		Output *dynamodb.BatchGetItemOutput
+		Error  error
+	}
+	BatchWriteItemResults []struct {
+		Output *dynamodb.BatchWriteItemOutput
+		Error  error
+	}
+
+	mu sync.Mutex
 }
 
 func (m *DDBConnecter) GetItem(ctx context.Context, input *dynamodb.GetItemInput,
 	opts ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
 
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	m.GetItemCalls++
 	m.GetItemInputs = append(m.GetItemInputs, *input)
 
@@ -44,6 +83,9 @@ func (m *DDBConnecter) GetItem(ctx context.Context, input *dynamodb.GetItemInput
 func (m *DDBConnecter) PutItem(ctx context.Context, input *dynamodb.PutItemInput,
 	opts ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
 
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	m.PutItemCalls++
 	m.PutItemInputs = append(m.PutItemInputs, *input)
 
@@ -58,6 +100,9 @@ func (m *DDBConnecter) PutItem(ctx context.Context, input *dynamodb.PutItemInput
 func (m *DDBConnecter) DeleteItem(ctx context.Context, input *dynamodb.DeleteItemInput,
 	opts ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
 
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	m.DeleteItemCalls++
 	m.DeleteItemInputs = append(m.DeleteItemInputs, *input)
 
@@ -68,3 +113,88 @@ func (m *DDBConnecter) DeleteItem(ctx context.Context, input *dynamodb.DeleteIte
 	result := m.DeleteItemResults[m.DeleteItemCalls-1]
 	return nil, result.Error
 }
+
+func (m *DDBConnecter) UpdateItem(ctx context.Context, input *dynamodb.UpdateItemInput,
+	opts ...func(*dynamodb.Options)) (*dynamodb.UpdateItemOutput, error) {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.UpdateItemCalls++
+	m.UpdateItemInputs = append(m.UpdateItemInputs, *input)
+
+	if len(m.UpdateItemResults) <= m.UpdateItemCalls-1 {
+		return &dynamodb.UpdateItemOutput{}, nil
+	}
+
+	result := m.UpdateItemResults[m.UpdateItemCalls-1]
+	return nil, result.Error
+}
+
+func (m *DDBConnecter) Scan(ctx context.Context, input *dynamodb.ScanInput,
+	opts ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.ScanCalls++
+	m.ScanInputs = append(m.ScanInputs, *input)
+
+	if len(m.ScanResults) <= m.ScanCalls-1 {
+		return &dynamodb.ScanOutput{}, nil
+	}
+
+	result := m.ScanResults[m.ScanCalls-1]
+	return result.Output, result.Error
+}
+
+func (m *DDBConnecter) Query(ctx context.Context, input *dynamodb.QueryInput,
+	opts ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error) {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.QueryCalls++
+	m.QueryInputs = append(m.QueryInputs, *input)
+
+	if len(m.QueryResults) <= m.QueryCalls-1 {
+		return &dynamodb.QueryOutput{}, nil
+	}
+
+	result := m.QueryResults[m.QueryCalls-1]
+	return result.Output, result.Error
+}
+
+func (m *DDBConnecter) BatchGetItem(ctx context.Context, input *dynamodb.BatchGetItemInput,
+	opts ...func(*dynamodb.Options)) (*dynamodb.BatchGetItemOutput, error) {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.BatchGetItemCalls++
+	m.BatchGetItemInputs = append(m.BatchGetItemInputs, *input)
+
+	if len(m.BatchGetItemResults) <= m.BatchGetItemCalls-1 {
+		return &dynamodb.BatchGetItemOutput{}, nil
+	}
+
+	result := m.BatchGetItemResults[m.BatchGetItemCalls-1]
+	return result.Output, result.Error
+}
+
+func (m *DDBConnecter) BatchWriteItem(ctx context.Context, input *dynamodb.BatchWriteItemInput,
+	opts ...func(*dynamodb.Options)) (*dynamodb.BatchWriteItemOutput, error) {
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.BatchWriteItemCalls++
+	m.BatchWriteItemInputs = append(m.BatchWriteItemInputs, *input)
+
+	if len(m.BatchWriteItemResults) <= m.BatchWriteItemCalls-1 {
+		return &dynamodb.BatchWriteItemOutput{}, nil
+	}
+
+	result := m.BatchWriteItemResults[m.BatchWriteItemCalls-1]
+	return result.Output, result.Error
+}
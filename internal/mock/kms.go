@@ -0,0 +1,47 @@
+package mock
+
+import (
+	"bytes"
+	"context"
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+)
+
+// KMSConnecter hands out a fixed data key. The wrapped data key is the data key with a prefix, so
+// that Decrypt can unwrap it again.
+type KMSConnecter struct {
+	DecryptCalls         int
+	GenerateDataKeyCalls int
+
+	DecryptInputs         []kms.DecryptInput
+	GenerateDataKeyInputs []kms.GenerateDataKeyInput
+
+	DataKey []byte
+}
+
+func (m *KMSConnecter) Decrypt(ctx context.Context, input *kms.DecryptInput,
+	opts ...func(*kms.Options)) (*kms.DecryptOutput, error) {
+
+	m.DecryptCalls++
+	m.DecryptInputs = append(m.DecryptInputs, *input)
+
+	key, found := bytes.CutPrefix(input.CiphertextBlob, []byte("wrapped:"))
+	if !found {
+		return nil, errors.New("invalid ciphertext")
+	}
+
+	return &kms.DecryptOutput{Plaintext: key}, nil
+}
+
+func (m *KMSConnecter) GenerateDataKey(ctx context.Context, input *kms.GenerateDataKeyInput,
+	opts ...func(*kms.Options)) (*kms.GenerateDataKeyOutput, error) {
+
+	m.GenerateDataKeyCalls++
+	m.GenerateDataKeyInputs = append(m.GenerateDataKeyInputs, *input)
+
+	return &kms.GenerateDataKeyOutput{
+		CiphertextBlob: append([]byte("wrapped:"), m.DataKey...),
+		Plaintext:      m.DataKey,
+	}, nil
+}
@@ -0,0 +1,236 @@
+package object
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/mshort55/prayertexter/internal/db"
+	"github.com/mshort55/prayertexter/internal/encryption"
+)
+
+// ArchivedPrayer is a completed Prayer. Members are only kept as phone hashes, see PhoneHash. The
+// prayer request text is stored in an item of its own, which ddb removes with ttl on
+// ExpirationTime once the retention period is over. The ArchivedPrayer itself has no
+// ExpirationTime, so it is kept without the text after that and PrayerStats keep counting it.
+type ArchivedPrayer struct {
+	CompletedTime   string
+	ID              string
+	IntercessorHash string
+	// Request is stored in the archivedRequest of the ArchivedPrayer, see Put. It is empty once
+	// the retention period is over
+	Request string `dynamodbav:"-"`
+	// RequestExpirationTime is when ddb removes the Request
+	RequestExpirationTime int64
+	RequestorHash         string
+	TenantPhone           string `dynamodbav:",omitempty"`
+}
+
+// archivedRequest is the prayer request text of an ArchivedPrayer. It is stored in the
+// ArchivedPrayers table, keyed on ArchivedRequestKeyPrefix and the ID of the ArchivedPrayer.
+type archivedRequest struct {
+	ExpirationTime int64
+	ID             string
+	Request        string
+}
+
+// PrayerStats counts completed prayers of a month, in total and per Tenant.
+type PrayerStats struct {
+	Completed int
+	Key       string
+	Tenants   map[string]int `dynamodbav:",omitempty"`
+}
+
+const (
+	ArchivedPrayerAttribute  = "ID"
+	ArchivedPrayersTable     = "ArchivedPrayers"
+	ArchivedRequestKeyPrefix = "Request#"
+)
+
+const (
+	PrayerStatsAttribute = "Key"
+	PrayerStatsKeyPrefix = "PrayerStats#"
+	PrayerStatsTable     = "General"
+	prayerStatsMonthFmt  = "2006-01"
+)
+
+// Get gets the ArchivedPrayer and its Request, decrypted by cphr. Request is empty if the retention
+// period of the ArchivedPrayer is over.
+func (a *ArchivedPrayer) Get(ddbClnt db.DDBConnecter, cphr encryption.Cipher) error {
+	arch, err := db.GetDdbObject[ArchivedPrayer](ddbClnt, ArchivedPrayerAttribute, a.ID, ArchivedPrayersTable)
+	if err != nil {
+		return fmt.Errorf("ArchivedPrayer get: %w", err)
+	}
+
+	// this is important so that the original ArchivedPrayer object doesn't get reset to all empty
+	// struct values if the ArchivedPrayer does not exist in ddb
+	if arch.ID == "" {
+		return nil
+	}
+
+	if err := arch.getRequest(ddbClnt, cphr); err != nil {
+		return fmt.Errorf("ArchivedPrayer get: %w", err)
+	}
+	*a = *arch
+
+	return nil
+}
+
+// Put puts the ArchivedPrayer, and its Request encrypted by cphr as an archivedRequest that expires
+// at RequestExpirationTime. The ArchivedPrayer itself is not changed.
+func (a *ArchivedPrayer) Put(ddbClnt db.DDBConnecter, cphr encryption.Cipher) error {
+	if a.Request != "" {
+		req := archivedRequest{
+			ExpirationTime: a.RequestExpirationTime,
+			ID:             ArchivedRequestKeyPrefix + a.ID,
+			Request:        a.Request,
+		}
+		if err := cphr.Encrypt(&req.Request); err != nil {
+			return fmt.Errorf("ArchivedPrayer put: %w", err)
+		}
+
+		if err := db.PutDdbObject(ddbClnt, ArchivedPrayersTable, &req); err != nil {
+			return fmt.Errorf("ArchivedPrayer put: %w", err)
+		}
+	}
+
+	if err := db.PutDdbObject(ddbClnt, ArchivedPrayersTable, a); err != nil {
+		return fmt.Errorf("ArchivedPrayer put: %w", err)
+	}
+
+	return nil
+}
+
+// Delete deletes the ArchivedPrayer and its Request.
+func (a *ArchivedPrayer) Delete(ddbClnt db.DDBConnecter) error {
+	for _, id := range []string{ArchivedRequestKeyPrefix + a.ID, a.ID} {
+		if err := db.DelDdbItem(ddbClnt, ArchivedPrayerAttribute, id, ArchivedPrayersTable); err != nil {
+			return fmt.Errorf("ArchivedPrayer delete: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// getRequest sets Request to the decrypted text of the archivedRequest of the ArchivedPrayer.
+func (a *ArchivedPrayer) getRequest(ddbClnt db.DDBConnecter, cphr encryption.Cipher) error {
+	req, err := db.GetDdbObject[archivedRequest](ddbClnt, ArchivedPrayerAttribute, ArchivedRequestKeyPrefix+a.ID,
+		ArchivedPrayersTable)
+	if err != nil {
+		return err
+	}
+
+	if err := cphr.Decrypt(&req.Request); err != nil {
+		return err
+	}
+	a.Request = req.Request
+
+	return nil
+}
+
+// GetMemberArchivedPrayers returns the ArchivedPrayers where phone is the requestor or the
+// intercessor, decrypted by cphr. Without a hashKey, ArchivedPrayers do not identify members and
+// none are returned. This scans the whole ArchivedPrayers table.
+func GetMemberArchivedPrayers(ddbClnt db.DDBConnecter, cphr encryption.Cipher, hashKey,
+	phone string) ([]ArchivedPrayer, error) {
+	hash := PhoneHash(hashKey, phone)
+	if hash == "" {
+		return nil, nil
+	}
+
+	filter := db.Condition{
+		Expression: "#intercessorHash = :hash OR #requestorHash = :hash",
+		Names:      map[string]string{"#intercessorHash": "IntercessorHash", "#requestorHash": "RequestorHash"},
+		Values:     map[string]types.AttributeValue{":hash": &types.AttributeValueMemberS{Value: hash}},
+	}
+
+	archs, err := db.ScanDdbObjects[ArchivedPrayer](ddbClnt, ArchivedPrayersTable, filter)
+	if err != nil {
+		return nil, fmt.Errorf("GetMemberArchivedPrayers: %w", err)
+	}
+
+	for i := range archs {
+		if err := archs[i].getRequest(ddbClnt, cphr); err != nil {
+			return nil, fmt.Errorf("GetMemberArchivedPrayers: %w", err)
+		}
+	}
+
+	return archs, nil
+}
+
+func (p *PrayerStats) Get(ddbClnt db.DDBConnecter) error {
+	stats, err := db.GetDdbObject[PrayerStats](ddbClnt, PrayerStatsAttribute, p.Key, PrayerStatsTable)
+	if err != nil {
+		return fmt.Errorf("PrayerStats get: %w", err)
+	}
+
+	// this is important so that the original PrayerStats object doesn't get reset to all empty
+	// struct values if the PrayerStats does not exist in ddb
+	if stats.Key != "" {
+		*p = *stats
+	}
+
+	return nil
+}
+
+func (p *PrayerStats) Put(ddbClnt db.DDBConnecter) error {
+	if err := db.PutDdbObject(ddbClnt, PrayerStatsTable, p); err != nil {
+		return fmt.Errorf("PrayerStats put: %w", err)
+	}
+
+	return nil
+}
+
+// PrayerStatsKey returns the key of the PrayerStats of the month of t (UTC).
+func PrayerStatsKey(t time.Time) string {
+	return PrayerStatsKeyPrefix + t.UTC().Format(prayerStatsMonthFmt)
+}
+
+// PhoneHash returns the hex encoded HMAC-SHA256 of phone with key. It identifies a member in
+// archived data without keeping their phone number. There are few enough phone numbers to hash
+// every one of them, so only the secret key keeps the hash from being reversed. Without a key it
+// returns "", and archived data does not identify members at all.
+func PhoneHash(key, phone string) string {
+	if key == "" {
+		return ""
+	}
+
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(phone))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ArchivePrayer saves pryr as an ArchivedPrayer whose Request expires after retention, with its members
+// hashed with hashKey, and counts it in the PrayerStats of the month. The counts are added
+// atomically, so that prayers completed at the same time are all counted.
+func ArchivePrayer(ddbClnt db.DDBConnecter, cphr encryption.Cipher, hashKey string, pryr Prayer,
+	retention time.Duration, id string, now time.Time) error {
+	arch := ArchivedPrayer{
+		CompletedTime:         now.Format(time.RFC3339),
+		ID:                    id,
+		IntercessorHash:       PhoneHash(hashKey, pryr.IntercessorPhone),
+		Request:               pryr.Request,
+		RequestExpirationTime: now.Add(retention).Unix(),
+		RequestorHash:         PhoneHash(hashKey, pryr.Requestor.Phone),
+		TenantPhone:           pryr.Requestor.TenantPhone,
+	}
+	if err := arch.Put(ddbClnt, cphr); err != nil {
+		return fmt.Errorf("archivePrayer: %w", err)
+	}
+
+	counters := []db.Counter{{Path: []string{"Completed"}, Value: 1}}
+	if pryr.Requestor.TenantPhone != "" {
+		counters = append(counters, db.Counter{Path: []string{"Tenants", pryr.Requestor.TenantPhone}, Value: 1})
+	}
+
+	err := db.AddDdbCounters[PrayerStats](ddbClnt, PrayerStatsAttribute, PrayerStatsKey(now), PrayerStatsTable,
+		counters)
+	if err != nil {
+		return fmt.Errorf("archivePrayer: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,248 @@
+package object_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/mshort55/prayertexter/internal/db"
+	"github.com/mshort55/prayertexter/internal/encryption"
+	"github.com/mshort55/prayertexter/internal/object"
+)
+
+func TestArchivePrayer(t *testing.T) {
+	keys, err := encryption.NewStaticKeyProvider(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32)))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	cphr := encryption.Cipher{Keys: keys}
+
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	pryr := object.Prayer{
+		IntercessorPhone: "+11111111111",
+		Request:          "I need prayer for...",
+		Requestor:        object.Member{Phone: "+11234567890", TenantPhone: "+19998887777"},
+	}
+
+	memDB := db.NewMemoryDB(object.TableKeys())
+	for _, id := range []string{"1a2b3c4d", "5e6f7a8b"} {
+		if err := object.ArchivePrayer(memDB, cphr, "secret", pryr, 90*24*time.Hour, id, now); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+
+	out, err := memDB.GetItem(context.Background(), &dynamodb.GetItemInput{
+		TableName: aws.String(object.ArchivedPrayersTable),
+		Key: map[string]types.AttributeValue{
+			object.ArchivedPrayerAttribute: &types.AttributeValueMemberS{Value: "1a2b3c4d"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	for _, attr := range []string{"Request", "ExpirationTime"} {
+		if _, ok := out.Item[attr]; ok {
+			t.Errorf("expected no %v in ArchivedPrayer, got %v", attr, out.Item[attr])
+		}
+	}
+
+	for _, av := range out.Item {
+		if s, ok := av.(*types.AttributeValueMemberS); ok && (s.Value == pryr.IntercessorPhone ||
+			s.Value == pryr.Requestor.Phone) {
+			t.Errorf("expected no member phones in ArchivedPrayer, got %v", s.Value)
+		}
+	}
+
+	out, err = memDB.GetItem(context.Background(), &dynamodb.GetItemInput{
+		TableName: aws.String(object.ArchivedPrayersTable),
+		Key: map[string]types.AttributeValue{
+			object.ArchivedPrayerAttribute: &types.AttributeValueMemberS{
+				Value: object.ArchivedRequestKeyPrefix + "1a2b3c4d",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	var req struct {
+		ExpirationTime int64
+		Request        string
+	}
+	if err := attributevalue.UnmarshalMap(out.Item, &req); err != nil {
+		t.Fatalf("failed to unmarshal item into archived request: %v", err)
+	}
+
+	if !encryption.IsEncrypted(req.Request) {
+		t.Errorf("expected encrypted Request, got %v", req.Request)
+	}
+
+	if expected := now.Add(90 * 24 * time.Hour).Unix(); req.ExpirationTime != expected {
+		t.Errorf("expected archived request ExpirationTime %v, got %v", expected, req.ExpirationTime)
+	}
+
+	arch := object.ArchivedPrayer{ID: "1a2b3c4d"}
+	if err := arch.Get(memDB, cphr); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	expectedArch := object.ArchivedPrayer{
+		CompletedTime:         now.Format(time.RFC3339),
+		ID:                    "1a2b3c4d",
+		IntercessorHash:       object.PhoneHash("secret", "+11111111111"),
+		Request:               "I need prayer for...",
+		RequestExpirationTime: now.Add(90 * 24 * time.Hour).Unix(),
+		RequestorHash:         object.PhoneHash("secret", "+11234567890"),
+		TenantPhone:           "+19998887777",
+	}
+	if !reflect.DeepEqual(arch, expectedArch) {
+		t.Errorf("expected ArchivedPrayer %v, got %v", expectedArch, arch)
+	}
+
+	stats := object.PrayerStats{Key: object.PrayerStatsKey(now)}
+	if err := stats.Get(memDB); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	expectedStats := object.PrayerStats{
+		Completed: 2,
+		Key:       object.PrayerStatsKeyPrefix + "2026-10",
+		Tenants:   map[string]int{"+19998887777": 2},
+	}
+	if !reflect.DeepEqual(stats, expectedStats) {
+		t.Errorf("expected PrayerStats %v, got %v", expectedStats, stats)
+	}
+}
+
+func TestArchivedPrayerRequestExpires(t *testing.T) {
+	memDB := db.NewMemoryDB(object.TableKeys())
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	pryr := object.Prayer{
+		IntercessorPhone: "+11111111111",
+		Request:          "I need prayer for...",
+		Requestor:        object.Member{Phone: "+11234567890", TenantPhone: "+19998887777"},
+	}
+
+	if err := object.ArchivePrayer(memDB, encryption.Cipher{}, "secret", pryr, time.Hour, "1a2b3c4d", now); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// ddb ttl deletes every item of the table whose ExpirationTime has passed
+	out, err := memDB.Scan(context.Background(), &dynamodb.ScanInput{
+		TableName: aws.String(object.ArchivedPrayersTable),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	expired := 0
+	for _, item := range out.Items {
+		var ttl struct{ ExpirationTime int64 }
+		if err := attributevalue.UnmarshalMap(item, &ttl); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if ttl.ExpirationTime == 0 || ttl.ExpirationTime > now.Add(2*time.Hour).Unix() {
+			continue
+		}
+
+		expired++
+		_, err := memDB.DeleteItem(context.Background(), &dynamodb.DeleteItemInput{
+			TableName: aws.String(object.ArchivedPrayersTable),
+			Key:       map[string]types.AttributeValue{object.ArchivedPrayerAttribute: item[object.ArchivedPrayerAttribute]},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+	if expired != 1 {
+		t.Errorf("expected only the archived request to expire, got %v expired items", expired)
+	}
+
+	arch := object.ArchivedPrayer{ID: "1a2b3c4d"}
+	if err := arch.Get(memDB, encryption.Cipher{}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	expectedArch := object.ArchivedPrayer{
+		CompletedTime:         now.Format(time.RFC3339),
+		ID:                    "1a2b3c4d",
+		IntercessorHash:       object.PhoneHash("secret", "+11111111111"),
+		RequestExpirationTime: now.Add(time.Hour).Unix(),
+		RequestorHash:         object.PhoneHash("secret", "+11234567890"),
+		TenantPhone:           "+19998887777",
+	}
+	if !reflect.DeepEqual(arch, expectedArch) {
+		t.Errorf("expected ArchivedPrayer %v, got %v", expectedArch, arch)
+	}
+
+	archs, err := object.GetMemberArchivedPrayers(memDB, encryption.Cipher{}, "secret", "+11234567890")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(archs, []object.ArchivedPrayer{expectedArch}) {
+		t.Errorf("expected ArchivedPrayers %v, got %v", []object.ArchivedPrayer{expectedArch}, archs)
+	}
+}
+
+func TestArchivePrayerConcurrent(t *testing.T) {
+	memDB := db.NewMemoryDB(object.TableKeys())
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	pryr := object.Prayer{Request: "I need prayer for...", Requestor: object.Member{TenantPhone: "+19998887777"}}
+
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := object.ArchivePrayer(memDB, encryption.Cipher{}, "secret", pryr, time.Hour, strconv.Itoa(i), now)
+			if err != nil {
+				t.Errorf("unexpected error %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	stats := object.PrayerStats{Key: object.PrayerStatsKey(now)}
+	if err := stats.Get(memDB); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if stats.Completed != 10 || stats.Tenants["+19998887777"] != 10 {
+		t.Errorf("expected 10 completed prayers, got %v", stats)
+	}
+}
+
+func TestPhoneHash(t *testing.T) {
+	hash := object.PhoneHash("secret", "+11234567890")
+	if len(hash) != 64 || hash != object.PhoneHash("secret", "+11234567890") {
+		t.Errorf("expected stable hmac-sha256 hex hash, got %v", hash)
+	}
+
+	if hash == object.PhoneHash("secret", "+11111111111") {
+		t.Errorf("expected different hashes for different phones")
+	}
+
+	if hash == object.PhoneHash("other", "+11234567890") {
+		t.Errorf("expected different hashes for different keys")
+	}
+
+	sum := sha256.Sum256([]byte("+11234567890"))
+	if hash == hex.EncodeToString(sum[:]) {
+		t.Errorf("expected hash to depend on the key, got plain sha256")
+	}
+
+	if hash := object.PhoneHash("", "+11234567890"); hash != "" {
+		t.Errorf("expected no hash without a key, got %v", hash)
+	}
+}
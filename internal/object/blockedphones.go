@@ -0,0 +1,99 @@
+package object
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/mshort55/prayertexter/internal/db"
+)
+
+// BlockedPhones is the admin managed list of phones whose text messages are always dropped.
+type BlockedPhones struct {
+	Key    string
+	Phones []string
+	// Version is incremented by every put, see Update
+	Version int `dynamodbav:",omitempty"`
+}
+
+const (
+	BlockedPhonesAttribute = "Key"
+	BlockedPhonesKey       = "BlockedPhones"
+	BlockedPhonesTable     = "General"
+)
+
+func (b *BlockedPhones) Get(ddbClnt db.DDBConnecter) error {
+	blocked, err := db.GetDdbObject[BlockedPhones](ddbClnt, BlockedPhonesAttribute, BlockedPhonesKey,
+		BlockedPhonesTable)
+	if err != nil {
+		return fmt.Errorf("BlockedPhones get: %w", err)
+	}
+
+	// this is important so that the original BlockedPhones object doesn't get reset to all empty
+	// struct values if the BlockedPhones does not exist in ddb
+	if blocked.Key != "" {
+		*b = *blocked
+	}
+
+	return nil
+}
+
+func (b *BlockedPhones) Put(ddbClnt db.DDBConnecter) error {
+	b.Key = BlockedPhonesKey
+	b.Version++
+	if err := db.PutDdbObject(ddbClnt, BlockedPhonesTable, b); err != nil {
+		return fmt.Errorf("BlockedPhones put: %w", err)
+	}
+
+	return nil
+}
+
+// Update loads the block list, applies change to it and puts it back. Moderators and admins can
+// change the list at the same time, so it is only put if it did not change since it was loaded.
+// Otherwise it is loaded again and change is applied again, which means that change must only
+// depend on the list it gets.
+func (b *BlockedPhones) Update(ddbClnt db.DDBConnecter, change func(*BlockedPhones)) error {
+	for range db.MaxUpdateAttempts {
+		*b = BlockedPhones{}
+		if err := b.Get(ddbClnt); err != nil {
+			return fmt.Errorf("BlockedPhones update: %w", err)
+		}
+
+		version := b.Version
+		change(b)
+		b.Key = BlockedPhonesKey
+		b.Version++
+
+		updated, err := db.PutDdbObjectIf(ddbClnt, BlockedPhonesTable, b, db.VersionCondition(version))
+		if err != nil {
+			return fmt.Errorf("BlockedPhones update: %w", err)
+		} else if updated {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("BlockedPhones update: %w", db.ErrConflict)
+}
+
+func (b *BlockedPhones) AddPhone(phone string) {
+	if !b.IsBlocked(phone) {
+		b.Phones = append(b.Phones, phone)
+	}
+}
+
+func (b *BlockedPhones) RemovePhone(phone string) {
+	b.Phones = slices.DeleteFunc(b.Phones, func(p string) bool { return p == phone })
+}
+
+func (b *BlockedPhones) IsBlocked(phone string) bool {
+	return slices.Contains(b.Phones, phone)
+}
+
+// IsPhoneBlocked returns true if phone is on the block list.
+func IsPhoneBlocked(ddbClnt db.DDBConnecter, phone string) (bool, error) {
+	blocked := BlockedPhones{}
+	if err := blocked.Get(ddbClnt); err != nil {
+		return false, fmt.Errorf("isPhoneBlocked: %w", err)
+	}
+
+	return blocked.IsBlocked(phone), nil
+}
@@ -0,0 +1,101 @@
+package object_test
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/mshort55/prayertexter/internal/db"
+	"github.com/mshort55/prayertexter/internal/mock"
+	"github.com/mshort55/prayertexter/internal/object"
+)
+
+func TestBlockedPhones(t *testing.T) {
+	blocked := object.BlockedPhones{}
+	blocked.AddPhone("+11234567890")
+	blocked.AddPhone("+11234567890")
+	blocked.AddPhone("+19998887777")
+
+	if expected := []string{"+11234567890", "+19998887777"}; !reflect.DeepEqual(blocked.Phones, expected) {
+		t.Errorf("expected Phones %v, got %v", expected, blocked.Phones)
+	}
+
+	blocked.RemovePhone("+11234567890")
+	if blocked.IsBlocked("+11234567890") || !blocked.IsBlocked("+19998887777") {
+		t.Errorf("expected only +19998887777 to be blocked, got %v", blocked.Phones)
+	}
+}
+
+func TestIsPhoneBlocked(t *testing.T) {
+	ddbMock := &mock.DDBConnecter{}
+	ddbMock.GetItemResults = []struct {
+		Output *dynamodb.GetItemOutput
+		Error  error
+	}{
+		{
+			Output: &dynamodb.GetItemOutput{
+				Item: map[string]types.AttributeValue{
+					"Key": &types.AttributeValueMemberS{Value: object.BlockedPhonesKey},
+					"Phones": &types.AttributeValueMemberL{Value: []types.AttributeValue{
+						&types.AttributeValueMemberS{Value: "+11234567890"},
+					}},
+				},
+			},
+		},
+		{
+			// no block list in ddb
+			Output: &dynamodb.GetItemOutput{},
+		},
+	}
+
+	if blocked, err := object.IsPhoneBlocked(ddbMock, "+11234567890"); err != nil || !blocked {
+		t.Errorf("expected phone to be blocked, got %v (error %v)", blocked, err)
+	}
+
+	if blocked, err := object.IsPhoneBlocked(ddbMock, "+11234567890"); err != nil || blocked {
+		t.Errorf("expected phone not to be blocked without a block list, got %v (error %v)", blocked, err)
+	}
+}
+
+func TestBlockedPhonesUpdate(t *testing.T) {
+	ddbClnt := db.NewMemoryDB(object.TableKeys())
+	blocked := object.BlockedPhones{Phones: []string{"+10000000000"}}
+	if err := blocked.Put(ddbClnt); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// moderators and admins block and unblock phones at the same time
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for n := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b := object.BlockedPhones{}
+			errs <- b.Update(ddbClnt, func(b *object.BlockedPhones) {
+				if n == 0 {
+					b.RemovePhone("+10000000000")
+				} else {
+					b.AddPhone(fmt.Sprintf("+1%010d", n))
+				}
+			})
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+
+	if err := blocked.Get(ddbClnt); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(blocked.Phones) != 9 || blocked.IsBlocked("+10000000000") || blocked.Version != 11 {
+		t.Errorf("expected every change to be kept, got %v (version %v)", blocked.Phones, blocked.Version)
+	}
+}
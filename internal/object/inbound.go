@@ -0,0 +1,118 @@
+package object
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/mshort55/prayertexter/internal/db"
+	"github.com/mshort55/prayertexter/internal/messaging"
+)
+
+// InboundMessage records that a received text message has been processed. It is used to drop
+// retried deliveries of the same text message. ddb removes expired InboundMessages with ttl on
+// ExpirationTime.
+type InboundMessage struct {
+	ExpirationTime int64
+	Key            string
+	Phone          string
+	ReceivedTime   string
+}
+
+const (
+	InboundMessageAttribute = "Key"
+	InboundMessageKeyPrefix = "Inbound#"
+	InboundMessageTable     = "General"
+	// InboundMessageTTL is how long a received text message is remembered. Retries from api
+	// gateway or the sms provider happen well within this time
+	InboundMessageTTL = 24 * time.Hour
+	// InboundHashTTL is used instead of InboundMessageTTL for text messages without a message ID
+	// or timestamp. These are identified by phone and body only, so they are remembered for a
+	// short time to not drop members that text the same thing twice, for example "prayed"
+	InboundHashTTL = 5 * time.Minute
+)
+
+// InboundMessageKey returns the key that identifies msg and how long it is remembered. The sms
+// provider's message ID is used when it exists, otherwise a hash of phone, body and timestamp.
+func InboundMessageKey(msg messaging.TextMessage) (string, time.Duration) {
+	if msg.ID != "" {
+		return InboundMessageKeyPrefix + msg.ID, InboundMessageTTL
+	}
+
+	hash := sha256.Sum256([]byte(msg.Phone + "\x00" + msg.Body + "\x00" + msg.Timestamp))
+	key := InboundMessageKeyPrefix + "sha256:" + hex.EncodeToString(hash[:])
+	if msg.Timestamp == "" {
+		return key, InboundHashTTL
+	}
+
+	return key, InboundMessageTTL
+}
+
+// ClaimInboundMessage records msg as received. It returns false if msg was already received and
+// has not expired yet, which means that msg is a retry and must not be processed again.
+func ClaimInboundMessage(ddbClnt db.DDBConnecter, msg messaging.TextMessage, now time.Time) (bool, error) {
+	key, ttl := InboundMessageKey(msg)
+	inbound := InboundMessage{
+		ExpirationTime: now.Add(ttl).Unix(),
+		Key:            key,
+		Phone:          msg.Phone,
+		ReceivedTime:   now.Format(time.RFC3339),
+	}
+
+	// ddb ttl deletes expired items eventually but not right away, so expired items that still
+	// exist are treated as if they do not exist
+	cond := db.Condition{
+		Expression: "attribute_not_exists(#key) OR #expiration < :now",
+		Names:      map[string]string{"#key": InboundMessageAttribute, "#expiration": ExpirationAttribute},
+		Values: map[string]types.AttributeValue{
+			":now": &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Unix(), 10)},
+		},
+	}
+
+	claimed, err := db.PutDdbObjectIf(ddbClnt, InboundMessageTable, &inbound, cond)
+	if err != nil {
+		return false, fmt.Errorf("claimInboundMessage: %w", err)
+	}
+
+	return claimed, nil
+}
+
+// ReleaseInboundMessage forgets that msg was received. This is used when processing msg failed so
+// that a retry of msg gets processed.
+func ReleaseInboundMessage(ddbClnt db.DDBConnecter, msg messaging.TextMessage) error {
+	key, _ := InboundMessageKey(msg)
+	if err := db.DelDdbItem(ddbClnt, InboundMessageAttribute, key, InboundMessageTable); err != nil {
+		return fmt.Errorf("releaseInboundMessage: %w", err)
+	}
+
+	return nil
+}
+
+func (i *InboundMessage) Delete(ddbClnt db.DDBConnecter) error {
+	if err := db.DelDdbItem(ddbClnt, InboundMessageAttribute, i.Key, InboundMessageTable); err != nil {
+		return fmt.Errorf("InboundMessage delete: %w", err)
+	}
+
+	return nil
+}
+
+// GetMemberInboundMessages returns the InboundMessages of text messages received from phone. This
+// scans the whole General table.
+func GetMemberInboundMessages(ddbClnt db.DDBConnecter, phone string) ([]InboundMessage, error) {
+	inbound, err := db.ScanDdbObjects[InboundMessage](ddbClnt, InboundMessageTable, db.Condition{
+		Expression: "begins_with(#key, :prefix) AND #phone = :phone",
+		Names:      map[string]string{"#key": InboundMessageAttribute, "#phone": "Phone"},
+		Values: map[string]types.AttributeValue{
+			":phone":  &types.AttributeValueMemberS{Value: phone},
+			":prefix": &types.AttributeValueMemberS{Value: InboundMessageKeyPrefix},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("GetMemberInboundMessages: %w", err)
+	}
+
+	return inbound, nil
+}
@@ -0,0 +1,111 @@
+package object_test
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/mshort55/prayertexter/internal/db"
+	"github.com/mshort55/prayertexter/internal/messaging"
+	"github.com/mshort55/prayertexter/internal/mock"
+	"github.com/mshort55/prayertexter/internal/object"
+)
+
+func TestInboundMessageKey(t *testing.T) {
+	msg := messaging.TextMessage{Body: "prayed", ID: "abc-123", Phone: "+11234567890"}
+	if key, ttl := object.InboundMessageKey(msg); key != object.InboundMessageKeyPrefix+"abc-123" ||
+		ttl != object.InboundMessageTTL {
+		t.Errorf("expected provider message ID key, got %v (ttl %v)", key, ttl)
+	}
+
+	msg.ID = ""
+	key, ttl := object.InboundMessageKey(msg)
+	if !strings.HasPrefix(key, object.InboundMessageKeyPrefix+"sha256:") || ttl != object.InboundHashTTL {
+		t.Errorf("expected hash key with short ttl, got %v (ttl %v)", key, ttl)
+	}
+
+	msg.Timestamp = "2026-10-18T12:00:00Z"
+	timestampKey, ttl := object.InboundMessageKey(msg)
+	if timestampKey == key || ttl != object.InboundMessageTTL {
+		t.Errorf("expected timestamp to change the hash key and ttl, got %v (ttl %v)", timestampKey, ttl)
+	}
+
+	// the same text from a different phone is a different message
+	msg.Phone = "+19998887777"
+	if otherKey, _ := object.InboundMessageKey(msg); otherKey == timestampKey {
+		t.Errorf("expected different key for different phone, got %v", otherKey)
+	}
+}
+
+func TestClaimInboundMessage(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	msg := messaging.TextMessage{Body: "prayed", ID: "abc-123", Phone: "+11234567890"}
+
+	ddbMock := &mock.DDBConnecter{}
+	ddbMock.PutItemResults = []struct {
+		Error error
+	}{
+		{Error: nil},
+		{Error: &types.ConditionalCheckFailedException{}},
+	}
+
+	claimed, err := object.ClaimInboundMessage(ddbMock, msg, now)
+	if err != nil || !claimed {
+		t.Errorf("expected first delivery to be claimed, got %v (error %v)", claimed, err)
+	}
+
+	expiration := ddbMock.PutItemInputs[0].Item["ExpirationTime"].(*types.AttributeValueMemberN).Value
+	if expected := strconv.FormatInt(now.Add(object.InboundMessageTTL).Unix(), 10); expiration != expected {
+		t.Errorf("expected expiration time %v, got %v", expected, expiration)
+	}
+
+	claimed, err = object.ClaimInboundMessage(ddbMock, msg, now)
+	if err != nil || claimed {
+		t.Errorf("expected retried delivery not to be claimed, got %v (error %v)", claimed, err)
+	}
+
+	if err := object.ReleaseInboundMessage(ddbMock, msg); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	key := ddbMock.DeleteItemInputs[0].Key[object.InboundMessageAttribute].(*types.AttributeValueMemberS).Value
+	if key != object.InboundMessageKeyPrefix+"abc-123" {
+		t.Errorf("expected deleted key %v, got %v", object.InboundMessageKeyPrefix+"abc-123", key)
+	}
+}
+
+func TestGetMemberInboundMessages(t *testing.T) {
+	ddbClnt := db.NewMemoryDB(object.TableKeys())
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	for _, msg := range []messaging.TextMessage{
+		{Body: "pray", ID: "1", Phone: "+11234567890"},
+		{Body: "prayed", Phone: "+11234567890"},
+		{Body: "pray", ID: "2", Phone: "+11111111111"},
+	} {
+		if _, err := object.ClaimInboundMessage(ddbClnt, msg, now); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+
+	inbound, err := object.GetMemberInboundMessages(ddbClnt, "+11234567890")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(inbound) != 2 {
+		t.Fatalf("expected 2 InboundMessages of phone, got %v", inbound)
+	}
+
+	for _, in := range inbound {
+		if err := in.Delete(ddbClnt); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+	if inbound, err := object.GetMemberInboundMessages(ddbClnt, "+11234567890"); err != nil || len(inbound) != 0 {
+		t.Errorf("expected no InboundMessages of phone, got %v (%v)", inbound, err)
+	}
+	if inbound, err := object.GetMemberInboundMessages(ddbClnt, "+11111111111"); err != nil || len(inbound) != 1 {
+		t.Errorf("expected InboundMessages of other phones to be kept, got %v (%v)", inbound, err)
+	}
+}
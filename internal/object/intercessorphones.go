@@ -3,15 +3,19 @@ package object
 import (
 	"fmt"
 	"log/slog"
+	"maps"
 	"math/rand/v2"
 	"slices"
 
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/mshort55/prayertexter/internal/db"
 )
 
 type IntercessorPhones struct {
 	Key    string
 	Phones []string
+	// Version is incremented by every put, see Update
+	Version int `dynamodbav:",omitempty"`
 }
 
 const (
@@ -21,9 +25,15 @@ const (
 	NumIntercessorsPerPrayer   = 2
 )
 
+// Get loads the IntercessorPhones list for i.Key. An empty Key loads the default Tenant's list. Use
+// TenantIntercessorPhones to get the list of a specific Tenant.
 func (i *IntercessorPhones) Get(ddbClnt db.DDBConnecter) error {
+	if i.Key == "" {
+		i.Key = IntercessorPhonesKey
+	}
+
 	intr, err := db.GetDdbObject[IntercessorPhones](ddbClnt, IntercessorPhonesAttribute,
-		IntercessorPhonesKey, IntercessorPhonesTable)
+		i.Key, IntercessorPhonesTable)
 	if err != nil {
 		return fmt.Errorf("IntercessorPhones get: %w", err)
 	}
@@ -38,7 +48,11 @@ func (i *IntercessorPhones) Get(ddbClnt db.DDBConnecter) error {
 }
 
 func (i *IntercessorPhones) Put(ddbClnt db.DDBConnecter) error {
-	i.Key = IntercessorPhonesKey
+	if i.Key == "" {
+		i.Key = IntercessorPhonesKey
+	}
+
+	i.Version++
 	if err := db.PutDdbObject(ddbClnt, IntercessorPhonesTable, i); err != nil {
 		return fmt.Errorf("IntercessorPhones put: %w", err)
 	}
@@ -46,6 +60,37 @@ func (i *IntercessorPhones) Put(ddbClnt db.DDBConnecter) error {
 	return nil
 }
 
+// Update loads the list for i.Key, applies change to it and puts it back. Lists are shared by
+// concurrent flows, so the list is only put if it did not change since it was loaded. Otherwise it
+// is loaded again and change is applied again, which means that change must only depend on the
+// list it gets.
+func (i *IntercessorPhones) Update(ddbClnt db.DDBConnecter, change func(*IntercessorPhones)) error {
+	key := i.Key
+	if key == "" {
+		key = IntercessorPhonesKey
+	}
+
+	for range db.MaxUpdateAttempts {
+		*i = IntercessorPhones{Key: key}
+		if err := i.Get(ddbClnt); err != nil {
+			return fmt.Errorf("IntercessorPhones update: %w", err)
+		}
+
+		version := i.Version
+		change(i)
+		i.Version++
+
+		updated, err := db.PutDdbObjectIf(ddbClnt, IntercessorPhonesTable, i, db.VersionCondition(version))
+		if err != nil {
+			return fmt.Errorf("IntercessorPhones update: %w", err)
+		} else if updated {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("IntercessorPhones update: %w", db.ErrConflict)
+}
+
 func (i *IntercessorPhones) AddPhone(phone string) {
 	i.Phones = append(i.Phones, phone)
 }
@@ -62,7 +107,7 @@ func (i *IntercessorPhones) RemovePhone(phone string) {
 	i.Phones = newPhones
 }
 
-func (i *IntercessorPhones) GenRandPhones() []string {
+func (i *IntercessorPhones) GenRandPhones(num int) []string {
 	var selectedPhones []string
 
 	if len(i.Phones) == 0 {
@@ -72,12 +117,12 @@ func (i *IntercessorPhones) GenRandPhones() []string {
 
 	// this is needed so it can return some/one phones even if it is less than the set # of
 	// intercessors for each prayer
-	if len(i.Phones) <= NumIntercessorsPerPrayer {
+	if len(i.Phones) <= num {
 		selectedPhones = append(selectedPhones, i.Phones...)
 		return selectedPhones
 	}
 
-	for len(selectedPhones) < NumIntercessorsPerPrayer {
+	for len(selectedPhones) < num {
 		phone := i.Phones[rand.IntN(len(i.Phones))]
 		if slices.Contains(selectedPhones, phone) {
 			continue
@@ -87,3 +132,95 @@ func (i *IntercessorPhones) GenRandPhones() []string {
 
 	return selectedPhones
 }
+
+// TenantIntercessorPhones returns an empty IntercessorPhones keyed for the Tenant that owns
+// tenantPhone. The default Tenant (empty tenantPhone) uses IntercessorPhonesKey.
+func TenantIntercessorPhones(tenantPhone string) IntercessorPhones {
+	if tenantPhone == "" {
+		return IntercessorPhones{Key: IntercessorPhonesKey}
+	}
+
+	return IntercessorPhones{Key: IntercessorPhonesKey + "#" + tenantPhone}
+}
+
+// RebuildIntercessorPhones rebuilds the IntercessorPhones lists of all Tenants from the Member
+// records, for example after the lists got out of sync. Lists of Tenants without intercessors are
+// emptied. This scans the whole Members table.
+func RebuildIntercessorPhones(ddbClnt db.DDBConnecter) ([]IntercessorPhones, error) {
+	existing, err := scanIntercessorPhones(ddbClnt)
+	if err != nil {
+		return nil, fmt.Errorf("RebuildIntercessorPhones: %w", err)
+	}
+
+	mems, err := db.ScanDdbObjects[Member](ddbClnt, MemberTable, db.Condition{
+		// members that are still signing up are added to the list when they finish
+		Expression: "#intercessor = :intercessor AND #status = :status",
+		Names:      map[string]string{"#intercessor": "Intercessor", "#status": "SetupStatus"},
+		Values: map[string]types.AttributeValue{
+			":intercessor": &types.AttributeValueMemberBOOL{Value: true},
+			":status":      &types.AttributeValueMemberS{Value: "completed"},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("RebuildIntercessorPhones: %w", err)
+	}
+
+	lists := map[string]*IntercessorPhones{}
+	for _, phones := range existing {
+		lists[phones.Key] = &IntercessorPhones{Key: phones.Key}
+	}
+
+	for _, mem := range mems {
+		phones := TenantIntercessorPhones(mem.TenantPhone)
+		if _, ok := lists[phones.Key]; !ok {
+			lists[phones.Key] = &phones
+		}
+		lists[phones.Key].AddPhone(mem.Phone)
+	}
+
+	rebuilt := make([]IntercessorPhones, 0, len(lists))
+	for _, key := range slices.Sorted(maps.Keys(lists)) {
+		phones := IntercessorPhones{Key: key}
+		err := phones.Update(ddbClnt, func(p *IntercessorPhones) {
+			p.Phones = slices.Sorted(slices.Values(lists[key].Phones))
+		})
+		if err != nil {
+			return nil, fmt.Errorf("RebuildIntercessorPhones: %w", err)
+		}
+		rebuilt = append(rebuilt, phones)
+	}
+
+	return rebuilt, nil
+}
+
+// RemoveIntercessorPhone removes phone from the IntercessorPhones lists of all Tenants, for when it
+// is not known which Tenant phone belongs to. Only lists that hold phone are updated. This scans
+// the General table.
+func RemoveIntercessorPhone(ddbClnt db.DDBConnecter, phone string) error {
+	lists, err := scanIntercessorPhones(ddbClnt)
+	if err != nil {
+		return fmt.Errorf("RemoveIntercessorPhone: %w", err)
+	}
+
+	for _, list := range lists {
+		if !slices.Contains(list.Phones, phone) {
+			continue
+		}
+
+		phones := IntercessorPhones{Key: list.Key}
+		if err := phones.Update(ddbClnt, func(p *IntercessorPhones) { p.RemovePhone(phone) }); err != nil {
+			return fmt.Errorf("RemoveIntercessorPhone: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// scanIntercessorPhones returns the IntercessorPhones lists of all Tenants.
+func scanIntercessorPhones(ddbClnt db.DDBConnecter) ([]IntercessorPhones, error) {
+	return db.ScanDdbObjects[IntercessorPhones](ddbClnt, IntercessorPhonesTable, db.Condition{
+		Expression: "begins_with(#key, :key)",
+		Names:      map[string]string{"#key": IntercessorPhonesAttribute},
+		Values:     map[string]types.AttributeValue{":key": &types.AttributeValueMemberS{Value: IntercessorPhonesKey}},
+	})
+}
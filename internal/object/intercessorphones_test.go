@@ -1,9 +1,16 @@
 package object_test
 
 import (
+	"errors"
+	"reflect"
 	"slices"
 	"testing"
 
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/mshort55/prayertexter/internal/db"
+	"github.com/mshort55/prayertexter/internal/mock"
 	"github.com/mshort55/prayertexter/internal/object"
 )
 
@@ -35,7 +42,7 @@ func TestRemovePhone(t *testing.T) {
 }
 
 func TestGenRandPhones(t *testing.T) {
-	phones := i.GenRandPhones()
+	phones := i.GenRandPhones(object.NumIntercessorsPerPrayer)
 	if len(phones) != object.NumIntercessorsPerPrayer {
 		t.Errorf("expected number of phones to be %v, got %v", object.NumIntercessorsPerPrayer, len(phones))
 	}
@@ -49,7 +56,7 @@ func TestGenRandPhones(t *testing.T) {
 	for len(i.Phones) > object.NumIntercessorsPerPrayer-1 {
 		i.Phones = i.Phones[:len(i.Phones)-1]
 	}
-	phones = i.GenRandPhones()
+	phones = i.GenRandPhones(object.NumIntercessorsPerPrayer)
 	if len(phones) != object.NumIntercessorsPerPrayer-1 {
 		t.Errorf("expected phone list to be len %v, got len: %v phones: %v", object.NumIntercessorsPerPrayer-1, len(phones), phones)
 	}
@@ -59,7 +66,7 @@ func TestGenRandPhones(t *testing.T) {
 	}
 
 	i.Phones = []string{}
-	if phones = i.GenRandPhones(); phones != nil {
+	if phones = i.GenRandPhones(object.NumIntercessorsPerPrayer); phones != nil {
 		t.Errorf("expected nil return when phone slice is empty, got %v", phones)
 	}
 }
@@ -74,3 +81,143 @@ func checkDuplicates(slice []string) bool {
 	}
 	return false
 }
+
+func TestRebuildIntercessorPhones(t *testing.T) {
+	ddbMock := &mock.DDBConnecter{}
+	ddbMock.ScanResults = []struct {
+		Output *dynamodb.ScanOutput
+		Error  error
+	}{
+		{
+			Output: &dynamodb.ScanOutput{
+				Items: []map[string]types.AttributeValue{
+					{
+						"Key": &types.AttributeValueMemberS{Value: object.IntercessorPhonesKey},
+						"Phones": &types.AttributeValueMemberL{Value: []types.AttributeValue{
+							&types.AttributeValueMemberS{Value: "+19999999999"},
+						}},
+					},
+					{
+						"Key": &types.AttributeValueMemberS{Value: object.IntercessorPhonesKey + "#+18888888888"},
+						"Phones": &types.AttributeValueMemberL{Value: []types.AttributeValue{
+							&types.AttributeValueMemberS{Value: "+17777777777"},
+						}},
+					},
+				},
+			},
+			Error: nil,
+		},
+		{
+			Output: &dynamodb.ScanOutput{
+				Items: []map[string]types.AttributeValue{
+					{"Phone": &types.AttributeValueMemberS{Value: "+12222222222"}},
+					{"Phone": &types.AttributeValueMemberS{Value: "+11111111111"}},
+					{
+						"Phone":       &types.AttributeValueMemberS{Value: "+13333333333"},
+						"TenantPhone": &types.AttributeValueMemberS{Value: "+16666666666"},
+					},
+				},
+			},
+			Error: nil,
+		},
+	}
+
+	rebuilt, err := object.RebuildIntercessorPhones(ddbMock)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	expected := []object.IntercessorPhones{
+		{Key: object.IntercessorPhonesKey, Phones: []string{"+11111111111", "+12222222222"}, Version: 1},
+		{Key: object.IntercessorPhonesKey + "#+16666666666", Phones: []string{"+13333333333"}, Version: 1},
+		{Key: object.IntercessorPhonesKey + "#+18888888888", Version: 1},
+	}
+	if !reflect.DeepEqual(rebuilt, expected) {
+		t.Errorf("expected %v, got %v", expected, rebuilt)
+	}
+
+	if ddbMock.PutItemCalls != len(expected) {
+		t.Fatalf("expected %v PutItem calls, got %v", len(expected), ddbMock.PutItemCalls)
+	}
+
+	var phones object.IntercessorPhones
+	if err := attributevalue.UnmarshalMap(ddbMock.PutItemInputs[2].Item, &phones); err != nil {
+		t.Fatalf("failed to unmarshal PutItemInput into IntercessorPhones: %v", err)
+	}
+	if len(phones.Phones) != 0 {
+		t.Errorf("expected emptied list for tenant without intercessors, got %v", phones.Phones)
+	}
+}
+
+func TestIntercessorPhonesUpdate(t *testing.T) {
+	conflict := struct{ Error error }{Error: &types.ConditionalCheckFailedException{}}
+	stored := func(phones ...string) struct {
+		Output *dynamodb.GetItemOutput
+		Error  error
+	} {
+		item, err := attributevalue.MarshalMap(object.IntercessorPhones{
+			Key: object.IntercessorPhonesKey, Phones: phones, Version: len(phones)})
+		if err != nil {
+			t.Fatalf("failed to marshal IntercessorPhones: %v", err)
+		}
+		return struct {
+			Output *dynamodb.GetItemOutput
+			Error  error
+		}{Output: &dynamodb.GetItemOutput{Item: item}, Error: nil}
+	}
+
+	// another flow adds a phone between the first get and put, so the change is applied again to
+	// the list with that phone
+	ddbMock := &mock.DDBConnecter{}
+	ddbMock.GetItemResults = append(ddbMock.GetItemResults, stored("+11111111111"),
+		stored("+11111111111", "+12222222222"))
+	ddbMock.PutItemResults = append(ddbMock.PutItemResults, conflict)
+
+	phones := object.IntercessorPhones{}
+	if err := phones.Update(ddbMock, func(p *object.IntercessorPhones) { p.AddPhone("+13333333333") }); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	expected := object.IntercessorPhones{Key: object.IntercessorPhonesKey,
+		Phones: []string{"+11111111111", "+12222222222", "+13333333333"}, Version: 3}
+	if !reflect.DeepEqual(phones, expected) || ddbMock.PutItemCalls != 2 {
+		t.Errorf("expected %v after 2 PutItem calls, got %v after %v", expected, phones, ddbMock.PutItemCalls)
+	}
+
+	ddbMock = &mock.DDBConnecter{}
+	for range db.MaxUpdateAttempts {
+		ddbMock.PutItemResults = append(ddbMock.PutItemResults, conflict)
+	}
+	if err := phones.Update(ddbMock, func(*object.IntercessorPhones) {}); !errors.Is(err, db.ErrConflict) {
+		t.Errorf("expected ErrConflict, got %v", err)
+	}
+}
+
+func TestRemoveIntercessorPhone(t *testing.T) {
+	ddbClnt := db.NewMemoryDB(object.TableKeys())
+	lists := []object.IntercessorPhones{
+		{Key: object.IntercessorPhonesKey, Phones: []string{"+11111111111", "+12222222222"}},
+		{Key: object.IntercessorPhonesKey + "#+18888888888", Phones: []string{"+11111111111"}},
+		{Key: object.IntercessorPhonesKey + "#+16666666666", Phones: []string{"+13333333333"}},
+	}
+	for _, list := range lists {
+		if err := list.Put(ddbClnt); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+
+	if err := object.RemoveIntercessorPhone(ddbClnt, "+11111111111"); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	expected := [][]string{{"+12222222222"}, nil, {"+13333333333"}}
+	for n, list := range lists {
+		phones := object.IntercessorPhones{Key: list.Key}
+		if err := phones.Get(ddbClnt); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if !slices.Equal(phones.Phones, expected[n]) {
+			t.Errorf("expected %v in %v, got %v", expected[n], list.Key, phones.Phones)
+		}
+	}
+}
@@ -9,12 +9,16 @@ import (
 )
 
 type Member struct {
-	Intercessor       bool
-	Name              string
-	Phone             string
-	PrayerCount       int
-	SetupStage        int
-	SetupStatus       string
+	Intercessor bool
+	Language    string `dynamodbav:",omitempty"`
+	Name        string
+	Phone       string
+	PrayerCount int
+	SetupStage  int
+	SetupStatus string
+	TenantPhone string `dynamodbav:",omitempty"`
+	// Version is incremented by every put, see Update
+	Version           int `dynamodbav:",omitempty"`
 	WeeklyPrayerDate  string
 	WeeklyPrayerLimit int
 }
@@ -40,6 +44,7 @@ func (m *Member) Get(ddbClnt db.DDBConnecter) error {
 }
 
 func (m *Member) Put(ddbClnt db.DDBConnecter) error {
+	m.Version++
 	if err := db.PutDdbObject(ddbClnt, MemberTable, m); err != nil {
 		return fmt.Errorf("Member put: %w", err)
 	}
@@ -47,6 +52,40 @@ func (m *Member) Put(ddbClnt db.DDBConnecter) error {
 	return nil
 }
 
+// Update applies change to m and puts it back. Flows of other phones change Members too, for
+// example when they select an intercessor, so m is only put if the stored Member did not change
+// since m was loaded. Otherwise the Member is loaded again and change is applied again, which
+// means that change must only depend on the Member it gets. m must be loaded with Get first, which
+// flows already do, so that updates without conflicts do not load the Member twice. change
+// returns false to leave the Member as it is, for example when it no longer exists, and m is the
+// stored Member then. Update returns whether the Member was put.
+func (m *Member) Update(ddbClnt db.DDBConnecter, change func(*Member) bool) (bool, error) {
+	phone := m.Phone
+	for attempt := range db.MaxUpdateAttempts {
+		if attempt > 0 {
+			*m = Member{Phone: phone}
+			if err := m.Get(ddbClnt); err != nil {
+				return false, fmt.Errorf("Member update: %w", err)
+			}
+		}
+
+		version := m.Version
+		if !change(m) {
+			return false, nil
+		}
+		m.Phone, m.Version = phone, version+1
+
+		updated, err := db.PutDdbObjectIf(ddbClnt, MemberTable, m, db.VersionCondition(version))
+		if err != nil {
+			return false, fmt.Errorf("Member update: %w", err)
+		} else if updated {
+			return true, nil
+		}
+	}
+
+	return false, fmt.Errorf("Member update: %w", db.ErrConflict)
+}
+
 func (m *Member) Delete(ddbClnt db.DDBConnecter) error {
 	if err := db.DelDdbItem(ddbClnt, MemberAttribute, m.Phone, MemberTable); err != nil {
 		return fmt.Errorf("Member delete: %w", err)
@@ -55,13 +94,23 @@ func (m *Member) Delete(ddbClnt db.DDBConnecter) error {
 	return nil
 }
 
-func (m *Member) SendMessage(smsClnt messaging.TextSender, body string) error {
+// SendMessage renders the message template name with data in the Member's language and sends it
+// to the Member.
+func (m *Member) SendMessage(smsClnt messaging.TextSender, tmpls *messaging.Templates, name string,
+	data map[string]string) error {
+	tmpls = tmpls.Locale(m.Language)
+	body, err := tmpls.Render(name, data)
+	if err != nil {
+		return fmt.Errorf("Member sendText: %w", err)
+	}
+
 	message := messaging.TextMessage{
-		Body:  body,
-		Phone: m.Phone,
+		Body:        body,
+		Phone:       m.Phone,
+		TenantPhone: m.TenantPhone,
 	}
 
-	if err := messaging.SendText(smsClnt, message); err != nil {
+	if err := messaging.SendText(smsClnt, tmpls, message); err != nil {
 		slog.Error("sendMessage failed", "recipient", m.Phone, "msg", body, "error", err)
 		return fmt.Errorf("Member sendText: %w", err)
 	}
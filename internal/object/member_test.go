@@ -3,19 +3,20 @@ package object_test
 import (
 	"errors"
 	"reflect"
+	"sync"
 	"testing"
 
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/mshort55/prayertexter/internal/db"
 	"github.com/mshort55/prayertexter/internal/messaging"
 	"github.com/mshort55/prayertexter/internal/mock"
 	"github.com/mshort55/prayertexter/internal/object"
 )
 
 func TestSendMessage(t *testing.T) {
-	txtBody := "test message"
 	expectedText := messaging.TextMessage{
-		Body:  messaging.MsgPre + txtBody + "\n\n" + messaging.MsgPost,
+		Body:  "PrayerTexter: Hello! Please pray for John Doe:\nI need prayer for...\n\nReply HELP for help or STOP to cancel.",
 		Phone: "+11234567890",
 	}
 
@@ -27,8 +28,14 @@ func TestSendMessage(t *testing.T) {
 		SetupStatus: "completed",
 	}
 
+	tmpls, err := messaging.LoadTemplates(nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
 	txtMock := &mock.TextSender{}
-	if err := member.SendMessage(txtMock, txtBody); err != nil {
+	data := map[string]string{"Name": "John Doe", "Request": "I need prayer for..."}
+	if err := member.SendMessage(txtMock, tmpls, messaging.MsgPrayerIntro, data); err != nil {
 		t.Errorf("unexpected error %v", err)
 	}
 
@@ -92,3 +99,69 @@ func TestCheckIfActiveMember(t *testing.T) {
 		t.Errorf("expected error, got %v", err)
 	}
 }
+
+func TestMemberUpdate(t *testing.T) {
+	ddbClnt := db.NewMemoryDB(object.TableKeys())
+	mem := object.Member{Intercessor: true, Phone: "+11234567890", SetupStatus: "completed", WeeklyPrayerLimit: 20}
+	if err := mem.Put(ddbClnt); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// prayer requests select the same intercessor at the same time
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			intr := object.Member{Phone: mem.Phone}
+			if err := intr.Get(ddbClnt); err != nil {
+				errs <- err
+				return
+			}
+			_, err := intr.Update(ddbClnt, func(m *object.Member) bool {
+				m.PrayerCount++
+				return true
+			})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+
+	stored := object.Member{Phone: mem.Phone}
+	if err := stored.Get(ddbClnt); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if stored.PrayerCount != 10 || stored.Version != 11 {
+		t.Errorf("expected every prayer to be counted, got %v (version %v)", stored.PrayerCount, stored.Version)
+	}
+
+	// a member that quits after being loaded is not added back by a change that checks for it
+	if err := mem.Delete(ddbClnt); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	updated, err := stored.Update(ddbClnt, func(m *object.Member) bool {
+		if m.SetupStatus == "" {
+			return false
+		}
+		m.PrayerCount++
+		return true
+	})
+	if err != nil || updated {
+		t.Fatalf("expected no update of a deleted member, got %v (%v)", updated, err)
+	}
+
+	stored = object.Member{Phone: mem.Phone}
+	if err := stored.Get(ddbClnt); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if stored.SetupStatus != "" {
+		t.Errorf("expected deleted member to stay deleted, got %v", stored)
+	}
+}
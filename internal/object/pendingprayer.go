@@ -0,0 +1,192 @@
+package object
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/mshort55/prayertexter/internal/db"
+	"github.com/mshort55/prayertexter/internal/encryption"
+)
+
+// PendingPrayer is a prayer request that is held for moderation. Moderated PendingPrayers are
+// kept with their Status and moderator as a record of who approved or rejected what.
+type PendingPrayer struct {
+	ID            string
+	ModeratedBy   string `dynamodbav:",omitempty"`
+	ModeratedTime string `dynamodbav:",omitempty"`
+	Request       string
+	Requestor     Member
+	Status        string
+	TimeReceived  string
+}
+
+const (
+	PendingPrayerAttribute = "ID"
+	PendingPrayersTable    = "PendingPrayers"
+	// pendingPrayerIDLength is kept short because moderators type the ID in their reply
+	pendingPrayerIDLength = 8
+)
+
+// PendingPrayer statuses
+const (
+	PrayerApproved = "APPROVED"
+	PrayerPending  = "PENDING"
+	PrayerRejected = "REJECTED"
+)
+
+// Get gets the PendingPrayer and decrypts the fields that were encrypted by Put.
+func (p *PendingPrayer) Get(ddbClnt db.DDBConnecter, cphr encryption.Cipher) error {
+	pryr, err := db.GetDdbObject[PendingPrayer](ddbClnt, PendingPrayerAttribute, p.ID, PendingPrayersTable)
+	if err != nil {
+		return fmt.Errorf("PendingPrayer get: %w", err)
+	}
+
+	if err := cphr.Decrypt(&pryr.Request, &pryr.Requestor.Name); err != nil {
+		return fmt.Errorf("PendingPrayer get: %w", err)
+	}
+
+	// this is important so that the original PendingPrayer object doesn't get reset to all empty
+	// struct values if the PendingPrayer does not exist in ddb
+	if pryr.ID != "" {
+		*p = *pryr
+	}
+
+	return nil
+}
+
+// Put puts the PendingPrayer with the Request (and the requestor name if cphr encrypts names)
+// encrypted by cphr. The PendingPrayer itself is not changed.
+func (p *PendingPrayer) Put(ddbClnt db.DDBConnecter, cphr encryption.Cipher) error {
+	encrypted, err := p.encrypt(cphr)
+	if err != nil {
+		return fmt.Errorf("PendingPrayer put: %w", err)
+	}
+
+	if err := db.PutDdbObject(ddbClnt, PendingPrayersTable, &encrypted); err != nil {
+		return fmt.Errorf("PendingPrayer put: %w", err)
+	}
+
+	return nil
+}
+
+// encrypt returns a copy of the PendingPrayer with the fields that Put encrypts encrypted.
+func (p *PendingPrayer) encrypt(cphr encryption.Cipher) (PendingPrayer, error) {
+	encrypted := *p
+	fields := []*string{&encrypted.Request}
+	if cphr.EncryptNames {
+		fields = append(fields, &encrypted.Requestor.Name)
+	}
+
+	err := cphr.Encrypt(fields...)
+	return encrypted, err
+}
+
+// Decide puts the moderated PendingPrayer like Put, but only if it is still pending in ddb, so
+// that only one of several moderators who decide it at the same time gets to. It returns false if
+// another moderator decided it first.
+func (p *PendingPrayer) Decide(ddbClnt db.DDBConnecter, cphr encryption.Cipher) (bool, error) {
+	cond := db.Condition{
+		Expression: "#status = :pending",
+		Names:      map[string]string{"#status": "Status"},
+		Values:     map[string]types.AttributeValue{":pending": &types.AttributeValueMemberS{Value: PrayerPending}},
+	}
+
+	encrypted, err := p.encrypt(cphr)
+	if err != nil {
+		return false, fmt.Errorf("PendingPrayer decide: %w", err)
+	}
+
+	decided, err := db.PutDdbObjectIf(ddbClnt, PendingPrayersTable, &encrypted, cond)
+	if err != nil {
+		return false, fmt.Errorf("PendingPrayer decide: %w", err)
+	}
+
+	return decided, nil
+}
+
+func (p *PendingPrayer) Delete(ddbClnt db.DDBConnecter) error {
+	if err := db.DelDdbItem(ddbClnt, PendingPrayerAttribute, p.ID, PendingPrayersTable); err != nil {
+		return fmt.Errorf("PendingPrayer delete: %w", err)
+	}
+
+	return nil
+}
+
+// GetMemberPendingPrayers returns the PendingPrayers requested by phone, decrypted by cphr. This
+// scans the whole PendingPrayers table.
+func GetMemberPendingPrayers(ddbClnt db.DDBConnecter, cphr encryption.Cipher, phone string) ([]PendingPrayer,
+	error) {
+	filter := db.Condition{
+		Expression: "#requestor.#phone = :phone",
+		Names:      map[string]string{"#phone": MemberAttribute, "#requestor": "Requestor"},
+		Values:     map[string]types.AttributeValue{":phone": &types.AttributeValueMemberS{Value: phone}},
+	}
+
+	pryrs, err := scanPendingPrayers(ddbClnt, cphr, filter)
+	if err != nil {
+		return nil, fmt.Errorf("GetMemberPendingPrayers: %w", err)
+	}
+
+	return pryrs, nil
+}
+
+// GetPendingPrayers returns the PendingPrayers that are still waiting for a moderator, decrypted by
+// cphr. This scans the whole PendingPrayers table.
+func GetPendingPrayers(ddbClnt db.DDBConnecter, cphr encryption.Cipher) ([]PendingPrayer, error) {
+	filter := db.Condition{
+		Expression: "#status = :pending",
+		Names:      map[string]string{"#status": "Status"},
+		Values:     map[string]types.AttributeValue{":pending": &types.AttributeValueMemberS{Value: PrayerPending}},
+	}
+
+	pryrs, err := scanPendingPrayers(ddbClnt, cphr, filter)
+	if err != nil {
+		return nil, fmt.Errorf("GetPendingPrayers: %w", err)
+	}
+
+	return pryrs, nil
+}
+
+func scanPendingPrayers(ddbClnt db.DDBConnecter, cphr encryption.Cipher, filter db.Condition) ([]PendingPrayer,
+	error) {
+	pryrs, err := db.ScanDdbObjects[PendingPrayer](ddbClnt, PendingPrayersTable, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range pryrs {
+		if err := cphr.Decrypt(&pryrs[i].Request, &pryrs[i].Requestor.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	return pryrs, nil
+}
+
+// Create puts a new PendingPrayer with a new short ID, encrypted like Put. The put fails instead
+// of overwriting an existing PendingPrayer in the unlikely case that the ID is already used.
+func (p *PendingPrayer) Create(ddbClnt db.DDBConnecter, cphr encryption.Cipher, id string) error {
+	if len(id) > pendingPrayerIDLength {
+		id = id[:pendingPrayerIDLength]
+	}
+	p.ID, p.Status = id, PrayerPending
+
+	cond := db.Condition{
+		Expression: "attribute_not_exists(#id)",
+		Names:      map[string]string{"#id": PendingPrayerAttribute},
+	}
+
+	encrypted, err := p.encrypt(cphr)
+	if err != nil {
+		return fmt.Errorf("PendingPrayer create: %w", err)
+	}
+
+	created, err := db.PutDdbObjectIf(ddbClnt, PendingPrayersTable, &encrypted, cond)
+	if err != nil {
+		return fmt.Errorf("PendingPrayer create: %w", err)
+	} else if !created {
+		return fmt.Errorf("PendingPrayer create: ID %v is already used", p.ID)
+	}
+
+	return nil
+}
@@ -0,0 +1,127 @@
+package object_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/mshort55/prayertexter/internal/db"
+	"github.com/mshort55/prayertexter/internal/encryption"
+	"github.com/mshort55/prayertexter/internal/mock"
+	"github.com/mshort55/prayertexter/internal/object"
+)
+
+func TestPendingPrayerCreate(t *testing.T) {
+	ddbMock := &mock.DDBConnecter{}
+	ddbMock.PutItemResults = []struct {
+		Error error
+	}{
+		{Error: nil},
+		{Error: &types.ConditionalCheckFailedException{}},
+	}
+
+	pryr := object.PendingPrayer{Request: "I need prayer for..."}
+	if err := pryr.Create(ddbMock, encryption.Cipher{}, "1a2b3c4d5e6f7a8b"); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if pryr.ID != "1a2b3c4d" || pryr.Status != object.PrayerPending {
+		t.Errorf("expected short ID and pending status, got %v %v", pryr.ID, pryr.Status)
+	}
+
+	if cond := ddbMock.PutItemInputs[0].ConditionExpression; cond == nil || *cond != "attribute_not_exists(#id)" {
+		t.Errorf("expected conditional put, got %v", cond)
+	}
+
+	other := object.PendingPrayer{Request: "I need prayer for..."}
+	if err := other.Create(ddbMock, encryption.Cipher{}, "1a2b3c4d5e6f7a8b"); err == nil {
+		t.Errorf("expected error for an ID that is already used")
+	}
+}
+
+func TestPendingPrayerDecide(t *testing.T) {
+	ddbClnt := db.NewMemoryDB(object.TableKeys())
+	pryr := object.PendingPrayer{Request: "I need prayer for..."}
+	if err := pryr.Create(ddbClnt, encryption.Cipher{}, "1a2b3c4d"); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// two moderators decide the same prayer request
+	approved, rejected := pryr, pryr
+	approved.Status, rejected.Status = object.PrayerApproved, object.PrayerRejected
+	if decided, err := approved.Decide(ddbClnt, encryption.Cipher{}); err != nil || !decided {
+		t.Fatalf("expected first decision to be saved, got %v (error %v)", decided, err)
+	}
+	if decided, err := rejected.Decide(ddbClnt, encryption.Cipher{}); err != nil || decided {
+		t.Errorf("expected second decision not to be saved, got %v (error %v)", decided, err)
+	}
+
+	stored := object.PendingPrayer{ID: pryr.ID}
+	if err := stored.Get(ddbClnt, encryption.Cipher{}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if stored.Status != object.PrayerApproved {
+		t.Errorf("expected first decision to be kept, got %v", stored.Status)
+	}
+}
+
+func TestGetPendingPrayers(t *testing.T) {
+	ddbClnt := db.NewMemoryDB(object.TableKeys())
+	for _, id := range []string{"1a2b3c4d", "5e6f7a8b"} {
+		pryr := object.PendingPrayer{Request: "I need prayer for..."}
+		if err := pryr.Create(ddbClnt, encryption.Cipher{}, id); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+
+	decided := object.PendingPrayer{ID: "5e6f7a8b", Request: "I need prayer for...", Status: object.PrayerApproved}
+	if err := decided.Put(ddbClnt, encryption.Cipher{}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	pryrs, err := object.GetPendingPrayers(ddbClnt, encryption.Cipher{})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(pryrs) != 1 || pryrs[0].ID != "1a2b3c4d" {
+		t.Errorf("expected only pending prayer request 1a2b3c4d, got %v", pryrs)
+	}
+}
+
+func TestPendingPrayerEncryption(t *testing.T) {
+	keys, err := encryption.NewStaticKeyProvider(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32)))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	cphr := encryption.Cipher{EncryptNames: true, Keys: keys}
+
+	ddbClnt := db.NewMemoryDB(object.TableKeys())
+	pryr := object.PendingPrayer{Request: "please pray for my family", Requestor: object.Member{Name: "John Doe"}}
+	if err := pryr.Create(ddbClnt, cphr, "1a2b3c4d"); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	key := map[string]types.AttributeValue{object.PendingPrayerAttribute: &types.AttributeValueMemberS{Value: pryr.ID}}
+	out, err := ddbClnt.GetItem(context.Background(), &dynamodb.GetItemInput{
+		TableName: aws.String(object.PendingPrayersTable),
+		Key:       key,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if request := out.Item["Request"].(*types.AttributeValueMemberS).Value; !encryption.IsEncrypted(request) {
+		t.Errorf("expected encrypted request, got %v", request)
+	}
+
+	stored := object.PendingPrayer{ID: pryr.ID}
+	if err := stored.Get(ddbClnt, cphr); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if stored.Request != pryr.Request || stored.Requestor.Name != "John Doe" {
+		t.Errorf("expected decrypted PendingPrayer, got %v", stored)
+	}
+}
@@ -3,14 +3,19 @@ package object
 import (
 	"fmt"
 
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/mshort55/prayertexter/internal/db"
+	"github.com/mshort55/prayertexter/internal/encryption"
 )
 
 type Prayer struct {
 	Intercessor      Member
 	IntercessorPhone string
-	Request          string
-	Requestor        Member
+	// QueuedTime is when a queued Prayer was queued, RFC3339 formatted. Active Prayers do not have
+	// it
+	QueuedTime string `dynamodbav:",omitempty"`
+	Request    string
+	Requestor  Member
 }
 
 const (
@@ -19,7 +24,9 @@ const (
 	QueuedPrayersTable = "PrayersQueue"
 )
 
-func (p *Prayer) Get(ddbClnt db.DDBConnecter, queue bool) error {
+// Get gets the Prayer and decrypts the fields that were encrypted by Put. Prayers that were put
+// without encryption are read as they are.
+func (p *Prayer) Get(ddbClnt db.DDBConnecter, cphr encryption.Cipher, queue bool) error {
 	// queue determines whether ActivePrayers or PrayersQueue table is used for get
 	table := GetPrayerTable(queue)
 	pryr, err := db.GetDdbObject[Prayer](ddbClnt, PrayersAttribute, p.IntercessorPhone, table)
@@ -27,6 +34,10 @@ func (p *Prayer) Get(ddbClnt db.DDBConnecter, queue bool) error {
 		return fmt.Errorf("Prayer get: %w", err)
 	}
 
+	if err := cphr.Decrypt(&pryr.Request, &pryr.Requestor.Name, &pryr.Intercessor.Name); err != nil {
+		return fmt.Errorf("Prayer get: %w", err)
+	}
+
 	// this is important so that the original Prayer object doesn't get reset to all empty struct
 	// values if the Prayer does not exist in ddb
 	if pryr.IntercessorPhone != "" {
@@ -36,12 +47,23 @@ func (p *Prayer) Get(ddbClnt db.DDBConnecter, queue bool) error {
 	return nil
 }
 
-func (p *Prayer) Put(ddbClnt db.DDBConnecter, queue bool) error {
+// Put puts the Prayer with the Request (and member names if cphr encrypts names) encrypted by
+// cphr. The Prayer itself is not changed.
+func (p *Prayer) Put(ddbClnt db.DDBConnecter, cphr encryption.Cipher, queue bool) error {
+	encrypted := *p
+	fields := []*string{&encrypted.Request}
+	if cphr.EncryptNames {
+		fields = append(fields, &encrypted.Requestor.Name, &encrypted.Intercessor.Name)
+	}
+	if err := cphr.Encrypt(fields...); err != nil {
+		return fmt.Errorf("Prayer put: %w", err)
+	}
+
 	// queue is only used if there are not enough intercessors available to take a prayer request
 	// prayers get queued in order to save them for a time when intercessors are available
 	// this will change the ddb table that the prayer is saved to
 	table := GetPrayerTable(queue)
-	if err := db.PutDdbObject(ddbClnt, table, p); err != nil {
+	if err := db.PutDdbObject(ddbClnt, table, &encrypted); err != nil {
 		return fmt.Errorf("Prayer put: %w", err)
 	}
 
@@ -57,6 +79,75 @@ func (p *Prayer) Delete(ddbClnt db.DDBConnecter, queue bool) error {
 	return nil
 }
 
+// Dequeue deletes the queued Prayer only if it is still queued the way it was read, which is
+// checked on QueuedTime. It returns false if the Prayer was already taken out of the queue, for
+// example by a concurrent flow that assigned it to intercessors.
+func (p *Prayer) Dequeue(ddbClnt db.DDBConnecter) (bool, error) {
+	cond := db.Condition{
+		Expression: "attribute_exists(#phone) AND attribute_not_exists(#queuedTime)",
+		Names:      map[string]string{"#phone": PrayersAttribute, "#queuedTime": "QueuedTime"},
+	}
+	if p.QueuedTime != "" {
+		cond.Expression = "attribute_exists(#phone) AND #queuedTime = :queuedTime"
+		cond.Values = map[string]types.AttributeValue{":queuedTime": &types.AttributeValueMemberS{Value: p.QueuedTime}}
+	}
+
+	dequeued, err := db.DelDdbItemIf(ddbClnt, PrayersAttribute, p.IntercessorPhone, QueuedPrayersTable, cond)
+	if err != nil {
+		return false, fmt.Errorf("Prayer dequeue: %w", err)
+	}
+
+	return dequeued, nil
+}
+
+// GetPrayers returns all Prayers in the ActivePrayers or PrayersQueue table, decrypted by cphr.
+// This scans the whole table.
+func GetPrayers(ddbClnt db.DDBConnecter, cphr encryption.Cipher, queue bool) ([]Prayer, error) {
+	pryrs, err := scanPrayers(ddbClnt, cphr, queue, db.Condition{})
+	if err != nil {
+		return nil, fmt.Errorf("GetPrayers: %w", err)
+	}
+
+	return pryrs, nil
+}
+
+// GetMemberPrayers returns the Prayers where phone is the requestor or the intercessor, decrypted
+// by cphr. This scans the whole ActivePrayers or PrayersQueue table.
+func GetMemberPrayers(ddbClnt db.DDBConnecter, cphr encryption.Cipher, phone string, queue bool) ([]Prayer, error) {
+	filter := db.Condition{
+		Expression: "#intercessorPhone = :phone OR #requestor.#phone = :phone",
+		Names: map[string]string{
+			"#intercessorPhone": PrayersAttribute,
+			"#phone":            MemberAttribute,
+			"#requestor":        "Requestor",
+		},
+		Values: map[string]types.AttributeValue{":phone": &types.AttributeValueMemberS{Value: phone}},
+	}
+
+	pryrs, err := scanPrayers(ddbClnt, cphr, queue, filter)
+	if err != nil {
+		return nil, fmt.Errorf("GetMemberPrayers: %w", err)
+	}
+
+	return pryrs, nil
+}
+
+func scanPrayers(ddbClnt db.DDBConnecter, cphr encryption.Cipher, queue bool, filter db.Condition) ([]Prayer, error) {
+	pryrs, err := db.ScanDdbObjects[Prayer](ddbClnt, GetPrayerTable(queue), filter)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range pryrs {
+		pryr := &pryrs[i]
+		if err := cphr.Decrypt(&pryr.Request, &pryr.Requestor.Name, &pryr.Intercessor.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	return pryrs, nil
+}
+
 func GetPrayerTable(queue bool) string {
 	var table string
 	if queue {
@@ -69,8 +160,9 @@ func GetPrayerTable(queue bool) string {
 }
 
 func IsPrayerActive(ddbClnt db.DDBConnecter, phone string) (bool, error) {
-	pryr := Prayer{IntercessorPhone: phone}
-	if err := pryr.Get(ddbClnt, false); err != nil {
+	// the Request is only checked for being empty, so the Prayer is not decrypted
+	pryr, err := db.GetDdbObject[Prayer](ddbClnt, PrayersAttribute, phone, ActivePrayersTable)
+	if err != nil {
 		return false, fmt.Errorf("isPrayerActive: %w", err)
 	}
 
@@ -1,11 +1,16 @@
 package object_test
 
 import (
+	"bytes"
+	"encoding/base64"
 	"errors"
 	"testing"
 
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/mshort55/prayertexter/internal/db"
+	"github.com/mshort55/prayertexter/internal/encryption"
 	"github.com/mshort55/prayertexter/internal/mock"
 	"github.com/mshort55/prayertexter/internal/object"
 )
@@ -94,3 +99,71 @@ func TestCheckIfActivePrayer(t *testing.T) {
 		t.Errorf("expected error, got %v", err)
 	}
 }
+
+func TestPrayerEncryption(t *testing.T) {
+	keys, err := encryption.NewStaticKeyProvider(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32)))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	cphr := encryption.Cipher{EncryptNames: true, Keys: keys}
+
+	pryr := object.Prayer{
+		IntercessorPhone: "+11111111111",
+		Request:          "please pray for my family",
+		Requestor:        object.Member{Name: "John Doe", Phone: "+12222222222"},
+	}
+
+	ddbMock := &mock.DDBConnecter{}
+	if err := pryr.Put(ddbMock, cphr, false); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if pryr.Request != "please pray for my family" {
+		t.Errorf("expected Put not to change the Prayer, got %v", pryr.Request)
+	}
+
+	// the stored item is read back by the next get
+	item := ddbMock.PutItemInputs[0].Item
+	var stored object.Prayer
+	if err := attributevalue.UnmarshalMap(item, &stored); err != nil {
+		t.Fatalf("failed to unmarshal PutItemInput into Prayer: %v", err)
+	}
+
+	if !encryption.IsEncrypted(stored.Request) || !encryption.IsEncrypted(stored.Requestor.Name) ||
+		stored.Requestor.Phone != "+12222222222" {
+		t.Errorf("expected encrypted request and name, got %v", stored)
+	}
+
+	ddbMock.GetItemResults = []struct {
+		Output *dynamodb.GetItemOutput
+		Error  error
+	}{
+		{Output: &dynamodb.GetItemOutput{Item: item}},
+		{Output: &dynamodb.GetItemOutput{Item: item}},
+	}
+
+	got := object.Prayer{IntercessorPhone: "+11111111111"}
+	if err := got.Get(ddbMock, cphr, false); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if got.Request != pryr.Request || got.Requestor.Name != "John Doe" {
+		t.Errorf("expected decrypted Prayer, got %v", got)
+	}
+
+	// a Prayer that is encrypted cannot be read without the key
+	if err := got.Get(ddbMock, encryption.Cipher{}, false); !errors.Is(err, encryption.ErrNoKeyProvider) {
+		t.Errorf("expected ErrNoKeyProvider, got %v", err)
+	}
+
+	// a request that looks encrypted is still read back without encryption
+	texted := object.Prayer{IntercessorPhone: "+11111111111", Request: "enc:v1:please pray"}
+	ddbClnt := db.NewMemoryDB(object.TableKeys())
+	if err := texted.Put(ddbClnt, encryption.Cipher{}, false); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	got = object.Prayer{IntercessorPhone: "+11111111111"}
+	if err := got.Get(ddbClnt, encryption.Cipher{}, false); err != nil || got.Request != texted.Request {
+		t.Errorf("expected request %q, got %q (error %v)", texted.Request, got.Request, err)
+	}
+}
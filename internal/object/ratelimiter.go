@@ -0,0 +1,166 @@
+package object
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/mshort55/prayertexter/internal/config"
+	"github.com/mshort55/prayertexter/internal/db"
+)
+
+// RateLimiter holds the rate limit state of a single phone. Each kind of message (prayer requests
+// and commands) has its own token bucket. ddb removes RateLimiters of phones that have not sent
+// anything for a while with ttl on ExpirationTime, which is the same as a full bucket.
+type RateLimiter struct {
+	BlockedUntil   int64 `dynamodbav:",omitempty"`
+	Buckets        map[string]TokenBucket
+	ExpirationTime int64
+	Key            string
+	// Rejected is the number of rate limited messages in a row
+	Rejected int
+	// Throttled is true once the throttle reply has been sent and until a message is allowed again
+	Throttled bool
+	// Version is incremented by every put, see Update
+	Version int `dynamodbav:",omitempty"`
+}
+
+type TokenBucket struct {
+	Tokens  float64
+	Updated int64
+}
+
+const (
+	RateLimiterAttribute = "Key"
+	RateLimiterKeyPrefix = "RateLimit#"
+	RateLimiterTable     = "General"
+	rateLimiterTTL       = 7 * 24 * time.Hour
+)
+
+// kinds of rate limited messages
+const (
+	RateLimitCommand = "command"
+	RateLimitPrayer  = "prayer"
+)
+
+// results of RateLimiter Take
+const (
+	RateLimitAllowed   = "ALLOWED"
+	RateLimitBlocked   = "BLOCKED"
+	RateLimitThrottled = "THROTTLED"
+)
+
+// PhoneRateLimiter returns the (not yet loaded) RateLimiter of phone.
+func PhoneRateLimiter(phone string) RateLimiter {
+	return RateLimiter{Key: RateLimiterKeyPrefix + phone}
+}
+
+func (r *RateLimiter) Get(ddbClnt db.DDBConnecter) error {
+	rl, err := db.GetDdbObject[RateLimiter](ddbClnt, RateLimiterAttribute, r.Key, RateLimiterTable)
+	if err != nil {
+		return fmt.Errorf("RateLimiter get: %w", err)
+	}
+
+	// this is important so that the original RateLimiter object doesn't get reset to all empty
+	// struct values if the RateLimiter does not exist in ddb
+	if rl.Key != "" {
+		*r = *rl
+	}
+
+	return nil
+}
+
+func (r *RateLimiter) Put(ddbClnt db.DDBConnecter) error {
+	r.Version++
+	if err := db.PutDdbObject(ddbClnt, RateLimiterTable, r); err != nil {
+		return fmt.Errorf("RateLimiter put: %w", err)
+	}
+
+	return nil
+}
+
+// Update loads the RateLimiter, applies change to it and puts it back. Messages of the same phone
+// arrive at the same time when it sends spam, so it is only put if it did not change since it was
+// loaded. Otherwise it is loaded again and change is applied again, which means that change must
+// only depend on the RateLimiter it gets.
+func (r *RateLimiter) Update(ddbClnt db.DDBConnecter, change func(*RateLimiter)) error {
+	key := r.Key
+	for range db.MaxUpdateAttempts {
+		*r = RateLimiter{Key: key}
+		if err := r.Get(ddbClnt); err != nil {
+			return fmt.Errorf("RateLimiter update: %w", err)
+		}
+
+		version := r.Version
+		change(r)
+		r.Key = key
+		r.Version = version + 1
+
+		updated, err := db.PutDdbObjectIf(ddbClnt, RateLimiterTable, r, db.VersionCondition(version))
+		if err != nil {
+			return fmt.Errorf("RateLimiter update: %w", err)
+		} else if updated {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("RateLimiter update: %w", db.ErrConflict)
+}
+
+func (r *RateLimiter) Delete(ddbClnt db.DDBConnecter) error {
+	if err := db.DelDdbItem(ddbClnt, RateLimiterAttribute, r.Key, RateLimiterTable); err != nil {
+		return fmt.Errorf("RateLimiter delete: %w", err)
+	}
+
+	return nil
+}
+
+// Take takes a token for a message of kind from the bucket of kind. It returns RateLimitAllowed if
+// the message may be processed. notify is true only for the first throttled message in a row, so
+// that the throttle reply gets sent at most once until the phone is allowed to send again. Phones
+// that keep sending while throttled get blocked for a while according to cfg.
+func (r *RateLimiter) Take(kind string, limit config.RateLimit, cfg config.Config, now time.Time) (string, bool) {
+	r.ExpirationTime = max(r.BlockedUntil, now.Unix()) + int64(rateLimiterTTL.Seconds())
+
+	if r.BlockedUntil > now.Unix() {
+		return RateLimitBlocked, false
+	}
+
+	if !limit.IsEnabled() {
+		return RateLimitAllowed, false
+	}
+
+	if r.Buckets == nil {
+		r.Buckets = map[string]TokenBucket{}
+	}
+
+	bucket, found := r.Buckets[kind]
+	if !found {
+		bucket.Tokens = float64(limit.Capacity)
+	} else {
+		refill := float64(now.Unix()-bucket.Updated) / float64(limit.RefillSeconds)
+		bucket.Tokens = math.Min(float64(limit.Capacity), bucket.Tokens+refill)
+	}
+	bucket.Updated = now.Unix()
+
+	if bucket.Tokens >= 1 {
+		bucket.Tokens--
+		r.Buckets[kind] = bucket
+		r.Rejected, r.Throttled = 0, false
+		return RateLimitAllowed, false
+	}
+	r.Buckets[kind] = bucket
+
+	r.Rejected++
+	if cfg.RateLimitBlockThreshold > 0 && cfg.RateLimitBlockMinutes > 0 && r.Rejected >= cfg.RateLimitBlockThreshold {
+		r.BlockedUntil = now.Add(time.Duration(cfg.RateLimitBlockMinutes) * time.Minute).Unix()
+		r.ExpirationTime = r.BlockedUntil + int64(rateLimiterTTL.Seconds())
+		r.Rejected, r.Throttled = 0, false
+		return RateLimitBlocked, false
+	}
+
+	notify := !r.Throttled
+	r.Throttled = true
+
+	return RateLimitThrottled, notify
+}
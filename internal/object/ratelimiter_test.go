@@ -0,0 +1,60 @@
+package object_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mshort55/prayertexter/internal/config"
+	"github.com/mshort55/prayertexter/internal/object"
+)
+
+func TestRateLimiterTake(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	limit := config.RateLimit{Capacity: 2, RefillSeconds: 60}
+	cfg := config.Config{PrayerRateLimit: limit, RateLimitBlockMinutes: 30, RateLimitBlockThreshold: 3}
+
+	rl := object.PhoneRateLimiter("+11234567890")
+	if rl.Key != object.RateLimiterKeyPrefix+"+11234567890" {
+		t.Errorf("expected key %v, got %v", object.RateLimiterKeyPrefix+"+11234567890", rl.Key)
+	}
+
+	steps := []struct {
+		description string
+		kind        string
+		after       time.Duration
+		result      string
+		notify      bool
+	}{
+		{description: "full bucket", kind: object.RateLimitPrayer, result: object.RateLimitAllowed},
+		{description: "last token", kind: object.RateLimitPrayer, result: object.RateLimitAllowed},
+		{description: "empty bucket", kind: object.RateLimitPrayer, result: object.RateLimitThrottled, notify: true},
+		{description: "throttle reply only once", kind: object.RateLimitPrayer, after: time.Second,
+			result: object.RateLimitThrottled},
+		{description: "commands have their own bucket", kind: object.RateLimitCommand, result: object.RateLimitAllowed},
+		{description: "refilled token", kind: object.RateLimitPrayer, after: time.Minute, result: object.RateLimitAllowed},
+		{description: "throttled again", kind: object.RateLimitPrayer, result: object.RateLimitThrottled, notify: true},
+		{description: "still throttled", kind: object.RateLimitPrayer, result: object.RateLimitThrottled},
+		{description: "blocked at hard threshold", kind: object.RateLimitPrayer, result: object.RateLimitBlocked},
+		{description: "blocked phones cannot send commands", kind: object.RateLimitCommand, after: 29 * time.Minute,
+			result: object.RateLimitBlocked},
+		{description: "block expires", kind: object.RateLimitPrayer, after: time.Minute, result: object.RateLimitAllowed},
+	}
+
+	for _, step := range steps {
+		now = now.Add(step.after)
+		lim := config.RateLimit{}
+		if step.kind == object.RateLimitPrayer {
+			lim = limit
+		}
+
+		result, notify := rl.Take(step.kind, lim, cfg, now)
+		if result != step.result || notify != step.notify {
+			t.Errorf("%v: expected %v (notify %v), got %v (notify %v)", step.description, step.result, step.notify,
+				result, notify)
+		}
+	}
+
+	if rl.ExpirationTime <= now.Unix() {
+		t.Errorf("expected expiration time in the future, got %v", rl.ExpirationTime)
+	}
+}
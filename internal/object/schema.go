@@ -0,0 +1,190 @@
+package object
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/mshort55/prayertexter/internal/config"
+	"github.com/mshort55/prayertexter/internal/db"
+)
+
+// The schema versions of all stored objects except config.Config are kept here, so that changes to
+// them are easy to review together. When a struct changes in a way that existing items do not
+// unmarshal into, bump its version and add a migration from the previous version. Prayers and
+// PendingPrayers store Members inside them, so a Member migration needs a Prayer and PendingPrayer
+// migration that applies it to those Members too.
+
+func (a *ArchivedPrayer) Schema() db.Schema    { return db.Schema{Version: 1} }
+func (a *archivedRequest) Schema() db.Schema   { return db.Schema{Version: 1} }
+func (b *BlockedPhones) Schema() db.Schema     { return db.Schema{Version: 1} }
+func (i *InboundMessage) Schema() db.Schema    { return db.Schema{Version: 1} }
+func (i *IntercessorPhones) Schema() db.Schema { return db.Schema{Version: 1} }
+func (m *Member) Schema() db.Schema            { return db.Schema{Version: 1} }
+func (m *MigrationProgress) Schema() db.Schema { return db.Schema{Version: 1} }
+func (p *PendingPrayer) Schema() db.Schema     { return db.Schema{Version: 1} }
+func (p *Prayer) Schema() db.Schema            { return db.Schema{Version: 1} }
+func (p *PrayerStats) Schema() db.Schema       { return db.Schema{Version: 1} }
+func (r *RateLimiter) Schema() db.Schema       { return db.Schema{Version: 1} }
+func (st *StateTracker) Schema() db.Schema     { return db.Schema{Version: 1} }
+func (t *Tenant) Schema() db.Schema            { return db.Schema{Version: 1} }
+func (u *Usage) Schema() db.Schema             { return db.Schema{Version: 1} }
+
+// MigrationProgress is where a batch migration of one MigrationTarget is, so that an interrupted
+// migration resumes instead of starting over.
+type MigrationProgress struct {
+	Done bool
+	Key  string
+	// LastKey is the hash key of the last item of the last migrated page
+	LastKey  string
+	Migrated int
+	Scanned  int
+	Skipped  int
+}
+
+const (
+	MigrationProgressAttribute = "Key"
+	MigrationProgressKeyPrefix = "MigrationProgress#"
+	MigrationProgressTable     = "General"
+)
+
+// MigrationTarget is a kind of object that MigrateObjects can migrate: the objects of one type in
+// one table.
+type MigrationTarget struct {
+	Attribute string
+	Filter    db.Condition
+	Name      string
+	Table     string
+
+	migrate func(ddbClnt db.DDBConnecter, t MigrationTarget, startKey map[string]types.AttributeValue,
+		page func(db.MigrationPage) error) error
+}
+
+func migrationTarget[T any](name, attr, table string, filter db.Condition) MigrationTarget {
+	return MigrationTarget{
+		Attribute: attr,
+		Filter:    filter,
+		Name:      name,
+		Table:     table,
+		migrate: func(ddbClnt db.DDBConnecter, t MigrationTarget, startKey map[string]types.AttributeValue,
+			page func(db.MigrationPage) error) error {
+			return db.MigrateTable[T](ddbClnt, t.Attribute, t.Table, t.Filter, startKey, page)
+		},
+	}
+}
+
+// keyPrefix filters the General table for the objects whose Key starts with prefix.
+func keyPrefix(prefix string) db.Condition {
+	return db.Condition{
+		Expression: "begins_with(#key, :prefix)",
+		Names:      map[string]string{"#key": "Key"},
+		Values:     map[string]types.AttributeValue{":prefix": &types.AttributeValueMemberS{Value: prefix}},
+	}
+}
+
+// MigrationTargets returns every kind of stored object, by name.
+func MigrationTargets() []MigrationTarget {
+	return []MigrationTarget{
+		migrationTarget[Prayer]("active-prayers", PrayersAttribute, ActivePrayersTable, db.Condition{}),
+		migrationTarget[ArchivedPrayer]("archived-prayers", ArchivedPrayerAttribute, ArchivedPrayersTable,
+			db.Condition{
+				Expression: "NOT begins_with(#id, :prefix)",
+				Names:      map[string]string{"#id": ArchivedPrayerAttribute},
+				Values: map[string]types.AttributeValue{
+					":prefix": &types.AttributeValueMemberS{Value: ArchivedRequestKeyPrefix},
+				},
+			}),
+		migrationTarget[archivedRequest]("archived-requests", ArchivedPrayerAttribute, ArchivedPrayersTable,
+			db.Condition{
+				Expression: "begins_with(#id, :prefix)",
+				Names:      map[string]string{"#id": ArchivedPrayerAttribute},
+				Values: map[string]types.AttributeValue{
+					":prefix": &types.AttributeValueMemberS{Value: ArchivedRequestKeyPrefix},
+				},
+			}),
+		migrationTarget[config.Config]("config", config.ConfigAttribute, config.ConfigTable,
+			keyPrefix(config.ConfigKey)),
+		migrationTarget[BlockedPhones]("blocked-phones", BlockedPhonesAttribute, BlockedPhonesTable,
+			keyPrefix(BlockedPhonesKey)),
+		migrationTarget[InboundMessage]("inbound-messages", InboundMessageAttribute, InboundMessageTable,
+			keyPrefix(InboundMessageKeyPrefix)),
+		migrationTarget[IntercessorPhones]("intercessor-phones", IntercessorPhonesAttribute, IntercessorPhonesTable,
+			keyPrefix(IntercessorPhonesKey)),
+		migrationTarget[Member]("members", MemberAttribute, MemberTable, db.Condition{}),
+		migrationTarget[PendingPrayer]("pending-prayers", PendingPrayerAttribute, PendingPrayersTable,
+			db.Condition{}),
+		migrationTarget[PrayerStats]("prayer-stats", PrayerStatsAttribute, PrayerStatsTable,
+			keyPrefix(PrayerStatsKeyPrefix)),
+		migrationTarget[Prayer]("queued-prayers", PrayersAttribute, QueuedPrayersTable, db.Condition{}),
+		migrationTarget[RateLimiter]("rate-limiters", RateLimiterAttribute, RateLimiterTable,
+			keyPrefix(RateLimiterKeyPrefix)),
+		migrationTarget[StateTracker]("state-tracker", StateTrackerAttribute, StateTrackerTable,
+			keyPrefix(StateTrackerKey)),
+		migrationTarget[Tenant]("tenants", TenantAttribute, TenantTable, keyPrefix(TenantKeyPrefix)),
+		migrationTarget[Usage]("usage", UsageAttribute, UsageTable, keyPrefix(UsageKeyPrefix)),
+	}
+}
+
+func (m *MigrationProgress) Get(ddbClnt db.DDBConnecter) error {
+	prog, err := db.GetDdbObject[MigrationProgress](ddbClnt, MigrationProgressAttribute, m.Key,
+		MigrationProgressTable)
+	if err != nil {
+		return fmt.Errorf("MigrationProgress get: %w", err)
+	}
+
+	if prog.Key != "" {
+		*m = *prog
+	}
+
+	return nil
+}
+
+func (m *MigrationProgress) Put(ddbClnt db.DDBConnecter) error {
+	if err := db.PutDdbObject(ddbClnt, MigrationProgressTable, m); err != nil {
+		return fmt.Errorf("MigrationProgress put: %w", err)
+	}
+
+	return nil
+}
+
+// MigrateObjects upgrades every stored object of t to its current schema version. Progress is
+// saved after every page, and a migration of t that did not finish resumes where it stopped unless
+// restart is true. report is called with the progress after every page.
+func MigrateObjects(ddbClnt db.DDBConnecter, t MigrationTarget, restart bool, report func(MigrationProgress)) error {
+	prog := MigrationProgress{Key: MigrationProgressKeyPrefix + t.Name}
+	if !restart {
+		if err := prog.Get(ddbClnt); err != nil {
+			return fmt.Errorf("MigrateObjects: %w", err)
+		}
+	}
+	// a finished migration is started over, since new items may need it again
+	if prog.Done {
+		prog = MigrationProgress{Key: prog.Key}
+	}
+
+	var startKey map[string]types.AttributeValue
+	if prog.LastKey != "" {
+		startKey = map[string]types.AttributeValue{t.Attribute: &types.AttributeValueMemberS{Value: prog.LastKey}}
+	}
+
+	err := t.migrate(ddbClnt, t, startKey, func(page db.MigrationPage) error {
+		prog.Migrated += page.Migrated
+		prog.Scanned += page.Scanned
+		prog.Skipped += page.Skipped
+		prog.LastKey, prog.Done = "", len(page.LastKey) == 0
+		if key, ok := page.LastKey[t.Attribute].(*types.AttributeValueMemberS); ok {
+			prog.LastKey = key.Value
+		}
+
+		if err := prog.Put(ddbClnt); err != nil {
+			return err
+		}
+		report(prog)
+
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("MigrateObjects %v: %w", t.Name, err)
+	}
+
+	return nil
+}
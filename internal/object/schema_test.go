@@ -0,0 +1,119 @@
+package object_test
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/mshort55/prayertexter/internal/db"
+	"github.com/mshort55/prayertexter/internal/mock"
+	"github.com/mshort55/prayertexter/internal/object"
+)
+
+func TestMigrationTargets(t *testing.T) {
+	keys := object.TableKeys()
+	names := map[string]bool{}
+
+	for _, target := range object.MigrationTargets() {
+		if names[target.Name] {
+			t.Errorf("duplicate migration target %v", target.Name)
+		}
+		names[target.Name] = true
+
+		if keys[target.Table] != target.Attribute {
+			t.Errorf("expected %v to use key %v of table %v, got %v", target.Name, keys[target.Table], target.Table,
+				target.Attribute)
+		}
+	}
+}
+
+func TestMigrateObjects(t *testing.T) {
+	ddbClnt := db.NewMemoryDB(object.TableKeys())
+	for _, mem := range []object.Member{{Phone: "+11111111111"}, {Phone: "+12222222222"}} {
+		if err := mem.Put(ddbClnt); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+
+	target := findMigrationTarget(t, "members")
+
+	var reports []object.MigrationProgress
+	if err := object.MigrateObjects(ddbClnt, target, false, func(prog object.MigrationProgress) {
+		reports = append(reports, prog)
+	}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	prog := object.MigrationProgress{Key: object.MigrationProgressKeyPrefix + "members"}
+	if err := prog.Get(ddbClnt); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !prog.Done || prog.Scanned != 2 || prog.Migrated != 0 || len(reports) != 1 {
+		t.Errorf("expected finished migration with 2 scanned members, got %+v (%v reports)", prog, len(reports))
+	}
+}
+
+func TestMigrateObjectsResume(t *testing.T) {
+	prog := object.MigrationProgress{
+		Key:     object.MigrationProgressKeyPrefix + "members",
+		LastKey: "+11111111111",
+		Scanned: 5,
+	}
+	item, err := attributevalue.MarshalMap(prog)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	ddbMock := &mock.DDBConnecter{}
+	ddbMock.GetItemResults = []struct {
+		Output *dynamodb.GetItemOutput
+		Error  error
+	}{
+		{Output: &dynamodb.GetItemOutput{Item: item}},
+	}
+	ddbMock.ScanResults = []struct {
+		Output *dynamodb.ScanOutput
+		Error  error
+	}{
+		{
+			Output: &dynamodb.ScanOutput{
+				Items: []map[string]types.AttributeValue{
+					{"Phone": &types.AttributeValueMemberS{Value: "+12222222222"}},
+				},
+			},
+		},
+	}
+
+	target := findMigrationTarget(t, "members")
+
+	if err := object.MigrateObjects(ddbMock, target, false, func(object.MigrationProgress) {}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	start, ok := ddbMock.ScanInputs[0].ExclusiveStartKey[object.MemberAttribute].(*types.AttributeValueMemberS)
+	if !ok || start.Value != prog.LastKey {
+		t.Errorf("expected scan to resume after %v, got %v", prog.LastKey, ddbMock.ScanInputs[0].ExclusiveStartKey)
+	}
+
+	saved := object.MigrationProgress{}
+	if err := attributevalue.UnmarshalMap(ddbMock.PutItemInputs[0].Item, &saved); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !saved.Done || saved.Scanned != 6 || saved.LastKey != "" {
+		t.Errorf("expected finished migration with 6 scanned objects, got %+v", saved)
+	}
+}
+
+func findMigrationTarget(t *testing.T, name string) object.MigrationTarget {
+	t.Helper()
+
+	for _, target := range object.MigrationTargets() {
+		if target.Name == name {
+			return target
+		}
+	}
+	t.Fatalf("no migration target %v", name)
+
+	return object.MigrationTarget{}
+}
@@ -2,21 +2,26 @@ package object
 
 import (
 	"fmt"
+	"slices"
 
 	"github.com/mshort55/prayertexter/internal/db"
 	"github.com/mshort55/prayertexter/internal/messaging"
-	"github.com/mshort55/prayertexter/internal/utility"
 )
 
 type StateTracker struct {
 	Key    string
 	States []State
+	// Version is incremented by every put, see Update
+	Version int `dynamodbav:",omitempty"`
 }
 
 type State struct {
-	Error     string
-	Message   messaging.TextMessage
-	ID        string
+	Error   string
+	Message messaging.TextMessage
+	ID      string
+	// PhoneHash is the PhoneHash of the phone of Message. Message is redacted according to the
+	// privacy level, so this is what finds the states of a phone when it is masked
+	PhoneHash string `dynamodbav:",omitempty"`
 	Stage     string
 	Status    string
 	TimeStart string
@@ -45,6 +50,7 @@ func (st *StateTracker) Get(ddbClnt db.DDBConnecter) error {
 
 func (st *StateTracker) Put(ddbClnt db.DDBConnecter) error {
 	st.Key = StateTrackerKey
+	st.Version++
 	if err := db.PutDdbObject(ddbClnt, string(StateTrackerTable), st); err != nil {
 		return fmt.Errorf("StateTracker put: %w", err)
 	}
@@ -52,24 +58,45 @@ func (st *StateTracker) Put(ddbClnt db.DDBConnecter) error {
 	return nil
 }
 
-func (s *State) Update(ddbClnt db.DDBConnecter, remove bool) error {
-	st := StateTracker{}
-	if err := st.Get(ddbClnt); err != nil {
-		return fmt.Errorf("State update: %w", err)
-	}
+// Update loads the StateTracker, applies change to it and puts it back. Every flow updates the
+// StateTracker, so it is only put if it did not change since it was loaded. Otherwise it is loaded
+// again and change is applied again, which means that change must only depend on the StateTracker
+// it gets.
+func (st *StateTracker) Update(ddbClnt db.DDBConnecter, change func(*StateTracker)) error {
+	for range db.MaxUpdateAttempts {
+		*st = StateTracker{}
+		if err := st.Get(ddbClnt); err != nil {
+			return fmt.Errorf("StateTracker update: %w", err)
+		}
+
+		version := st.Version
+		change(st)
+		st.Key = StateTrackerKey
+		st.Version++
 
-	states := &st.States
-	for _, state := range st.States {
-		if state.ID == s.ID {
-			utility.RemoveItem(states, state)
+		updated, err := db.PutDdbObjectIf(ddbClnt, StateTrackerTable, st, db.VersionCondition(version))
+		if err != nil {
+			return fmt.Errorf("StateTracker update: %w", err)
+		} else if updated {
+			return nil
 		}
 	}
 
-	if !remove {
-		st.States = append(st.States, *s)
-	}
+	return fmt.Errorf("StateTracker update: %w", db.ErrConflict)
+}
+
+func (s *State) Update(ddbClnt db.DDBConnecter, remove bool) error {
+	st := StateTracker{}
+	err := st.Update(ddbClnt, func(st *StateTracker) {
+		st.States = slices.DeleteFunc(st.States, func(state State) bool {
+			return state.ID == s.ID
+		})
 
-	if err := st.Put(ddbClnt); err != nil {
+		if !remove {
+			st.States = append(st.States, *s)
+		}
+	})
+	if err != nil {
 		return fmt.Errorf("State update: %w", err)
 	}
 
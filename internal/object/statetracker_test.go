@@ -4,6 +4,7 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
@@ -47,7 +48,8 @@ func TestUpdate(t *testing.T) {
 	}
 
 	expectedStateTracker := object.StateTracker{
-		Key: object.StateTrackerKey,
+		Key:     object.StateTrackerKey,
+		Version: 1,
 		States: []object.State{
 			{
 				Error: "sample error text",
@@ -96,6 +98,11 @@ func TestUpdate(t *testing.T) {
 	input := ddbMock.PutItemInputs[0]
 	testStateTracker(input, t, expectedStateTracker)
 
+	// the StateTracker is only put if no other flow changed it since it was read
+	if aws.ToString(input.ConditionExpression) == "" {
+		t.Errorf("expected conditional put of StateTracker")
+	}
+
 	//// test removing the State from StateTracker
 	// this resets the GetItem mock so that it can re-use mockGetItemResults
 	ddbMock.GetItemCalls = 0
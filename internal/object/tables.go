@@ -0,0 +1,79 @@
+package object
+
+import "slices"
+
+// Table is the schema of a ddb table. Tables is the only definition of the tables, template.yaml
+// and the localdev table files are generated from it with go run ./cmd/tablegen.
+type Table struct {
+	// HashKey is the string hash key attribute of the table
+	HashKey string
+	Indexes []Index
+	Name    string
+	// Stream is the stream view type of the table, or empty for no stream
+	Stream string
+	// TTL is the number attribute that ddb ttl expires items on, or empty for no ttl
+	TTL string
+}
+
+// Index is a global secondary index that projects all attributes.
+type Index struct {
+	HashKey string
+	Name    string
+	// RangeKey is the string range key attribute of the index, or empty for none
+	RangeKey string
+}
+
+const (
+	// ExpirationAttribute is the ttl attribute of the tables that expire items
+	ExpirationAttribute = "ExpirationTime"
+	// StreamNewAndOldImages is the stream view type of all tables
+	StreamNewAndOldImages = "NEW_AND_OLD_IMAGES"
+)
+
+// Tables returns the schema of every ddb table, sorted by name.
+func Tables() []Table {
+	return []Table{
+		{Name: ActivePrayersTable, HashKey: PrayersAttribute, Stream: StreamNewAndOldImages},
+		{
+			Name:    ArchivedPrayersTable,
+			HashKey: ArchivedPrayerAttribute,
+			Stream:  StreamNewAndOldImages,
+			TTL:     ExpirationAttribute,
+		},
+		// every object that is stored in the General table is keyed on "Key"
+		{
+			Name:    StateTrackerTable,
+			HashKey: StateTrackerAttribute,
+			Stream:  StreamNewAndOldImages,
+			TTL:     ExpirationAttribute,
+		},
+		{Name: MemberTable, HashKey: MemberAttribute, Stream: StreamNewAndOldImages},
+		{Name: PendingPrayersTable, HashKey: PendingPrayerAttribute, Stream: StreamNewAndOldImages},
+		{Name: QueuedPrayersTable, HashKey: PrayersAttribute, Stream: StreamNewAndOldImages},
+	}
+}
+
+// TableKeys returns the hash key attribute of every ddb table, by table name.
+func TableKeys() map[string]string {
+	keys := map[string]string{}
+	for _, tbl := range Tables() {
+		keys[tbl.Name] = tbl.HashKey
+	}
+
+	return keys
+}
+
+// Attributes returns every key attribute of the table and its indexes, sorted and without
+// duplicates. They are the attribute definitions of the table.
+func (t Table) Attributes() []string {
+	attrs := []string{t.HashKey}
+	for _, idx := range t.Indexes {
+		attrs = append(attrs, idx.HashKey)
+		if idx.RangeKey != "" {
+			attrs = append(attrs, idx.RangeKey)
+		}
+	}
+	slices.Sort(attrs)
+
+	return slices.Compact(attrs)
+}
@@ -0,0 +1,100 @@
+package object
+
+import (
+	"fmt"
+
+	"github.com/mshort55/prayertexter/internal/db"
+	"github.com/mshort55/prayertexter/internal/messaging"
+)
+
+// Tenant is a single congregation served by this deployment. Each Tenant has its own origination
+// phone number, member pool, message templates, and settings. Tenants are identified by their phone number.
+// The default Tenant has an empty Phone and uses messaging.PrayerTexterPhone so that deployments
+// without any configured Tenants keep working as before.
+type Tenant struct {
+	IntercessorsPerPrayer int
+	Key                   string
+	// Moderators are asked to approve the held prayer requests of this Tenant's members and are the
+	// only ones who can approve or reject them by text message. Tenants without Moderators use the
+	// deployment Moderators
+	Moderators []string `dynamodbav:",omitempty"`
+	Name       string
+	Phone      string
+	// TemplateVars and Templates override the deployment message templates for this Tenant, for
+	// example the help template or the SupportPhone variable
+	TemplateVars map[string]string
+	Templates    map[string]string
+}
+
+const (
+	TenantAttribute = "Key"
+	TenantKeyPrefix = "Tenant#"
+	TenantTable     = "General"
+)
+
+func (t *Tenant) Get(ddbClnt db.DDBConnecter) error {
+	tnt, err := db.GetDdbObject[Tenant](ddbClnt, TenantAttribute, TenantKeyPrefix+t.Phone, TenantTable)
+	if err != nil {
+		return fmt.Errorf("Tenant get: %w", err)
+	}
+
+	// this is important so that the original Tenant object doesn't get reset to all empty struct
+	// values if the Tenant does not exist in ddb
+	if tnt.Key != "" {
+		*t = *tnt
+	}
+
+	return nil
+}
+
+func (t *Tenant) Put(ddbClnt db.DDBConnecter) error {
+	t.Key = TenantKeyPrefix + t.Phone
+	if err := db.PutDdbObject(ddbClnt, TenantTable, t); err != nil {
+		return fmt.Errorf("Tenant put: %w", err)
+	}
+
+	return nil
+}
+
+func (t *Tenant) IsDefault() bool {
+	return t.Phone == ""
+}
+
+func (t *Tenant) GetIntercessorsPerPrayer() int {
+	if t.IntercessorsPerPrayer <= 0 {
+		return NumIntercessorsPerPrayer
+	}
+
+	return t.IntercessorsPerPrayer
+}
+
+// GetModerators returns the Moderators of the Tenant, or deployment if the Tenant has none.
+func (t *Tenant) GetModerators(deployment []string) []string {
+	if len(t.Moderators) == 0 {
+		return deployment
+	}
+
+	return t.Moderators
+}
+
+// GetTenant returns the Tenant that owns phone, which is the PrayerTexter number that a member
+// texted. An empty phone or the default PrayerTexter number returns the default Tenant without a
+// ddb lookup.
+func GetTenant(ddbClnt db.DDBConnecter, phone string) (Tenant, error) {
+	if phone == "" || phone == messaging.PrayerTexterPhone {
+		return Tenant{}, nil
+	}
+
+	tnt := Tenant{Phone: phone}
+	if err := tnt.Get(ddbClnt); err != nil {
+		return Tenant{}, fmt.Errorf("getTenant: %w", err)
+	}
+
+	// empty key means get Tenant did not return a Tenant. Dynamodb get requests return empty data
+	// if the key does not exist inside the database
+	if tnt.Key == "" {
+		return Tenant{}, fmt.Errorf("getTenant: no tenant configured for phone %v", phone)
+	}
+
+	return tnt, nil
+}
@@ -0,0 +1,104 @@
+package object_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/mshort55/prayertexter/internal/messaging"
+	"github.com/mshort55/prayertexter/internal/mock"
+	"github.com/mshort55/prayertexter/internal/object"
+)
+
+func TestGetTenant(t *testing.T) {
+	ddbMock := &mock.DDBConnecter{}
+
+	// default Tenant should never cause a ddb lookup
+	for _, phone := range []string{"", messaging.PrayerTexterPhone} {
+		tnt, err := object.GetTenant(ddbMock, phone)
+		if err != nil {
+			t.Errorf("unexpected error %v", err)
+		} else if !tnt.IsDefault() {
+			t.Errorf("expected default Tenant for phone %v, got %v", phone, tnt)
+		}
+	}
+
+	if ddbMock.GetItemCalls != 0 {
+		t.Errorf("expected GetItem to be called 0 times for default Tenant, got %v", ddbMock.GetItemCalls)
+	}
+
+	ddbMock.GetItemResults = []struct {
+		Output *dynamodb.GetItemOutput
+		Error  error
+	}{
+		{
+			Output: &dynamodb.GetItemOutput{
+				Item: map[string]types.AttributeValue{
+					"IntercessorsPerPrayer": &types.AttributeValueMemberN{Value: "3"},
+					"Key":                   &types.AttributeValueMemberS{Value: object.TenantKeyPrefix + "+19998887777"},
+					"Name":                  &types.AttributeValueMemberS{Value: "Grace Church"},
+					"Phone":                 &types.AttributeValueMemberS{Value: "+19998887777"},
+					"Templates": &types.AttributeValueMemberM{
+						Value: map[string]types.AttributeValue{
+							"help": &types.AttributeValueMemberS{Value: "Call Grace Church"},
+						},
+					},
+				},
+			},
+			Error: nil,
+		},
+		{
+			// This is an empty ddb response, meaning that the Tenant is not configured
+			Output: &dynamodb.GetItemOutput{},
+			Error:  nil,
+		},
+	}
+
+	tnt, err := object.GetTenant(ddbMock, "+19998887777")
+	if err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	key := ddbMock.GetItemInputs[0].Key[object.TenantAttribute].(*types.AttributeValueMemberS).Value
+	if key != object.TenantKeyPrefix+"+19998887777" {
+		t.Errorf("expected Tenant key %v, got %v", object.TenantKeyPrefix+"+19998887777", key)
+	}
+
+	if tnt.Templates[messaging.MsgHelp] != "Call Grace Church" || tnt.GetIntercessorsPerPrayer() != 3 {
+		t.Errorf("expected Tenant settings from ddb, got %v", tnt)
+	}
+
+	if _, err := object.GetTenant(ddbMock, "+19998887777"); err == nil {
+		t.Errorf("expected error for unconfigured Tenant, got nil")
+	}
+}
+
+func TestTenantDefaults(t *testing.T) {
+	tnt := object.Tenant{}
+	if tnt.GetIntercessorsPerPrayer() != object.NumIntercessorsPerPrayer {
+		t.Errorf("expected %v intercessors per prayer, got %v", object.NumIntercessorsPerPrayer,
+			tnt.GetIntercessorsPerPrayer())
+	}
+
+	deployment := []string{"+19990001111"}
+	if mods := tnt.GetModerators(deployment); !slices.Equal(mods, deployment) {
+		t.Errorf("expected deployment moderators %v, got %v", deployment, mods)
+	}
+
+	tnt.Moderators = []string{"+19990002222"}
+	if mods := tnt.GetModerators(deployment); !slices.Equal(mods, tnt.Moderators) {
+		t.Errorf("expected tenant moderators %v, got %v", tnt.Moderators, mods)
+	}
+}
+
+func TestTenantIntercessorPhones(t *testing.T) {
+	if phones := object.TenantIntercessorPhones(""); phones.Key != object.IntercessorPhonesKey {
+		t.Errorf("expected key %v, got %v", object.IntercessorPhonesKey, phones.Key)
+	}
+
+	expectedKey := object.IntercessorPhonesKey + "#+19998887777"
+	if phones := object.TenantIntercessorPhones("+19998887777"); phones.Key != expectedKey {
+		t.Errorf("expected key %v, got %v", expectedKey, phones.Key)
+	}
+}
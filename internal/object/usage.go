@@ -0,0 +1,235 @@
+package object
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/mshort55/prayertexter/internal/db"
+	"github.com/mshort55/prayertexter/internal/messaging"
+)
+
+// Usage holds counters of sent text messages. There is one Usage per day, which counts per
+// member, per flow stage and per destination country, and one Usage per month, which only
+// counts totals and per flow stage so that it stays small enough for a single ddb item.
+type Usage struct {
+	Countries map[string]UsageCount `dynamodbav:",omitempty"`
+	Key       string
+	Members   map[string]UsageCount `dynamodbav:",omitempty"`
+	Stages    map[string]UsageCount `dynamodbav:",omitempty"`
+	Total     UsageCount
+}
+
+type UsageCount struct {
+	Messages     int
+	Segments     int
+	UCS2Messages int
+}
+
+const (
+	UsageAttribute = "Key"
+	UsageKeyPrefix = "Usage#"
+	UsageTable     = "General"
+	usageDayFmt    = "2006-01-02"
+	usageMonthFmt  = "2006-01"
+)
+
+func (u *Usage) Get(ddbClnt db.DDBConnecter) error {
+	usg, err := db.GetDdbObject[Usage](ddbClnt, UsageAttribute, u.Key, UsageTable)
+	if err != nil {
+		return fmt.Errorf("Usage get: %w", err)
+	}
+
+	// this is important so that the original Usage object doesn't get reset to all empty struct
+	// values if the Usage does not exist in ddb
+	if usg.Key != "" {
+		*u = *usg
+	}
+
+	return nil
+}
+
+func (u *Usage) Put(ddbClnt db.DDBConnecter) error {
+	if err := db.PutDdbObject(ddbClnt, UsageTable, u); err != nil {
+		return fmt.Errorf("Usage put: %w", err)
+	}
+
+	return nil
+}
+
+// Add counts sent under stage. Per member counters are only kept if perMember is true.
+func (u *Usage) Add(stage string, sent messaging.SentText, perMember bool) {
+	count := UsageCount{Messages: 1, Segments: sent.Segments}
+	if sent.Encoding == messaging.EncodingUCS2 {
+		count.UCS2Messages = 1
+	}
+
+	country := sent.Country
+	if country == "" {
+		country = "unknown"
+	}
+
+	u.Total = u.Total.plus(count)
+	addUsageCount(&u.Stages, stage, count)
+	addUsageCount(&u.Countries, country, count)
+	if perMember {
+		addUsageCount(&u.Members, sent.Phone, count)
+	}
+}
+
+// Merge adds all counters of other to u.
+func (u *Usage) Merge(other Usage) {
+	u.Total = u.Total.plus(other.Total)
+	for _, merge := range []struct {
+		into *map[string]UsageCount
+		from map[string]UsageCount
+	}{
+		{into: &u.Countries, from: other.Countries},
+		{into: &u.Members, from: other.Members},
+		{into: &u.Stages, from: other.Stages},
+	} {
+		for key, count := range merge.from {
+			addUsageCount(merge.into, key, count)
+		}
+	}
+}
+
+func (c UsageCount) plus(other UsageCount) UsageCount {
+	return UsageCount{
+		Messages:     c.Messages + other.Messages,
+		Segments:     c.Segments + other.Segments,
+		UCS2Messages: c.UCS2Messages + other.UCS2Messages,
+	}
+}
+
+// counters returns every count of u as a db.Counter, for adding u to a stored Usage.
+func (u *Usage) counters() []db.Counter {
+	counters := u.Total.counters("Total")
+	for _, group := range []struct {
+		counts map[string]UsageCount
+		name   string
+	}{
+		{counts: u.Countries, name: "Countries"},
+		{counts: u.Members, name: "Members"},
+		{counts: u.Stages, name: "Stages"},
+	} {
+		for _, key := range slices.Sorted(maps.Keys(group.counts)) {
+			counters = append(counters, group.counts[key].counters(group.name, key)...)
+		}
+	}
+
+	return counters
+}
+
+func (c UsageCount) counters(path ...string) []db.Counter {
+	return []db.Counter{
+		{Path: append(slices.Clone(path), "Messages"), Value: c.Messages},
+		{Path: append(slices.Clone(path), "Segments"), Value: c.Segments},
+		{Path: append(slices.Clone(path), "UCS2Messages"), Value: c.UCS2Messages},
+	}
+}
+
+func addUsageCount(counts *map[string]UsageCount, key string, count UsageCount) {
+	if *counts == nil {
+		*counts = map[string]UsageCount{}
+	}
+	(*counts)[key] = (*counts)[key].plus(count)
+}
+
+func UsageDayKey(day time.Time) string {
+	return UsageKeyPrefix + day.UTC().Format(usageDayFmt)
+}
+
+func UsageMonthKey(month time.Time) string {
+	return UsageKeyPrefix + month.UTC().Format(usageMonthFmt)
+}
+
+// RecordUsage adds sent text messages to the counters of the day and month of now. stage is the
+// flow stage that caused the text messages to be sent. The counters are added atomically, so that
+// concurrent flows do not lose counts.
+func RecordUsage(ddbClnt db.DDBConnecter, stage string, sent []messaging.SentText, now time.Time) error {
+	if len(sent) == 0 {
+		return nil
+	}
+
+	for _, usg := range []struct {
+		key       string
+		perMember bool
+	}{
+		{key: UsageDayKey(now), perMember: true},
+		{key: UsageMonthKey(now), perMember: false},
+	} {
+		u := Usage{}
+		for _, s := range sent {
+			u.Add(stage, s, usg.perMember)
+		}
+
+		if err := db.AddDdbCounters[Usage](ddbClnt, UsageAttribute, usg.key, UsageTable, u.counters()); err != nil {
+			return fmt.Errorf("recordUsage: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// GetMemberUsage returns the daily Usages that count text messages sent to phone, with only the
+// counters of phone. This scans the whole General table.
+func GetMemberUsage(ddbClnt db.DDBConnecter, phone string) ([]Usage, error) {
+	usages, err := db.ScanDdbObjects[Usage](ddbClnt, UsageTable, db.Condition{
+		Expression: "begins_with(#key, :prefix) AND attribute_exists(#members.#phone)",
+		Names:      map[string]string{"#key": UsageAttribute, "#members": "Members", "#phone": phone},
+		Values:     map[string]types.AttributeValue{":prefix": &types.AttributeValueMemberS{Value: UsageKeyPrefix}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("GetMemberUsage: %w", err)
+	}
+
+	for i, u := range usages {
+		usages[i] = Usage{Key: u.Key, Members: map[string]UsageCount{phone: u.Members[phone]}}
+	}
+
+	return usages, nil
+}
+
+// RemoveMember removes the counters of phone from u. The totals still count the text
+// messages sent to phone. The counters are removed atomically, so that counts that concurrent
+// flows add are kept.
+func (u *Usage) RemoveMember(ddbClnt db.DDBConnecter, phone string) error {
+	err := db.RemoveDdbAttributes(ddbClnt, UsageAttribute, u.Key, UsageTable, [][]string{{"Members", phone}})
+	if err != nil {
+		return fmt.Errorf("Usage removeMember: %w", err)
+	}
+
+	return nil
+}
+
+// GetUsageReport returns the merged daily Usage of every day from from to to, including both.
+func GetUsageReport(ddbClnt db.DDBConnecter, from, to time.Time) (Usage, error) {
+	report := Usage{}
+	for day := from.UTC().Truncate(24 * time.Hour); !day.After(to.UTC()); day = day.AddDate(0, 0, 1) {
+		u := Usage{Key: UsageDayKey(day)}
+		if err := u.Get(ddbClnt); err != nil {
+			return report, fmt.Errorf("getUsageReport: %w", err)
+		}
+		report.Merge(u)
+	}
+
+	return report, nil
+}
+
+// IsOverBudget returns true if the number of segments sent in the month of now has reached
+// budget. A budget of 0 or less means there is no budget.
+func IsOverBudget(ddbClnt db.DDBConnecter, budget int, now time.Time) (bool, error) {
+	if budget <= 0 {
+		return false, nil
+	}
+
+	u := Usage{Key: UsageMonthKey(now)}
+	if err := u.Get(ddbClnt); err != nil {
+		return false, fmt.Errorf("isOverBudget: %w", err)
+	}
+
+	return u.Total.Segments >= budget, nil
+}
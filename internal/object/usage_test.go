@@ -0,0 +1,256 @@
+package object_test
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/mshort55/prayertexter/internal/db"
+	"github.com/mshort55/prayertexter/internal/messaging"
+	"github.com/mshort55/prayertexter/internal/mock"
+	"github.com/mshort55/prayertexter/internal/object"
+)
+
+func TestRecordUsage(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	sent := []messaging.SentText{
+		{Country: "+1", Encoding: messaging.EncodingGSM7, Phone: "+11234567890", Segments: 1},
+		{Country: "+1", Encoding: messaging.EncodingUCS2, Phone: "+11234567890", Segments: 2},
+		{Country: "+52", Encoding: messaging.EncodingGSM7, Phone: "+525512345678", Segments: 1},
+	}
+
+	ddbClnt := db.NewMemoryDB(object.TableKeys())
+	existing := object.Usage{
+		Key:   object.UsageKeyPrefix + "2026-10",
+		Total: object.UsageCount{Messages: 10, Segments: 12},
+	}
+	if err := existing.Put(ddbClnt); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if err := object.RecordUsage(ddbClnt, "PRAYER REQUEST", sent, now); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	day, month := object.Usage{Key: object.UsageDayKey(now)}, object.Usage{Key: object.UsageMonthKey(now)}
+	for _, u := range []*object.Usage{&day, &month} {
+		if err := u.Get(ddbClnt); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+
+	expectedDay := object.Usage{
+		Countries: map[string]object.UsageCount{
+			"+1":  {Messages: 2, Segments: 3, UCS2Messages: 1},
+			"+52": {Messages: 1, Segments: 1},
+		},
+		Key: object.UsageKeyPrefix + "2026-10-18",
+		Members: map[string]object.UsageCount{
+			"+11234567890":  {Messages: 2, Segments: 3, UCS2Messages: 1},
+			"+525512345678": {Messages: 1, Segments: 1},
+		},
+		Stages: map[string]object.UsageCount{"PRAYER REQUEST": {Messages: 3, Segments: 4, UCS2Messages: 1}},
+		Total:  object.UsageCount{Messages: 3, Segments: 4, UCS2Messages: 1},
+	}
+	if !reflect.DeepEqual(day, expectedDay) {
+		t.Errorf("expected daily Usage %v, got %v", expectedDay, day)
+	}
+
+	// monthly usage adds to the existing counters and does not count per member
+	if month.Total != (object.UsageCount{Messages: 13, Segments: 16, UCS2Messages: 1}) || month.Members != nil {
+		t.Errorf("expected monthly Usage to be added to existing counters, got %v", month)
+	}
+
+	// nothing sent means nothing to record
+	ddbMock := &mock.DDBConnecter{}
+	if err := object.RecordUsage(ddbMock, "HELP", nil, now); err != nil || ddbMock.UpdateItemCalls != 0 {
+		t.Errorf("expected no ddb calls when nothing was sent, got %v UpdateItem calls (error %v)",
+			ddbMock.UpdateItemCalls, err)
+	}
+}
+
+func TestRecordUsageConcurrent(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	ddbClnt := db.NewMemoryDB(object.TableKeys())
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sent := []messaging.SentText{{Country: "+1", Phone: fmt.Sprintf("+1%010d", i%4), Segments: 1}}
+			errs <- object.RecordUsage(ddbClnt, "HELP", sent, now)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+
+	day := object.Usage{Key: object.UsageDayKey(now)}
+	if err := day.Get(ddbClnt); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if day.Total.Messages != 20 || day.Stages["HELP"].Segments != 20 || day.Members["+10000000001"].Messages != 5 {
+		t.Errorf("expected every message to be counted once, got %v", day)
+	}
+}
+
+func TestMemberUsage(t *testing.T) {
+	ddbClnt := db.NewMemoryDB(object.TableKeys())
+	phone, other := "+11234567890", "+11111111111"
+	days := []time.Time{
+		time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC),
+		time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC),
+	}
+	for _, day := range days {
+		sent := []messaging.SentText{{Phone: phone, Segments: 2}, {Phone: other, Segments: 1}}
+		if err := object.RecordUsage(ddbClnt, "HELP", sent, day); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+	later := days[1].AddDate(0, 0, 1)
+	if err := object.RecordUsage(ddbClnt, "HELP", []messaging.SentText{{Phone: other}}, later); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	usages, err := object.GetMemberUsage(ddbClnt, phone)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	count := object.UsageCount{Messages: 1, Segments: 2}
+	expected := []object.Usage{
+		{Key: object.UsageDayKey(days[0]), Members: map[string]object.UsageCount{phone: count}},
+		{Key: object.UsageDayKey(days[1]), Members: map[string]object.UsageCount{phone: count}},
+	}
+	if !reflect.DeepEqual(usages, expected) {
+		t.Fatalf("expected member usage %v, got %v", expected, usages)
+	}
+
+	for _, u := range usages {
+		if err := u.RemoveMember(ddbClnt, phone); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+
+	day := object.Usage{Key: object.UsageDayKey(days[0])}
+	if err := day.Get(ddbClnt); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if _, found := day.Members[phone]; found || day.Members[other].Messages != 1 || day.Total.Messages != 2 {
+		t.Errorf("expected only the counters of phone to be removed, got %v", day)
+	}
+
+	// removing from a Usage that does not exist does not create it
+	missing := object.Usage{Key: object.UsageDayKey(days[0].AddDate(0, 0, -1))}
+	if err := missing.RemoveMember(ddbClnt, phone); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if usages, err := object.GetMemberUsage(ddbClnt, phone); err != nil || len(usages) != 0 {
+		t.Errorf("expected no member usage, got %v (%v)", usages, err)
+	}
+	if err := missing.Get(ddbClnt); err != nil || missing.Total.Messages != 0 {
+		t.Errorf("expected no Usage to be created, got %v (%v)", missing, err)
+	}
+}
+
+func TestIsOverBudget(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	ddbMock := &mock.DDBConnecter{}
+	ddbMock.GetItemResults = []struct {
+		Output *dynamodb.GetItemOutput
+		Error  error
+	}{
+		{
+			Output: &dynamodb.GetItemOutput{
+				Item: map[string]types.AttributeValue{
+					"Key": &types.AttributeValueMemberS{Value: object.UsageKeyPrefix + "2026-10"},
+					"Total": &types.AttributeValueMemberM{
+						Value: map[string]types.AttributeValue{
+							"Segments": &types.AttributeValueMemberN{Value: "500"},
+						},
+					},
+				},
+			},
+			Error: nil,
+		},
+	}
+
+	// no budget never causes a ddb lookup
+	if over, err := object.IsOverBudget(ddbMock, 0, now); err != nil || over || ddbMock.GetItemCalls != 0 {
+		t.Errorf("expected no budget to never be exceeded without ddb lookup")
+	}
+
+	over, err := object.IsOverBudget(ddbMock, 500, now)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if !over {
+		t.Errorf("expected budget of 500 segments to be exceeded")
+	}
+
+	key := ddbMock.GetItemInputs[0].Key[object.UsageAttribute].(*types.AttributeValueMemberS).Value
+	if key != object.UsageMonthKey(now) {
+		t.Errorf("expected Usage key %v, got %v", object.UsageMonthKey(now), key)
+	}
+}
+
+func TestGetUsageReport(t *testing.T) {
+	usage := func(day string, segments string) *dynamodb.GetItemOutput {
+		return &dynamodb.GetItemOutput{
+			Item: map[string]types.AttributeValue{
+				"Key": &types.AttributeValueMemberS{Value: object.UsageKeyPrefix + day},
+				"Stages": &types.AttributeValueMemberM{
+					Value: map[string]types.AttributeValue{
+						"HELP": &types.AttributeValueMemberM{
+							Value: map[string]types.AttributeValue{
+								"Messages": &types.AttributeValueMemberN{Value: "1"},
+								"Segments": &types.AttributeValueMemberN{Value: segments},
+							},
+						},
+					},
+				},
+				"Total": &types.AttributeValueMemberM{
+					Value: map[string]types.AttributeValue{
+						"Messages": &types.AttributeValueMemberN{Value: "1"},
+						"Segments": &types.AttributeValueMemberN{Value: segments},
+					},
+				},
+			},
+		}
+	}
+
+	ddbMock := &mock.DDBConnecter{}
+	ddbMock.GetItemResults = []struct {
+		Output *dynamodb.GetItemOutput
+		Error  error
+	}{
+		{Output: usage("2026-10-01", "2"), Error: nil},
+		{Output: &dynamodb.GetItemOutput{}, Error: nil},
+		{Output: usage("2026-10-03", "3"), Error: nil},
+	}
+
+	from := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 10, 3, 18, 0, 0, 0, time.UTC)
+	report, err := object.GetUsageReport(ddbMock, from, to)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if ddbMock.GetItemCalls != 3 {
+		t.Errorf("expected GetItem to be called 3 times, got %v", ddbMock.GetItemCalls)
+	}
+
+	if report.Total.Segments != 5 || report.Stages["HELP"].Messages != 2 {
+		t.Errorf("expected merged daily Usage, got %v", report)
+	}
+}
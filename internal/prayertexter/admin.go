@@ -0,0 +1,533 @@
+package prayertexter
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/mshort55/prayertexter/internal/config"
+	"github.com/mshort55/prayertexter/internal/db"
+	"github.com/mshort55/prayertexter/internal/messaging"
+	"github.com/mshort55/prayertexter/internal/object"
+)
+
+var (
+	// ErrNoActivePrayer is returned when an intercessor does not have an active prayer.
+	ErrNoActivePrayer = errors.New("no active prayer")
+	// ErrNoQueuedPrayer is returned when there is no queued prayer with the given ID.
+	ErrNoQueuedPrayer = errors.New("no queued prayer")
+	// ErrIntercessorUnavailable is returned when a prayer can not be assigned to a member because
+	// they are not an intercessor or already have an active prayer.
+	ErrIntercessorUnavailable = errors.New("intercessor is unavailable")
+	// ErrOverBudget is returned by Broadcast when the monthly sms budget is used up.
+	ErrOverBudget = errors.New("monthly sms budget is used up")
+	// ErrNoMember is returned when there is no member with the given phone.
+	ErrNoMember = errors.New("no member")
+	// ErrMemberExists is returned by AddMember when there already is a member with the phone.
+	ErrMemberExists = errors.New("member already exists")
+)
+
+// admin commands follow the admin prefix, for example "#admin stats"
+const (
+	adminBlock     = "block"
+	adminBroadcast = "broadcast"
+	adminHelp      = "help"
+	adminQueue     = "queue"
+	adminRetry     = "retry"
+	adminStats     = "stats"
+	adminStuck     = "stuck"
+)
+
+const (
+	// StuckStateAge is how long a state may stay in progress before it counts as stuck
+	StuckStateAge  = 15 * time.Minute
+	maxStuckStates = 5
+)
+
+// MemberEdit holds the Member fields that EditMember changes. nil fields are left as they are.
+type MemberEdit struct {
+	Intercessor       *bool
+	Language          *string
+	Name              *string
+	PrayerCount       *int
+	WeeklyPrayerLimit *int
+}
+
+// Stats is a snapshot of the numbers of members and prayers of the whole deployment.
+type Stats struct {
+	ActivePrayers    int
+	CompletedPrayers int
+	Intercessors     int
+	Members          int
+	QueuedPrayers    int
+}
+
+// AssignPrayer assigns the queued prayer queueID to the intercessor with intercessorPhone and sends
+// it to them. This skips the weekly prayer limit of the intercessor, but still counts the prayer.
+func AssignPrayer(queueID, intercessorPhone string, cfg config.Config, ddbClnt db.DDBConnecter,
+	smsClnt messaging.TextSender) (object.Prayer, error) {
+	pryr := object.Prayer{IntercessorPhone: queueID}
+	if err := pryr.Get(ddbClnt, cfg.Cipher, true); err != nil {
+		return pryr, fmt.Errorf("AssignPrayer: %w", err)
+	} else if pryr.Request == "" {
+		return pryr, fmt.Errorf("AssignPrayer %v: %w", queueID, ErrNoQueuedPrayer)
+	}
+
+	intr := object.Member{Phone: intercessorPhone}
+	if err := intr.Get(ddbClnt); err != nil {
+		return pryr, fmt.Errorf("AssignPrayer: %w", err)
+	} else if !intr.Intercessor || intr.SetupStatus != "completed" || intr.TenantPhone != pryr.Requestor.TenantPhone {
+		return pryr, fmt.Errorf("AssignPrayer %v: %w", intercessorPhone, ErrIntercessorUnavailable)
+	}
+
+	isActive, err := object.IsPrayerActive(ddbClnt, intr.Phone)
+	if err != nil {
+		return pryr, fmt.Errorf("AssignPrayer: %w", err)
+	} else if isActive {
+		return pryr, fmt.Errorf("AssignPrayer %v: %w", intercessorPhone, ErrIntercessorUnavailable)
+	}
+
+	tnt, err := object.GetTenant(ddbClnt, pryr.Requestor.TenantPhone)
+	if err != nil {
+		return pryr, fmt.Errorf("AssignPrayer: %w", err)
+	}
+
+	tmpls, err := loadTemplates(cfg, tnt)
+	if err != nil {
+		return pryr, fmt.Errorf("AssignPrayer: %w", err)
+	}
+
+	// the prayer is taken out of the queue first, so that a concurrent assignment or queue retry
+	// that read the same prayer does not send it out a second time
+	queued := pryr
+	if dequeued, err := queued.Dequeue(ddbClnt); err != nil {
+		return pryr, fmt.Errorf("AssignPrayer: %w", err)
+	} else if !dequeued {
+		return pryr, fmt.Errorf("AssignPrayer %v: %w", queueID, ErrNoQueuedPrayer)
+	}
+
+	// the intercessor is checked again on the stored Member, in case it changed in a concurrent flow
+	counted, err := intr.Update(ddbClnt, func(m *object.Member) bool {
+		if !m.Intercessor || m.SetupStatus != "completed" || m.TenantPhone != pryr.Requestor.TenantPhone {
+			return false
+		}
+		m.PrayerCount++
+		return true
+	})
+	if err == nil && !counted {
+		err = fmt.Errorf("%v: %w", intercessorPhone, ErrIntercessorUnavailable)
+	}
+	if err != nil {
+		// the prayer goes back to the queue, so that it is not lost
+		if putErr := queued.Put(ddbClnt, cfg.Cipher, true); putErr != nil {
+			err = errors.Join(err, putErr)
+		}
+		return pryr, fmt.Errorf("AssignPrayer: %w", err)
+	}
+
+	pryr.Intercessor, pryr.IntercessorPhone, pryr.QueuedTime = intr, intr.Phone, ""
+	if err := pryr.Put(ddbClnt, cfg.Cipher, false); err != nil {
+		return pryr, fmt.Errorf("AssignPrayer: %w", err)
+	}
+
+	data := map[string]string{"Name": pryr.Requestor.Name, "Request": pryr.Request}
+	if err := intr.SendMessage(smsClnt, tmpls, messaging.MsgPrayerIntro, data); err != nil {
+		return pryr, fmt.Errorf("AssignPrayer: %w", err)
+	}
+
+	return pryr, nil
+}
+
+// RequeuePrayer takes the active prayer away from the intercessor with intercessorPhone and moves
+// it back to the prayer queue, for example when the intercessor stopped responding. No text
+// messages are sent.
+func RequeuePrayer(intercessorPhone string, cfg config.Config, ddbClnt db.DDBConnecter) (object.Prayer, error) {
+	pryr := object.Prayer{IntercessorPhone: intercessorPhone}
+	if err := pryr.Get(ddbClnt, cfg.Cipher, false); err != nil {
+		return pryr, fmt.Errorf("RequeuePrayer: %w", err)
+	} else if pryr.Request == "" {
+		return pryr, fmt.Errorf("RequeuePrayer %v: %w", intercessorPhone, ErrNoActivePrayer)
+	}
+
+	if err := requeuePrayer(pryr, cfg, ddbClnt); err != nil {
+		return pryr, fmt.Errorf("RequeuePrayer: %w", err)
+	}
+
+	return pryr, nil
+}
+
+// AddMember saves mem as a member that completed sign up, without sending any text messages.
+// Intercessors are added to the intercessor phone list of their tenant.
+func AddMember(mem object.Member, ddbClnt db.DDBConnecter) (object.Member, error) {
+	existing := object.Member{Phone: mem.Phone}
+	if err := existing.Get(ddbClnt); err != nil {
+		return mem, fmt.Errorf("AddMember: %w", err)
+	} else if existing.SetupStatus != "" {
+		return mem, fmt.Errorf("AddMember %v: %w", mem.Phone, ErrMemberExists)
+	}
+
+	mem.SetupStatus, mem.SetupStage = "completed", 99
+	if mem.Intercessor {
+		mem.WeeklyPrayerDate = time.Now().Format(time.RFC3339)
+	}
+
+	// the member may have signed up in a concurrent flow since it was checked
+	added, err := existing.Update(ddbClnt, func(m *object.Member) bool {
+		if m.SetupStatus != "" {
+			return false
+		}
+		*m = mem
+		return true
+	})
+	if err != nil {
+		return mem, fmt.Errorf("AddMember: %w", err)
+	} else if !added {
+		return mem, fmt.Errorf("AddMember %v: %w", mem.Phone, ErrMemberExists)
+	}
+	mem = existing
+
+	if mem.Intercessor {
+		if err := updateIntercessorPhones(mem, ddbClnt); err != nil {
+			return mem, fmt.Errorf("AddMember: %w", err)
+		}
+	}
+
+	return mem, nil
+}
+
+// EditMember changes the fields of the member with phone that are set in edit. Changing
+// Intercessor also adds the member to or removes them from the intercessor phone list of their
+// tenant.
+func EditMember(phone string, edit MemberEdit, ddbClnt db.DDBConnecter) (object.Member, error) {
+	mem := object.Member{Phone: phone}
+	if err := mem.Get(ddbClnt); err != nil {
+		return mem, fmt.Errorf("EditMember: %w", err)
+	} else if mem.SetupStatus == "" {
+		return mem, fmt.Errorf("EditMember %v: %w", phone, ErrNoMember)
+	}
+
+	var wasIntercessor bool
+	edited, err := mem.Update(ddbClnt, func(m *object.Member) bool {
+		// members that quit in a concurrent flow are not added back
+		if m.SetupStatus == "" {
+			return false
+		}
+		wasIntercessor = m.Intercessor
+
+		if edit.Intercessor != nil {
+			m.Intercessor = *edit.Intercessor
+		}
+		if edit.Language != nil {
+			m.Language = *edit.Language
+		}
+		if edit.Name != nil {
+			m.Name = *edit.Name
+		}
+		if edit.PrayerCount != nil {
+			m.PrayerCount = *edit.PrayerCount
+		}
+		if edit.WeeklyPrayerLimit != nil {
+			m.WeeklyPrayerLimit = *edit.WeeklyPrayerLimit
+		}
+
+		if m.Intercessor && m.WeeklyPrayerDate == "" {
+			m.WeeklyPrayerDate = time.Now().Format(time.RFC3339)
+		}
+		return true
+	})
+	if err != nil {
+		return mem, fmt.Errorf("EditMember: %w", err)
+	} else if !edited {
+		return mem, fmt.Errorf("EditMember %v: %w", phone, ErrNoMember)
+	}
+
+	if mem.Intercessor != wasIntercessor {
+		if err := updateIntercessorPhones(mem, ddbClnt); err != nil {
+			return mem, fmt.Errorf("EditMember: %w", err)
+		}
+	}
+
+	return mem, nil
+}
+
+// updateIntercessorPhones adds mem to the intercessor phone list of their tenant if they are an
+// intercessor, and removes them from it otherwise.
+func updateIntercessorPhones(mem object.Member, ddbClnt db.DDBConnecter) error {
+	phones := object.TenantIntercessorPhones(mem.TenantPhone)
+	return phones.Update(ddbClnt, func(p *object.IntercessorPhones) {
+		p.RemovePhone(mem.Phone)
+		if mem.Intercessor {
+			p.AddPhone(mem.Phone)
+		}
+	})
+}
+
+// GetStats counts members and prayers. CompletedPrayers are the prayers completed in the month of
+// now. This scans the Members, ActivePrayers and PrayersQueue tables.
+func GetStats(ddbClnt db.DDBConnecter, now time.Time) (Stats, error) {
+	stats := Stats{}
+
+	mems, err := db.ScanDdbObjects[object.Member](ddbClnt, object.MemberTable, db.Condition{})
+	if err != nil {
+		return stats, fmt.Errorf("GetStats: %w", err)
+	}
+	for _, mem := range mems {
+		if mem.SetupStatus != "completed" {
+			continue
+		}
+		stats.Members++
+		if mem.Intercessor {
+			stats.Intercessors++
+		}
+	}
+
+	active, err := db.ScanDdbObjects[object.Prayer](ddbClnt, object.ActivePrayersTable, db.Condition{})
+	if err != nil {
+		return stats, fmt.Errorf("GetStats: %w", err)
+	}
+	stats.ActivePrayers = len(active)
+
+	queued, err := db.ScanDdbObjects[object.Prayer](ddbClnt, object.QueuedPrayersTable, db.Condition{})
+	if err != nil {
+		return stats, fmt.Errorf("GetStats: %w", err)
+	}
+	stats.QueuedPrayers = len(queued)
+
+	completed := object.PrayerStats{Key: object.PrayerStatsKey(now)}
+	if err := completed.Get(ddbClnt); err != nil {
+		return stats, fmt.Errorf("GetStats: %w", err)
+	}
+	stats.CompletedPrayers = completed.Completed
+
+	return stats, nil
+}
+
+// StuckStates returns the states that failed or have been in progress for longer than
+// StuckStateAge at now, oldest first.
+func StuckStates(ddbClnt db.DDBConnecter, now time.Time) ([]object.State, error) {
+	st := object.StateTracker{}
+	if err := st.Get(ddbClnt); err != nil {
+		return nil, fmt.Errorf("StuckStates: %w", err)
+	}
+
+	var stuck []object.State
+	for _, state := range st.States {
+		started, err := time.Parse(time.RFC3339, state.TimeStart)
+		if state.Status == "FAILED" || err != nil || now.Sub(started) > StuckStateAge {
+			stuck = append(stuck, state)
+		}
+	}
+
+	slices.SortFunc(stuck, func(a, b object.State) int { return strings.Compare(a.TimeStart, b.TimeStart) })
+	return stuck, nil
+}
+
+// Broadcast sends message to every intercessor of the Tenant with tenantPhone and returns the
+// number of intercessors it was sent to. Broadcasts are non-essential sends, so they fail with
+// ErrOverBudget once the monthly sms budget is used up.
+func Broadcast(tenantPhone, message string, cfg config.Config, ddbClnt db.DDBConnecter,
+	smsClnt messaging.TextSender) (int, error) {
+	overBudget, err := object.IsOverBudget(ddbClnt, cfg.MonthlySegmentBudget, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("Broadcast: %w", err)
+	} else if overBudget {
+		return 0, fmt.Errorf("Broadcast: %w", ErrOverBudget)
+	}
+
+	tnt, err := object.GetTenant(ddbClnt, tenantPhone)
+	if err != nil {
+		return 0, fmt.Errorf("Broadcast: %w", err)
+	}
+
+	tmpls, err := loadTemplates(cfg, tnt)
+	if err != nil {
+		return 0, fmt.Errorf("Broadcast: %w", err)
+	}
+
+	phones := object.TenantIntercessorPhones(tenantPhone)
+	if err := phones.Get(ddbClnt); err != nil {
+		return 0, fmt.Errorf("Broadcast: %w", err)
+	}
+
+	for i, phone := range phones.Phones {
+		msg := messaging.TextMessage{Body: message, Phone: phone, TenantPhone: tenantPhone}
+		if err := messaging.SendText(smsClnt, tmpls, msg); err != nil {
+			return i, fmt.Errorf("Broadcast: %w", err)
+		}
+	}
+
+	return len(phones.Phones), nil
+}
+
+// RetryQueue tries to send every queued prayer to available intercessors. It returns the number
+// of prayers that were sent out and the number that are still queued. Prayers that a concurrent
+// flow took out of the queue in the meantime are skipped and not counted.
+func RetryQueue(cfg config.Config, ddbClnt db.DDBConnecter, smsClnt messaging.TextSender) (int, int, error) {
+	queued, err := object.GetPrayers(ddbClnt, cfg.Cipher, true)
+	if err != nil {
+		return 0, 0, fmt.Errorf("RetryQueue: %w", err)
+	}
+
+	sent, taken := 0, 0
+	for _, pryr := range queued {
+		tnt, err := object.GetTenant(ddbClnt, pryr.Requestor.TenantPhone)
+		if err != nil {
+			return sent, len(queued) - sent - taken, fmt.Errorf("RetryQueue: %w", err)
+		}
+
+		tmpls, err := loadTemplates(cfg, tnt)
+		if err != nil {
+			return sent, len(queued) - sent - taken, fmt.Errorf("RetryQueue: %w", err)
+		}
+
+		intercessors, err := FindIntercessors(ddbClnt, tnt, pryr.Requestor.Phone)
+		if err != nil {
+			return sent, len(queued) - sent - taken, fmt.Errorf("RetryQueue: %w", err)
+		} else if intercessors == nil {
+			continue
+		}
+
+		// the prayer is taken out of the queue before it is sent, so that a concurrent retry or
+		// assignment that read the same prayer does not send it out a second time. The prayers
+		// that were counted for the intercessors are not given back if that happened
+		dequeued, err := pryr.Dequeue(ddbClnt)
+		if err != nil {
+			return sent, len(queued) - sent - taken, fmt.Errorf("RetryQueue: %w", err)
+		} else if !dequeued {
+			slog.Warn("queued prayer was taken by a concurrent flow, skipping", "queueID", pryr.IntercessorPhone)
+			taken++
+			continue
+		}
+
+		if err := deliverPrayer(pryr.Request, pryr.Requestor, intercessors, cfg, ddbClnt, smsClnt,
+			tmpls); err != nil {
+			// the prayer goes back to the queue, so that it is not lost
+			if putErr := pryr.Put(ddbClnt, cfg.Cipher, true); putErr != nil {
+				err = errors.Join(err, putErr)
+			}
+			return sent, len(queued) - sent - taken, fmt.Errorf("RetryQueue: %w", err)
+		}
+		sent++
+	}
+
+	return sent, len(queued) - sent - taken, nil
+}
+
+// adminCommand returns the admin command and its argument if msg starts with the admin prefix and
+// is sent by one of the admins. Unknown commands return adminHelp. Otherwise command is empty.
+func adminCommand(msg messaging.TextMessage, cfg config.Config) (string, string) {
+	if !slices.Contains(cfg.Admins, msg.Phone) {
+		return "", ""
+	}
+
+	body, prefix := strings.TrimSpace(msg.Body), cfg.GetAdminPrefix()
+	if len(body) < len(prefix) || !strings.EqualFold(body[:len(prefix)], prefix) {
+		return "", ""
+	}
+
+	// the prefix has to be a word of its own, so that "#administrator" is not a command
+	rest := body[len(prefix):]
+	if rest != "" && !unicode.IsSpace(rune(rest[0])) {
+		return "", ""
+	}
+
+	rest = strings.TrimSpace(rest)
+	command, arg := rest, ""
+	if i := strings.IndexFunc(rest, unicode.IsSpace); i >= 0 {
+		command, arg = rest[:i], strings.TrimSpace(rest[i:])
+	}
+
+	command = strings.ToLower(command)
+	switch command {
+	case adminStats, adminQueue, adminStuck, adminRetry:
+		return command, ""
+	case adminBroadcast, adminBlock:
+		if arg != "" {
+			return command, arg
+		}
+	}
+
+	return adminHelp, ""
+}
+
+// administer runs the admin command and replies to mem with a summary of the result.
+func administer(mem object.Member, command, arg string, tnt object.Tenant, cfg config.Config,
+	ddbClnt db.DDBConnecter, smsClnt messaging.TextSender, tmpls *messaging.Templates) error {
+	switch command {
+	case adminStats:
+		stats, err := GetStats(ddbClnt, time.Now())
+		if err != nil {
+			return err
+		}
+
+		data := map[string]string{
+			"Active":       strconv.Itoa(stats.ActivePrayers),
+			"Completed":    strconv.Itoa(stats.CompletedPrayers),
+			"Intercessors": strconv.Itoa(stats.Intercessors),
+			"Members":      strconv.Itoa(stats.Members),
+			"Queued":       strconv.Itoa(stats.QueuedPrayers),
+		}
+		return mem.SendMessage(smsClnt, tmpls, messaging.MsgAdminStats, data)
+	case adminQueue:
+		queued, err := db.ScanDdbObjects[object.Prayer](ddbClnt, object.QueuedPrayersTable, db.Condition{})
+		if err != nil {
+			return err
+		}
+
+		data := map[string]string{"Queued": strconv.Itoa(len(queued))}
+		return mem.SendMessage(smsClnt, tmpls, messaging.MsgAdminQueue, data)
+	case adminStuck:
+		stuck, err := StuckStates(ddbClnt, time.Now())
+		if err != nil {
+			return err
+		}
+
+		var lines []string
+		for _, state := range stuck[:min(len(stuck), maxStuckStates)] {
+			lines = append(lines, fmt.Sprintf("%v %v %v %v", state.ID, state.Stage, state.Status, state.TimeStart))
+		}
+
+		data := map[string]string{"Count": strconv.Itoa(len(stuck)), "States": strings.Join(lines, "\n")}
+		return mem.SendMessage(smsClnt, tmpls, messaging.MsgAdminStuck, data)
+	case adminBroadcast:
+		count, err := Broadcast(tnt.Phone, arg, cfg, ddbClnt, smsClnt)
+		if errors.Is(err, ErrOverBudget) {
+			slog.Warn("monthly sms budget exceeded, broadcast is suspended", "budget", cfg.MonthlySegmentBudget)
+			return mem.SendMessage(smsClnt, tmpls, messaging.MsgAdminOverBudget, nil)
+		} else if err != nil {
+			return err
+		}
+
+		data := map[string]string{"Count": strconv.Itoa(count)}
+		return mem.SendMessage(smsClnt, tmpls, messaging.MsgAdminBroadcast, data)
+	case adminBlock:
+		// phones are blocked the way members text from, so "+1 (123) 456-7890" blocks +11234567890
+		phone, err := messaging.NormalizePhone(arg)
+		if err != nil {
+			return mem.SendMessage(smsClnt, tmpls, messaging.MsgInvalidPhone, map[string]string{"Phone": arg})
+		}
+
+		blocked := object.BlockedPhones{}
+		if err := blocked.Update(ddbClnt, func(b *object.BlockedPhones) { b.AddPhone(phone) }); err != nil {
+			return err
+		}
+
+		return mem.SendMessage(smsClnt, tmpls, messaging.MsgPhoneBlocked, map[string]string{"Phone": phone})
+	case adminRetry:
+		sent, queued, err := RetryQueue(cfg, ddbClnt, smsClnt)
+		if err != nil {
+			return err
+		}
+
+		data := map[string]string{"Queued": strconv.Itoa(queued), "Sent": strconv.Itoa(sent)}
+		return mem.SendMessage(smsClnt, tmpls, messaging.MsgAdminRetry, data)
+	default:
+		data := map[string]string{"Prefix": cfg.GetAdminPrefix()}
+		return mem.SendMessage(smsClnt, tmpls, messaging.MsgAdminHelp, data)
+	}
+}
@@ -0,0 +1,206 @@
+package prayertexter
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/mshort55/prayertexter/internal/config"
+	"github.com/mshort55/prayertexter/internal/db"
+	"github.com/mshort55/prayertexter/internal/object"
+	"github.com/mshort55/prayertexter/internal/privacy"
+)
+
+// MemberData is everything that is stored about a phone number. Prayers are included when the
+// phone number is either the requestor or the intercessor. Usage only holds the counters of the
+// phone number.
+type MemberData struct {
+	ActivePrayers   []object.Prayer
+	ArchivedPrayers []object.ArchivedPrayer
+	Blocked         bool
+	InboundMessages []object.InboundMessage
+	Member          object.Member
+	PendingPrayers  []object.PendingPrayer
+	Phone           string
+	QueuedPrayers   []object.Prayer
+	RateLimiter     object.RateLimiter
+	States          []object.State
+	Usage           []object.Usage
+}
+
+// ExportMemberData collects all data stored about phone, for example to answer a privacy request.
+// Prayers are found by scanning their tables, so this should only be used for admin operations.
+func ExportMemberData(phone string, cfg config.Config, ddbClnt db.DDBConnecter) (MemberData, error) {
+	data := MemberData{Phone: phone}
+
+	mem := object.Member{Phone: phone}
+	if err := mem.Get(ddbClnt); err != nil {
+		return data, fmt.Errorf("ExportMemberData: %w", err)
+	}
+	if mem.SetupStatus != "" {
+		data.Member = mem
+	}
+
+	var err error
+	if data.ActivePrayers, err = object.GetMemberPrayers(ddbClnt, cfg.Cipher, phone, false); err != nil {
+		return data, fmt.Errorf("ExportMemberData: %w", err)
+	}
+
+	if data.QueuedPrayers, err = object.GetMemberPrayers(ddbClnt, cfg.Cipher, phone, true); err != nil {
+		return data, fmt.Errorf("ExportMemberData: %w", err)
+	}
+
+	if data.PendingPrayers, err = object.GetMemberPendingPrayers(ddbClnt, cfg.Cipher, phone); err != nil {
+		return data, fmt.Errorf("ExportMemberData: %w", err)
+	}
+
+	data.ArchivedPrayers, err = object.GetMemberArchivedPrayers(ddbClnt, cfg.Cipher, cfg.PhoneHashKey, phone)
+	if err != nil {
+		return data, fmt.Errorf("ExportMemberData: %w", err)
+	}
+
+	if data.InboundMessages, err = object.GetMemberInboundMessages(ddbClnt, phone); err != nil {
+		return data, fmt.Errorf("ExportMemberData: %w", err)
+	}
+
+	st := object.StateTracker{}
+	if err := st.Get(ddbClnt); err != nil {
+		return data, fmt.Errorf("ExportMemberData: %w", err)
+	}
+	for _, state := range st.States {
+		if isStateOf(state, phone, cfg, data.InboundMessages) {
+			data.States = append(data.States, state)
+		}
+	}
+
+	// every stored RateLimiter has an expiration time
+	rl := object.PhoneRateLimiter(phone)
+	if err := rl.Get(ddbClnt); err != nil {
+		return data, fmt.Errorf("ExportMemberData: %w", err)
+	}
+	if rl.ExpirationTime != 0 {
+		data.RateLimiter = rl
+	}
+
+	if data.Usage, err = object.GetMemberUsage(ddbClnt, phone); err != nil {
+		return data, fmt.Errorf("ExportMemberData: %w", err)
+	}
+
+	if data.Blocked, err = object.IsPhoneBlocked(ddbClnt, phone); err != nil {
+		return data, fmt.Errorf("ExportMemberData: %w", err)
+	}
+
+	return data, nil
+}
+
+// EraseMemberData removes all data stored about phone and returns what was removed. Prayers that
+// phone requested are deleted. Active prayers where phone is the intercessor go back to the prayer
+// queue, and archived prayers where phone is the intercessor are kept without the intercessor.
+// Usage totals keep counting the text messages of phone, but without its counters. phone is also
+// removed from the block list, so it has to be blocked again if it keeps sending abuse. No text
+// messages are sent.
+func EraseMemberData(phone string, cfg config.Config, ddbClnt db.DDBConnecter) (MemberData, error) {
+	data, err := ExportMemberData(phone, cfg, ddbClnt)
+	if err != nil {
+		return data, fmt.Errorf("EraseMemberData: %w", err)
+	}
+
+	if data.Member.Phone != "" {
+		if err := data.Member.Delete(ddbClnt); err != nil {
+			return data, fmt.Errorf("EraseMemberData: %w", err)
+		}
+	}
+
+	// the phone is removed from the intercessor lists of every tenant, since the member and with it
+	// their tenant may be gone already if an earlier removal did not finish
+	if err := object.RemoveIntercessorPhone(ddbClnt, phone); err != nil {
+		return data, fmt.Errorf("EraseMemberData: %w", err)
+	}
+
+	for _, pryr := range data.ActivePrayers {
+		if pryr.Requestor.Phone == phone {
+			err = pryr.Delete(ddbClnt, false)
+		} else {
+			err = requeuePrayer(pryr, cfg, ddbClnt)
+		}
+		if err != nil {
+			return data, fmt.Errorf("EraseMemberData: %w", err)
+		}
+	}
+
+	for _, pryr := range data.QueuedPrayers {
+		if err := pryr.Delete(ddbClnt, true); err != nil {
+			return data, fmt.Errorf("EraseMemberData: %w", err)
+		}
+	}
+
+	for _, pryr := range data.PendingPrayers {
+		if err := pryr.Delete(ddbClnt); err != nil {
+			return data, fmt.Errorf("EraseMemberData: %w", err)
+		}
+	}
+
+	hash := object.PhoneHash(cfg.PhoneHashKey, phone)
+	for _, arch := range data.ArchivedPrayers {
+		if arch.RequestorHash == hash {
+			err = arch.Delete(ddbClnt)
+		} else {
+			arch.IntercessorHash = ""
+			err = arch.Put(ddbClnt, cfg.Cipher)
+		}
+		if err != nil {
+			return data, fmt.Errorf("EraseMemberData: %w", err)
+		}
+	}
+
+	for _, state := range data.States {
+		if err := state.Update(ddbClnt, true); err != nil {
+			return data, fmt.Errorf("EraseMemberData: %w", err)
+		}
+	}
+
+	if data.RateLimiter.Key != "" {
+		if err := data.RateLimiter.Delete(ddbClnt); err != nil {
+			return data, fmt.Errorf("EraseMemberData: %w", err)
+		}
+	}
+
+	for _, inbound := range data.InboundMessages {
+		if err := inbound.Delete(ddbClnt); err != nil {
+			return data, fmt.Errorf("EraseMemberData: %w", err)
+		}
+	}
+
+	for _, usg := range data.Usage {
+		if err := usg.RemoveMember(ddbClnt, phone); err != nil {
+			return data, fmt.Errorf("EraseMemberData: %w", err)
+		}
+	}
+
+	if data.Blocked {
+		blocked := object.BlockedPhones{}
+		if err := blocked.Update(ddbClnt, func(b *object.BlockedPhones) { b.RemovePhone(phone) }); err != nil {
+			return data, fmt.Errorf("EraseMemberData: %w", err)
+		}
+	}
+
+	return data, nil
+}
+
+// isStateOf returns true if state holds a message from phone. States saved with privacy off hold
+// the phone itself. Other states are found by their PhoneHash or their strict hash, both keyed
+// with the PhoneHashKey, or by the message ID of an inbound message of phone when there is no
+// PhoneHashKey. The masked phone alone is never used, since it is shared by other phones.
+func isStateOf(state object.State, phone string, cfg config.Config, inbound []object.InboundMessage) bool {
+	if state.Message.Phone == phone {
+		return true
+	}
+
+	if hash := object.PhoneHash(cfg.PhoneHashKey, phone); hash != "" && (state.PhoneHash == hash ||
+		state.Message.Phone == privacy.Phone(phone, privacy.LevelStrict, cfg.PhoneHashKey)) {
+		return true
+	}
+
+	return state.Message.ID != "" && slices.ContainsFunc(inbound, func(msg object.InboundMessage) bool {
+		return msg.Key == object.InboundMessageKeyPrefix+state.Message.ID
+	})
+}
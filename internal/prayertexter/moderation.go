@@ -0,0 +1,180 @@
+package prayertexter
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mshort55/prayertexter/internal/config"
+	"github.com/mshort55/prayertexter/internal/db"
+	"github.com/mshort55/prayertexter/internal/messaging"
+	"github.com/mshort55/prayertexter/internal/object"
+	"github.com/mshort55/prayertexter/internal/utility"
+)
+
+// ErrNoPendingPrayer is returned by ModeratePrayer if there is no pending prayer request with
+// the given ID. This includes prayer requests that were already moderated.
+var ErrNoPendingPrayer = errors.New("no pending prayer request")
+
+// holdPrayer saves the prayer request in msg as a PendingPrayer and asks the moderators of tnt to
+// approve it.
+func holdPrayer(msg messaging.TextMessage, mem object.Member, tnt object.Tenant, cfg config.Config,
+	ddbClnt db.DDBConnecter, smsClnt messaging.TextSender, tmpls *messaging.Templates) error {
+	id, err := utility.GenerateID()
+	if err != nil {
+		return err
+	}
+
+	pryr := object.PendingPrayer{
+		Request:      msg.Body,
+		Requestor:    mem,
+		TimeReceived: time.Now().Format(time.RFC3339),
+	}
+	if err := pryr.Create(ddbClnt, cfg.Cipher, id); err != nil {
+		return err
+	}
+
+	data := map[string]string{"ID": pryr.ID, "Name": mem.Name, "Request": pryr.Request}
+	for _, phone := range tnt.GetModerators(cfg.Moderators) {
+		moderator := object.Member{Phone: phone, TenantPhone: mem.TenantPhone}
+		if err := moderator.SendMessage(smsClnt, tmpls, messaging.MsgModerationRequest, data); err != nil {
+			return err
+		}
+	}
+
+	if err := mem.SendMessage(smsClnt, tmpls, messaging.MsgPrayerPending, nil); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ModeratePrayer approves or rejects the pending prayer request id on behalf of moderator.
+// Approved prayer requests are sent out to intercessors, and the requestor of a rejected prayer
+// request is told that it will not be sent out. The PendingPrayer is kept as a record of the
+// decision. Only prayer requests of members of the tenant tenantPhone can be moderated, so that
+// moderators of one tenant can not see or decide the prayer requests of another.
+func ModeratePrayer(id string, approve bool, moderator, tenantPhone string, cfg config.Config,
+	ddbClnt db.DDBConnecter, smsClnt messaging.TextSender) (object.PendingPrayer, error) {
+	pryr := object.PendingPrayer{ID: id}
+	if err := pryr.Get(ddbClnt, cfg.Cipher); err != nil {
+		return pryr, fmt.Errorf("ModeratePrayer: %w", err)
+	}
+
+	if pryr.Status != object.PrayerPending || pryr.Requestor.TenantPhone != tenantPhone {
+		return object.PendingPrayer{ID: id}, fmt.Errorf("ModeratePrayer %v: %w", id, ErrNoPendingPrayer)
+	}
+
+	// the decision is saved before anything is sent, and only if the prayer request is still
+	// pending, so that neither a retry nor a second moderator can send the prayer request out twice
+	pryr.Status = object.PrayerRejected
+	if approve {
+		pryr.Status = object.PrayerApproved
+	}
+	pryr.ModeratedBy, pryr.ModeratedTime = moderator, time.Now().Format(time.RFC3339)
+	decided, err := pryr.Decide(ddbClnt, cfg.Cipher)
+	if err != nil {
+		return pryr, fmt.Errorf("ModeratePrayer: %w", err)
+	} else if !decided {
+		return pryr, fmt.Errorf("ModeratePrayer %v already decided: %w", id, ErrNoPendingPrayer)
+	}
+
+	tnt, err := object.GetTenant(ddbClnt, pryr.Requestor.TenantPhone)
+	if err != nil {
+		return pryr, fmt.Errorf("ModeratePrayer: %w", err)
+	}
+
+	tmpls, err := loadTemplates(cfg, tnt)
+	if err != nil {
+		return pryr, fmt.Errorf("ModeratePrayer: %w", err)
+	}
+
+	if !approve {
+		if err := pryr.Requestor.SendMessage(smsClnt, tmpls, messaging.MsgPrayerRejected, nil); err != nil {
+			return pryr, fmt.Errorf("ModeratePrayer: %w", err)
+		}
+
+		return pryr, nil
+	}
+
+	msg := messaging.TextMessage{Body: pryr.Request, Phone: pryr.Requestor.Phone}
+	if err := sendPrayer(msg, pryr.Requestor, tnt, cfg, ddbClnt, smsClnt, tmpls); err != nil {
+		return pryr, fmt.Errorf("ModeratePrayer: %w", err)
+	}
+
+	return pryr, nil
+}
+
+// moderatorCommand returns the moderator keyword and its argument if msg is a moderator command
+// like "approve 1a2b3c4d" sent by one of the moderators of tnt, which is the tenant they texted.
+// Otherwise keyword is empty.
+func moderatorCommand(msg messaging.TextMessage, tnt object.Tenant, cfg config.Config,
+	tmpls *messaging.Templates) (string, string) {
+	if !slices.Contains(tnt.GetModerators(cfg.Moderators), msg.Phone) {
+		return "", ""
+	}
+
+	fields := strings.Fields(msg.Body)
+	if len(fields) != 2 {
+		return "", ""
+	}
+
+	keyword, _ := tmpls.MatchKeyword(fields[0])
+	switch keyword {
+	case messaging.KeywordApprove, messaging.KeywordReject, messaging.KeywordBlock, messaging.KeywordUnblock:
+		return keyword, fields[1]
+	default:
+		return "", ""
+	}
+}
+
+func moderate(mem object.Member, keyword, arg string, tnt object.Tenant, cfg config.Config,
+	ddbClnt db.DDBConnecter, smsClnt messaging.TextSender, tmpls *messaging.Templates) error {
+	switch keyword {
+	case messaging.KeywordApprove, messaging.KeywordReject:
+		approve := keyword == messaging.KeywordApprove
+		data := map[string]string{"ID": arg}
+
+		_, err := ModeratePrayer(arg, approve, mem.Phone, tnt.Phone, cfg, ddbClnt, smsClnt)
+		if errors.Is(err, ErrNoPendingPrayer) {
+			return mem.SendMessage(smsClnt, tmpls, messaging.MsgModerationNotFound, data)
+		} else if err != nil {
+			return err
+		}
+
+		reply := messaging.MsgModerationRejected
+		if approve {
+			reply = messaging.MsgModerationApproved
+		}
+
+		return mem.SendMessage(smsClnt, tmpls, reply, data)
+	case messaging.KeywordBlock, messaging.KeywordUnblock:
+		reply := messaging.MsgPhoneUnblocked
+		if keyword == messaging.KeywordBlock {
+			reply = messaging.MsgPhoneBlocked
+		}
+
+		phone, err := messaging.NormalizePhone(arg)
+		if err != nil {
+			return mem.SendMessage(smsClnt, tmpls, messaging.MsgInvalidPhone, map[string]string{"Phone": arg})
+		}
+
+		blocked := object.BlockedPhones{}
+		err = blocked.Update(ddbClnt, func(b *object.BlockedPhones) {
+			if keyword == messaging.KeywordBlock {
+				b.AddPhone(phone)
+			} else {
+				b.RemovePhone(phone)
+			}
+		})
+		if err != nil {
+			return err
+		}
+
+		return mem.SendMessage(smsClnt, tmpls, reply, map[string]string{"Phone": phone})
+	default:
+		return fmt.Errorf("moderate: unknown keyword %v", keyword)
+	}
+}
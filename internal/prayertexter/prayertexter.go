@@ -7,13 +7,16 @@ import (
 	"strings"
 	"time"
 
+	"github.com/mshort55/prayertexter/internal/config"
 	"github.com/mshort55/prayertexter/internal/db"
+	"github.com/mshort55/prayertexter/internal/filter"
 	"github.com/mshort55/prayertexter/internal/messaging"
 	"github.com/mshort55/prayertexter/internal/object"
+	"github.com/mshort55/prayertexter/internal/privacy"
 	"github.com/mshort55/prayertexter/internal/utility"
 )
 
-func MainFlow(msg messaging.TextMessage, ddbClnt db.DDBConnecter, smsClnt messaging.TextSender) error {
+func MainFlow(msg messaging.TextMessage, cfg config.Config, ddbClnt db.DDBConnecter, smsClnt messaging.TextSender) error {
 	currTime := time.Now().Format(time.RFC3339)
 	id, err := utility.GenerateID()
 	if err != nil {
@@ -22,28 +25,99 @@ func MainFlow(msg messaging.TextMessage, ddbClnt db.DDBConnecter, smsClnt messag
 	}
 
 	state := object.State{}
-	state.Status, state.TimeStart, state.ID, state.Message = "IN PROGRESS", currTime, id, msg
+	// states are kept in ddb until they are resolved, so the message is redacted like it is in logs
+	state.Status, state.TimeStart, state.ID = "IN PROGRESS", currTime, id
+	state.Message = privacy.Message(msg, cfg.Privacy, cfg.PhoneHashKey)
+	state.PhoneHash = object.PhoneHash(cfg.PhoneHashKey, msg.Phone)
 	if err := state.Update(ddbClnt, false); err != nil {
 		slog.Error("failure during pre-flow stages", "error", err)
 		return err
 	}
 
+	// every text message sent during this flow is counted under the final flow stage, including
+	// flows that fail part of the way through
+	if cfg.TrackUsage {
+		rec := &messaging.RecordingSender{TextSender: smsClnt}
+		smsClnt = rec
+		defer recordUsage(ddbClnt, &state, rec)
+	}
+
 	mem := object.Member{Phone: msg.Phone}
 	if err := mem.Get(ddbClnt); err != nil {
 		slog.Error("failure during pre-flow stages", "error", err)
 		return err
 	}
 
-	// HELP FLOW
-	// this responds with contact info and is a requirement to get sent to to anyone regardless
-	// whether they are a member or not
-	if strings.ToLower(msg.Body) == "help" {
+	// messages are routed to a Tenant by the PrayerTexter number that the member texted
+	tnt, err := object.GetTenant(ddbClnt, msg.TenantPhone)
+	if err != nil {
+		slog.Error("failure during pre-flow stages", "error", err)
+		return err
+	}
+
+	tmpls, err := loadTemplates(cfg, tnt)
+	if err != nil {
+		slog.Error("failure during pre-flow stages", "error", err)
+		return err
+	}
+
+	// non members are treated as part of the Tenant they texted so that sign up and any replies
+	// are scoped to that Tenant
+	if mem.SetupStatus == "" {
+		mem.TenantPhone = tnt.Phone
+	}
+
+	// keywords are recognized in every language. locale is the language of the keyword that was
+	// texted in
+	keyword, locale := tmpls.MatchKeyword(msg.Body)
+	modKeyword, modArg := moderatorCommand(msg, tnt, cfg, tmpls)
+	adminCmd, adminArg := adminCommand(msg, cfg)
+
+	limit, notify, err := rateLimit(mem, keyword, cfg, ddbClnt)
+	if err != nil {
+		slog.Error("failure during pre-flow stages", "error", err)
+		return err
+	}
+
+	// RATE LIMIT FLOW
+	// this drops messages of phones that send too many messages or are temporarily blocked for
+	// doing so. The throttle reply is only sent for the first dropped message in a row
+	if limit != object.RateLimitAllowed {
+		state.Stage = "RATE LIMIT"
+		if err := state.Update(ddbClnt, false); err != nil {
+			slog.Error("failure during rate limit flow", "error", err)
+			return err
+		}
+
+		slog.Warn("phone is rate limited, dropping message", "member", mem.Phone, "limit", limit)
+
+		if notify {
+			if err1 := mem.SendMessage(smsClnt, tmpls, messaging.MsgRateLimited, nil); err1 != nil {
+				state.Error = err1.Error()
+				state.Status = "FAILED"
+				if err2 := state.Update(ddbClnt, false); err2 != nil {
+					slog.Error("failure during rate limit flow", "error", err)
+					return err2
+				}
+
+				slog.Error("failure during rate limit flow", "error", err)
+				return err1
+			}
+		}
+
+		// HELP FLOW
+		// this responds with contact info and is a requirement to get sent to to anyone regardless
+		// whether they are a member or not
+	} else if keyword == messaging.KeywordHelp {
 		state.Stage = "HELP"
 		if err := state.Update(ddbClnt, false); err != nil {
 			slog.Error("failure during help flow", "error", err)
 			return err
 		}
-		if err1 := mem.SendMessage(smsClnt, messaging.MsgHelp); err1 != nil {
+		// help always comes from the Tenant that was texted, even if the member belongs to a
+		// different Tenant. It is sent in the language of the help keyword that was used
+		recipient := object.Member{Language: locale, Phone: mem.Phone, TenantPhone: tnt.Phone}
+		if err1 := recipient.SendMessage(smsClnt, tmpls, messaging.MsgHelp, nil); err1 != nil {
 			state.Error = err1.Error()
 			state.Status = "FAILED"
 			if err2 := state.Update(ddbClnt, false); err2 != nil {
@@ -55,15 +129,70 @@ func MainFlow(msg messaging.TextMessage, ddbClnt db.DDBConnecter, smsClnt messag
 			return err1
 		}
 
+		// ADMIN FLOW
+		// this is for admins running commands by text message, such as stats or retrying the
+		// prayer queue. Admins do not need to be members
+	} else if adminCmd != "" {
+		state.Stage = "ADMIN"
+		if err := state.Update(ddbClnt, false); err != nil {
+			slog.Error("failure during admin flow", "error", err)
+			return err
+		}
+		if err1 := administer(mem, adminCmd, adminArg, tnt, cfg, ddbClnt, smsClnt, tmpls); err1 != nil {
+			state.Error = err1.Error()
+			state.Status = "FAILED"
+			if err2 := state.Update(ddbClnt, false); err2 != nil {
+				slog.Error("failure during admin flow", "error", err)
+				return err2
+			}
+
+			slog.Error("failure during admin flow", "error", err)
+			return err1
+		}
+
+		// MODERATION FLOW
+		// this is for moderators approving or rejecting held prayer requests and blocking or
+		// unblocking phones. Moderators do not need to be members
+	} else if modKeyword != "" {
+		state.Stage = "MODERATION"
+		if err := state.Update(ddbClnt, false); err != nil {
+			slog.Error("failure during moderation flow", "error", err)
+			return err
+		}
+		if err1 := moderate(mem, modKeyword, modArg, tnt, cfg, ddbClnt, smsClnt, tmpls); err1 != nil {
+			state.Error = err1.Error()
+			state.Status = "FAILED"
+			if err2 := state.Update(ddbClnt, false); err2 != nil {
+				slog.Error("failure during moderation flow", "error", err)
+				return err2
+			}
+
+			slog.Error("failure during moderation flow", "error", err)
+			return err1
+		}
+
+		// TENANT MISMATCH FLOW
+		// members belong to a single Tenant. This drops messages that members send to a different
+		// Tenant's number so they cannot affect that Tenant's members or prayers
+	} else if mem.TenantPhone != tnt.Phone {
+		state.Stage = "DROP MESSAGE"
+		if err := state.Update(ddbClnt, false); err != nil {
+			slog.Error("failure during tenant mismatch flow", "error", err)
+			return err
+		}
+
+		slog.Warn("member belongs to a different tenant, dropping message", "member", mem.Phone,
+			"memberTenant", mem.TenantPhone, "tenant", tnt.Phone)
+
 		// CANCEL FLOW
 		// this removes member from database
-	} else if strings.ToLower(msg.Body) == "cancel" || strings.ToLower(msg.Body) == "stop" {
+	} else if keyword == messaging.KeywordStop {
 		state.Stage = "MEMBER DELETE"
 		if err := state.Update(ddbClnt, false); err != nil {
 			slog.Error("failure during cancel flow", "error", err)
 			return err
 		}
-		if err1 := memberDelete(mem, ddbClnt, smsClnt); err1 != nil {
+		if err1 := memberDelete(mem, cfg, ddbClnt, smsClnt, tmpls); err1 != nil {
 			state.Error = err1.Error()
 			state.Status = "FAILED"
 			if err2 := state.Update(ddbClnt, false); err2 != nil {
@@ -75,15 +204,36 @@ func MainFlow(msg messaging.TextMessage, ddbClnt db.DDBConnecter, smsClnt messag
 			return err1
 		}
 
+		// LANGUAGE FLOW
+		// this changes the language of all messages that a member receives to the language of the
+		// keyword that was texted in. This works during sign up as well
+	} else if keyword == messaging.KeywordLanguage && mem.SetupStatus != "" {
+		state.Stage = "LANGUAGE"
+		if err := state.Update(ddbClnt, false); err != nil {
+			slog.Error("failure during language flow", "error", err)
+			return err
+		}
+		if err1 := changeLanguage(mem, locale, ddbClnt, smsClnt, tmpls); err1 != nil {
+			state.Error = err1.Error()
+			state.Status = "FAILED"
+			if err2 := state.Update(ddbClnt, false); err2 != nil {
+				slog.Error("failure during language flow", "error", err)
+				return err2
+			}
+
+			slog.Error("failure during language flow", "error", err)
+			return err1
+		}
+
 		// SIGN UP FLOW
 		// this is the initial sign up process
-	} else if strings.ToLower(msg.Body) == "pray" || mem.SetupStatus == "in-progress" {
+	} else if keyword == messaging.KeywordPray || mem.SetupStatus == "in-progress" {
 		state.Stage = "SIGN UP"
 		if err := state.Update(ddbClnt, false); err != nil {
 			slog.Error("failure during sign up flow", "error", err)
 			return err
 		}
-		if err1 := signUp(msg, mem, ddbClnt, smsClnt); err1 != nil {
+		if err1 := signUp(msg, keyword, locale, mem, ddbClnt, smsClnt, tmpls); err1 != nil {
 			state.Error = err1.Error()
 			state.Status = "FAILED"
 			if err2 := state.Update(ddbClnt, false); err2 != nil {
@@ -110,13 +260,13 @@ func MainFlow(msg messaging.TextMessage, ddbClnt db.DDBConnecter, smsClnt messag
 		// PRAYER CONFIRMATION FLOW
 		// this is when intercessors pray for a prayer request and send back the confirmation that
 		// they prayed. This will let the prayer requestor know that their prayer was prayed for
-	} else if strings.ToLower(msg.Body) == "prayed" {
+	} else if keyword == messaging.KeywordPrayed {
 		state.Stage = "COMPLETE PRAYER"
 		if err := state.Update(ddbClnt, false); err != nil {
 			slog.Error("failure during prayer confirmation flow", "error", err)
 			return err
 		}
-		if err1 := completePrayer(mem, ddbClnt, smsClnt); err1 != nil {
+		if err1 := completePrayer(mem, cfg, ddbClnt, smsClnt, tmpls); err1 != nil {
 			state.Error = err1.Error()
 			state.Status = "FAILED"
 			if err2 := state.Update(ddbClnt, false); err2 != nil {
@@ -136,7 +286,7 @@ func MainFlow(msg messaging.TextMessage, ddbClnt db.DDBConnecter, smsClnt messag
 			slog.Error("failure during prayer request flow", "error", err)
 			return err
 		}
-		if err1 := prayerRequest(msg, mem, ddbClnt, smsClnt); err1 != nil {
+		if err1 := prayerRequest(msg, mem, tnt, cfg, ddbClnt, smsClnt, tmpls); err1 != nil {
 			state.Error = err1.Error()
 			state.Status = "FAILED"
 			if err2 := state.Update(ddbClnt, false); err2 != nil {
@@ -157,34 +307,37 @@ func MainFlow(msg messaging.TextMessage, ddbClnt db.DDBConnecter, smsClnt messag
 	return nil
 }
 
-func signUp(msg messaging.TextMessage, mem object.Member, ddbClnt db.DDBConnecter, smsClnt messaging.TextSender) error {
+func signUp(msg messaging.TextMessage, keyword, locale string, mem object.Member, ddbClnt db.DDBConnecter,
+	smsClnt messaging.TextSender, tmpls *messaging.Templates) error {
 	switch {
-	case strings.ToLower(msg.Body) == "pray":
-		if err := signUpStageOne(mem, ddbClnt, smsClnt); err != nil {
+	case keyword == messaging.KeywordPray:
+		// members sign up in the language of the pray keyword they texted in
+		mem.Language = locale
+		if err := signUpStageOne(mem, ddbClnt, smsClnt, tmpls); err != nil {
 			return fmt.Errorf("signUpStageOne: %w", err)
 		}
 	case msg.Body != "2" && mem.SetupStage == 1:
-		if err := signUpStageTwoA(mem, ddbClnt, smsClnt, msg); err != nil {
+		if err := signUpStageTwoA(mem, ddbClnt, smsClnt, tmpls, msg); err != nil {
 			return fmt.Errorf("signUpStageTwoA: %w", err)
 		}
 	case msg.Body == "2" && mem.SetupStage == 1:
-		if err := signUpStageTwoB(mem, ddbClnt, smsClnt); err != nil {
+		if err := signUpStageTwoB(mem, ddbClnt, smsClnt, tmpls); err != nil {
 			return fmt.Errorf("signUpStageTwoB: %w", err)
 		}
 	case msg.Body == "1" && mem.SetupStage == 2:
-		if err := signUpFinalPrayerMessage(mem, ddbClnt, smsClnt); err != nil {
+		if err := signUpFinalPrayerMessage(mem, ddbClnt, smsClnt, tmpls); err != nil {
 			return fmt.Errorf("signUpFinalPrayerMessage: %w", err)
 		}
 	case msg.Body == "2" && mem.SetupStage == 2:
-		if err := signUpStageThree(mem, ddbClnt, smsClnt); err != nil {
+		if err := signUpStageThree(mem, ddbClnt, smsClnt, tmpls); err != nil {
 			return fmt.Errorf("signUpStageThree: %w", err)
 		}
 	case mem.SetupStage == 3:
-		if err := signUpFinalIntercessorMessage(mem, ddbClnt, smsClnt, msg); err != nil {
+		if err := signUpFinalIntercessorMessage(mem, ddbClnt, smsClnt, tmpls, msg); err != nil {
 			return fmt.Errorf("signUpFinalIntercessorMessage: %w", err)
 		}
 	default:
-		if err := signUpWrongInput(mem, smsClnt); err != nil {
+		if err := signUpWrongInput(mem, smsClnt, tmpls); err != nil {
 			return fmt.Errorf("signUpWrongInput: %w", err)
 		}
 	}
@@ -192,131 +345,197 @@ func signUp(msg messaging.TextMessage, mem object.Member, ddbClnt db.DDBConnecte
 	return nil
 }
 
-func signUpStageOne(mem object.Member, ddbClnt db.DDBConnecter, smsClnt messaging.TextSender) error {
-	mem.SetupStatus = "in-progress"
-	mem.SetupStage = 1
-	if err := mem.Put(ddbClnt); err != nil {
+func signUpStageOne(mem object.Member, ddbClnt db.DDBConnecter, smsClnt messaging.TextSender, tmpls *messaging.Templates) error {
+	language, tenantPhone := mem.Language, mem.TenantPhone
+	_, err := mem.Update(ddbClnt, func(m *object.Member) bool {
+		m.Language, m.TenantPhone = language, tenantPhone
+		m.SetupStatus = "in-progress"
+		m.SetupStage = 1
+		return true
+	})
+	if err != nil {
 		return err
 	}
 
-	if err := mem.SendMessage(smsClnt, messaging.MsgNameRequest); err != nil {
+	if err := mem.SendMessage(smsClnt, tmpls, messaging.MsgNameRequest, nil); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func signUpStageTwoA(mem object.Member, ddbClnt db.DDBConnecter, smsClnt messaging.TextSender, msg messaging.TextMessage) error {
-	mem.SetupStage = 2
-	mem.Name = msg.Body
-	if err := mem.Put(ddbClnt); err != nil {
+func signUpStageTwoA(mem object.Member, ddbClnt db.DDBConnecter, smsClnt messaging.TextSender, tmpls *messaging.Templates,
+	msg messaging.TextMessage) error {
+	if updated, err := updateSignUp(&mem, 1, ddbClnt, func(m *object.Member) {
+		m.SetupStage = 2
+		m.Name = msg.Body
+	}); err != nil || !updated {
 		return err
 	}
 
-	if err := mem.SendMessage(smsClnt, messaging.MsgMemberTypeRequest); err != nil {
+	if err := mem.SendMessage(smsClnt, tmpls, messaging.MsgMemberTypeRequest, nil); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func signUpStageTwoB(mem object.Member, ddbClnt db.DDBConnecter, smsClnt messaging.TextSender) error {
-	mem.SetupStage = 2
-	mem.Name = "Anonymous"
-	if err := mem.Put(ddbClnt); err != nil {
+func signUpStageTwoB(mem object.Member, ddbClnt db.DDBConnecter, smsClnt messaging.TextSender, tmpls *messaging.Templates) error {
+	if updated, err := updateSignUp(&mem, 1, ddbClnt, func(m *object.Member) {
+		m.SetupStage = 2
+		m.Name = "Anonymous"
+	}); err != nil || !updated {
 		return err
 	}
 
-	if err := mem.SendMessage(smsClnt, messaging.MsgMemberTypeRequest); err != nil {
+	if err := mem.SendMessage(smsClnt, tmpls, messaging.MsgMemberTypeRequest, nil); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func signUpFinalPrayerMessage(mem object.Member, ddbClnt db.DDBConnecter, smsClnt messaging.TextSender) error {
-	mem.SetupStatus = "completed"
-	mem.SetupStage = 99
-	mem.Intercessor = false
-	if err := mem.Put(ddbClnt); err != nil {
+func signUpFinalPrayerMessage(mem object.Member, ddbClnt db.DDBConnecter, smsClnt messaging.TextSender,
+	tmpls *messaging.Templates) error {
+	if updated, err := updateSignUp(&mem, 2, ddbClnt, func(m *object.Member) {
+		m.SetupStatus = "completed"
+		m.SetupStage = 99
+		m.Intercessor = false
+	}); err != nil || !updated {
 		return err
 	}
 
-	body := messaging.MsgPrayerInstructions + "\n\n" + messaging.MsgSignUpConfirmation
-	if err := mem.SendMessage(smsClnt, body); err != nil {
+	if err := mem.SendMessage(smsClnt, tmpls, messaging.MsgPrayerSignUpComplete, nil); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func signUpStageThree(mem object.Member, ddbClnt db.DDBConnecter, smsClnt messaging.TextSender) error {
-	mem.SetupStage = 3
-	mem.Intercessor = true
-	if err := mem.Put(ddbClnt); err != nil {
+func signUpStageThree(mem object.Member, ddbClnt db.DDBConnecter, smsClnt messaging.TextSender, tmpls *messaging.Templates) error {
+	if updated, err := updateSignUp(&mem, 2, ddbClnt, func(m *object.Member) {
+		m.SetupStage = 3
+		m.Intercessor = true
+	}); err != nil || !updated {
 		return err
 	}
 
-	if err := mem.SendMessage(smsClnt, messaging.MsgPrayerNumRequest); err != nil {
+	if err := mem.SendMessage(smsClnt, tmpls, messaging.MsgPrayerNumRequest, nil); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func signUpFinalIntercessorMessage(mem object.Member, ddbClnt db.DDBConnecter, smsClnt messaging.TextSender, msg messaging.TextMessage) error {
+func signUpFinalIntercessorMessage(mem object.Member, ddbClnt db.DDBConnecter, smsClnt messaging.TextSender,
+	tmpls *messaging.Templates, msg messaging.TextMessage) error {
 	num, err := strconv.Atoi(msg.Body)
 	if err != nil {
-		return signUpWrongInput(mem, smsClnt)
+		return signUpWrongInput(mem, smsClnt, tmpls)
 	}
 
-	phones := object.IntercessorPhones{}
-	if err := phones.Get(ddbClnt); err != nil {
+	phones := object.TenantIntercessorPhones(mem.TenantPhone)
+	if err := phones.Update(ddbClnt, func(p *object.IntercessorPhones) { p.AddPhone(mem.Phone) }); err != nil {
 		return err
 	}
 
-	phones.AddPhone(mem.Phone)
-	if err := phones.Put(ddbClnt); err != nil {
+	if updated, err := updateSignUp(&mem, 3, ddbClnt, func(m *object.Member) {
+		m.SetupStatus = "completed"
+		m.SetupStage = 99
+		m.WeeklyPrayerLimit = num
+		m.WeeklyPrayerDate = time.Now().Format(time.RFC3339)
+	}); err != nil || !updated {
 		return err
 	}
 
-	mem.SetupStatus = "completed"
-	mem.SetupStage = 99
-	mem.WeeklyPrayerLimit = num
-	mem.WeeklyPrayerDate = time.Now().Format(time.RFC3339)
-	if err := mem.Put(ddbClnt); err != nil {
+	if err := mem.SendMessage(smsClnt, tmpls, messaging.MsgIntercessorSignUpComplete, nil); err != nil {
 		return err
 	}
 
-	body := messaging.MsgPrayerInstructions + "\n\n" + messaging.MsgIntercessorInstructions + "\n\n" + messaging.MsgSignUpConfirmation
-	if err := mem.SendMessage(smsClnt, body); err != nil {
+	return nil
+}
+
+// updateSignUp applies change to mem if mem is still signing up at stage. It returns false if mem
+// is not at stage anymore, for example because mem quit in a concurrent flow, and then the text
+// message that was meant for stage is dropped.
+func updateSignUp(mem *object.Member, stage int, ddbClnt db.DDBConnecter, change func(*object.Member)) (bool,
+	error) {
+	updated, err := mem.Update(ddbClnt, func(m *object.Member) bool {
+		if m.SetupStatus != "in-progress" || m.SetupStage != stage {
+			return false
+		}
+		change(m)
+		return true
+	})
+	if err != nil {
+		return false, err
+	} else if !updated {
+		slog.Warn("member left sign up stage in a concurrent flow, dropping message", "member", mem.Phone,
+			"stage", stage)
+	}
+
+	return updated, nil
+}
+
+func signUpWrongInput(mem object.Member, smsClnt messaging.TextSender, tmpls *messaging.Templates) error {
+	slog.Warn("wrong input received during sign up", "member", mem.Phone)
+
+	if err := mem.SendMessage(smsClnt, tmpls, messaging.MsgWrongInput, nil); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func signUpWrongInput(mem object.Member, smsClnt messaging.TextSender) error {
-	slog.Warn("wrong input received during sign up", "member", mem.Phone)
+func changeLanguage(mem object.Member, locale string, ddbClnt db.DDBConnecter, smsClnt messaging.TextSender,
+	tmpls *messaging.Templates) error {
+	updated, err := mem.Update(ddbClnt, func(m *object.Member) bool {
+		// members that quit in a concurrent flow are not added back
+		if m.SetupStatus == "" {
+			return false
+		}
+		m.Language = locale
+		return true
+	})
+	if err != nil || !updated {
+		return err
+	}
 
-	if err := mem.SendMessage(smsClnt, messaging.MsgWrongInput); err != nil {
+	if err := mem.SendMessage(smsClnt, tmpls, messaging.MsgLanguageChanged, nil); err != nil {
 		return err
 	}
 
+	// members that are in the middle of signing up get the current sign up question again in the
+	// new language
+	var prompt string
+	if mem.SetupStatus == "in-progress" {
+		switch mem.SetupStage {
+		case 1:
+			prompt = messaging.MsgNameRequest
+		case 2:
+			prompt = messaging.MsgMemberTypeRequest
+		case 3:
+			prompt = messaging.MsgPrayerNumRequest
+		}
+	}
+
+	if prompt != "" {
+		if err := mem.SendMessage(smsClnt, tmpls, prompt, nil); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-func memberDelete(mem object.Member, ddbClnt db.DDBConnecter, smsClnt messaging.TextSender) error {
+func memberDelete(mem object.Member, cfg config.Config, ddbClnt db.DDBConnecter, smsClnt messaging.TextSender,
+	tmpls *messaging.Templates) error {
 	if err := mem.Delete(ddbClnt); err != nil {
 		return err
 	}
 	if mem.Intercessor {
-		phones := object.IntercessorPhones{}
-		if err := phones.Get(ddbClnt); err != nil {
-			return err
-		}
-		phones.RemovePhone(mem.Phone)
-		if err := phones.Put(ddbClnt); err != nil {
+		phones := object.TenantIntercessorPhones(mem.TenantPhone)
+		if err := phones.Update(ddbClnt, func(p *object.IntercessorPhones) { p.RemovePhone(mem.Phone) }); err != nil {
 			return err
 		}
 
@@ -327,84 +546,165 @@ func memberDelete(mem object.Member, ddbClnt db.DDBConnecter, smsClnt messaging.
 			return err
 		} else if isActive {
 			pryr := object.Prayer{IntercessorPhone: mem.Phone}
-			if err := pryr.Get(ddbClnt, false); err != nil {
-				return err
-			}
-
-			if err := pryr.Delete(ddbClnt, false); err != nil {
+			if err := pryr.Get(ddbClnt, cfg.Cipher, false); err != nil {
 				return err
 			}
 
-			// random ID is generated here since queued Prayers do not have an intercessor assigned
-			// to them
-			id, err := utility.GenerateID()
-			if err != nil {
-				return err
-			}
-			pryr.IntercessorPhone, pryr.Intercessor = id, object.Member{}
-
-			if err := pryr.Put(ddbClnt, true); err != nil {
+			if err := requeuePrayer(pryr, cfg, ddbClnt); err != nil {
 				return err
 			}
 		}
 	}
 
-	if err := mem.SendMessage(smsClnt, messaging.MsgRemoveUser); err != nil {
+	if err := mem.SendMessage(smsClnt, tmpls, messaging.MsgRemoveUser, nil); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func prayerRequest(msg messaging.TextMessage, mem object.Member, ddbClnt db.DDBConnecter, smsClnt messaging.TextSender) error {
-	profanity := msg.CheckProfanity()
-	if profanity != "" {
-		msg := strings.Replace(messaging.MsgProfanityFound, "PLACEHOLDER", profanity, 1)
-		if err := mem.SendMessage(smsClnt, msg); err != nil {
+// requeuePrayer moves an active Prayer back to the prayer queue, so that the Prayer can get sent
+// to someone else.
+func requeuePrayer(pryr object.Prayer, cfg config.Config, ddbClnt db.DDBConnecter) error {
+	if err := pryr.Delete(ddbClnt, false); err != nil {
+		return err
+	}
+
+	// random ID is generated here since queued Prayers do not have an intercessor assigned to them
+	id, err := utility.GenerateID()
+	if err != nil {
+		return err
+	}
+	pryr.IntercessorPhone, pryr.Intercessor = id, object.Member{}
+	pryr.QueuedTime = time.Now().Format(time.RFC3339)
+
+	return pryr.Put(ddbClnt, cfg.Cipher, true)
+}
+
+func prayerRequest(msg messaging.TextMessage, mem object.Member, tnt object.Tenant, cfg config.Config,
+	ddbClnt db.DDBConnecter, smsClnt messaging.TextSender, tmpls *messaging.Templates) error {
+	pipeline, err := filter.New(cfg.ContentFilters)
+	if err != nil {
+		return err
+	}
+
+	result := pipeline.Check(msg.Body)
+	if result.Action == filter.ActionReject {
+		slog.Warn("prayer request rejected by content filter", "member", mem.Phone, "filter", result.Type)
+		if err := rejectContent(result, mem, smsClnt, tmpls); err != nil {
+			return err
+		}
+		return nil
+	}
+	// redacted content is never sent to moderators or intercessors
+	msg.Body = result.Text
+
+	segments, err := prayerSegments(msg, mem, tmpls)
+	if err != nil {
+		return err
+	} else if segments > cfg.GetMaxPrayerSegments() {
+		slog.Warn("prayer request is too long, rejecting", "member", mem.Phone, "segments", segments,
+			"maxSegments", cfg.GetMaxPrayerSegments())
+		if err := mem.SendMessage(smsClnt, tmpls, messaging.MsgPrayerTooLong, nil); err != nil {
 			return err
 		}
 		return nil
 	}
 
-	intercessors, err := FindIntercessors(ddbClnt, mem.Phone)
+	// flagged prayer requests are held for review even if moderation is not enabled
+	if cfg.ModeratePrayers || result.Action == filter.ActionFlag {
+		if err := holdPrayer(msg, mem, tnt, cfg, ddbClnt, smsClnt, tmpls); err != nil {
+			return fmt.Errorf("holdPrayer: %w", err)
+		}
+
+		return nil
+	}
+
+	return sendPrayer(msg, mem, tnt, cfg, ddbClnt, smsClnt, tmpls)
+}
+
+// rejectContent tells the member why their prayer request was rejected by a content filter.
+func rejectContent(result filter.Result, mem object.Member, smsClnt messaging.TextSender,
+	tmpls *messaging.Templates) error {
+	if result.Type == filter.TypeWords {
+		data := map[string]string{"Profanity": strings.Join(result.Found, ", ")}
+		return mem.SendMessage(smsClnt, tmpls, messaging.MsgProfanityFound, data)
+	}
+
+	return mem.SendMessage(smsClnt, tmpls, messaging.MsgContactInfoFound, nil)
+}
+
+// sendPrayer assigns the prayer request in msg to intercessors of tnt, or queues it if there are
+// no intercessors available.
+func sendPrayer(msg messaging.TextMessage, mem object.Member, tnt object.Tenant, cfg config.Config,
+	ddbClnt db.DDBConnecter, smsClnt messaging.TextSender, tmpls *messaging.Templates) error {
+	intercessors, err := FindIntercessors(ddbClnt, tnt, mem.Phone)
 	if err != nil {
 		return fmt.Errorf("findIntercessors: %w", err)
 	} else if intercessors == nil {
-		if err := queuePrayer(msg, mem, ddbClnt, smsClnt); err != nil {
+		if err := queuePrayer(msg, mem, cfg, ddbClnt, smsClnt, tmpls); err != nil {
 			return fmt.Errorf("queuePrayer: %w", err)
 		}
 
 		return nil
 	}
 
+	return deliverPrayer(msg.Body, mem, intercessors, cfg, ddbClnt, smsClnt, tmpls)
+}
+
+// deliverPrayer saves the prayer request of mem as an active Prayer for each of the intercessors,
+// sends it to them, and lets mem know that it was sent out.
+func deliverPrayer(request string, mem object.Member, intercessors []object.Member, cfg config.Config,
+	ddbClnt db.DDBConnecter, smsClnt messaging.TextSender, tmpls *messaging.Templates) error {
 	for _, intr := range intercessors {
 		pryr := object.Prayer{
 			Intercessor:      intr,
 			IntercessorPhone: intr.Phone,
-			Request:          msg.Body,
+			Request:          request,
 			Requestor:        mem,
 		}
-		if err := pryr.Put(ddbClnt, false); err != nil {
+		if err := pryr.Put(ddbClnt, cfg.Cipher, false); err != nil {
 			return err
 		}
 
-		msg := strings.Replace(messaging.MsgPrayerIntro, "PLACEHOLDER", mem.Name, 1)
-		if err := intr.SendMessage(smsClnt, msg+pryr.Request); err != nil {
+		data := map[string]string{"Name": mem.Name, "Request": pryr.Request}
+		if err := intr.SendMessage(smsClnt, tmpls, messaging.MsgPrayerIntro, data); err != nil {
 			return err
 		}
 	}
 
-	if err := mem.SendMessage(smsClnt, messaging.MsgPrayerSentOut); err != nil {
+	if err := mem.SendMessage(smsClnt, tmpls, messaging.MsgPrayerSentOut, nil); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func FindIntercessors(ddbClnt db.DDBConnecter, skipPhone string) ([]object.Member, error) {
+// prayerSegments returns the number of sms segments that the prayer request in msg takes up when
+// it gets sent out to an intercessor. This uses the language of the prayer requestor since the
+// language of the intercessors is not known yet.
+func prayerSegments(msg messaging.TextMessage, mem object.Member, tmpls *messaging.Templates) (int, error) {
+	tmpls = tmpls.Locale(mem.Language)
+	body, err := tmpls.Render(messaging.MsgPrayerIntro, map[string]string{"Name": mem.Name, "Request": msg.Body})
+	if err != nil {
+		return 0, fmt.Errorf("prayerSegments: %w", err)
+	}
+
+	parts, err := tmpls.Compose(body)
+	if err != nil {
+		return 0, fmt.Errorf("prayerSegments: %w", err)
+	}
+
+	return messaging.TotalSegments(parts), nil
+}
+
+// FindIntercessors selects available intercessors from the member pool of tnt. skipPhone is
+// never selected, which is used to keep prayer requestors from praying for their own requests.
+func FindIntercessors(ddbClnt db.DDBConnecter, tnt object.Tenant, skipPhone string) ([]object.Member, error) {
 	var intercessors []object.Member
+	numIntercessors := tnt.GetIntercessorsPerPrayer()
 
-	allPhones := object.IntercessorPhones{}
+	allPhones := object.TenantIntercessorPhones(tnt.Phone)
 	if err := allPhones.Get(ddbClnt); err != nil {
 		return nil, err
 	}
@@ -413,8 +713,8 @@ func FindIntercessors(ddbClnt db.DDBConnecter, skipPhone string) ([]object.Membe
 	// list so they don't get assigned to pray for their own prayer request
 	utility.RemoveItem(&allPhones.Phones, skipPhone)
 
-	for len(intercessors) < object.NumIntercessorsPerPrayer {
-		randPhones := allPhones.GenRandPhones()
+	for len(intercessors) < numIntercessors {
+		randPhones := allPhones.GenRandPhones(numIntercessors - len(intercessors))
 		if randPhones == nil {
 			// this means that there are no more available intercessors for a prayer request
 			if len(intercessors) != 0 {
@@ -429,11 +729,28 @@ func FindIntercessors(ddbClnt db.DDBConnecter, skipPhone string) ([]object.Membe
 		}
 
 		for _, phn := range randPhones {
+			// every phone is tried at most once
+			allPhones.RemovePhone(phn)
+
 			intr := object.Member{Phone: phn}
 			if err := intr.Get(ddbClnt); err != nil {
 				return nil, err
 			}
 
+			if !intr.Intercessor || intr.SetupStatus != "completed" {
+				// this protects against a phone list that was read while the intercessor quit or
+				// had not finished signing up yet in a concurrent flow
+				continue
+			}
+
+			if intr.TenantPhone != tnt.Phone {
+				// this protects against a phone list that is out of sync with Member records. only
+				// intercessors of the same Tenant as the prayer request can be selected
+				slog.Warn("intercessor belongs to a different tenant, skipping", "intercessor", intr.Phone,
+					"intercessorTenant", intr.TenantPhone, "tenant", tnt.Phone)
+				continue
+			}
+
 			isActive, err := object.IsPrayerActive(ddbClnt, intr.Phone)
 			if err != nil {
 				return nil, err
@@ -441,38 +758,23 @@ func FindIntercessors(ddbClnt db.DDBConnecter, skipPhone string) ([]object.Membe
 			if isActive {
 				// this means that intercessor already has 1 active prayer and cannot be used for
 				// another 1. there is a limitation of 1 active prayer at a time per intercessor
-				allPhones.RemovePhone(intr.Phone)
 				continue
 			}
 
-			if intr.PrayerCount < intr.WeeklyPrayerLimit {
-				intr.PrayerCount++
+			// the intercessor is checked again on the stored Member, which concurrent flows of the
+			// intercessor or of other prayer requests may have changed since it was loaded
+			var countErr error
+			selected, err := intr.Update(ddbClnt, func(m *object.Member) bool {
+				var counted bool
+				counted, countErr = countPrayer(m, tnt.Phone, time.Now())
+				return counted
+			})
+			if err != nil {
+				return nil, err
+			} else if countErr != nil {
+				return nil, countErr
+			} else if selected {
 				intercessors = append(intercessors, intr)
-				allPhones.RemovePhone(intr.Phone)
-				if err := intr.Put(ddbClnt); err != nil {
-					return nil, err
-				}
-			} else if intr.PrayerCount >= intr.WeeklyPrayerLimit {
-				currentTime := time.Now()
-				previousTime, err := time.Parse(time.RFC3339, intr.WeeklyPrayerDate)
-				if err != nil {
-					return nil, fmt.Errorf("time.Parse: %w", err)
-				}
-
-				diff := currentTime.Sub(previousTime).Hours()
-				// reset prayer counter if time between now and weekly prayer date is greater than
-				// 7 days and select intercessor
-				if (diff / 24) > 7 {
-					intr.PrayerCount = 1
-					intr.WeeklyPrayerDate = time.Now().Format(time.RFC3339)
-					intercessors = append(intercessors, intr)
-					allPhones.RemovePhone(intr.Phone)
-					if err := intr.Put(ddbClnt); err != nil {
-						return nil, err
-					}
-				} else if (diff / 24) < 7 {
-					allPhones.RemovePhone(intr.Phone)
-				}
 			}
 		}
 	}
@@ -480,7 +782,37 @@ func FindIntercessors(ddbClnt db.DDBConnecter, skipPhone string) ([]object.Membe
 	return intercessors, nil
 }
 
-func queuePrayer(msg messaging.TextMessage, mem object.Member, ddbClnt db.DDBConnecter, smsClnt messaging.TextSender) error {
+// countPrayer counts a prayer against the weekly prayer limit of intr. The count is reset once the
+// week of intr is over. It returns false if intr can not take another prayer, because intr is not
+// a completed intercessor of the Tenant with tenantPhone or reached its weekly prayer limit.
+func countPrayer(intr *object.Member, tenantPhone string, now time.Time) (bool, error) {
+	if !intr.Intercessor || intr.SetupStatus != "completed" || intr.TenantPhone != tenantPhone {
+		return false, nil
+	}
+
+	if intr.PrayerCount < intr.WeeklyPrayerLimit {
+		intr.PrayerCount++
+		return true, nil
+	}
+
+	previousTime, err := time.Parse(time.RFC3339, intr.WeeklyPrayerDate)
+	if err != nil {
+		return false, fmt.Errorf("time.Parse: %w", err)
+	}
+
+	// reset prayer counter if time between now and weekly prayer date is greater than 7 days and
+	// select intercessor
+	if now.Sub(previousTime).Hours()/24 > 7 {
+		intr.PrayerCount = 1
+		intr.WeeklyPrayerDate = now.Format(time.RFC3339)
+		return true, nil
+	}
+
+	return false, nil
+}
+
+func queuePrayer(msg messaging.TextMessage, mem object.Member, cfg config.Config, ddbClnt db.DDBConnecter,
+	smsClnt messaging.TextSender, tmpls *messaging.Templates) error {
 	pryr := object.Prayer{}
 	// random ID is generated here since queued Prayers do not have an intercessor assigned
 	// to them
@@ -490,49 +822,61 @@ func queuePrayer(msg messaging.TextMessage, mem object.Member, ddbClnt db.DDBCon
 	}
 
 	pryr.IntercessorPhone, pryr.Request, pryr.Requestor = id, msg.Body, mem
+	pryr.QueuedTime = time.Now().Format(time.RFC3339)
 
-	if err := pryr.Put(ddbClnt, true); err != nil {
+	if err := pryr.Put(ddbClnt, cfg.Cipher, true); err != nil {
 		return err
 	}
 
-	if err := mem.SendMessage(smsClnt, messaging.MsgPrayerQueued); err != nil {
+	if err := mem.SendMessage(smsClnt, tmpls, messaging.MsgPrayerQueued, nil); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-func completePrayer(mem object.Member, ddbClnt db.DDBConnecter, smsClnt messaging.TextSender) error {
+func completePrayer(mem object.Member, cfg config.Config, ddbClnt db.DDBConnecter, smsClnt messaging.TextSender,
+	tmpls *messaging.Templates) error {
 	pryr := object.Prayer{IntercessorPhone: mem.Phone}
-	if err := pryr.Get(ddbClnt, false); err != nil {
+	if err := pryr.Get(ddbClnt, cfg.Cipher, false); err != nil {
 		return err
 	}
 
 	if pryr.Request == "" {
 		// this means that the get prayer did not return an active prayer
-		if err := mem.SendMessage(smsClnt, messaging.MsgNoActivePrayer); err != nil {
+		if err := mem.SendMessage(smsClnt, tmpls, messaging.MsgNoActivePrayer, nil); err != nil {
 			return err
 		}
 		return nil
 	}
 
-	if err := mem.SendMessage(smsClnt, messaging.MsgPrayerThankYou); err != nil {
+	if err := mem.SendMessage(smsClnt, tmpls, messaging.MsgPrayerThankYou, nil); err != nil {
 		return err
 	}
 
-	msg := strings.Replace(messaging.MsgPrayerConfirmation, "PLACEHOLDER", mem.Name, 1)
-
 	isActive, err := object.IsMemberActive(ddbClnt, pryr.Requestor.Phone)
 	if err != nil {
 		return err
 	}
 
 	if isActive {
-		if err := pryr.Requestor.SendMessage(smsClnt, msg); err != nil {
+		data := map[string]string{"Name": mem.Name}
+		if err := pryr.Requestor.SendMessage(smsClnt, tmpls, messaging.MsgPrayerConfirmation, data); err != nil {
 			return err
 		}
 	} else {
-		slog.Warn("Skip sending message, member is not active", "recipient", pryr.Requestor.Phone, "body", msg)
+		slog.Warn("Skip sending message, member is not active", "recipient", pryr.Requestor.Phone,
+			"template", messaging.MsgPrayerConfirmation)
+	}
+
+	id, err := utility.GenerateID()
+	if err != nil {
+		return err
+	}
+
+	retention := time.Duration(cfg.GetPrayerRetentionDays()) * 24 * time.Hour
+	if err := object.ArchivePrayer(ddbClnt, cfg.Cipher, cfg.PhoneHashKey, pryr, retention, id, time.Now()); err != nil {
+		return err
 	}
 
 	if err := pryr.Delete(ddbClnt, false); err != nil {
@@ -541,3 +885,54 @@ func completePrayer(mem object.Member, ddbClnt db.DDBConnecter, smsClnt messagin
 
 	return nil
 }
+
+// rateLimit takes a token from the rate limiter of the member's phone. It returns the rate limit
+// result and whether the member needs to be told that they are throttled. Rate limiting needs a
+// ddb get and put, so it is skipped when no rate limits are configured. STOP and HELP are never
+// rate limited so that anyone can always leave and get help and compliance info.
+func rateLimit(mem object.Member, keyword string, cfg config.Config, ddbClnt db.DDBConnecter) (string, bool, error) {
+	if keyword == messaging.KeywordStop || keyword == messaging.KeywordHelp ||
+		(!cfg.PrayerRateLimit.IsEnabled() && !cfg.CommandRateLimit.IsEnabled()) {
+		return object.RateLimitAllowed, false, nil
+	}
+
+	// anything that completed members send without a keyword is a prayer request
+	kind, limit := object.RateLimitCommand, cfg.CommandRateLimit
+	if keyword == "" && mem.SetupStatus == "completed" {
+		kind, limit = object.RateLimitPrayer, cfg.PrayerRateLimit
+	}
+
+	var result string
+	var notify bool
+	now := time.Now()
+	rl := object.PhoneRateLimiter(mem.Phone)
+	err := rl.Update(ddbClnt, func(r *object.RateLimiter) { result, notify = r.Take(kind, limit, cfg, now) })
+	if err != nil {
+		return "", false, fmt.Errorf("rateLimit: %w", err)
+	}
+
+	return result, notify, nil
+}
+
+func recordUsage(ddbClnt db.DDBConnecter, state *object.State, rec *messaging.RecordingSender) {
+	// usage counters are only informational, so failures are logged and do not fail the flow
+	if err := object.RecordUsage(ddbClnt, state.Stage, rec.Sent, time.Now()); err != nil {
+		slog.Error("failure during usage recording", "error", err)
+	}
+}
+
+// loadTemplates returns the message templates for tnt. Tenant overrides are applied on top of the
+// deployment templates from cfg.
+func loadTemplates(cfg config.Config, tnt object.Tenant) (*messaging.Templates, error) {
+	tmpls, err := messaging.LoadTemplates(cfg.Templates, cfg.TemplateVars)
+	if err != nil {
+		return nil, fmt.Errorf("loadTemplates: %w", err)
+	}
+
+	tmpls, err = tmpls.Override(tnt.Templates, tnt.TemplateVars)
+	if err != nil {
+		return nil, fmt.Errorf("loadTemplates tenant %v: %w", tnt.Phone, err)
+	}
+
+	return tmpls, nil
+}
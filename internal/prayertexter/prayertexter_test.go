@@ -1,20 +1,30 @@
 package prayertexter_test
 
 import (
+	"context"
 	"errors"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
 	"reflect"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/mshort55/prayertexter/internal/config"
+	"github.com/mshort55/prayertexter/internal/db"
+	"github.com/mshort55/prayertexter/internal/filter"
 	"github.com/mshort55/prayertexter/internal/messaging"
 	"github.com/mshort55/prayertexter/internal/mock"
 	"github.com/mshort55/prayertexter/internal/object"
 	"github.com/mshort55/prayertexter/internal/prayertexter"
+	"github.com/mshort55/prayertexter/internal/privacy"
 )
 
 type TestCase struct {
@@ -141,7 +151,10 @@ func testPrayers(inputs []dynamodb.PutItemInput, t *testing.T, test TestCase, qu
 		if !queue {
 			actualPryr.Intercessor.WeeklyPrayerDate = "dummy date/time"
 		} else if queue {
-			actualPryr.IntercessorPhone = "dummy ID"
+			if actualPryr.QueuedTime == "" {
+				t.Errorf("expected queued Prayer to have a QueuedTime")
+			}
+			actualPryr.IntercessorPhone, actualPryr.QueuedTime = "dummy ID", ""
 		}
 
 		expectedPryr := test.expectedPrayers[index]
@@ -179,6 +192,8 @@ func testPhones(inputs []dynamodb.PutItemInput, t *testing.T, test TestCase) {
 		if err := attributevalue.UnmarshalMap(input.Item, &actualPhones); err != nil {
 			t.Errorf("failed to unmarshal PutItemInput into IntercessorPhones: %v", err)
 		}
+		// every put increments the version, which is tested with IntercessorPhones
+		actualPhones.Version = 0
 
 		if !reflect.DeepEqual(actualPhones, test.expectedPhones) {
 			t.Errorf("expected IntercessorPhones %v, got %v", test.expectedPhones, actualPhones)
@@ -248,16 +263,6 @@ func testTxtMessage(txtMock *mock.TextSender, t *testing.T, test TestCase) {
 			t.Errorf("there are more text message inputs than expected texts")
 		}
 
-		// Some text messages use PLACEHOLDER and replace that with the txt recipients name
-		// Therefor to make testing easier, the message body is replaced by the msg constant
-		if strings.Contains(*input.MessageBody, "Hello! Please pray for") {
-			input.MessageBody = aws.String(messaging.MsgPrayerIntro)
-		} else if strings.Contains(*input.MessageBody, "There was profanity found in your prayer request:") {
-			input.MessageBody = aws.String(messaging.MsgProfanityFound)
-		} else if strings.Contains(*input.MessageBody, "You're prayer request has been prayed for by") {
-			input.MessageBody = aws.String(messaging.MsgPrayerConfirmation)
-		}
-
 		receivedText := messaging.TextMessage{
 			Body:  *input.MessageBody,
 			Phone: *input.DestinationPhoneNumber,
@@ -265,8 +270,12 @@ func testTxtMessage(txtMock *mock.TextSender, t *testing.T, test TestCase) {
 
 		// This part makes mocking messages less painful. We do not need to worry about new lines,
 		// pre, or post messages. They are removed when messages are tested.
+		strs := []string{"\n"}
+		for _, locale := range []string{messaging.DefaultLocale, "es"} {
+			strs = append(strs, localeMsgText(locale, messaging.MsgPre, nil), localeMsgText(locale, messaging.MsgPost, nil))
+		}
 		for _, t := range []*messaging.TextMessage{&receivedText, &test.expectedTexts[index]} {
-			for _, str := range []string{"\n", messaging.MsgPre, messaging.MsgPost} {
+			for _, str := range strs {
 				t.Body = strings.ReplaceAll(t.Body, str, "")
 			}
 		}
@@ -283,6 +292,26 @@ func testTxtMessage(txtMock *mock.TextSender, t *testing.T, test TestCase) {
 	}
 }
 
+// msgText renders a default message template so that tests can compare against the exact text
+// members receive.
+func msgText(name string, data map[string]string) string {
+	return localeMsgText(messaging.DefaultLocale, name, data)
+}
+
+func localeMsgText(locale, name string, data map[string]string) string {
+	tmpls, err := messaging.LoadTemplates(nil, nil)
+	if err != nil {
+		panic(err)
+	}
+
+	body, err := tmpls.Locale(locale).Render(name, data)
+	if err != nil {
+		panic(err)
+	}
+
+	return body
+}
+
 func TestMainFlowSignUp(t *testing.T) {
 	testCases := []TestCase{
 		{
@@ -295,15 +324,17 @@ func TestMainFlowSignUp(t *testing.T) {
 
 			expectedMembers: []object.Member{
 				{
+					Language:    "en",
 					Phone:       "+11234567890",
 					SetupStage:  1,
 					SetupStatus: "in-progress",
+					Version:     1,
 				},
 			},
 
 			expectedTexts: []messaging.TextMessage{
 				{
-					Body:  messaging.MsgNameRequest,
+					Body:  msgText(messaging.MsgNameRequest, nil),
 					Phone: "+11234567890",
 				},
 			},
@@ -322,15 +353,17 @@ func TestMainFlowSignUp(t *testing.T) {
 
 			expectedMembers: []object.Member{
 				{
+					Language:    "en",
 					Phone:       "+11234567890",
 					SetupStage:  1,
 					SetupStatus: "in-progress",
+					Version:     1,
 				},
 			},
 
 			expectedTexts: []messaging.TextMessage{
 				{
-					Body:  messaging.MsgNameRequest,
+					Body:  msgText(messaging.MsgNameRequest, nil),
 					Phone: "+11234567890",
 				},
 			},
@@ -401,12 +434,13 @@ func TestMainFlowSignUp(t *testing.T) {
 					Phone:       "+11234567890",
 					SetupStage:  2,
 					SetupStatus: "in-progress",
+					Version:     1,
 				},
 			},
 
 			expectedTexts: []messaging.TextMessage{
 				{
-					Body:  messaging.MsgMemberTypeRequest,
+					Body:  msgText(messaging.MsgMemberTypeRequest, nil),
 					Phone: "+11234567890",
 				},
 			},
@@ -450,12 +484,13 @@ func TestMainFlowSignUp(t *testing.T) {
 					Phone:       "+11234567890",
 					SetupStage:  2,
 					SetupStatus: "in-progress",
+					Version:     1,
 				},
 			},
 
 			expectedTexts: []messaging.TextMessage{
 				{
-					Body:  messaging.MsgMemberTypeRequest,
+					Body:  msgText(messaging.MsgMemberTypeRequest, nil),
 					Phone: "+11234567890",
 				},
 			},
@@ -501,12 +536,13 @@ func TestMainFlowSignUp(t *testing.T) {
 					Phone:       "+11234567890",
 					SetupStage:  99,
 					SetupStatus: "completed",
+					Version:     1,
 				},
 			},
 
 			expectedTexts: []messaging.TextMessage{
 				{
-					Body:  messaging.MsgPrayerInstructions + "\n\n" + messaging.MsgSignUpConfirmation,
+					Body:  msgText(messaging.MsgPrayerSignUpComplete, nil),
 					Phone: "+11234567890",
 				},
 			},
@@ -552,12 +588,13 @@ func TestMainFlowSignUp(t *testing.T) {
 					Phone:       "+11234567890",
 					SetupStage:  3,
 					SetupStatus: "in-progress",
+					Version:     1,
 				},
 			},
 
 			expectedTexts: []messaging.TextMessage{
 				{
-					Body:  messaging.MsgPrayerNumRequest,
+					Body:  msgText(messaging.MsgPrayerNumRequest, nil),
 					Phone: "+11234567890",
 				},
 			},
@@ -622,6 +659,7 @@ func TestMainFlowSignUp(t *testing.T) {
 					Phone:             "+11234567890",
 					SetupStage:        99,
 					SetupStatus:       "completed",
+					Version:           1,
 					WeeklyPrayerDate:  "dummy date/time",
 					WeeklyPrayerLimit: 10,
 				},
@@ -639,7 +677,7 @@ func TestMainFlowSignUp(t *testing.T) {
 
 			expectedTexts: []messaging.TextMessage{
 				{
-					Body:  messaging.MsgPrayerInstructions + "\n\n" + messaging.MsgIntercessorInstructions + "\n\n" + messaging.MsgSignUpConfirmation,
+					Body:  msgText(messaging.MsgIntercessorSignUpComplete, nil),
 					Phone: "+11234567890",
 				},
 			},
@@ -726,13 +764,13 @@ func TestMainFlowSignUp(t *testing.T) {
 
 			if test.expectedError {
 				// handles failures for error mocks
-				if err := prayertexter.MainFlow(test.initialMessage, ddbMock, txtMock); err == nil {
+				if err := prayertexter.MainFlow(test.initialMessage, config.Config{}, ddbMock, txtMock); err == nil {
 					t.Fatalf("expected error, got nil")
 				}
 				testNumMethodCalls(ddbMock, txtMock, t, test)
 			} else {
 				// handles success test cases
-				if err := prayertexter.MainFlow(test.initialMessage, ddbMock, txtMock); err != nil {
+				if err := prayertexter.MainFlow(test.initialMessage, config.Config{}, ddbMock, txtMock); err != nil {
 					t.Fatalf("unexpected error starting MainFlow: %v", err)
 				}
 
@@ -792,7 +830,7 @@ func TestMainFlowSignUpWrongInputs(t *testing.T) {
 
 			expectedTexts: []messaging.TextMessage{
 				{
-					Body:  messaging.MsgWrongInput,
+					Body:  msgText(messaging.MsgWrongInput, nil),
 					Phone: "+11234567890",
 				},
 			},
@@ -834,7 +872,7 @@ func TestMainFlowSignUpWrongInputs(t *testing.T) {
 
 			expectedTexts: []messaging.TextMessage{
 				{
-					Body:  messaging.MsgWrongInput,
+					Body:  msgText(messaging.MsgWrongInput, nil),
 					Phone: "+11234567890",
 				},
 			},
@@ -852,7 +890,7 @@ func TestMainFlowSignUpWrongInputs(t *testing.T) {
 		t.Run(test.description, func(t *testing.T) {
 			setMocks(ddbMock, txtMock, test)
 
-			if err := prayertexter.MainFlow(test.initialMessage, ddbMock, txtMock); err != nil {
+			if err := prayertexter.MainFlow(test.initialMessage, config.Config{}, ddbMock, txtMock); err != nil {
 				t.Fatalf("unexpected error starting MainFlow: %v", err)
 			}
 
@@ -906,7 +944,7 @@ func TestMainFlowMemberDelete(t *testing.T) {
 
 			expectedTexts: []messaging.TextMessage{
 				{
-					Body:  messaging.MsgRemoveUser,
+					Body:  msgText(messaging.MsgRemoveUser, nil),
 					Phone: "+11234567890",
 				},
 			},
@@ -989,7 +1027,7 @@ func TestMainFlowMemberDelete(t *testing.T) {
 
 			expectedTexts: []messaging.TextMessage{
 				{
-					Body:  messaging.MsgRemoveUser,
+					Body:  msgText(messaging.MsgRemoveUser, nil),
 					Phone: "+14444444444",
 				},
 			},
@@ -1152,7 +1190,7 @@ func TestMainFlowMemberDelete(t *testing.T) {
 
 			expectedTexts: []messaging.TextMessage{
 				{
-					Body:  messaging.MsgRemoveUser,
+					Body:  msgText(messaging.MsgRemoveUser, nil),
 					Phone: "+14444444444",
 				},
 			},
@@ -1217,13 +1255,13 @@ func TestMainFlowMemberDelete(t *testing.T) {
 
 			if test.expectedError {
 				// handles failures for error mocks
-				if err := prayertexter.MainFlow(test.initialMessage, ddbMock, txtMock); err == nil {
+				if err := prayertexter.MainFlow(test.initialMessage, config.Config{}, ddbMock, txtMock); err == nil {
 					t.Fatalf("expected error, got nil")
 				}
 				testNumMethodCalls(ddbMock, txtMock, t, test)
 			} else {
 				// handles success test cases
-				if err := prayertexter.MainFlow(test.initialMessage, ddbMock, txtMock); err != nil {
+				if err := prayertexter.MainFlow(test.initialMessage, config.Config{}, ddbMock, txtMock); err != nil {
 					t.Fatalf("unexpected error starting MainFlow: %v", err)
 				}
 
@@ -1271,7 +1309,7 @@ func TestMainFlowHelp(t *testing.T) {
 
 			expectedTexts: []messaging.TextMessage{
 				{
-					Body:  messaging.MsgHelp,
+					Body:  msgText(messaging.MsgHelp, nil),
 					Phone: "+11234567890",
 				},
 			},
@@ -1312,7 +1350,78 @@ func TestMainFlowHelp(t *testing.T) {
 
 			expectedTexts: []messaging.TextMessage{
 				{
-					Body:  messaging.MsgHelp,
+					Body:  msgText(messaging.MsgHelp, nil),
+					Phone: "+11234567890",
+				},
+			},
+
+			expectedGetItemCalls:  4,
+			expectedPutItemCalls:  3,
+			expectedSendTextCalls: 1,
+		},
+	}
+
+	for _, test := range testCases {
+		txtMock := &mock.TextSender{}
+		ddbMock := &mock.DDBConnecter{}
+
+		t.Run(test.description, func(t *testing.T) {
+			setMocks(ddbMock, txtMock, test)
+
+			if err := prayertexter.MainFlow(test.initialMessage, config.Config{}, ddbMock, txtMock); err != nil {
+				t.Fatalf("unexpected error starting MainFlow: %v", err)
+			}
+
+			testNumMethodCalls(ddbMock, txtMock, t, test)
+			testTxtMessage(txtMock, t, test)
+			testMembers(ddbMock.PutItemInputs, t, test)
+			testPrayers(ddbMock.PutItemInputs, t, test, test.expectedPrayerQueue)
+		})
+	}
+}
+
+func TestMainFlowLanguage(t *testing.T) {
+	testCases := []TestCase{
+		{
+			description: "Non member texts orar and signs up in spanish",
+
+			initialMessage: messaging.TextMessage{
+				Body:  "Orar",
+				Phone: "+11234567890",
+			},
+
+			expectedMembers: []object.Member{
+				{
+					Language:    "es",
+					Phone:       "+11234567890",
+					SetupStage:  1,
+					SetupStatus: "in-progress",
+					Version:     1,
+				},
+			},
+
+			expectedTexts: []messaging.TextMessage{
+				{
+					Body:  localeMsgText("es", messaging.MsgNameRequest, nil),
+					Phone: "+11234567890",
+				},
+			},
+
+			expectedGetItemCalls:  4,
+			expectedPutItemCalls:  4,
+			expectedSendTextCalls: 1,
+		},
+		{
+			description: "Non member texts ayuda and receives the spanish help message",
+
+			initialMessage: messaging.TextMessage{
+				Body:  "ayuda",
+				Phone: "+11234567890",
+			},
+
+			expectedTexts: []messaging.TextMessage{
+				{
+					Body:  localeMsgText("es", messaging.MsgHelp, nil),
 					Phone: "+11234567890",
 				},
 			},
@@ -1321,6 +1430,115 @@ func TestMainFlowHelp(t *testing.T) {
 			expectedPutItemCalls:  3,
 			expectedSendTextCalls: 1,
 		},
+		{
+			description: "Setup stage 2 user texts idioma and gets the member type request again in spanish",
+
+			initialMessage: messaging.TextMessage{
+				Body:  "idioma",
+				Phone: "+11234567890",
+			},
+
+			mockGetItemResults: []struct {
+				Output *dynamodb.GetItemOutput
+				Error  error
+			}{
+				{
+					// StateTracker empty get response. It would over complicate to test this here
+					Output: &dynamodb.GetItemOutput{},
+					Error:  nil,
+				},
+				{
+					Output: &dynamodb.GetItemOutput{
+						Item: map[string]types.AttributeValue{
+							"Name":        &types.AttributeValueMemberS{Value: "John Doe"},
+							"Phone":       &types.AttributeValueMemberS{Value: "+11234567890"},
+							"SetupStage":  &types.AttributeValueMemberN{Value: "2"},
+							"SetupStatus": &types.AttributeValueMemberS{Value: "in-progress"},
+						},
+					},
+					Error: nil,
+				},
+			},
+
+			expectedMembers: []object.Member{
+				{
+					Language:    "es",
+					Name:        "John Doe",
+					Phone:       "+11234567890",
+					SetupStage:  2,
+					SetupStatus: "in-progress",
+					Version:     1,
+				},
+			},
+
+			expectedTexts: []messaging.TextMessage{
+				{
+					Body:  localeMsgText("es", messaging.MsgLanguageChanged, nil),
+					Phone: "+11234567890",
+				},
+				{
+					Body:  localeMsgText("es", messaging.MsgMemberTypeRequest, nil),
+					Phone: "+11234567890",
+				},
+			},
+
+			expectedGetItemCalls:  4,
+			expectedPutItemCalls:  4,
+			expectedSendTextCalls: 2,
+		},
+		{
+			description: "Spanish member texts language and switches back to english",
+
+			initialMessage: messaging.TextMessage{
+				Body:  "LANGUAGE",
+				Phone: "+11234567890",
+			},
+
+			mockGetItemResults: []struct {
+				Output *dynamodb.GetItemOutput
+				Error  error
+			}{
+				{
+					// StateTracker empty get response. It would over complicate to test this here
+					Output: &dynamodb.GetItemOutput{},
+					Error:  nil,
+				},
+				{
+					Output: &dynamodb.GetItemOutput{
+						Item: map[string]types.AttributeValue{
+							"Language":    &types.AttributeValueMemberS{Value: "es"},
+							"Name":        &types.AttributeValueMemberS{Value: "John Doe"},
+							"Phone":       &types.AttributeValueMemberS{Value: "+11234567890"},
+							"SetupStage":  &types.AttributeValueMemberN{Value: "99"},
+							"SetupStatus": &types.AttributeValueMemberS{Value: "completed"},
+						},
+					},
+					Error: nil,
+				},
+			},
+
+			expectedMembers: []object.Member{
+				{
+					Language:    "en",
+					Name:        "John Doe",
+					Phone:       "+11234567890",
+					SetupStage:  99,
+					SetupStatus: "completed",
+					Version:     1,
+				},
+			},
+
+			expectedTexts: []messaging.TextMessage{
+				{
+					Body:  msgText(messaging.MsgLanguageChanged, nil),
+					Phone: "+11234567890",
+				},
+			},
+
+			expectedGetItemCalls:  4,
+			expectedPutItemCalls:  4,
+			expectedSendTextCalls: 1,
+		},
 	}
 
 	for _, test := range testCases {
@@ -1330,14 +1548,1001 @@ func TestMainFlowHelp(t *testing.T) {
 		t.Run(test.description, func(t *testing.T) {
 			setMocks(ddbMock, txtMock, test)
 
-			if err := prayertexter.MainFlow(test.initialMessage, ddbMock, txtMock); err != nil {
+			if err := prayertexter.MainFlow(test.initialMessage, config.Config{}, ddbMock, txtMock); err != nil {
+				t.Fatalf("unexpected error starting MainFlow: %v", err)
+			}
+
+			testNumMethodCalls(ddbMock, txtMock, t, test)
+			testTxtMessage(txtMock, t, test)
+			testMembers(ddbMock.PutItemInputs, t, test)
+		})
+	}
+}
+
+func TestMainFlowUsage(t *testing.T) {
+	txtMock := &mock.TextSender{}
+	ddbMock := &mock.DDBConnecter{}
+	msg := messaging.TextMessage{Body: "help", Phone: "+11234567890"}
+
+	if err := prayertexter.MainFlow(msg, config.Config{TrackUsage: true}, ddbMock, txtMock); err != nil {
+		t.Fatalf("unexpected error starting MainFlow: %v", err)
+	}
+
+	// usage adds counters to the daily and monthly Usage without reading them
+	test := TestCase{expectedGetItemCalls: 4, expectedPutItemCalls: 3, expectedSendTextCalls: 1}
+	testNumMethodCalls(ddbMock, txtMock, t, test)
+
+	names := map[string]bool{}
+	for _, input := range ddbMock.UpdateItemInputs {
+		key := input.Key[object.UsageAttribute].(*types.AttributeValueMemberS).Value
+		if key == object.UsageDayKey(time.Now()) {
+			for _, name := range input.ExpressionAttributeNames {
+				names[name] = true
+			}
+		}
+	}
+
+	if ddbMock.UpdateItemCalls != 6 || !names["HELP"] || !names["+11234567890"] {
+		t.Errorf("expected help message to be counted in daily Usage, got %v updates of %v",
+			ddbMock.UpdateItemCalls, names)
+	}
+}
+
+func TestMainFlowPrivacy(t *testing.T) {
+	txtMock := &mock.TextSender{}
+	ddbMock := &mock.DDBConnecter{}
+	msg := messaging.TextMessage{Body: "please pray for my sister who is in the hospital", Phone: "+11234567890"}
+
+	if err := prayertexter.MainFlow(msg, config.Config{}, ddbMock, txtMock); err != nil {
+		t.Fatalf("unexpected error starting MainFlow: %v", err)
+	}
+
+	var st object.StateTracker
+	if err := attributevalue.UnmarshalMap(ddbMock.PutItemInputs[0].Item, &st); err != nil {
+		t.Fatalf("failed to unmarshal PutItemInput into StateTracker: %v", err)
+	}
+
+	if stored := st.States[0].Message; stored.Phone != "+*******7890" || strings.Contains(stored.Body, "hospital") {
+		t.Errorf("expected redacted message in StateTracker, got %v", stored)
+	}
+}
+
+func TestMainFlowRateLimit(t *testing.T) {
+	cfg := config.Config{
+		CommandRateLimit: config.RateLimit{Capacity: 5, RefillSeconds: 60},
+		PrayerRateLimit:  config.RateLimit{Capacity: 1, RefillSeconds: 3600},
+	}
+
+	member := &dynamodb.GetItemOutput{
+		Item: map[string]types.AttributeValue{
+			"Name":        &types.AttributeValueMemberS{Value: "John Doe"},
+			"Phone":       &types.AttributeValueMemberS{Value: "+11234567890"},
+			"SetupStage":  &types.AttributeValueMemberN{Value: "99"},
+			"SetupStatus": &types.AttributeValueMemberS{Value: "completed"},
+		},
+	}
+
+	// empty prayer bucket that was last updated just now
+	limiter := func(throttled bool) *dynamodb.GetItemOutput {
+		return &dynamodb.GetItemOutput{
+			Item: map[string]types.AttributeValue{
+				"Buckets": &types.AttributeValueMemberM{
+					Value: map[string]types.AttributeValue{
+						object.RateLimitPrayer: &types.AttributeValueMemberM{
+							Value: map[string]types.AttributeValue{
+								"Tokens":  &types.AttributeValueMemberN{Value: "0"},
+								"Updated": &types.AttributeValueMemberN{Value: strconv.FormatInt(time.Now().Unix(), 10)},
+							},
+						},
+					},
+				},
+				"Key":       &types.AttributeValueMemberS{Value: object.RateLimiterKeyPrefix + "+11234567890"},
+				"Throttled": &types.AttributeValueMemberBOOL{Value: throttled},
+			},
+		}
+	}
+
+	testCases := []TestCase{
+		{
+			description: "Member over the prayer rate limit gets the throttle reply",
+
+			initialMessage: messaging.TextMessage{
+				Body:  "I need prayer for...",
+				Phone: "+11234567890",
+			},
+
+			mockGetItemResults: []struct {
+				Output *dynamodb.GetItemOutput
+				Error  error
+			}{
+				{
+					// StateTracker empty get response. It would over complicate to test this here
+					Output: &dynamodb.GetItemOutput{},
+					Error:  nil,
+				},
+				{Output: member, Error: nil},
+				{Output: limiter(false), Error: nil},
+			},
+
+			expectedTexts: []messaging.TextMessage{
+				{
+					Body:  msgText(messaging.MsgRateLimited, nil),
+					Phone: "+11234567890",
+				},
+			},
+
+			expectedGetItemCalls:  5,
+			expectedPutItemCalls:  4,
+			expectedSendTextCalls: 1,
+		},
+		{
+			description: "Member that already got the throttle reply is dropped silently",
+
+			initialMessage: messaging.TextMessage{
+				Body:  "I need prayer for...",
+				Phone: "+11234567890",
+			},
+
+			mockGetItemResults: []struct {
+				Output *dynamodb.GetItemOutput
+				Error  error
+			}{
+				{
+					// StateTracker empty get response. It would over complicate to test this here
+					Output: &dynamodb.GetItemOutput{},
+					Error:  nil,
+				},
+				{Output: member, Error: nil},
+				{Output: limiter(true), Error: nil},
+			},
+
+			expectedGetItemCalls: 5,
+			expectedPutItemCalls: 4,
+		},
+		{
+			description: "HELP is never rate limited and does not read the rate limiter",
+
+			initialMessage: messaging.TextMessage{
+				Body:  "help",
+				Phone: "+11234567890",
+			},
+
+			mockGetItemResults: []struct {
+				Output *dynamodb.GetItemOutput
+				Error  error
+			}{
+				{
+					// StateTracker empty get response. It would over complicate to test this here
+					Output: &dynamodb.GetItemOutput{},
+					Error:  nil,
+				},
+				{Output: member, Error: nil},
+			},
+
+			expectedTexts: []messaging.TextMessage{
+				{
+					Body:  msgText(messaging.MsgHelp, nil),
+					Phone: "+11234567890",
+				},
+			},
+
+			expectedGetItemCalls:  4,
+			expectedPutItemCalls:  3,
+			expectedSendTextCalls: 1,
+		},
+	}
+
+	for _, test := range testCases {
+		txtMock := &mock.TextSender{}
+		ddbMock := &mock.DDBConnecter{}
+
+		t.Run(test.description, func(t *testing.T) {
+			setMocks(ddbMock, txtMock, test)
+
+			if err := prayertexter.MainFlow(test.initialMessage, cfg, ddbMock, txtMock); err != nil {
+				t.Fatalf("unexpected error starting MainFlow: %v", err)
+			}
+
+			testNumMethodCalls(ddbMock, txtMock, t, test)
+			testTxtMessage(txtMock, t, test)
+		})
+	}
+}
+
+func TestMainFlowModeration(t *testing.T) {
+	cfg := config.Config{ModeratePrayers: true, Moderators: []string{"+19990001111"}}
+
+	member := &dynamodb.GetItemOutput{
+		Item: map[string]types.AttributeValue{
+			"Name":        &types.AttributeValueMemberS{Value: "John Doe"},
+			"Phone":       &types.AttributeValueMemberS{Value: "+11234567890"},
+			"SetupStage":  &types.AttributeValueMemberN{Value: "99"},
+			"SetupStatus": &types.AttributeValueMemberS{Value: "completed"},
+		},
+	}
+
+	pending := &dynamodb.GetItemOutput{
+		Item: map[string]types.AttributeValue{
+			"ID":      &types.AttributeValueMemberS{Value: "1a2b3c4d"},
+			"Request": &types.AttributeValueMemberS{Value: "I need prayer for..."},
+			"Requestor": &types.AttributeValueMemberM{
+				Value: map[string]types.AttributeValue{
+					"Name":  &types.AttributeValueMemberS{Value: "John Doe"},
+					"Phone": &types.AttributeValueMemberS{Value: "+11234567890"},
+				},
+			},
+			"Status": &types.AttributeValueMemberS{Value: object.PrayerPending},
+		},
+	}
+
+	otherTenantPending := &dynamodb.GetItemOutput{Item: maps.Clone(pending.Item)}
+	otherTenantPending.Item["Requestor"] = &types.AttributeValueMemberM{
+		Value: map[string]types.AttributeValue{
+			"Name":        &types.AttributeValueMemberS{Value: "John Doe"},
+			"Phone":       &types.AttributeValueMemberS{Value: "+11234567890"},
+			"TenantPhone": &types.AttributeValueMemberS{Value: "+15550009999"},
+		},
+	}
+
+	t.Run("Prayer request is held and the moderator is asked to approve it", func(t *testing.T) {
+		txtMock := &mock.TextSender{}
+		ddbMock := &mock.DDBConnecter{}
+		ddbMock.GetItemResults = []struct {
+			Output *dynamodb.GetItemOutput
+			Error  error
+		}{
+			{Output: &dynamodb.GetItemOutput{}, Error: nil},
+			{Output: member, Error: nil},
+		}
+
+		msg := messaging.TextMessage{Body: "I need prayer for...", Phone: "+11234567890"}
+		if err := prayertexter.MainFlow(msg, cfg, ddbMock, txtMock); err != nil {
+			t.Fatalf("unexpected error starting MainFlow: %v", err)
+		}
+
+		testNumMethodCalls(ddbMock, txtMock, t, TestCase{
+			expectedGetItemCalls: 4, expectedPutItemCalls: 4, expectedSendTextCalls: 2,
+		})
+
+		// the PendingPrayer is put between the prayer request stage and final StateTracker puts
+		var pryr object.PendingPrayer
+		if err := attributevalue.UnmarshalMap(ddbMock.PutItemInputs[2].Item, &pryr); err != nil {
+			t.Fatalf("failed to unmarshal PutItemInput into PendingPrayer: %v", err)
+		}
+
+		if pryr.Status != object.PrayerPending || len(pryr.ID) != 8 || pryr.Request != msg.Body ||
+			pryr.Requestor.Phone != msg.Phone {
+			t.Errorf("unexpected PendingPrayer %v", pryr)
+		}
+
+		testTxtMessage(txtMock, t, TestCase{expectedTexts: []messaging.TextMessage{
+			{
+				Body: msgText(messaging.MsgModerationRequest,
+					map[string]string{"ID": pryr.ID, "Name": "John Doe", "Request": msg.Body}),
+				Phone: "+19990001111",
+			},
+			{
+				Body:  msgText(messaging.MsgPrayerPending, nil),
+				Phone: "+11234567890",
+			},
+		}})
+	})
+
+	testCases := []TestCase{
+		{
+			description: "Moderator rejects a pending prayer request",
+
+			initialMessage: messaging.TextMessage{
+				Body:  "reject 1a2b3c4d",
+				Phone: "+19990001111",
+			},
+
+			mockGetItemResults: []struct {
+				Output *dynamodb.GetItemOutput
+				Error  error
+			}{
+				{
+					// StateTracker empty get response. It would over complicate to test this here
+					Output: &dynamodb.GetItemOutput{},
+					Error:  nil,
+				},
+				{
+					// moderator is not a member
+					Output: &dynamodb.GetItemOutput{},
+					Error:  nil,
+				},
+				{
+					// StateTracker empty get response. It would over complicate to test this here
+					Output: &dynamodb.GetItemOutput{},
+					Error:  nil,
+				},
+				{Output: pending, Error: nil},
+			},
+
+			expectedTexts: []messaging.TextMessage{
+				{
+					Body:  msgText(messaging.MsgPrayerRejected, nil),
+					Phone: "+11234567890",
+				},
+				{
+					Body:  msgText(messaging.MsgModerationRejected, map[string]string{"ID": "1a2b3c4d"}),
+					Phone: "+19990001111",
+				},
+			},
+
+			expectedGetItemCalls:  5,
+			expectedPutItemCalls:  4,
+			expectedSendTextCalls: 2,
+		},
+		{
+			description: "Moderator can not decide prayer requests of another tenant",
+
+			initialMessage: messaging.TextMessage{
+				Body:  "approve 1a2b3c4d",
+				Phone: "+19990001111",
+			},
+
+			mockGetItemResults: []struct {
+				Output *dynamodb.GetItemOutput
+				Error  error
+			}{
+				{Output: &dynamodb.GetItemOutput{}, Error: nil},
+				{Output: &dynamodb.GetItemOutput{}, Error: nil},
+				{Output: &dynamodb.GetItemOutput{}, Error: nil},
+				{Output: otherTenantPending, Error: nil},
+			},
+
+			expectedTexts: []messaging.TextMessage{
+				{
+					Body:  msgText(messaging.MsgModerationNotFound, map[string]string{"ID": "1a2b3c4d"}),
+					Phone: "+19990001111",
+				},
+			},
+
+			expectedGetItemCalls:  5,
+			expectedPutItemCalls:  3,
+			expectedSendTextCalls: 1,
+		},
+		{
+			description: "Moderator approves a prayer request that does not exist",
+
+			initialMessage: messaging.TextMessage{
+				Body:  "approve 1a2b3c4d",
+				Phone: "+19990001111",
+			},
+
+			expectedTexts: []messaging.TextMessage{
+				{
+					Body:  msgText(messaging.MsgModerationNotFound, map[string]string{"ID": "1a2b3c4d"}),
+					Phone: "+19990001111",
+				},
+			},
+
+			expectedGetItemCalls:  5,
+			expectedPutItemCalls:  3,
+			expectedSendTextCalls: 1,
+		},
+		{
+			description: "Moderator blocks a phone",
+
+			initialMessage: messaging.TextMessage{
+				Body:  "block +15556667777",
+				Phone: "+19990001111",
+			},
+
+			expectedTexts: []messaging.TextMessage{
+				{
+					Body:  msgText(messaging.MsgPhoneBlocked, map[string]string{"Phone": "+15556667777"}),
+					Phone: "+19990001111",
+				},
+			},
+
+			expectedGetItemCalls:  5,
+			expectedPutItemCalls:  4,
+			expectedSendTextCalls: 1,
+		},
+		{
+			description: "Moderator block of an invalid phone blocks nothing",
+
+			initialMessage: messaging.TextMessage{
+				Body:  "block 5556667777",
+				Phone: "+19990001111",
+			},
+
+			expectedTexts: []messaging.TextMessage{
+				{
+					Body:  msgText(messaging.MsgInvalidPhone, map[string]string{"Phone": "5556667777"}),
+					Phone: "+19990001111",
+				},
+			},
+
+			expectedGetItemCalls:  4,
+			expectedPutItemCalls:  3,
+			expectedSendTextCalls: 1,
+		},
+		{
+			description: "Moderator commands from other phones are dropped",
+
+			initialMessage: messaging.TextMessage{
+				Body:  "block +15556667777",
+				Phone: "+11234567890",
+			},
+
+			expectedGetItemCalls: 4,
+			expectedPutItemCalls: 3,
+		},
+	}
+
+	for _, test := range testCases {
+		txtMock := &mock.TextSender{}
+		ddbMock := &mock.DDBConnecter{}
+
+		t.Run(test.description, func(t *testing.T) {
+			setMocks(ddbMock, txtMock, test)
+
+			if err := prayertexter.MainFlow(test.initialMessage, cfg, ddbMock, txtMock); err != nil {
+				t.Fatalf("unexpected error starting MainFlow: %v", err)
+			}
+
+			testNumMethodCalls(ddbMock, txtMock, t, test)
+			testTxtMessage(txtMock, t, test)
+		})
+	}
+}
+
+func TestModeratePrayerDecided(t *testing.T) {
+	cfg := config.Config{ModeratePrayers: true, Moderators: []string{"+19990001111", "+19990002222"}}
+	item, err := attributevalue.MarshalMap(object.PendingPrayer{
+		ID:        "1a2b3c4d",
+		Request:   "I need prayer for...",
+		Requestor: object.Member{Name: "John Doe", Phone: "+11234567890"},
+		Status:    object.PrayerPending,
+	})
+	if err != nil {
+		t.Fatalf("failed to marshal PendingPrayer: %v", err)
+	}
+
+	// another moderator decided the prayer request after it was read
+	txtMock := &mock.TextSender{}
+	ddbMock := &mock.DDBConnecter{}
+	ddbMock.GetItemResults = []struct {
+		Output *dynamodb.GetItemOutput
+		Error  error
+	}{
+		{Output: &dynamodb.GetItemOutput{Item: item}, Error: nil},
+	}
+	ddbMock.PutItemResults = []struct {
+		Error error
+	}{
+		{Error: &types.ConditionalCheckFailedException{}},
+	}
+
+	_, err = prayertexter.ModeratePrayer("1a2b3c4d", true, "+19990002222", "", cfg, ddbMock, txtMock)
+	if !errors.Is(err, prayertexter.ErrNoPendingPrayer) {
+		t.Errorf("expected ErrNoPendingPrayer, got %v", err)
+	}
+	if txtMock.SendTextCalls != 0 {
+		t.Errorf("expected nothing to be sent, got %v texts", txtMock.SendTextCalls)
+	}
+	if cond := ddbMock.PutItemInputs[0].ConditionExpression; cond == nil || *cond != "#status = :pending" {
+		t.Errorf("expected decision to be put only if the prayer request is pending, got %v", cond)
+	}
+}
+
+func TestMainFlowAdmin(t *testing.T) {
+	cfg := config.Config{Admins: []string{"+19990002222"}, MonthlySegmentBudget: 100}
+	admin := "+19990002222"
+
+	scanItems := func(objs ...any) *dynamodb.ScanOutput {
+		out := &dynamodb.ScanOutput{}
+		for _, obj := range objs {
+			item, err := attributevalue.MarshalMap(obj)
+			if err != nil {
+				t.Fatalf("failed to marshal %v: %v", obj, err)
+			}
+			out.Items = append(out.Items, item)
+		}
+		return out
+	}
+
+	t.Run("Admin asks for stats", func(t *testing.T) {
+		txtMock := &mock.TextSender{}
+		ddbMock := &mock.DDBConnecter{}
+		ddbMock.GetItemResults = []struct {
+			Output *dynamodb.GetItemOutput
+			Error  error
+		}{
+			{Output: &dynamodb.GetItemOutput{}, Error: nil},
+			{Output: &dynamodb.GetItemOutput{}, Error: nil},
+			{Output: &dynamodb.GetItemOutput{}, Error: nil},
+			{
+				Output: &dynamodb.GetItemOutput{
+					Item: map[string]types.AttributeValue{
+						"Completed": &types.AttributeValueMemberN{Value: "12"},
+						"Key":       &types.AttributeValueMemberS{Value: object.PrayerStatsKey(time.Now())},
+					},
+				},
+				Error: nil,
+			},
+		}
+		ddbMock.ScanResults = []struct {
+			Output *dynamodb.ScanOutput
+			Error  error
+		}{
+			{
+				Output: scanItems(
+					object.Member{Intercessor: true, Phone: "+11111111111", SetupStatus: "completed"},
+					object.Member{Phone: "+12222222222", SetupStatus: "completed"},
+					object.Member{Phone: "+13333333333", SetupStatus: "in-progress"},
+				),
+				Error: nil,
+			},
+			{Output: scanItems(object.Prayer{IntercessorPhone: "+11111111111"}), Error: nil},
+			{
+				Output: scanItems(object.Prayer{IntercessorPhone: "1a2b"}, object.Prayer{IntercessorPhone: "3c4d"}),
+				Error:  nil,
+			},
+		}
+
+		msg := messaging.TextMessage{Body: "#ADMIN stats", Phone: admin}
+		if err := prayertexter.MainFlow(msg, cfg, ddbMock, txtMock); err != nil {
+			t.Fatalf("unexpected error starting MainFlow: %v", err)
+		}
+
+		test := TestCase{
+			expectedGetItemCalls:  5,
+			expectedPutItemCalls:  3,
+			expectedSendTextCalls: 1,
+			expectedTexts: []messaging.TextMessage{
+				{
+					Body: msgText(messaging.MsgAdminStats, map[string]string{
+						"Active": "1", "Completed": "12", "Intercessors": "1", "Members": "2", "Queued": "2",
+					}),
+					Phone: admin,
+				},
+			},
+		}
+		testNumMethodCalls(ddbMock, txtMock, t, test)
+		testTxtMessage(txtMock, t, test)
+	})
+
+	t.Run("Admin broadcasts to intercessors", func(t *testing.T) {
+		txtMock := &mock.TextSender{}
+		ddbMock := &mock.DDBConnecter{}
+		ddbMock.GetItemResults = []struct {
+			Output *dynamodb.GetItemOutput
+			Error  error
+		}{
+			{Output: &dynamodb.GetItemOutput{}, Error: nil},
+			{Output: &dynamodb.GetItemOutput{}, Error: nil},
+			{Output: &dynamodb.GetItemOutput{}, Error: nil},
+			// empty usage means nothing was sent this month
+			{Output: &dynamodb.GetItemOutput{}, Error: nil},
+			{
+				Output: &dynamodb.GetItemOutput{
+					Item: map[string]types.AttributeValue{
+						"Key": &types.AttributeValueMemberS{Value: object.IntercessorPhonesKey},
+						"Phones": &types.AttributeValueMemberL{Value: []types.AttributeValue{
+							&types.AttributeValueMemberS{Value: "+11111111111"},
+							&types.AttributeValueMemberS{Value: "+12222222222"},
+						}},
+					},
+				},
+				Error: nil,
+			},
+		}
+
+		msg := messaging.TextMessage{Body: "#admin broadcast Prayer night is on Friday!", Phone: admin}
+		if err := prayertexter.MainFlow(msg, cfg, ddbMock, txtMock); err != nil {
+			t.Fatalf("unexpected error starting MainFlow: %v", err)
+		}
+
+		test := TestCase{
+			expectedGetItemCalls:  6,
+			expectedPutItemCalls:  3,
+			expectedSendTextCalls: 3,
+			expectedTexts: []messaging.TextMessage{
+				{Body: "Prayer night is on Friday!", Phone: "+11111111111"},
+				{Body: "Prayer night is on Friday!", Phone: "+12222222222"},
+				{Body: msgText(messaging.MsgAdminBroadcast, map[string]string{"Count": "2"}), Phone: admin},
+			},
+		}
+		testNumMethodCalls(ddbMock, txtMock, t, test)
+		testTxtMessage(txtMock, t, test)
+	})
+
+	t.Run("Admin retries the queue without available intercessors", func(t *testing.T) {
+		txtMock := &mock.TextSender{}
+		ddbMock := &mock.DDBConnecter{}
+		ddbMock.ScanResults = []struct {
+			Output *dynamodb.ScanOutput
+			Error  error
+		}{
+			{
+				Output: scanItems(object.Prayer{IntercessorPhone: "1a2b", Request: "pray for me",
+					Requestor: object.Member{Phone: "+11234567890"}}),
+				Error: nil,
+			},
+		}
+
+		msg := messaging.TextMessage{Body: "#admin retry", Phone: admin}
+		if err := prayertexter.MainFlow(msg, cfg, ddbMock, txtMock); err != nil {
+			t.Fatalf("unexpected error starting MainFlow: %v", err)
+		}
+
+		test := TestCase{
+			expectedGetItemCalls:  5,
+			expectedPutItemCalls:  3,
+			expectedSendTextCalls: 1,
+			expectedTexts: []messaging.TextMessage{
+				{Body: msgText(messaging.MsgAdminRetry, map[string]string{"Queued": "1", "Sent": "0"}), Phone: admin},
+			},
+		}
+		testNumMethodCalls(ddbMock, txtMock, t, test)
+		testTxtMessage(txtMock, t, test)
+	})
+
+	t.Run("Admin blocks a phone written with spaces and dashes", func(t *testing.T) {
+		txtMock := &mock.TextSender{}
+		ddbMock := &mock.DDBConnecter{}
+
+		msg := messaging.TextMessage{Body: "#admin block +1 555-666-7777", Phone: admin}
+		if err := prayertexter.MainFlow(msg, cfg, ddbMock, txtMock); err != nil {
+			t.Fatalf("unexpected error starting MainFlow: %v", err)
+		}
+
+		test := TestCase{
+			expectedGetItemCalls:  5,
+			expectedPutItemCalls:  4,
+			expectedSendTextCalls: 1,
+			expectedTexts: []messaging.TextMessage{
+				{Body: msgText(messaging.MsgPhoneBlocked, map[string]string{"Phone": "+15556667777"}), Phone: admin},
+			},
+		}
+		testNumMethodCalls(ddbMock, txtMock, t, test)
+		testTxtMessage(txtMock, t, test)
+
+		blocked := false
+		for _, input := range ddbMock.PutItemInputs {
+			var b object.BlockedPhones
+			if err := attributevalue.UnmarshalMap(input.Item, &b); err == nil && b.Key == object.BlockedPhonesKey {
+				blocked = reflect.DeepEqual(b.Phones, []string{"+15556667777"})
+			}
+		}
+		if !blocked {
+			t.Errorf("expected +15556667777 to be blocked, got %v", ddbMock.PutItemInputs)
+		}
+	})
+
+	t.Run("Admin block of an invalid phone blocks nothing", func(t *testing.T) {
+		txtMock := &mock.TextSender{}
+		ddbMock := &mock.DDBConnecter{}
+
+		msg := messaging.TextMessage{Body: "#admin block John", Phone: admin}
+		if err := prayertexter.MainFlow(msg, cfg, ddbMock, txtMock); err != nil {
+			t.Fatalf("unexpected error starting MainFlow: %v", err)
+		}
+
+		test := TestCase{
+			expectedGetItemCalls:  4,
+			expectedPutItemCalls:  3,
+			expectedSendTextCalls: 1,
+			expectedTexts: []messaging.TextMessage{
+				{Body: msgText(messaging.MsgInvalidPhone, map[string]string{"Phone": "John"}), Phone: admin},
+			},
+		}
+		testNumMethodCalls(ddbMock, txtMock, t, test)
+		testTxtMessage(txtMock, t, test)
+	})
+
+	t.Run("Unknown admin command replies with the admin commands", func(t *testing.T) {
+		txtMock := &mock.TextSender{}
+		ddbMock := &mock.DDBConnecter{}
+
+		msg := messaging.TextMessage{Body: "#admin block", Phone: admin}
+		if err := prayertexter.MainFlow(msg, cfg, ddbMock, txtMock); err != nil {
+			t.Fatalf("unexpected error starting MainFlow: %v", err)
+		}
+
+		test := TestCase{
+			expectedGetItemCalls:  4,
+			expectedPutItemCalls:  3,
+			expectedSendTextCalls: 1,
+			expectedTexts: []messaging.TextMessage{
+				{Body: msgText(messaging.MsgAdminHelp, map[string]string{"Prefix": "#admin"}), Phone: admin},
+			},
+		}
+		testNumMethodCalls(ddbMock, txtMock, t, test)
+		testTxtMessage(txtMock, t, test)
+	})
+
+	t.Run("Admin commands of other phones are dropped", func(t *testing.T) {
+		txtMock := &mock.TextSender{}
+		ddbMock := &mock.DDBConnecter{}
+
+		msg := messaging.TextMessage{Body: "#admin stats", Phone: "+11234567890"}
+		if err := prayertexter.MainFlow(msg, cfg, ddbMock, txtMock); err != nil {
+			t.Fatalf("unexpected error starting MainFlow: %v", err)
+		}
+
+		if ddbMock.ScanCalls != 0 || txtMock.SendTextCalls != 0 {
+			t.Errorf("expected message to be dropped, got %v scans and %v texts", ddbMock.ScanCalls,
+				txtMock.SendTextCalls)
+		}
+	})
+}
+
+func TestMainFlowContentFilter(t *testing.T) {
+	cfg := config.Config{
+		ContentFilters: []config.ContentFilter{
+			{Action: filter.ActionReject, Type: filter.TypePhone},
+			{Action: filter.ActionRedact, Type: filter.TypeEmail},
+			{Action: filter.ActionFlag, Type: filter.TypeURL},
+		},
+		Moderators: []string{"+19990001111"},
+	}
+
+	member := &dynamodb.GetItemOutput{
+		Item: map[string]types.AttributeValue{
+			"Name":        &types.AttributeValueMemberS{Value: "John Doe"},
+			"Phone":       &types.AttributeValueMemberS{Value: "+11234567890"},
+			"SetupStage":  &types.AttributeValueMemberN{Value: "99"},
+			"SetupStatus": &types.AttributeValueMemberS{Value: "completed"},
+		},
+	}
+
+	t.Run("Prayer request with a phone number is rejected", func(t *testing.T) {
+		txtMock := &mock.TextSender{}
+		ddbMock := &mock.DDBConnecter{}
+		ddbMock.GetItemResults = []struct {
+			Output *dynamodb.GetItemOutput
+			Error  error
+		}{
+			{Output: &dynamodb.GetItemOutput{}, Error: nil},
+			{Output: member, Error: nil},
+		}
+
+		msg := messaging.TextMessage{Body: "please call me at 555-555-5555", Phone: "+11234567890"}
+		if err := prayertexter.MainFlow(msg, cfg, ddbMock, txtMock); err != nil {
+			t.Fatalf("unexpected error starting MainFlow: %v", err)
+		}
+
+		test := TestCase{
+			expectedGetItemCalls:  4,
+			expectedPutItemCalls:  3,
+			expectedSendTextCalls: 1,
+			expectedTexts: []messaging.TextMessage{
+				{Body: msgText(messaging.MsgContactInfoFound, nil), Phone: "+11234567890"},
+			},
+		}
+		testNumMethodCalls(ddbMock, txtMock, t, test)
+		testTxtMessage(txtMock, t, test)
+	})
+
+	t.Run("Email is redacted and prayer request with a link is held for review", func(t *testing.T) {
+		txtMock := &mock.TextSender{}
+		ddbMock := &mock.DDBConnecter{}
+		ddbMock.GetItemResults = []struct {
+			Output *dynamodb.GetItemOutput
+			Error  error
+		}{
+			{Output: &dynamodb.GetItemOutput{}, Error: nil},
+			{Output: member, Error: nil},
+		}
+
+		msg := messaging.TextMessage{Body: "email mom@example.com or see example.org", Phone: "+11234567890"}
+		if err := prayertexter.MainFlow(msg, cfg, ddbMock, txtMock); err != nil {
+			t.Fatalf("unexpected error starting MainFlow: %v", err)
+		}
+
+		testNumMethodCalls(ddbMock, txtMock, t, TestCase{
+			expectedGetItemCalls: 4, expectedPutItemCalls: 4, expectedSendTextCalls: 2,
+		})
+
+		var pryr object.PendingPrayer
+		if err := attributevalue.UnmarshalMap(ddbMock.PutItemInputs[2].Item, &pryr); err != nil {
+			t.Fatalf("failed to unmarshal PutItemInput into PendingPrayer: %v", err)
+		}
+
+		if expected := "email " + filter.Redacted + " or see example.org"; pryr.Request != expected {
+			t.Errorf("expected held prayer request %q, got %q", expected, pryr.Request)
+		}
+	})
+}
+
+func TestMainFlowTenant(t *testing.T) {
+	tenantItem := &dynamodb.GetItemOutput{
+		Item: map[string]types.AttributeValue{
+			"Key":   &types.AttributeValueMemberS{Value: object.TenantKeyPrefix + "+19998887777"},
+			"Name":  &types.AttributeValueMemberS{Value: "Grace Church"},
+			"Phone": &types.AttributeValueMemberS{Value: "+19998887777"},
+			"TemplateVars": &types.AttributeValueMemberM{
+				Value: map[string]types.AttributeValue{
+					"SupportPhone": &types.AttributeValueMemberS{Value: "(555) 555-5555"},
+				},
+			},
+			"Templates": &types.AttributeValueMemberM{
+				Value: map[string]types.AttributeValue{
+					messaging.MsgHelp: &types.AttributeValueMemberS{Value: "Please call Grace Church at {{.SupportPhone}}"},
+				},
+			},
+		},
+	}
+
+	testCases := []TestCase{
+		{
+			description: "Non member texts help to a tenant number and receives that tenant's help message",
+
+			initialMessage: messaging.TextMessage{
+				Body:        "help",
+				Phone:       "+11234567890",
+				TenantPhone: "+19998887777",
+			},
+
+			mockGetItemResults: []struct {
+				Output *dynamodb.GetItemOutput
+				Error  error
+			}{
+				{
+					// StateTracker empty get response. It would over complicate to test this here
+					Output: &dynamodb.GetItemOutput{},
+					Error:  nil,
+				},
+				{
+					Output: &dynamodb.GetItemOutput{},
+					Error:  nil,
+				},
+				{
+					Output: tenantItem,
+					Error:  nil,
+				},
+			},
+
+			expectedTexts: []messaging.TextMessage{
+				{
+					Body:  "Please call Grace Church at (555) 555-5555",
+					Phone: "+11234567890",
+				},
+			},
+
+			expectedGetItemCalls:  5,
+			expectedPutItemCalls:  3,
+			expectedSendTextCalls: 1,
+		},
+		{
+			description: "Non member texts pray to a tenant number and gets signed up to that tenant",
+
+			initialMessage: messaging.TextMessage{
+				Body:        "pray",
+				Phone:       "+11234567890",
+				TenantPhone: "+19998887777",
+			},
+
+			mockGetItemResults: []struct {
+				Output *dynamodb.GetItemOutput
+				Error  error
+			}{
+				{
+					// StateTracker empty get response. It would over complicate to test this here
+					Output: &dynamodb.GetItemOutput{},
+					Error:  nil,
+				},
+				{
+					Output: &dynamodb.GetItemOutput{},
+					Error:  nil,
+				},
+				{
+					Output: tenantItem,
+					Error:  nil,
+				},
+			},
+
+			expectedMembers: []object.Member{
+				{
+					Language:    "en",
+					Phone:       "+11234567890",
+					SetupStage:  1,
+					SetupStatus: "in-progress",
+					TenantPhone: "+19998887777",
+					Version:     1,
+				},
+			},
+
+			expectedTexts: []messaging.TextMessage{
+				{
+					Body:  msgText(messaging.MsgNameRequest, nil),
+					Phone: "+11234567890",
+				},
+			},
+
+			expectedGetItemCalls:  5,
+			expectedPutItemCalls:  4,
+			expectedSendTextCalls: 1,
+		},
+		{
+			description: "Member of the default tenant texts a prayer request to a different tenant number - message dropped",
+
+			initialMessage: messaging.TextMessage{
+				Body:        "I need prayer for...",
+				Phone:       "+11234567890",
+				TenantPhone: "+19998887777",
+			},
+
+			mockGetItemResults: []struct {
+				Output *dynamodb.GetItemOutput
+				Error  error
+			}{
+				{
+					// StateTracker empty get response. It would over complicate to test this here
+					Output: &dynamodb.GetItemOutput{},
+					Error:  nil,
+				},
+				{
+					Output: &dynamodb.GetItemOutput{
+						Item: map[string]types.AttributeValue{
+							"Name":        &types.AttributeValueMemberS{Value: "John Doe"},
+							"Phone":       &types.AttributeValueMemberS{Value: "+11234567890"},
+							"SetupStage":  &types.AttributeValueMemberN{Value: "99"},
+							"SetupStatus": &types.AttributeValueMemberS{Value: "completed"},
+						},
+					},
+					Error: nil,
+				},
+				{
+					Output: tenantItem,
+					Error:  nil,
+				},
+			},
+
+			expectedGetItemCalls: 5,
+			expectedPutItemCalls: 3,
+		},
+		{
+			description: "Text to an unconfigured tenant number returns an error",
+
+			initialMessage: messaging.TextMessage{
+				Body:        "pray",
+				Phone:       "+11234567890",
+				TenantPhone: "+19998887777",
+			},
+
+			expectedError:        true,
+			expectedGetItemCalls: 3,
+			expectedPutItemCalls: 1,
+		},
+	}
+
+	for _, test := range testCases {
+		txtMock := &mock.TextSender{}
+		ddbMock := &mock.DDBConnecter{}
+
+		t.Run(test.description, func(t *testing.T) {
+			setMocks(ddbMock, txtMock, test)
+
+			if test.expectedError {
+				// handles failures for error mocks
+				if err := prayertexter.MainFlow(test.initialMessage, config.Config{}, ddbMock, txtMock); err == nil {
+					t.Fatalf("expected error, got nil")
+				}
+				testNumMethodCalls(ddbMock, txtMock, t, test)
+				return
+			}
+
+			if err := prayertexter.MainFlow(test.initialMessage, config.Config{}, ddbMock, txtMock); err != nil {
 				t.Fatalf("unexpected error starting MainFlow: %v", err)
 			}
 
 			testNumMethodCalls(ddbMock, txtMock, t, test)
 			testTxtMessage(txtMock, t, test)
 			testMembers(ddbMock.PutItemInputs, t, test)
-			testPrayers(ddbMock.PutItemInputs, t, test, test.expectedPrayerQueue)
+
+			// replies must come from the tenant number that was texted
+			for _, input := range txtMock.SendTextInputs {
+				if *input.OriginationIdentity != test.initialMessage.TenantPhone {
+					t.Errorf("expected origination %v, got %v", test.initialMessage.TenantPhone,
+						*input.OriginationIdentity)
+				}
+			}
 		})
 	}
 }
@@ -1439,6 +2644,7 @@ func TestMainFlowPrayerRequest(t *testing.T) {
 					PrayerCount:       1,
 					SetupStage:        99,
 					SetupStatus:       "completed",
+					Version:           1,
 					WeeklyPrayerDate:  "dummy date/time",
 					WeeklyPrayerLimit: 5,
 				},
@@ -1449,6 +2655,7 @@ func TestMainFlowPrayerRequest(t *testing.T) {
 					PrayerCount:       1,
 					SetupStage:        99,
 					SetupStatus:       "completed",
+					Version:           1,
 					WeeklyPrayerDate:  "dummy date/time",
 					WeeklyPrayerLimit: 5,
 				},
@@ -1463,6 +2670,7 @@ func TestMainFlowPrayerRequest(t *testing.T) {
 						PrayerCount:       1,
 						SetupStage:        99,
 						SetupStatus:       "completed",
+						Version:           1,
 						WeeklyPrayerDate:  "dummy date/time",
 						WeeklyPrayerLimit: 5,
 					},
@@ -1483,6 +2691,7 @@ func TestMainFlowPrayerRequest(t *testing.T) {
 						PrayerCount:       1,
 						SetupStage:        99,
 						SetupStatus:       "completed",
+						Version:           1,
 						WeeklyPrayerDate:  "dummy date/time",
 						WeeklyPrayerLimit: 5,
 					},
@@ -1499,15 +2708,15 @@ func TestMainFlowPrayerRequest(t *testing.T) {
 
 			expectedTexts: []messaging.TextMessage{
 				{
-					Body:  messaging.MsgPrayerIntro,
+					Body:  msgText(messaging.MsgPrayerIntro, map[string]string{"Name": "John Doe", "Request": "I need prayer for..."}),
 					Phone: "+11111111111",
 				},
 				{
-					Body:  messaging.MsgPrayerIntro,
+					Body:  msgText(messaging.MsgPrayerIntro, map[string]string{"Name": "John Doe", "Request": "I need prayer for..."}),
 					Phone: "+12222222222",
 				},
 				{
-					Body:  messaging.MsgPrayerSentOut,
+					Body:  msgText(messaging.MsgPrayerSentOut, nil),
 					Phone: "+11234567890",
 				},
 			},
@@ -1548,7 +2757,48 @@ func TestMainFlowPrayerRequest(t *testing.T) {
 
 			expectedTexts: []messaging.TextMessage{
 				{
-					Body:  messaging.MsgProfanityFound,
+					Body:  msgText(messaging.MsgProfanityFound, map[string]string{"Profanity": "shit"}),
+					Phone: "+11234567890",
+				},
+			},
+
+			expectedGetItemCalls:  4,
+			expectedPutItemCalls:  3,
+			expectedSendTextCalls: 1,
+		},
+		{
+			description: "Prayer request over the max number of sms segments is rejected",
+
+			initialMessage: messaging.TextMessage{
+				Body:  strings.Repeat("please pray for my family ", 100),
+				Phone: "+11234567890",
+			},
+
+			mockGetItemResults: []struct {
+				Output *dynamodb.GetItemOutput
+				Error  error
+			}{
+				{
+					// StateTracker empty get response. It would over complicate to test this here
+					Output: &dynamodb.GetItemOutput{},
+					Error:  nil,
+				},
+				{
+					Output: &dynamodb.GetItemOutput{
+						Item: map[string]types.AttributeValue{
+							"Name":        &types.AttributeValueMemberS{Value: "John Doe"},
+							"Phone":       &types.AttributeValueMemberS{Value: "+11234567890"},
+							"SetupStage":  &types.AttributeValueMemberN{Value: "99"},
+							"SetupStatus": &types.AttributeValueMemberS{Value: "completed"},
+						},
+					},
+					Error: nil,
+				},
+			},
+
+			expectedTexts: []messaging.TextMessage{
+				{
+					Body:  msgText(messaging.MsgPrayerTooLong, nil),
 					Phone: "+11234567890",
 				},
 			},
@@ -1764,7 +3014,7 @@ func TestMainFlowPrayerRequest(t *testing.T) {
 
 			expectedTexts: []messaging.TextMessage{
 				{
-					Body:  messaging.MsgPrayerQueued,
+					Body:  msgText(messaging.MsgPrayerQueued, nil),
 					Phone: "+11234567890",
 				},
 			},
@@ -1785,13 +3035,13 @@ func TestMainFlowPrayerRequest(t *testing.T) {
 
 			if test.expectedError {
 				// handles failures for error mocks
-				if err := prayertexter.MainFlow(test.initialMessage, ddbMock, txtMock); err == nil {
+				if err := prayertexter.MainFlow(test.initialMessage, config.Config{}, ddbMock, txtMock); err == nil {
 					t.Fatalf("expected error, got nil")
 				}
 				testNumMethodCalls(ddbMock, txtMock, t, test)
 			} else {
 				// handles success test cases
-				if err := prayertexter.MainFlow(test.initialMessage, ddbMock, txtMock); err != nil {
+				if err := prayertexter.MainFlow(test.initialMessage, config.Config{}, ddbMock, txtMock); err != nil {
 					t.Fatalf("unexpected error starting MainFlow: %v", err)
 				}
 
@@ -1938,6 +3188,7 @@ func TestFindIntercessors(t *testing.T) {
 					PrayerCount:       1,
 					SetupStage:        99,
 					SetupStatus:       "completed",
+					Version:           1,
 					WeeklyPrayerDate:  "dummy date/time",
 					WeeklyPrayerLimit: 15,
 				},
@@ -1948,6 +3199,7 @@ func TestFindIntercessors(t *testing.T) {
 					PrayerCount:       5,
 					SetupStage:        99,
 					SetupStatus:       "completed",
+					Version:           1,
 					WeeklyPrayerDate:  "dummy date/time",
 					WeeklyPrayerLimit: 5,
 				},
@@ -2046,6 +3298,7 @@ func TestFindIntercessors(t *testing.T) {
 					PrayerCount:       5,
 					SetupStage:        99,
 					SetupStatus:       "completed",
+					Version:           1,
 					WeeklyPrayerDate:  "dummy date/time",
 					WeeklyPrayerLimit: 5,
 				},
@@ -2104,6 +3357,7 @@ func TestFindIntercessors(t *testing.T) {
 					PrayerCount:       2,
 					SetupStage:        99,
 					SetupStatus:       "completed",
+					Version:           1,
 					WeeklyPrayerDate:  "dummy date/time",
 					WeeklyPrayerLimit: 5,
 				},
@@ -2317,6 +3571,7 @@ func TestFindIntercessors(t *testing.T) {
 					PrayerCount:       2,
 					SetupStage:        99,
 					SetupStatus:       "completed",
+					Version:           1,
 					WeeklyPrayerDate:  "dummy date/time",
 					WeeklyPrayerLimit: 5,
 				},
@@ -2336,13 +3591,13 @@ func TestFindIntercessors(t *testing.T) {
 
 			if test.expectedError {
 				// handles failures for error mocks
-				if _, err := prayertexter.FindIntercessors(ddbMock, "+18888888888"); err == nil {
+				if _, err := prayertexter.FindIntercessors(ddbMock, object.Tenant{}, "+18888888888"); err == nil {
 					t.Fatalf("expected error, got nil")
 				}
 				testNumMethodCalls(ddbMock, txtMock, t, test)
 			} else {
 				// handles success test cases
-				_, err := prayertexter.FindIntercessors(ddbMock, "+18888888888")
+				_, err := prayertexter.FindIntercessors(ddbMock, object.Tenant{}, "+18888888888")
 				if err != nil {
 					t.Fatalf("unexpected error starting FindIntercessors: %v", err)
 				}
@@ -2449,17 +3704,17 @@ func TestMainFlowCompletePrayer(t *testing.T) {
 
 			expectedTexts: []messaging.TextMessage{
 				{
-					Body:  messaging.MsgPrayerThankYou,
+					Body:  msgText(messaging.MsgPrayerThankYou, nil),
 					Phone: "+11111111111",
 				},
 				{
-					Body:  messaging.MsgPrayerConfirmation,
+					Body:  msgText(messaging.MsgPrayerConfirmation, map[string]string{"Name": "Intercessor1"}),
 					Phone: "+11234567890",
 				},
 			},
 
 			expectedGetItemCalls:    6,
-			expectedPutItemCalls:    3,
+			expectedPutItemCalls:    5,
 			expectedDeleteItemCalls: 1,
 			expectedSendTextCalls:   2,
 		},
@@ -2544,13 +3799,13 @@ func TestMainFlowCompletePrayer(t *testing.T) {
 
 			expectedTexts: []messaging.TextMessage{
 				{
-					Body:  messaging.MsgPrayerThankYou,
+					Body:  msgText(messaging.MsgPrayerThankYou, nil),
 					Phone: "+11111111111",
 				},
 			},
 
 			expectedGetItemCalls:    6,
-			expectedPutItemCalls:    3,
+			expectedPutItemCalls:    5,
 			expectedDeleteItemCalls: 1,
 			expectedSendTextCalls:   1,
 		},
@@ -2590,7 +3845,7 @@ func TestMainFlowCompletePrayer(t *testing.T) {
 
 			expectedTexts: []messaging.TextMessage{
 				{
-					Body:  messaging.MsgNoActivePrayer,
+					Body:  msgText(messaging.MsgNoActivePrayer, nil),
 					Phone: "+11111111111",
 				},
 			},
@@ -2690,7 +3945,7 @@ func TestMainFlowCompletePrayer(t *testing.T) {
 
 			expectedError:           true,
 			expectedGetItemCalls:    6,
-			expectedPutItemCalls:    3,
+			expectedPutItemCalls:    5,
 			expectedDeleteItemCalls: 1,
 			expectedSendTextCalls:   2,
 		},
@@ -2705,13 +3960,13 @@ func TestMainFlowCompletePrayer(t *testing.T) {
 
 			if test.expectedError {
 				// handles failures for error mocks
-				if err := prayertexter.MainFlow(test.initialMessage, ddbMock, txtMock); err == nil {
+				if err := prayertexter.MainFlow(test.initialMessage, config.Config{}, ddbMock, txtMock); err == nil {
 					t.Fatalf("expected error, got nil")
 				}
 				testNumMethodCalls(ddbMock, txtMock, t, test)
 			} else {
 				// handles success test cases
-				if err := prayertexter.MainFlow(test.initialMessage, ddbMock, txtMock); err != nil {
+				if err := prayertexter.MainFlow(test.initialMessage, config.Config{}, ddbMock, txtMock); err != nil {
 					t.Fatalf("unexpected error starting MainFlow: %v", err)
 				}
 
@@ -2722,3 +3977,643 @@ func TestMainFlowCompletePrayer(t *testing.T) {
 		})
 	}
 }
+
+func TestEraseMemberData(t *testing.T) {
+	phone := "+11234567890"
+	mem := object.Member{Intercessor: true, Name: "John Doe", Phone: phone, SetupStatus: "completed"}
+	other := object.Member{Name: "Jane Doe", Phone: "+11111111111", SetupStatus: "completed"}
+	requested := object.Prayer{IntercessorPhone: other.Phone, Intercessor: other, Request: "pray for me",
+		Requestor: mem}
+	interceding := object.Prayer{IntercessorPhone: phone, Intercessor: mem, Request: "pray for Jane",
+		Requestor: other}
+	queued := object.Prayer{IntercessorPhone: "1a2b3c4d", Request: "pray again", Requestor: mem}
+	pending := object.PendingPrayer{ID: "5e6f7a8b", Request: "pray later", Requestor: mem,
+		Status: object.PrayerPending}
+	cfg := config.Config{PhoneHashKey: "secret"}
+	hash, otherHash := object.PhoneHash(cfg.PhoneHashKey, phone), object.PhoneHash(cfg.PhoneHashKey, other.Phone)
+	archRequested := object.ArchivedPrayer{ID: "a1", IntercessorHash: otherHash, Request: "old prayer",
+		RequestorHash: hash}
+	archInterceded := object.ArchivedPrayer{ID: "a2", IntercessorHash: hash, Request: "old prayer for Jane",
+		RequestorHash: otherHash}
+	state := object.State{ID: "s1", Message: messaging.TextMessage{Body: "pray", Phone: phone}}
+	otherState := object.State{ID: "s2", Message: messaging.TextMessage{Body: "pray", Phone: other.Phone}}
+	rl := object.PhoneRateLimiter(phone)
+	rl.ExpirationTime = 1700000000
+	inbound := object.InboundMessage{ExpirationTime: 1700000000, Key: object.InboundMessageKeyPrefix + "1",
+		Phone: phone}
+	usage := object.Usage{Key: object.UsageDayKey(time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)),
+		Members: map[string]object.UsageCount{phone: {Messages: 2, Segments: 2},
+			other.Phone: {Messages: 1, Segments: 1}}}
+	memberUsage := object.Usage{Key: usage.Key, Members: map[string]object.UsageCount{phone: usage.Members[phone]}}
+	blocked := object.BlockedPhones{Key: object.BlockedPhonesKey, Phones: []string{phone, other.Phone}, Version: 3}
+
+	items := func(objs ...any) []map[string]types.AttributeValue {
+		var list []map[string]types.AttributeValue
+		for _, obj := range objs {
+			item, err := attributevalue.MarshalMap(obj)
+			if err != nil {
+				t.Fatalf("failed to marshal %v: %v", obj, err)
+			}
+			list = append(list, item)
+		}
+		return list
+	}
+	// phone is also left in the list of another tenant
+	phones := object.IntercessorPhones{Key: object.IntercessorPhonesKey, Phones: []string{phone, other.Phone}}
+	tenantPhones := object.TenantIntercessorPhones("+15550009999")
+	tenantPhones.Phones = []string{phone}
+	otherPhones := object.TenantIntercessorPhones("+15550008888")
+	otherPhones.Phones = []string{other.Phone}
+	st := items(object.StateTracker{Key: object.StateTrackerKey, States: []object.State{state, otherState}})[0]
+	archRequest := func(arch object.ArchivedPrayer) map[string]types.AttributeValue {
+		return items(struct{ ID, Request string }{ID: object.ArchivedRequestKeyPrefix + arch.ID,
+			Request: arch.Request})[0]
+	}
+
+	ddbMock := &mock.DDBConnecter{}
+	ddbMock.GetItemResults = []struct {
+		Output *dynamodb.GetItemOutput
+		Error  error
+	}{
+		{Output: &dynamodb.GetItemOutput{Item: items(mem)[0]}, Error: nil},
+		{Output: &dynamodb.GetItemOutput{Item: archRequest(archRequested)}, Error: nil},
+		{Output: &dynamodb.GetItemOutput{Item: archRequest(archInterceded)}, Error: nil},
+		{Output: &dynamodb.GetItemOutput{Item: st}, Error: nil},
+		{Output: &dynamodb.GetItemOutput{Item: items(rl)[0]}, Error: nil},
+		{Output: &dynamodb.GetItemOutput{Item: items(blocked)[0]}, Error: nil},
+		{Output: &dynamodb.GetItemOutput{Item: items(phones)[0]}, Error: nil},
+		{Output: &dynamodb.GetItemOutput{Item: items(tenantPhones)[0]}, Error: nil},
+		{Output: &dynamodb.GetItemOutput{Item: st}, Error: nil},
+		{Output: &dynamodb.GetItemOutput{Item: items(blocked)[0]}, Error: nil},
+	}
+	ddbMock.ScanResults = []struct {
+		Output *dynamodb.ScanOutput
+		Error  error
+	}{
+		{Output: &dynamodb.ScanOutput{Items: items(requested, interceding)}, Error: nil},
+		{Output: &dynamodb.ScanOutput{Items: items(queued)}, Error: nil},
+		{Output: &dynamodb.ScanOutput{Items: items(pending)}, Error: nil},
+		{Output: &dynamodb.ScanOutput{Items: items(archRequested, archInterceded)}, Error: nil},
+		{Output: &dynamodb.ScanOutput{Items: items(inbound)}, Error: nil},
+		{Output: &dynamodb.ScanOutput{Items: items(usage)}, Error: nil},
+		{Output: &dynamodb.ScanOutput{Items: items(phones, tenantPhones, otherPhones)}, Error: nil},
+	}
+
+	data, err := prayertexter.EraseMemberData(phone, cfg, ddbMock)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	expectedData := prayertexter.MemberData{
+		ActivePrayers:   []object.Prayer{requested, interceding},
+		ArchivedPrayers: []object.ArchivedPrayer{archRequested, archInterceded},
+		Blocked:         true,
+		InboundMessages: []object.InboundMessage{inbound},
+		Member:          mem,
+		PendingPrayers:  []object.PendingPrayer{pending},
+		Phone:           phone,
+		QueuedPrayers:   []object.Prayer{queued},
+		RateLimiter:     rl,
+		States:          []object.State{state},
+		Usage:           []object.Usage{memberUsage},
+	}
+	if !reflect.DeepEqual(data, expectedData) {
+		t.Errorf("expected MemberData %v, got %v", expectedData, data)
+	}
+
+	if ddbMock.ScanCalls != 7 || ddbMock.GetItemCalls != 10 || ddbMock.PutItemCalls != 7 ||
+		ddbMock.DeleteItemCalls != 9 || ddbMock.UpdateItemCalls != 1 {
+		t.Fatalf("expected 7 Scan, 10 GetItem, 7 PutItem, 9 DeleteItem and 1 UpdateItem calls, got %v, %v, %v, %v "+
+			"and %v", ddbMock.ScanCalls, ddbMock.GetItemCalls, ddbMock.PutItemCalls, ddbMock.DeleteItemCalls,
+			ddbMock.UpdateItemCalls)
+	}
+
+	expectedDeletes := []struct {
+		key   string
+		table string
+	}{
+		{key: phone, table: object.MemberTable},
+		{key: other.Phone, table: object.ActivePrayersTable},
+		{key: phone, table: object.ActivePrayersTable},
+		{key: queued.IntercessorPhone, table: object.QueuedPrayersTable},
+		{key: pending.ID, table: object.PendingPrayersTable},
+		{key: object.ArchivedRequestKeyPrefix + archRequested.ID, table: object.ArchivedPrayersTable},
+		{key: archRequested.ID, table: object.ArchivedPrayersTable},
+		{key: rl.Key, table: object.RateLimiterTable},
+		{key: inbound.Key, table: object.InboundMessageTable},
+	}
+	for i, expected := range expectedDeletes {
+		input := ddbMock.DeleteItemInputs[i]
+		for _, key := range input.Key {
+			if key.(*types.AttributeValueMemberS).Value != expected.key || *input.TableName != expected.table {
+				t.Errorf("expected delete of %v in %v, got %v in %v", expected.key, expected.table,
+					key.(*types.AttributeValueMemberS).Value, *input.TableName)
+			}
+		}
+	}
+
+	for i, expected := range []object.IntercessorPhones{{Key: phones.Key, Phones: []string{other.Phone}},
+		{Key: tenantPhones.Key}} {
+		var removed object.IntercessorPhones
+		if err := attributevalue.UnmarshalMap(ddbMock.PutItemInputs[i].Item, &removed); err != nil {
+			t.Fatalf("failed to unmarshal PutItemInput into IntercessorPhones: %v", err)
+		}
+		if removed.Key != expected.Key || !reflect.DeepEqual(removed.Phones, expected.Phones) {
+			t.Errorf("expected phone to be removed from IntercessorPhones %v, got %v", expected.Key, removed)
+		}
+	}
+
+	var requeued object.Prayer
+	if err := attributevalue.UnmarshalMap(ddbMock.PutItemInputs[2].Item, &requeued); err != nil {
+		t.Fatalf("failed to unmarshal PutItemInput into Prayer: %v", err)
+	}
+	if *ddbMock.PutItemInputs[2].TableName != object.QueuedPrayersTable || requeued.Intercessor.Phone != "" ||
+		requeued.Requestor.Phone != other.Phone {
+		t.Errorf("expected prayer to be queued without intercessor, got %v", requeued)
+	}
+
+	var arch object.ArchivedPrayer
+	if err := attributevalue.UnmarshalMap(ddbMock.PutItemInputs[4].Item, &arch); err != nil {
+		t.Fatalf("failed to unmarshal PutItemInput into ArchivedPrayer: %v", err)
+	}
+	if arch.ID != archInterceded.ID || arch.IntercessorHash != "" {
+		t.Errorf("expected archived prayer without intercessor, got %v", arch)
+	}
+
+	var tracker object.StateTracker
+	if err := attributevalue.UnmarshalMap(ddbMock.PutItemInputs[5].Item, &tracker); err != nil {
+		t.Fatalf("failed to unmarshal PutItemInput into StateTracker: %v", err)
+	}
+	if !reflect.DeepEqual(tracker.States, []object.State{otherState}) {
+		t.Errorf("expected only states of other phones in StateTracker, got %v", tracker.States)
+	}
+
+	update := ddbMock.UpdateItemInputs[0]
+	if *update.UpdateExpression != "REMOVE #n0.#n1" || update.ExpressionAttributeNames["#n1"] != phone ||
+		update.Key[object.UsageAttribute].(*types.AttributeValueMemberS).Value != usage.Key {
+		t.Errorf("expected removal of the usage counters of phone, got %v %v", *update.UpdateExpression,
+			update.ExpressionAttributeNames)
+	}
+
+	var unblocked object.BlockedPhones
+	if err := attributevalue.UnmarshalMap(ddbMock.PutItemInputs[6].Item, &unblocked); err != nil {
+		t.Fatalf("failed to unmarshal PutItemInput into BlockedPhones: %v", err)
+	}
+	if !reflect.DeepEqual(unblocked.Phones, []string{other.Phone}) {
+		t.Errorf("expected phone to be removed from BlockedPhones, got %v", unblocked.Phones)
+	}
+}
+
+func TestEraseMemberDataMasked(t *testing.T) {
+	ddbClnt := db.NewMemoryDB(object.TableKeys())
+	cfg := config.Config{PhoneHashKey: "secret", Privacy: privacy.LevelMask}
+	phone, other := "+11234567890", "+19994567890"
+
+	// the sign up reply fails, which leaves a failed state with the masked phone behind
+	txtMock := &mock.TextSender{SendTextResults: []struct {
+		Error error
+	}{
+		{Error: errors.New("send text failure")},
+	}}
+	msg := messaging.TextMessage{Body: "pray", ID: "m1", Phone: phone}
+	if err := prayertexter.MainFlow(msg, cfg, ddbClnt, txtMock); err == nil {
+		t.Fatalf("expected error from failed sign up reply, got nil")
+	}
+
+	// a state from before PhoneHash was saved is found by the inbound message of the phone
+	unhashed := object.State{ID: "s1", Message: privacy.Message(messaging.TextMessage{Body: "pray", ID: "m0",
+		Phone: phone}, cfg.Privacy, cfg.PhoneHashKey), Status: "FAILED"}
+	if _, err := object.ClaimInboundMessage(ddbClnt, messaging.TextMessage{ID: "m0", Phone: phone},
+		time.Now()); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// other has the same masked phone
+	otherState := object.State{ID: "s2", Message: privacy.Message(messaging.TextMessage{Body: "pray", Phone: other},
+		cfg.Privacy, cfg.PhoneHashKey), PhoneHash: object.PhoneHash(cfg.PhoneHashKey, other), Status: "FAILED"}
+	for _, state := range []object.State{unhashed, otherState} {
+		if err := state.Update(ddbClnt, false); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+
+	data, err := prayertexter.EraseMemberData(phone, cfg, ddbClnt)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(data.States) != 2 {
+		t.Errorf("expected the 2 masked states of phone, got %v", data.States)
+	}
+
+	st := object.StateTracker{}
+	if err := st.Get(ddbClnt); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(st.States, []object.State{otherState}) {
+		t.Errorf("expected only the state of the other phone to be left, got %v", st.States)
+	}
+}
+
+// dequeueRaceDB is a MemoryDB where a concurrent flow takes every item out of the table right
+// before a conditional delete of it.
+type dequeueRaceDB struct {
+	*db.MemoryDB
+}
+
+func (d dequeueRaceDB) DeleteItem(ctx context.Context, input *dynamodb.DeleteItemInput,
+	optFns ...func(*dynamodb.Options)) (*dynamodb.DeleteItemOutput, error) {
+	if input.ConditionExpression != nil {
+		taken := &dynamodb.DeleteItemInput{TableName: input.TableName, Key: input.Key}
+		if _, err := d.MemoryDB.DeleteItem(ctx, taken); err != nil {
+			return nil, err
+		}
+	}
+
+	return d.MemoryDB.DeleteItem(ctx, input, optFns...)
+}
+
+func TestRetryQueue(t *testing.T) {
+	setup := func(t *testing.T, ddbClnt db.DDBConnecter) {
+		t.Helper()
+		phones := object.IntercessorPhones{Key: object.IntercessorPhonesKey}
+		for _, phone := range []string{"+11111111111", "+12222222222"} {
+			intr := object.Member{Intercessor: true, Phone: phone, SetupStage: 99, SetupStatus: "completed",
+				WeeklyPrayerLimit: 5}
+			if err := intr.Put(ddbClnt); err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			phones.Phones = append(phones.Phones, phone)
+		}
+		if err := phones.Put(ddbClnt); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+
+		queued := object.Prayer{IntercessorPhone: "1a2b3c4d", QueuedTime: "2026-10-18T12:00:00Z",
+			Request: "pray for me", Requestor: object.Member{Phone: "+11234567890"}}
+		if err := queued.Put(ddbClnt, config.Config{}.Cipher, true); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+
+	t.Run("Queued prayer is sent out and taken out of the queue", func(t *testing.T) {
+		memDB := db.NewMemoryDB(object.TableKeys())
+		setup(t, memDB)
+
+		txtMock := &mock.TextSender{}
+		sent, queued, err := prayertexter.RetryQueue(config.Config{}, memDB, txtMock)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		} else if sent != 1 || queued != 0 {
+			t.Errorf("expected 1 sent and 0 queued prayers, got %v and %v", sent, queued)
+		}
+
+		left, err := object.GetPrayers(memDB, config.Config{}.Cipher, true)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		} else if len(left) != 0 {
+			t.Errorf("expected empty queue, got %v", left)
+		}
+
+		// both intercessors and the requestor
+		if txtMock.SendTextCalls != 3 {
+			t.Errorf("expected 3 SendText calls, got %v", txtMock.SendTextCalls)
+		}
+	})
+
+	t.Run("Queued prayer that a concurrent flow took is not sent out again", func(t *testing.T) {
+		memDB := db.NewMemoryDB(object.TableKeys())
+		setup(t, memDB)
+
+		txtMock := &mock.TextSender{}
+		sent, queued, err := prayertexter.RetryQueue(config.Config{}, dequeueRaceDB{memDB}, txtMock)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		} else if sent != 0 || queued != 0 {
+			t.Errorf("expected 0 sent and 0 queued prayers, got %v and %v", sent, queued)
+		}
+
+		if txtMock.SendTextCalls != 0 {
+			t.Errorf("expected no texts, got %v SendText calls", txtMock.SendTextCalls)
+		}
+
+		active, err := object.GetPrayers(memDB, config.Config{}.Cipher, false)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		} else if len(active) != 0 {
+			t.Errorf("expected no active prayers, got %v", active)
+		}
+	})
+}
+
+func TestAssignPrayer(t *testing.T) {
+	requestor := object.Member{Name: "John Doe", Phone: "+11234567890", SetupStatus: "completed"}
+	intr := object.Member{Intercessor: true, Name: "Intercessor1", Phone: "+11111111111", PrayerCount: 5,
+		SetupStatus: "completed", WeeklyPrayerLimit: 5}
+	queued := object.Prayer{IntercessorPhone: "1a2b3c4d", Request: "pray for me", Requestor: requestor}
+
+	queuedItem, err := attributevalue.MarshalMap(queued)
+	if err != nil {
+		t.Fatalf("failed to marshal Prayer: %v", err)
+	}
+	intrItem, err := attributevalue.MarshalMap(intr)
+	if err != nil {
+		t.Fatalf("failed to marshal Member: %v", err)
+	}
+
+	t.Run("Assign queued prayer to available intercessor", func(t *testing.T) {
+		txtMock := &mock.TextSender{}
+		ddbMock := &mock.DDBConnecter{}
+		ddbMock.GetItemResults = []struct {
+			Output *dynamodb.GetItemOutput
+			Error  error
+		}{
+			{Output: &dynamodb.GetItemOutput{Item: queuedItem}, Error: nil},
+			{Output: &dynamodb.GetItemOutput{Item: intrItem}, Error: nil},
+			// empty response means the intercessor does not have an active prayer
+			{Output: &dynamodb.GetItemOutput{}, Error: nil},
+		}
+
+		pryr, err := prayertexter.AssignPrayer(queued.IntercessorPhone, intr.Phone, config.Config{}, ddbMock, txtMock)
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+
+		if pryr.IntercessorPhone != intr.Phone || pryr.Intercessor.PrayerCount != 6 {
+			t.Errorf("expected prayer assigned to %v with counted prayer, got %v", intr.Phone, pryr)
+		}
+
+		if ddbMock.PutItemCalls != 2 || ddbMock.DeleteItemCalls != 1 || txtMock.SendTextCalls != 1 {
+			t.Fatalf("expected 2 PutItem, 1 DeleteItem and 1 SendText calls, got %v, %v and %v",
+				ddbMock.PutItemCalls, ddbMock.DeleteItemCalls, txtMock.SendTextCalls)
+		}
+
+		if *ddbMock.PutItemInputs[1].TableName != object.ActivePrayersTable ||
+			*ddbMock.DeleteItemInputs[0].TableName != object.QueuedPrayersTable {
+			t.Errorf("expected prayer to move from %v to %v", object.QueuedPrayersTable, object.ActivePrayersTable)
+		}
+
+		testTxtMessage(txtMock, t, TestCase{expectedTexts: []messaging.TextMessage{{
+			Body:  msgText(messaging.MsgPrayerIntro, map[string]string{"Name": "John Doe", "Request": "pray for me"}),
+			Phone: intr.Phone,
+		}}})
+	})
+
+	t.Run("Intercessor already has an active prayer", func(t *testing.T) {
+		ddbMock := &mock.DDBConnecter{}
+		ddbMock.GetItemResults = []struct {
+			Output *dynamodb.GetItemOutput
+			Error  error
+		}{
+			{Output: &dynamodb.GetItemOutput{Item: queuedItem}, Error: nil},
+			{Output: &dynamodb.GetItemOutput{Item: intrItem}, Error: nil},
+			{Output: &dynamodb.GetItemOutput{Item: queuedItem}, Error: nil},
+		}
+
+		_, err := prayertexter.AssignPrayer(queued.IntercessorPhone, intr.Phone, config.Config{}, ddbMock,
+			&mock.TextSender{})
+		if !errors.Is(err, prayertexter.ErrIntercessorUnavailable) {
+			t.Errorf("expected ErrIntercessorUnavailable, got %v", err)
+		}
+
+		if ddbMock.PutItemCalls != 0 || ddbMock.DeleteItemCalls != 0 {
+			t.Errorf("expected no changes, got %v puts and %v deletes", ddbMock.PutItemCalls, ddbMock.DeleteItemCalls)
+		}
+	})
+
+	t.Run("Queued prayer was taken by a concurrent flow", func(t *testing.T) {
+		txtMock := &mock.TextSender{}
+		ddbMock := &mock.DDBConnecter{}
+		ddbMock.GetItemResults = []struct {
+			Output *dynamodb.GetItemOutput
+			Error  error
+		}{
+			{Output: &dynamodb.GetItemOutput{Item: queuedItem}, Error: nil},
+			{Output: &dynamodb.GetItemOutput{Item: intrItem}, Error: nil},
+			{Output: &dynamodb.GetItemOutput{}, Error: nil},
+		}
+		ddbMock.DeleteItemResults = []struct {
+			Error error
+		}{
+			{Error: &types.ConditionalCheckFailedException{}},
+		}
+
+		_, err := prayertexter.AssignPrayer(queued.IntercessorPhone, intr.Phone, config.Config{}, ddbMock, txtMock)
+		if !errors.Is(err, prayertexter.ErrNoQueuedPrayer) {
+			t.Errorf("expected ErrNoQueuedPrayer, got %v", err)
+		}
+
+		if ddbMock.PutItemCalls != 0 || txtMock.SendTextCalls != 0 {
+			t.Errorf("expected no changes, got %v puts and %v texts", ddbMock.PutItemCalls, txtMock.SendTextCalls)
+		}
+	})
+
+	t.Run("Queued prayer does not exist", func(t *testing.T) {
+		_, err := prayertexter.AssignPrayer("ffffffff", intr.Phone, config.Config{}, &mock.DDBConnecter{},
+			&mock.TextSender{})
+		if !errors.Is(err, prayertexter.ErrNoQueuedPrayer) {
+			t.Errorf("expected ErrNoQueuedPrayer, got %v", err)
+		}
+	})
+}
+
+func TestRequeuePrayer(t *testing.T) {
+	intr := object.Member{Intercessor: true, Name: "Intercessor1", Phone: "+11111111111"}
+	active := object.Prayer{Intercessor: intr, IntercessorPhone: intr.Phone, Request: "pray for me",
+		Requestor: object.Member{Name: "John Doe", Phone: "+11234567890"}}
+
+	activeItem, err := attributevalue.MarshalMap(active)
+	if err != nil {
+		t.Fatalf("failed to marshal Prayer: %v", err)
+	}
+
+	ddbMock := &mock.DDBConnecter{}
+	ddbMock.GetItemResults = []struct {
+		Output *dynamodb.GetItemOutput
+		Error  error
+	}{
+		{Output: &dynamodb.GetItemOutput{Item: activeItem}, Error: nil},
+	}
+
+	if _, err := prayertexter.RequeuePrayer(intr.Phone, config.Config{}, ddbMock); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	var queued object.Prayer
+	if err := attributevalue.UnmarshalMap(ddbMock.PutItemInputs[0].Item, &queued); err != nil {
+		t.Fatalf("failed to unmarshal PutItemInput into Prayer: %v", err)
+	}
+
+	if *ddbMock.PutItemInputs[0].TableName != object.QueuedPrayersTable || queued.Intercessor.Phone != "" ||
+		queued.IntercessorPhone == intr.Phone || queued.Request != active.Request {
+		t.Errorf("expected prayer to be queued without intercessor, got %v", queued)
+	}
+
+	if *ddbMock.DeleteItemInputs[0].TableName != object.ActivePrayersTable {
+		t.Errorf("expected delete from %v, got %v", object.ActivePrayersTable, *ddbMock.DeleteItemInputs[0].TableName)
+	}
+
+	if _, err := prayertexter.RequeuePrayer(intr.Phone, config.Config{}, &mock.DDBConnecter{}); !errors.Is(err,
+		prayertexter.ErrNoActivePrayer) {
+		t.Errorf("expected ErrNoActivePrayer, got %v", err)
+	}
+}
+
+func TestEditMember(t *testing.T) {
+	mem := object.Member{Name: "John Doe", Phone: "+11234567890", SetupStatus: "completed"}
+	memItem, err := attributevalue.MarshalMap(mem)
+	if err != nil {
+		t.Fatalf("failed to marshal Member: %v", err)
+	}
+
+	ddbMock := &mock.DDBConnecter{}
+	ddbMock.GetItemResults = []struct {
+		Output *dynamodb.GetItemOutput
+		Error  error
+	}{
+		{Output: &dynamodb.GetItemOutput{Item: memItem}, Error: nil},
+	}
+
+	intercessor, limit := true, 3
+	edited, err := prayertexter.EditMember(mem.Phone, prayertexter.MemberEdit{Intercessor: &intercessor,
+		WeeklyPrayerLimit: &limit}, ddbMock)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if !edited.Intercessor || edited.WeeklyPrayerLimit != 3 || edited.Name != mem.Name ||
+		edited.WeeklyPrayerDate == "" {
+		t.Errorf("expected intercessor with weekly prayer limit 3, got %v", edited)
+	}
+
+	if ddbMock.PutItemCalls != 2 {
+		t.Errorf("expected 2 PutItem calls, got %v", ddbMock.PutItemCalls)
+	}
+
+	testPhones(ddbMock.PutItemInputs, t, TestCase{expectedPhones: object.IntercessorPhones{
+		Key:    object.IntercessorPhonesKey,
+		Phones: []string{mem.Phone},
+	}})
+
+	if _, err := prayertexter.EditMember(mem.Phone, prayertexter.MemberEdit{}, &mock.DDBConnecter{}); !errors.Is(err,
+		prayertexter.ErrNoMember) {
+		t.Errorf("expected ErrNoMember, got %v", err)
+	}
+}
+
+func TestAddMember(t *testing.T) {
+	mem := object.Member{Intercessor: true, Name: "John Doe", Phone: "+11234567890", WeeklyPrayerLimit: 5}
+
+	ddbMock := &mock.DDBConnecter{}
+	added, err := prayertexter.AddMember(mem, ddbMock)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if added.SetupStatus != "completed" || added.WeeklyPrayerDate == "" {
+		t.Errorf("expected completed member with weekly prayer date, got %v", added)
+	}
+
+	if ddbMock.PutItemCalls != 2 {
+		t.Errorf("expected 2 PutItem calls, got %v", ddbMock.PutItemCalls)
+	}
+
+	existing, err := attributevalue.MarshalMap(added)
+	if err != nil {
+		t.Fatalf("failed to marshal Member: %v", err)
+	}
+
+	ddbMock = &mock.DDBConnecter{}
+	ddbMock.GetItemResults = []struct {
+		Output *dynamodb.GetItemOutput
+		Error  error
+	}{
+		{Output: &dynamodb.GetItemOutput{Item: existing}, Error: nil},
+	}
+
+	if _, err := prayertexter.AddMember(mem, ddbMock); !errors.Is(err, prayertexter.ErrMemberExists) {
+		t.Errorf("expected ErrMemberExists, got %v", err)
+	}
+}
+
+func TestHandleWebhook(t *testing.T) {
+	ddbClnt := db.NewMemoryDB(object.TableKeys())
+	smsClnt := &messaging.LogSender{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	phone := "+11234567890"
+
+	tests := []struct {
+		description  string
+		body         string
+		expectedBody string
+		expectedSent int
+	}{
+		{
+			description:  "Sign up",
+			body:         `{"phone-number": "+11234567890", "body": "pray", "message-id": "1"}`,
+			expectedBody: "Success",
+			expectedSent: 1,
+		},
+		{
+			description:  "Duplicate delivery is only processed once",
+			body:         `{"phone-number": "+11234567890", "body": "pray", "message-id": "1"}`,
+			expectedBody: "Duplicate",
+			expectedSent: 1,
+		},
+		{
+			description:  "Sign up name",
+			body:         `{"phone-number": "+11234567890", "body": "John Doe", "message-id": "2"}`,
+			expectedBody: "Success",
+			expectedSent: 2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			req := events.APIGatewayProxyRequest{HTTPMethod: http.MethodPost, Path: "/", Body: test.body}
+			resp, err := prayertexter.HandleWebhook(context.Background(), req, config.Config{}, ddbClnt, smsClnt)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			if resp.Body != test.expectedBody {
+				t.Errorf("expected body %v, got %v", test.expectedBody, resp.Body)
+			}
+
+			if sent := smsClnt.Sent(phone); len(sent) != test.expectedSent {
+				t.Errorf("expected %v sent text messages, got %v", test.expectedSent, len(sent))
+			}
+		})
+	}
+
+	mem := object.Member{Phone: phone}
+	if err := mem.Get(ddbClnt); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	} else if mem.Name != "John Doe" {
+		t.Errorf("expected member name John Doe, got %v", mem.Name)
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	if err := prayertexter.ValidateConfig(config.Config{}); err != nil {
+		t.Errorf("expected default config to be valid, got %v", err)
+	}
+
+	cfg := config.Config{Privacy: "unknown"}
+	if err := prayertexter.ValidateConfig(cfg); err == nil {
+		t.Errorf("expected error for unknown privacy level, got nil")
+	}
+
+	cfg = config.Config{Privacy: privacy.LevelStrict}
+	if err := prayertexter.ValidateConfig(cfg); err == nil {
+		t.Errorf("expected error for strict privacy level without phone hash key, got nil")
+	}
+
+	cfg = config.Config{MonthlySegmentBudget: 1000}
+	if err := prayertexter.ValidateConfig(cfg); err == nil {
+		t.Errorf("expected error for monthly segment budget without usage tracking, got nil")
+	}
+
+	cfg = config.Config{MonthlySegmentBudget: 1000, TrackUsage: true}
+	if err := prayertexter.ValidateConfig(cfg); err != nil {
+		t.Errorf("expected monthly segment budget with usage tracking to be valid, got %v", err)
+	}
+}
@@ -0,0 +1,215 @@
+package prayertexter_test
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mshort55/prayertexter/internal/config"
+	"github.com/mshort55/prayertexter/internal/db"
+	"github.com/mshort55/prayertexter/internal/messaging"
+	"github.com/mshort55/prayertexter/internal/object"
+	"github.com/mshort55/prayertexter/internal/prayertexter"
+)
+
+// stressSeedEnv sets the seed of TestMainFlowStress, to replay a failed run.
+const stressSeedEnv = "PRAYERTEXTER_STRESS_SEED"
+
+// stressMember texts like a member would, in random order: it signs up, sends prayer requests,
+// confirms prayers, asks for help, sends text that is not a command and sometimes quits and signs
+// up again. The texts of one member are sent one after the other, like a phone does.
+func stressMember(rng *rand.Rand, phone string, texts int) []string {
+	var sent []string
+	signUp := func() {
+		sent = append(sent, "pray", "Member "+phone[len(phone)-4:])
+		if rng.IntN(3) == 0 {
+			sent = append(sent, "1")
+		} else {
+			sent = append(sent, "2", strconv.Itoa(1+rng.IntN(3)))
+		}
+	}
+
+	signUp()
+	for len(sent) < texts {
+		switch rng.IntN(10) {
+		case 0, 1, 2, 3:
+			sent = append(sent, fmt.Sprintf("please pray for request %d", rng.IntN(1000)))
+		case 4, 5, 6:
+			sent = append(sent, "prayed")
+		case 7:
+			sent = append(sent, "help")
+		case 8:
+			sent = append(sent, "hello?")
+		case 9:
+			sent = append(sent, "stop")
+			signUp()
+		}
+	}
+
+	return sent
+}
+
+// TestMainFlowStress drives MainFlow for many members at the same time against a MemoryDB and
+// checks invariants that must hold no matter how the flows interleave. Every run logs its seed,
+// which can be replayed with PRAYERTEXTER_STRESS_SEED.
+func TestMainFlowStress(t *testing.T) {
+	runs, members, texts := 20, 12, 25
+	if testing.Short() {
+		runs = 3
+	}
+
+	seed := uint64(time.Now().UnixNano())
+	if env := os.Getenv(stressSeedEnv); env != "" {
+		parsed, err := strconv.ParseUint(env, 10, 64)
+		if err != nil {
+			t.Fatalf("invalid %v: %v", stressSeedEnv, err)
+		}
+		seed, runs = parsed, 1
+	}
+
+	// every failed flow logs, which would hide the test output
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	for run := range runs {
+		runSeed := seed + uint64(run)
+		t.Run(fmt.Sprintf("seed %d", runSeed), func(t *testing.T) {
+			rng := rand.New(rand.NewPCG(runSeed, runSeed))
+			ddbClnt := db.NewMemoryDB(object.TableKeys())
+			smsClnt := &messaging.LogSender{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+
+			scripts := map[string][]string{}
+			for i := range members {
+				phone := fmt.Sprintf("+1555000%04d", i)
+				scripts[phone] = stressMember(rng, phone, texts)
+			}
+
+			var ids atomic.Int64
+			var wg sync.WaitGroup
+			errs := make(chan error, members*texts*2)
+			for phone, script := range scripts {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for _, body := range script {
+						msg := messaging.TextMessage{Body: body, ID: strconv.FormatInt(ids.Add(1), 10), Phone: phone}
+						if err := prayertexter.MainFlow(msg, config.Config{}, ddbClnt, smsClnt); err != nil {
+							errs <- fmt.Errorf("%v %q: %w", phone, body, err)
+						}
+					}
+				}()
+			}
+			wg.Wait()
+			close(errs)
+
+			for err := range errs {
+				t.Errorf("unexpected error %v", err)
+			}
+			testInvariants(t, ddbClnt)
+		})
+	}
+}
+
+// testInvariants checks the stored objects for inconsistencies between them.
+func testInvariants(t *testing.T, ddbClnt db.DDBConnecter) {
+	t.Helper()
+
+	active, err := db.ScanDdbObjects[object.Prayer](ddbClnt, object.ActivePrayersTable, db.Condition{})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	activeCount := map[string]int{}
+	for _, pryr := range active {
+		activeCount[pryr.IntercessorPhone]++
+		if activeCount[pryr.IntercessorPhone] > 1 {
+			t.Errorf("intercessor %v has more than one active prayer", pryr.IntercessorPhone)
+		}
+	}
+
+	mems, err := db.ScanDdbObjects[object.Member](ddbClnt, object.MemberTable, db.Condition{})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	for _, mem := range mems {
+		if !mem.Intercessor || mem.SetupStatus != "completed" {
+			continue
+		}
+
+		if mem.PrayerCount > mem.WeeklyPrayerLimit {
+			t.Errorf("intercessor %v has PrayerCount %v over WeeklyPrayerLimit %v", mem.Phone, mem.PrayerCount,
+				mem.WeeklyPrayerLimit)
+		}
+
+		phones := object.TenantIntercessorPhones(mem.TenantPhone)
+		if err := phones.Get(ddbClnt); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if !slices.Contains(phones.Phones, mem.Phone) {
+			t.Errorf("intercessor %v is missing from IntercessorPhones %v", mem.Phone, phones.Phones)
+		}
+	}
+
+	st := object.StateTracker{}
+	if err := st.Get(ddbClnt); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	for _, state := range st.States {
+		if state.Status == "IN PROGRESS" {
+			t.Errorf("state %v of %v is left IN PROGRESS at stage %v", state.ID, state.Message.Phone, state.Stage)
+		}
+	}
+}
+
+// TestMainFlowRateLimitStress sends many prayer requests of one member at the same time and checks
+// that the rate limiter lets exactly its capacity through, no matter how the flows interleave.
+func TestMainFlowRateLimitStress(t *testing.T) {
+	const capacity, requests = 3, 20
+
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	ddbClnt := db.NewMemoryDB(object.TableKeys())
+	smsClnt := &messaging.LogSender{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	cfg := config.Config{PrayerRateLimit: config.RateLimit{Capacity: capacity, RefillSeconds: 3600}}
+
+	mem := object.Member{Name: "Member", Phone: "+15550000001", SetupStage: 99, SetupStatus: "completed"}
+	if err := mem.Put(ddbClnt); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, requests)
+	for i := range requests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			msg := messaging.TextMessage{Body: fmt.Sprintf("please pray for request %d", i), ID: strconv.Itoa(i),
+				Phone: mem.Phone}
+			if err := prayertexter.MainFlow(msg, cfg, ddbClnt, smsClnt); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	queued, err := db.ScanDdbObjects[object.Prayer](ddbClnt, object.QueuedPrayersTable, db.Condition{})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(queued) != capacity {
+		t.Errorf("expected %v prayers to get through the rate limit, got %v", capacity, len(queued))
+	}
+}
@@ -0,0 +1,93 @@
+package prayertexter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/mshort55/prayertexter/internal/config"
+	"github.com/mshort55/prayertexter/internal/db"
+	"github.com/mshort55/prayertexter/internal/filter"
+	"github.com/mshort55/prayertexter/internal/messaging"
+	"github.com/mshort55/prayertexter/internal/object"
+	"github.com/mshort55/prayertexter/internal/privacy"
+)
+
+// HandleWebhook processes a text message that the sms provider delivered through api gateway.
+func HandleWebhook(ctx context.Context, req events.APIGatewayProxyRequest, cfg config.Config, ddbClnt db.DDBConnecter,
+	smsClnt messaging.TextSender) (events.APIGatewayProxyResponse, error) {
+	msg := messaging.TextMessage{}
+
+	if err := json.Unmarshal([]byte(req.Body), &msg); err != nil {
+		slog.Error("lambda handler: failed to unmarshal api gateway request", "error", err.Error())
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+
+	// api gateway and the sms provider retry deliveries, so the same text message can arrive more
+	// than once. Only the first delivery gets processed
+	claimed, err := object.ClaimInboundMessage(ddbClnt, msg, time.Now())
+	if err != nil {
+		slog.Error("lambda handler: failed to claim inbound message", "error", err.Error())
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	} else if !claimed {
+		slog.Warn("duplicate inbound message, dropping message", "member", msg.Phone, "id", msg.ID)
+		return events.APIGatewayProxyResponse{StatusCode: 200, Body: "Duplicate"}, nil
+	}
+
+	blocked, err := object.IsPhoneBlocked(ddbClnt, msg.Phone)
+	if err != nil {
+		slog.Error("lambda handler: failed to check block list", "error", err.Error())
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	} else if blocked {
+		slog.Warn("blocked phone, dropping message", "member", msg.Phone)
+		return events.APIGatewayProxyResponse{StatusCode: 200, Body: "Blocked"}, nil
+	}
+
+	if err := MainFlow(msg, cfg, ddbClnt, smsClnt); err != nil {
+		// this lets a retry of the failed text message get processed
+		if err := object.ReleaseInboundMessage(ddbClnt, msg); err != nil {
+			slog.Error("lambda handler: failed to release inbound message", "error", err.Error())
+		}
+		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError}, err
+	}
+
+	return events.APIGatewayProxyResponse{StatusCode: 200, Body: "Success"}, nil
+}
+
+// ValidateConfig checks the settings of cfg that would otherwise only fail once a member texts.
+func ValidateConfig(cfg config.Config) error {
+	if err := privacy.Validate(cfg.Privacy, cfg.PhoneHashKey); err != nil {
+		return fmt.Errorf("invalid privacy level: %w", err)
+	}
+
+	// this catches broken or too long template overrides before any member receives a message
+	tmpls, err := messaging.LoadTemplates(cfg.Templates, cfg.TemplateVars)
+	if err == nil {
+		err = tmpls.Validate()
+	}
+	if err != nil {
+		return fmt.Errorf("invalid message templates: %w", err)
+	}
+
+	// this catches unknown content filters, and flagged prayer requests that no one would be asked
+	// to review
+	pipeline, err := filter.New(cfg.ContentFilters)
+	if err == nil && pipeline.HasAction(filter.ActionFlag) && len(cfg.Moderators) == 0 {
+		err = errors.New("content filters flag prayer requests but there are no moderators")
+	}
+	if err != nil {
+		return fmt.Errorf("invalid content filters: %w", err)
+	}
+
+	// the budget is checked against the usage counters, which are only written with TrackUsage
+	if cfg.MonthlySegmentBudget > 0 && !cfg.TrackUsage {
+		return errors.New("invalid monthly segment budget: trackUsage is needed to enforce it")
+	}
+
+	return nil
+}
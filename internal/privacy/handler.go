@@ -0,0 +1,103 @@
+package privacy
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"regexp"
+)
+
+// e164 matches phone numbers in E.164 format, like members text from and the way they are stored.
+var e164 = regexp.MustCompile(`\+[1-9][0-9]{6,14}`)
+
+// Handler is a slog.Handler that redacts phone numbers and message bodies before passing log
+// records on to the wrapped handler. Attributes are recognized by key: "intercessor", "member",
+// "phone" and "recipient" hold phone numbers and "body" and "msg" hold message bodies. Phone
+// numbers that are part of the log message or of any other string or error attribute, such as
+// errors that name a member, are redacted as well.
+type Handler struct {
+	handler slog.Handler
+	key     string
+	level   string
+}
+
+// SetDefault makes the default slog logger write text to stderr with phone numbers and message
+// bodies redacted according to level, see NewHandler. Every binary that logs about members calls
+// this once it has loaded the config.
+func SetDefault(level, key string) {
+	slog.SetDefault(slog.New(NewHandler(slog.NewTextHandler(os.Stderr, nil), level, key)))
+}
+
+// NewHandler returns a Handler that redacts according to level, with LevelStrict hashes keyed with
+// key, and writes to handler.
+func NewHandler(handler slog.Handler, level, key string) Handler {
+	return Handler{handler: handler, key: key, level: level}
+}
+
+func (h Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+func (h Handler) Handle(ctx context.Context, r slog.Record) error {
+	if h.level == LevelOff {
+		return h.handler.Handle(ctx, r)
+	}
+
+	redacted := slog.NewRecord(r.Time, r.Level, h.redactPhones(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		redacted.AddAttrs(h.redact(a))
+		return true
+	})
+
+	return h.handler.Handle(ctx, redacted)
+}
+
+func (h Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		redacted = append(redacted, h.redact(a))
+	}
+
+	return Handler{handler: h.handler.WithAttrs(redacted), key: h.key, level: h.level}
+}
+
+func (h Handler) WithGroup(name string) slog.Handler {
+	return Handler{handler: h.handler.WithGroup(name), key: h.key, level: h.level}
+}
+
+func (h Handler) redact(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+
+	if a.Value.Kind() == slog.KindGroup {
+		group := a.Value.Group()
+		redacted := make([]any, 0, len(group))
+		for _, ga := range group {
+			redacted = append(redacted, h.redact(ga))
+		}
+
+		return slog.Group(a.Key, redacted...)
+	}
+
+	switch a.Key {
+	case "intercessor", "member", "phone", "recipient":
+		return slog.String(a.Key, Phone(a.Value.String(), h.level, h.key))
+	case "body", "msg":
+		return slog.String(a.Key, Body(a.Value.String(), h.level, h.key))
+	}
+
+	switch v := a.Value.Any().(type) {
+	case string:
+		return slog.String(a.Key, h.redactPhones(v))
+	case error:
+		return slog.String(a.Key, h.redactPhones(v.Error()))
+	default:
+		return a
+	}
+}
+
+// redactPhones redacts every phone number in s.
+func (h Handler) redactPhones(s string) string {
+	return e164.ReplaceAllStringFunc(s, func(phone string) string {
+		return Phone(phone, h.level, h.key)
+	})
+}
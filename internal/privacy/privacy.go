@@ -0,0 +1,128 @@
+package privacy
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/mshort55/prayertexter/internal/messaging"
+)
+
+// privacy levels
+const (
+	// LevelOff keeps phone numbers and message bodies as they are
+	LevelOff = "off"
+	// LevelMask masks all but the last digits of phone numbers and truncates message bodies. This
+	// is the default
+	LevelMask = "mask"
+	// LevelStrict replaces phone numbers and message bodies with hashes keyed with the phone hash
+	// key. The same phone number always has the same hash, so log lines of a phone can still be
+	// found. Without a key LevelMask is used instead, since an unkeyed hash of a phone number is
+	// reversed by hashing every phone number
+	LevelStrict = "strict"
+)
+
+const (
+	// maskedBodyLength is the number of characters of a message body that are kept by LevelMask
+	maskedBodyLength = 16
+	// visiblePhoneDigits is the number of trailing digits of a phone number kept by LevelMask
+	visiblePhoneDigits = 4
+	hashLength         = 12
+)
+
+// Validate returns an error if level is not a privacy level, or if level is LevelStrict and there
+// is no key to hash with. "" is valid and means LevelMask.
+func Validate(level, key string) error {
+	switch level {
+	case "", LevelOff, LevelMask:
+		return nil
+	case LevelStrict:
+		if key == "" {
+			return errors.New("privacy validate: strict privacy level needs a phone hash key")
+		}
+		return nil
+	default:
+		return fmt.Errorf("privacy validate: unknown privacy level %q", level)
+	}
+}
+
+// Phone returns phone redacted according to level. key is the secret key of LevelStrict hashes.
+func Phone(phone, level, key string) string {
+	if phone == "" {
+		return phone
+	}
+
+	switch effectiveLevel(level, key) {
+	case LevelOff:
+		return phone
+	case LevelStrict:
+		return hash(phone, key)
+	default:
+		// digits are masked from the end so that the last visiblePhoneDigits stay visible
+		runes, visible := []rune(phone), 0
+		for i := len(runes) - 1; i >= 0; i-- {
+			if !unicode.IsDigit(runes[i]) {
+				continue
+			}
+
+			if visible < visiblePhoneDigits {
+				visible++
+			} else {
+				runes[i] = '*'
+			}
+		}
+
+		return string(runes)
+	}
+}
+
+// Body returns the message body redacted according to level. key is the secret key of LevelStrict
+// hashes.
+func Body(body, level, key string) string {
+	if body == "" {
+		return body
+	}
+
+	runes := []rune(body)
+	switch effectiveLevel(level, key) {
+	case LevelOff:
+		return body
+	case LevelStrict:
+		return fmt.Sprintf("[%v chars %v]", len(runes), hash(body, key))
+	default:
+		if len(runes) <= maskedBodyLength {
+			return body
+		}
+
+		return fmt.Sprintf("%v... [%v chars]", string(runes[:maskedBodyLength]), len(runes))
+	}
+}
+
+// Message returns msg with phone and body redacted like Phone and Body do. The PrayerTexter number
+// that msg was sent to is not personal information and is kept.
+func Message(msg messaging.TextMessage, level, key string) messaging.TextMessage {
+	msg.Body = Body(msg.Body, level, key)
+	msg.Phone = Phone(msg.Phone, level, key)
+
+	return msg
+}
+
+// effectiveLevel returns the level that is used to redact, which is LevelMask instead of
+// LevelStrict when there is no key.
+func effectiveLevel(level, key string) string {
+	if level == LevelStrict && key == "" {
+		return LevelMask
+	}
+
+	return level
+}
+
+func hash(s, key string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(strings.TrimSpace(s)))
+	return "hmac:" + hex.EncodeToString(mac.Sum(nil))[:hashLength]
+}
@@ -0,0 +1,116 @@
+package privacy_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/mshort55/prayertexter/internal/messaging"
+	"github.com/mshort55/prayertexter/internal/privacy"
+)
+
+const testKey = "secret"
+
+func TestRedact(t *testing.T) {
+	body := "please pray for my sister who is in the hospital"
+
+	testCases := []struct {
+		level string
+		phone string
+		body  string
+	}{
+		{level: privacy.LevelOff, phone: "+11234567890", body: body},
+		{level: privacy.LevelMask, phone: "+*******7890", body: "please pray for ... [48 chars]"},
+		{level: "", phone: "+*******7890", body: "please pray for ... [48 chars]"},
+	}
+
+	for _, test := range testCases {
+		msg := privacy.Message(messaging.TextMessage{Body: body, Phone: "+11234567890"}, test.level, testKey)
+		if msg.Phone != test.phone || msg.Body != test.body {
+			t.Errorf("expected %q and %q for level %q, got %q and %q", test.phone, test.body, test.level,
+				msg.Phone, msg.Body)
+		}
+	}
+
+	// short bodies such as keywords are kept
+	if redacted := privacy.Body("prayed", privacy.LevelMask, testKey); redacted != "prayed" {
+		t.Errorf("expected short body to be kept, got %q", redacted)
+	}
+
+	strict := privacy.Phone("+11234567890", privacy.LevelStrict, testKey)
+	if !strings.HasPrefix(strict, "hmac:") || strings.Contains(strict, "7890") {
+		t.Errorf("expected hashed phone, got %q", strict)
+	}
+
+	if privacy.Phone("+11234567890", privacy.LevelStrict, testKey) != strict {
+		t.Errorf("expected the same phone to have the same hash")
+	}
+
+	// without the key the hash can not be computed from the phone
+	if privacy.Phone("+11234567890", privacy.LevelStrict, "other") == strict {
+		t.Errorf("expected the hash to depend on the key")
+	}
+
+	if redacted := privacy.Body(body, privacy.LevelStrict, testKey); strings.Contains(redacted, "pray") {
+		t.Errorf("expected hashed body, got %q", redacted)
+	}
+
+	// strict without a key falls back to mask instead of an unkeyed hash
+	if redacted := privacy.Phone("+11234567890", privacy.LevelStrict, ""); redacted != "+*******7890" {
+		t.Errorf("expected masked phone without a key, got %q", redacted)
+	}
+
+	if err := privacy.Validate("everything", testKey); err == nil {
+		t.Errorf("expected error for unknown privacy level")
+	}
+
+	if err := privacy.Validate(privacy.LevelStrict, ""); err == nil {
+		t.Errorf("expected error for strict privacy level without a key")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(privacy.NewHandler(slog.NewTextHandler(&buf, nil), privacy.LevelMask, testKey))
+
+	logger.With("member", "+11234567890").Warn("non registered user, dropping message",
+		"msg", "please pray for my sister who is in the hospital", "stage", "DROP MESSAGE")
+
+	out := buf.String()
+	for _, leaked := range []string{"+11234567890", "hospital"} {
+		if strings.Contains(out, leaked) {
+			t.Errorf("expected %q to be redacted, got %v", leaked, out)
+		}
+	}
+
+	for _, kept := range []string{"+*******7890", "stage=\"DROP MESSAGE\"", "non registered user"} {
+		if !strings.Contains(out, kept) {
+			t.Errorf("expected %q in log line, got %v", kept, out)
+		}
+	}
+}
+
+func TestHandlerRedactsPhonesInValues(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(privacy.NewHandler(slog.NewTextHandler(&buf, nil), privacy.LevelMask, testKey))
+
+	err := fmt.Errorf("AssignPrayer %v: %w", "+11234567890", errors.New("no member"))
+	logger.Error("failure during admin flow for +12223334444", "error", err, "detail",
+		"AddMember +15556667777 failed", "count", 3)
+
+	out := buf.String()
+	for _, leaked := range []string{"+11234567890", "+12223334444", "+15556667777"} {
+		if strings.Contains(out, leaked) {
+			t.Errorf("expected %q to be redacted, got %v", leaked, out)
+		}
+	}
+
+	for _, kept := range []string{"+*******7890", "+*******4444", "+*******7777", "no member", "count=3"} {
+		if !strings.Contains(out, kept) {
+			t.Errorf("expected %q in log line, got %v", kept, out)
+		}
+	}
+}
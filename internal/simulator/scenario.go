@@ -0,0 +1,169 @@
+package simulator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mshort55/prayertexter/internal/config"
+	"github.com/mshort55/prayertexter/internal/db"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrExpectation is returned by Run when a step did not send the expected text messages.
+var ErrExpectation = errors.New("expectation failed")
+
+// Scenario is a scripted conversation between virtual phones. Every step optionally sends a text
+// message and then checks the text messages that were sent back during that step.
+type Scenario struct {
+	// Config holds the same keys as the config file
+	Config map[string]any `yaml:"config"`
+	Name   string         `yaml:"name"`
+	// Phones names phone numbers, so that steps can refer to phones by name
+	Phones map[string]string `yaml:"phones"`
+	Steps  []Step            `yaml:"steps"`
+}
+
+type Step struct {
+	Expect []Expectation `yaml:"expect"`
+	// Phone sends Send. Phone is also the default phone of expectations
+	Phone string `yaml:"phone"`
+	Send  string `yaml:"send"`
+}
+
+// Expectation matches the text messages that a phone received during a step by template and by
+// text. At least one text message has to match, unless Count or None is set.
+type Expectation struct {
+	Contains string `yaml:"contains"`
+	// Count is the exact number of matching text messages. 0 means at least one
+	Count int `yaml:"count"`
+	// None expects no matching text messages
+	None bool `yaml:"none"`
+	// Phone is the phone that received the text messages. "" means the phone of the step, "*"
+	// means any phone
+	Phone string `yaml:"phone"`
+	// Save names the phone that received the first matching text message, which is how steps refer
+	// to phones that were picked at random, such as intercessors
+	Save     string `yaml:"save"`
+	Template string `yaml:"template"`
+}
+
+// LoadScenario reads a YAML scenario. Unknown keys are an error, to catch typos.
+func LoadScenario(r io.Reader) (Scenario, error) {
+	sc := Scenario{}
+
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	if err := dec.Decode(&sc); err != nil {
+		return sc, fmt.Errorf("LoadScenario: %w", err)
+	}
+
+	return sc, nil
+}
+
+// Run plays sc on a new Simulator against ddbClnt and returns the Simulator, so that callers can
+// look at the end state. The first step that fails stops the scenario.
+func Run(sc Scenario, ddbClnt db.DDBConnecter) (*Simulator, error) {
+	// config keys are the json keys of Config, so the config goes through json to keep one
+	// definition of them
+	data, err := json.Marshal(sc.Config)
+	if err != nil {
+		return nil, fmt.Errorf("Run: %w", err)
+	}
+	cfg := config.Config{}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("Run failed unmarshal config: %w", err)
+	}
+
+	sim, err := New(cfg, ddbClnt)
+	if err != nil {
+		return nil, fmt.Errorf("Run: %w", err)
+	}
+	for name, phone := range sc.Phones {
+		sim.Alias(name, phone)
+	}
+
+	for i, step := range sc.Steps {
+		if err := sim.runStep(step); err != nil {
+			return sim, fmt.Errorf("Run %v step %d: %w", sc.Name, i+1, err)
+		}
+	}
+
+	return sim, nil
+}
+
+func (s *Simulator) runStep(step Step) error {
+	if step.Send != "" {
+		if err := s.Send(step.Phone, step.Send); err != nil {
+			return err
+		}
+	}
+
+	for _, expect := range step.Expect {
+		if expect.Phone == "" {
+			expect.Phone = step.Phone
+		}
+		if err := s.check(expect); err != nil {
+			return err
+		}
+	}
+	s.MarkRead()
+
+	return nil
+}
+
+// check verifies expect against the unread text messages.
+func (s *Simulator) check(expect Expectation) error {
+	phone := expect.Phone
+	if phone != "*" {
+		var err error
+		if phone, err = s.Phone(phone); err != nil {
+			return err
+		}
+	}
+
+	matches := 0
+	received := []string{}
+	for _, msg := range s.Unread() {
+		if phone != "*" && msg.Phone != phone {
+			continue
+		}
+		received = append(received, s.Name(msg.Phone)+": "+msg.Body)
+
+		if (expect.Template != "" && !s.Matches(expect.Template, msg.Body)) ||
+			!strings.Contains(msg.Body, expect.Contains) {
+			continue
+		}
+
+		if matches == 0 && expect.Save != "" {
+			s.Alias(expect.Save, msg.Phone)
+		}
+		matches++
+	}
+
+	switch {
+	case expect.None && matches > 0:
+		return fmt.Errorf("%w: expected no %v, got %d", ErrExpectation, expect, matches)
+	case !expect.None && expect.Count > 0 && matches != expect.Count:
+		return fmt.Errorf("%w: expected %d %v, got %d in %q", ErrExpectation, expect.Count, expect, matches, received)
+	case !expect.None && expect.Count == 0 && matches == 0:
+		return fmt.Errorf("%w: expected %v, got %q", ErrExpectation, expect, received)
+	}
+
+	return nil
+}
+
+// String describes the text messages that e matches.
+func (e Expectation) String() string {
+	desc := "text message"
+	if e.Template != "" {
+		desc = e.Template + " " + desc
+	}
+	if e.Contains != "" {
+		desc += fmt.Sprintf(" containing %q", e.Contains)
+	}
+
+	return desc + " to " + e.Phone
+}
@@ -0,0 +1,129 @@
+// Package simulator runs prayertexter conversations between virtual phones without any aws
+// services, for end to end testing of whole flows.
+package simulator
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mshort55/prayertexter/internal/config"
+	"github.com/mshort55/prayertexter/internal/db"
+	"github.com/mshort55/prayertexter/internal/messaging"
+	"github.com/mshort55/prayertexter/internal/prayertexter"
+)
+
+// Simulator sends the text messages of virtual phones through MainFlow, usually against a MemoryDB,
+// and keeps every text message sent back as the inbox of the receiving phone. Phones can be given
+// names with Alias, and every method that takes a phone also takes a name.
+type Simulator struct {
+	aliases map[string]string
+	cfg     config.Config
+	ddbClnt db.DDBConnecter
+	ids     int
+	read    int
+	smsClnt *messaging.LogSender
+	tmpls   *messaging.Templates
+}
+
+func New(cfg config.Config, ddbClnt db.DDBConnecter) (*Simulator, error) {
+	if err := prayertexter.ValidateConfig(cfg); err != nil {
+		return nil, fmt.Errorf("simulator New: %w", err)
+	}
+
+	tmpls, err := messaging.LoadTemplates(cfg.Templates, cfg.TemplateVars)
+	if err != nil {
+		return nil, fmt.Errorf("simulator New: %w", err)
+	}
+
+	return &Simulator{
+		aliases: map[string]string{},
+		cfg:     cfg,
+		ddbClnt: ddbClnt,
+		smsClnt: &messaging.LogSender{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))},
+		tmpls:   tmpls,
+	}, nil
+}
+
+// DB returns the store that every flow runs against, so that tests can look at the stored objects.
+func (s *Simulator) DB() db.DDBConnecter {
+	return s.ddbClnt
+}
+
+// Alias names phone, so that it can be referred to by name.
+func (s *Simulator) Alias(name, phone string) {
+	s.aliases[name] = phone
+}
+
+// Phone returns the phone named name, or name itself if it is a phone number.
+func (s *Simulator) Phone(name string) (string, error) {
+	if phone, ok := s.aliases[name]; ok {
+		return phone, nil
+	} else if strings.HasPrefix(name, "+") {
+		return name, nil
+	}
+
+	return "", fmt.Errorf("unknown phone %v", name)
+}
+
+// Name returns the name of phone, or phone itself if it has no name.
+func (s *Simulator) Name(phone string) string {
+	for name, aliased := range s.aliases {
+		if aliased == phone {
+			return name
+		}
+	}
+
+	return phone
+}
+
+// Send texts body from phone to the default tenant and runs MainFlow on it.
+func (s *Simulator) Send(phone, body string) error {
+	phone, err := s.Phone(phone)
+	if err != nil {
+		return fmt.Errorf("simulator Send: %w", err)
+	}
+
+	s.ids++
+	msg := messaging.TextMessage{
+		Body:      body,
+		ID:        fmt.Sprintf("sim-%d", s.ids),
+		Phone:     phone,
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+
+	if err := prayertexter.MainFlow(msg, s.cfg, s.ddbClnt, s.smsClnt); err != nil {
+		return fmt.Errorf("simulator Send: %w", err)
+	}
+
+	return nil
+}
+
+// Inbox returns every text message that phone received, oldest first.
+func (s *Simulator) Inbox(phone string) ([]messaging.TextMessage, error) {
+	phone, err := s.Phone(phone)
+	if err != nil {
+		return nil, fmt.Errorf("simulator Inbox: %w", err)
+	}
+
+	return s.smsClnt.Sent(phone), nil
+}
+
+// Unread returns the text messages that any phone received since the last MarkRead, oldest
+// first.
+func (s *Simulator) Unread() []messaging.TextMessage {
+	return slices.Clone(s.smsClnt.Sent("")[s.read:])
+}
+
+// MarkRead marks every received text message as read.
+func (s *Simulator) MarkRead() {
+	s.read = len(s.smsClnt.Sent(""))
+}
+
+// Matches returns whether body was rendered from the template name, see Templates.Matches.
+func (s *Simulator) Matches(name, body string) bool {
+	return s.tmpls.Matches(name, body)
+}
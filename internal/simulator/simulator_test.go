@@ -0,0 +1,95 @@
+package simulator_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mshort55/prayertexter/internal/config"
+	"github.com/mshort55/prayertexter/internal/db"
+	"github.com/mshort55/prayertexter/internal/messaging"
+	"github.com/mshort55/prayertexter/internal/object"
+	"github.com/mshort55/prayertexter/internal/simulator"
+)
+
+func TestScenarios(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "*.yaml"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("expected scenarios in testdata, got %v (%v)", files, err)
+	}
+
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			f, err := os.Open(file)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			defer f.Close()
+
+			sc, err := simulator.LoadScenario(f)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			if _, err := simulator.Run(sc, db.NewMemoryDB(object.TableKeys())); err != nil {
+				t.Errorf("unexpected error %v", err)
+			}
+		})
+	}
+}
+
+func TestRunFailedExpectation(t *testing.T) {
+	sc, err := simulator.LoadScenario(strings.NewReader(`
+name: wrong template
+steps:
+  - {phone: "+11234567890", send: pray, expect: [{template: prayer-confirmation}]}
+`))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	if _, err := simulator.Run(sc, db.NewMemoryDB(object.TableKeys())); !errors.Is(err, simulator.ErrExpectation) {
+		t.Errorf("expected ErrExpectation, got %v", err)
+	}
+}
+
+func TestLoadScenarioUnknownKey(t *testing.T) {
+	if _, err := simulator.LoadScenario(strings.NewReader("steps: [{phone: a, sned: pray}]")); err == nil {
+		t.Errorf("expected error for unknown key, got nil")
+	}
+}
+
+func TestSimulator(t *testing.T) {
+	sim, err := simulator.New(config.Config{}, db.NewMemoryDB(object.TableKeys()))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	sim.Alias("john", "+11234567890")
+
+	for _, body := range []string{"pray", "John", "1"} {
+		if err := sim.Send("john", body); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+
+	inbox, err := sim.Inbox("john")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(inbox) != 3 || !sim.Matches(messaging.MsgPrayerSignUpComplete, inbox[2].Body) {
+		t.Errorf("expected 3 sign up messages, got %v", inbox)
+	}
+
+	mem := object.Member{Phone: "+11234567890"}
+	if err := mem.Get(sim.DB()); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	} else if mem.Name != "John" || mem.SetupStatus != "completed" {
+		t.Errorf("expected completed sign up of John, got %v", mem)
+	}
+
+	if _, err := sim.Inbox("jane"); err == nil {
+		t.Errorf("expected error for unknown phone, got nil")
+	}
+}
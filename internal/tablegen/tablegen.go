@@ -0,0 +1,393 @@
+// Package tablegen renders object.Tables as the files that need the table schemas outside of Go:
+// the create-table and ttl files that localdev/dynamodbsetup.sh uses and the table resources of
+// template.yaml.
+package tablegen
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/mshort55/prayertexter/internal/object"
+)
+
+var ErrTemplate = errors.New("template does not match tables")
+
+const (
+	LocalDevDir     = "localdev"
+	TableFileSuffix = "-table.json"
+	TemplateFile    = "template.yaml"
+	TTLFileSuffix   = "-ttl.json"
+
+	tableType = "AWS::DynamoDB::Table"
+)
+
+type attributeDefinition struct {
+	AttributeName string
+	AttributeType string
+}
+
+type keySchemaElement struct {
+	AttributeName string
+	KeyType       string
+}
+
+type projection struct {
+	ProjectionType string
+}
+
+type globalSecondaryIndex struct {
+	IndexName  string
+	KeySchema  []keySchemaElement
+	Projection projection
+}
+
+type streamSpecification struct {
+	StreamEnabled  bool
+	StreamViewType string
+}
+
+type timeToLiveSpecification struct {
+	AttributeName string
+	Enabled       bool
+}
+
+// createTable is the input of aws dynamodb create-table --cli-input-json.
+type createTable struct {
+	AttributeDefinitions   []attributeDefinition
+	BillingMode            string
+	GlobalSecondaryIndexes []globalSecondaryIndex `json:",omitempty"`
+	KeySchema              []keySchemaElement
+	StreamSpecification    *streamSpecification `json:",omitempty"`
+	TableName              string
+}
+
+// updateTimeToLive is the input of aws dynamodb update-time-to-live --cli-input-json.
+type updateTimeToLive struct {
+	TableName               string
+	TimeToLiveSpecification timeToLiveSpecification
+}
+
+// FileName returns the kebab case name of tbl that its localdev files start with, for example
+// prayers-queue for PrayersQueue.
+func FileName(tbl object.Table) string {
+	var b strings.Builder
+	for i, r := range tbl.Name {
+		if i > 0 && r >= 'A' && r <= 'Z' {
+			b.WriteByte('-')
+		}
+		b.WriteRune(r)
+	}
+
+	return strings.ToLower(b.String())
+}
+
+// LocalDevFiles returns the contents of the localdev files of tables by file name. Every table has
+// a create-table file, and tables with ttl have an update-time-to-live file too, because
+// create-table does not take ttl.
+func LocalDevFiles(tables []object.Table) (map[string][]byte, error) {
+	files := map[string][]byte{}
+
+	for _, tbl := range tables {
+		create := createTable{
+			BillingMode: string(types.BillingModePayPerRequest),
+			KeySchema:   keySchema(tbl.HashKey, ""),
+			TableName:   tbl.Name,
+		}
+		for _, attr := range tbl.Attributes() {
+			create.AttributeDefinitions = append(create.AttributeDefinitions,
+				attributeDefinition{AttributeName: attr, AttributeType: string(types.ScalarAttributeTypeS)})
+		}
+		for _, idx := range tbl.Indexes {
+			create.GlobalSecondaryIndexes = append(create.GlobalSecondaryIndexes, globalSecondaryIndex{
+				IndexName:  idx.Name,
+				KeySchema:  keySchema(idx.HashKey, idx.RangeKey),
+				Projection: projection{ProjectionType: string(types.ProjectionTypeAll)},
+			})
+		}
+		if tbl.Stream != "" {
+			create.StreamSpecification = &streamSpecification{StreamEnabled: true, StreamViewType: tbl.Stream}
+		}
+
+		data, err := marshal(create)
+		if err != nil {
+			return nil, fmt.Errorf("LocalDevFiles %v: %w", tbl.Name, err)
+		}
+		files[FileName(tbl)+TableFileSuffix] = data
+
+		if tbl.TTL == "" {
+			continue
+		}
+		data, err = marshal(updateTimeToLive{
+			TableName:               tbl.Name,
+			TimeToLiveSpecification: timeToLiveSpecification{AttributeName: tbl.TTL, Enabled: true},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("LocalDevFiles %v: %w", tbl.Name, err)
+		}
+		files[FileName(tbl)+TTLFileSuffix] = data
+	}
+
+	return files, nil
+}
+
+func marshal(v any) ([]byte, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(data, '\n'), nil
+}
+
+func keySchema(hashKey, rangeKey string) []keySchemaElement {
+	keys := []keySchemaElement{{AttributeName: hashKey, KeyType: string(types.KeyTypeHash)}}
+	if rangeKey != "" {
+		keys = append(keys, keySchemaElement{AttributeName: rangeKey, KeyType: string(types.KeyTypeRange)})
+	}
+
+	return keys
+}
+
+// CreateTableInput returns the input that creates tbl, without ttl which needs a separate
+// UpdateTimeToLive call.
+func CreateTableInput(tbl object.Table) *dynamodb.CreateTableInput {
+	input := &dynamodb.CreateTableInput{
+		BillingMode: types.BillingModePayPerRequest,
+		KeySchema:   sdkKeySchema(tbl.HashKey, ""),
+		TableName:   aws.String(tbl.Name),
+	}
+	for _, attr := range tbl.Attributes() {
+		input.AttributeDefinitions = append(input.AttributeDefinitions,
+			types.AttributeDefinition{AttributeName: aws.String(attr), AttributeType: types.ScalarAttributeTypeS})
+	}
+	for _, idx := range tbl.Indexes {
+		input.GlobalSecondaryIndexes = append(input.GlobalSecondaryIndexes, types.GlobalSecondaryIndex{
+			IndexName:  aws.String(idx.Name),
+			KeySchema:  sdkKeySchema(idx.HashKey, idx.RangeKey),
+			Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+		})
+	}
+	if tbl.Stream != "" {
+		input.StreamSpecification = &types.StreamSpecification{
+			StreamEnabled:  aws.Bool(true),
+			StreamViewType: types.StreamViewType(tbl.Stream),
+		}
+	}
+
+	return input
+}
+
+func sdkKeySchema(hashKey, rangeKey string) []types.KeySchemaElement {
+	keys := []types.KeySchemaElement{}
+	for _, key := range keySchema(hashKey, rangeKey) {
+		keys = append(keys, types.KeySchemaElement{
+			AttributeName: aws.String(key.AttributeName),
+			KeyType:       types.KeyType(key.KeyType),
+		})
+	}
+
+	return keys
+}
+
+// Resource returns the template.yaml resource of tbl, indented as a resource of Resources.
+func Resource(tbl object.Table) string {
+	var b strings.Builder
+	line := func(indent int, format string, args ...any) {
+		b.WriteString(strings.Repeat("  ", indent))
+		fmt.Fprintf(&b, format, args...)
+		b.WriteByte('\n')
+	}
+	keys := func(indent int, hashKey, rangeKey string) {
+		for _, key := range keySchema(hashKey, rangeKey) {
+			line(indent, "- AttributeName: %v", key.AttributeName)
+			line(indent+1, "KeyType: %v", key.KeyType)
+		}
+	}
+
+	line(1, "%v:", tbl.Name)
+	line(2, "Type: %v", tableType)
+	line(2, "Properties:")
+	line(3, "AttributeDefinitions:")
+	for _, attr := range tbl.Attributes() {
+		line(4, "- AttributeName: %v", attr)
+		line(5, "AttributeType: %v", types.ScalarAttributeTypeS)
+	}
+	line(3, "BillingMode: %v", types.BillingModePayPerRequest)
+	if len(tbl.Indexes) > 0 {
+		line(3, "GlobalSecondaryIndexes:")
+		for _, idx := range tbl.Indexes {
+			line(4, "- IndexName: %v", idx.Name)
+			line(5, "KeySchema:")
+			keys(6, idx.HashKey, idx.RangeKey)
+			line(5, "Projection:")
+			line(6, "ProjectionType: %v", types.ProjectionTypeAll)
+		}
+	}
+	line(3, "KeySchema:")
+	keys(4, tbl.HashKey, "")
+	if tbl.Stream != "" {
+		line(3, "StreamSpecification:")
+		line(4, "StreamViewType: %v", tbl.Stream)
+	}
+	if tbl.TTL != "" {
+		line(3, "TimeToLiveSpecification:")
+		line(4, "AttributeName: %v", tbl.TTL)
+		line(4, "Enabled: true")
+	}
+
+	return b.String()
+}
+
+// resource is a top level resource of template.yaml, as lines [start, end).
+type resource struct {
+	end   int
+	name  string
+	start int
+	table bool
+}
+
+// resources returns the resources of the template split into lines. A resource ends at the next
+// line that is indented less than its properties.
+func resources(lines []string) []resource {
+	var res []resource
+	inResources := false
+
+	for i, l := range lines {
+		if strings.TrimSpace(l) == "" || strings.HasPrefix(l, "   ") {
+			continue
+		}
+		if n := len(res); n > 0 && res[n-1].end == 0 {
+			res[n-1].end = i
+		}
+		if !strings.HasPrefix(l, " ") {
+			inResources = l == "Resources:"
+		} else if inResources {
+			res = append(res, resource{name: strings.TrimSuffix(strings.TrimSpace(l), ":"), start: i})
+		}
+	}
+	if n := len(res); n > 0 && res[n-1].end == 0 {
+		res[n-1].end = len(lines)
+	}
+
+	for i, r := range res {
+		// blank lines after a resource are not part of it
+		for res[i].end > r.start+1 && strings.TrimSpace(lines[res[i].end-1]) == "" {
+			res[i].end--
+		}
+		res[i].table = slices.Contains(lines[r.start:res[i].end], "    Type: "+tableType)
+	}
+
+	return res
+}
+
+// UpdateTemplate returns template with the table resources replaced by the resources of tables.
+// Tables that are not in template yet are added after the table before them in tables, or after
+// the last resource if there is none, and everything else is left as it is. A table resource of template that is not in tables is an error, because
+// removing it from the stack deletes the table and its data.
+func UpdateTemplate(template []byte, tables []object.Table) ([]byte, error) {
+	lines := strings.Split(string(template), "\n")
+	res := resources(lines)
+
+	names := map[string]bool{}
+	for _, tbl := range tables {
+		names[tbl.Name] = true
+	}
+	for _, r := range res {
+		if r.table && !names[r.name] {
+			return nil, fmt.Errorf("UpdateTemplate table %v is not in tables: %w", r.name, ErrTemplate)
+		}
+	}
+	if len(res) == 0 {
+		return nil, fmt.Errorf("UpdateTemplate found no resources: %w", ErrTemplate)
+	}
+
+	// replacements are the new lines of each resource by start line, and insertions are new
+	// resources by the line they go before
+	replacements := map[int][]string{}
+	insertions := map[int][]string{}
+	after := res[len(res)-1].end
+	for _, tbl := range tables {
+		rendered := strings.Split(strings.TrimSuffix(Resource(tbl), "\n"), "\n")
+		idx := slices.IndexFunc(res, func(r resource) bool { return r.name == tbl.Name })
+		if idx < 0 {
+			insertions[after] = append(insertions[after], rendered...)
+			continue
+		}
+		if !res[idx].table {
+			return nil, fmt.Errorf("UpdateTemplate resource %v is not a table: %w", tbl.Name, ErrTemplate)
+		}
+		replacements[res[idx].start] = rendered
+		after = res[idx].end
+	}
+
+	var out []string
+	for i := 0; i <= len(lines); i++ {
+		out = append(out, insertions[i]...)
+		if i == len(lines) {
+			break
+		}
+		if rendered, ok := replacements[i]; ok {
+			out = append(out, rendered...)
+			r := res[slices.IndexFunc(res, func(r resource) bool { return r.start == i })]
+			i = r.end - 1
+			continue
+		}
+		out = append(out, lines[i])
+	}
+
+	return []byte(strings.Join(out, "\n")), nil
+}
+
+// Generate returns the contents of every generated file of the repository at dir by path: the
+// localdev files and template.yaml.
+func Generate(dir string, tables []object.Table) (map[string][]byte, error) {
+	files := map[string][]byte{}
+
+	localDev, err := LocalDevFiles(tables)
+	if err != nil {
+		return nil, fmt.Errorf("Generate: %w", err)
+	}
+	for name, data := range localDev {
+		files[filepath.Join(dir, LocalDevDir, name)] = data
+	}
+
+	path := filepath.Join(dir, TemplateFile)
+	template, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Generate: %w", err)
+	}
+	if files[path], err = UpdateTemplate(template, tables); err != nil {
+		return nil, fmt.Errorf("Generate: %w", err)
+	}
+
+	return files, nil
+}
+
+// StaleFiles returns the localdev table files in the repository at dir that are not in files, for
+// example the files of a table that was renamed.
+func StaleFiles(dir string, files map[string][]byte) ([]string, error) {
+	var stale []string
+
+	for _, suffix := range []string{TableFileSuffix, TTLFileSuffix} {
+		paths, err := filepath.Glob(filepath.Join(dir, LocalDevDir, "*"+suffix))
+		if err != nil {
+			return nil, fmt.Errorf("StaleFiles: %w", err)
+		}
+		for _, path := range paths {
+			if _, ok := files[path]; !ok {
+				stale = append(stale, path)
+			}
+		}
+	}
+	slices.Sort(stale)
+
+	return stale, nil
+}
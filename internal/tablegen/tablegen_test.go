@@ -0,0 +1,148 @@
+package tablegen_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mshort55/prayertexter/internal/object"
+	"github.com/mshort55/prayertexter/internal/tablegen"
+)
+
+// TestGeneratedFiles fails when the table files of the repository drift from object.Tables.
+func TestGeneratedFiles(t *testing.T) {
+	dir := filepath.Join("..", "..")
+
+	files, err := tablegen.Generate(dir, object.Tables())
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	for path, data := range files {
+		current, err := os.ReadFile(path)
+		if err != nil {
+			t.Errorf("unexpected error %v", err)
+		} else if !bytes.Equal(current, data) {
+			t.Errorf("%v is out of date, run go run ./cmd/tablegen", path)
+		}
+	}
+
+	stale, err := tablegen.StaleFiles(dir, files)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(stale) > 0 {
+		t.Errorf("expected no stale table files, got %v", stale)
+	}
+}
+
+func TestUpdateTemplate(t *testing.T) {
+	template := `Transform: AWS::Serverless-2016-10-31
+Resources:
+  Api:
+    Type: AWS::Serverless::Api
+    Properties:
+      StageName: Prod
+  Members:
+    Type: AWS::DynamoDB::Table
+    Properties:
+      AttributeDefinitions:
+        - AttributeName: Name
+          AttributeType: S
+  Function:
+    Type: AWS::Serverless::Function
+Outputs:
+  Api:
+    Value: Api
+`
+	tables := []object.Table{
+		{
+			Name:    "Members",
+			HashKey: "Phone",
+			Indexes: []object.Index{{Name: "ByTenant", HashKey: "TenantPhone", RangeKey: "Phone"}},
+		},
+		{Name: "Queue", HashKey: "ID", Stream: object.StreamNewAndOldImages, TTL: object.ExpirationAttribute},
+	}
+	expected := `Transform: AWS::Serverless-2016-10-31
+Resources:
+  Api:
+    Type: AWS::Serverless::Api
+    Properties:
+      StageName: Prod
+  Members:
+    Type: AWS::DynamoDB::Table
+    Properties:
+      AttributeDefinitions:
+        - AttributeName: Phone
+          AttributeType: S
+        - AttributeName: TenantPhone
+          AttributeType: S
+      BillingMode: PAY_PER_REQUEST
+      GlobalSecondaryIndexes:
+        - IndexName: ByTenant
+          KeySchema:
+            - AttributeName: TenantPhone
+              KeyType: HASH
+            - AttributeName: Phone
+              KeyType: RANGE
+          Projection:
+            ProjectionType: ALL
+      KeySchema:
+        - AttributeName: Phone
+          KeyType: HASH
+  Queue:
+    Type: AWS::DynamoDB::Table
+    Properties:
+      AttributeDefinitions:
+        - AttributeName: ID
+          AttributeType: S
+      BillingMode: PAY_PER_REQUEST
+      KeySchema:
+        - AttributeName: ID
+          KeyType: HASH
+      StreamSpecification:
+        StreamViewType: NEW_AND_OLD_IMAGES
+      TimeToLiveSpecification:
+        AttributeName: ExpirationTime
+        Enabled: true
+  Function:
+    Type: AWS::Serverless::Function
+Outputs:
+  Api:
+    Value: Api
+`
+
+	updated, err := tablegen.UpdateTemplate([]byte(template), tables)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if string(updated) != expected {
+		t.Errorf("expected template\n%v\ngot\n%v", expected, string(updated))
+	}
+
+	// the table must not be dropped from the stack by a generator run
+	if _, err := tablegen.UpdateTemplate([]byte(template), tables[1:]); !errors.Is(err, tablegen.ErrTemplate) {
+		t.Errorf("expected ErrTemplate for table that is not in tables, got %v", err)
+	}
+}
+
+func TestLocalDevFiles(t *testing.T) {
+	files, err := tablegen.LocalDevFiles([]object.Table{
+		{Name: "PrayersQueue", HashKey: "IntercessorPhone"},
+		{Name: "General", HashKey: "Key", TTL: object.ExpirationAttribute},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	for _, name := range []string{"prayers-queue-table.json", "general-table.json", "general-ttl.json"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("expected file %v, got %v files", name, len(files))
+		}
+	}
+	if len(files) != 3 {
+		t.Errorf("expected 3 files, got %v", len(files))
+	}
+}
@@ -4,7 +4,10 @@ cd localdev || exit
 sudo docker compose down
 sudo docker compose up -d
 sleep 15
-aws dynamodb create-table --cli-input-json file://active-prayers-table.json --endpoint-url http://localhost:8000
-aws dynamodb create-table --cli-input-json file://general-table.json --endpoint-url http://localhost:8000
-aws dynamodb create-table --cli-input-json file://members-table.json --endpoint-url http://localhost:8000
-aws dynamodb create-table --cli-input-json file://prayers-queue-table.json --endpoint-url http://localhost:8000
\ No newline at end of file
+# the table files are generated from object.Tables with go run ./cmd/tablegen
+for table in *-table.json; do
+    aws dynamodb create-table --cli-input-json "file://$table" --endpoint-url http://localhost:8000
+done
+for ttl in *-ttl.json; do
+    aws dynamodb update-time-to-live --cli-input-json "file://$ttl" --endpoint-url http://localhost:8000
+done